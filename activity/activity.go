@@ -0,0 +1,280 @@
+// Package activity keeps the feed of what happens to each todo. Store wraps
+// a store.Storer and turns writes of todos, comments and assignees into
+// models.Activity rows, so handlers never write the feed themselves.
+package activity
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+type Store struct {
+	next  store.Storer
+	actor string
+}
+
+func NewStore(next store.Storer) *Store {
+	return &Store{next: next}
+}
+
+// As returns a store that attributes its writes to actor.
+func (s *Store) As(actor string) store.Storer {
+	return &Store{next: s.next, actor: actor}
+}
+
+func (s *Store) Find(dest any, conds ...any) error {
+	return s.next.Find(dest, conds...)
+}
+
+func (s *Store) First(dest any, conds ...any) error {
+	return s.next.First(dest, conds...)
+}
+
+func (s *Store) Each(dest any, fn func() error, conds ...any) error {
+	return store.Each(s.next, dest, fn, conds...)
+}
+
+func (s *Store) Truncate(model any) error {
+	return store.Truncate(s.next, model)
+}
+
+func (s *Store) Create(value any) error {
+	if err := s.next.Create(value); err != nil {
+		return err
+	}
+	s.record(created(value))
+	return nil
+}
+
+func (s *Store) Save(value any) error {
+	before := s.previous(value)
+	if err := s.next.Save(value); err != nil {
+		return err
+	}
+	s.record(saved(before, value))
+	return nil
+}
+
+// Update records the changes to every todo or comment it matched.
+func (s *Store) Update(model any, set map[string]any, conds ...any) error {
+	matched := s.matching(model, conds)
+	if err := store.Update(s.next, model, set, conds...); err != nil {
+		return err
+	}
+	for _, before := range matched {
+		// The stored version is the updated one by now.
+		if after := s.previous(before); after != nil {
+			s.record(saved(before, after))
+		}
+	}
+	return nil
+}
+
+func (s *Store) Delete(value any, conds ...any) error {
+	matched := s.matching(value, conds)
+	if err := store.Delete(s.next, value, conds...); err != nil {
+		return err
+	}
+	s.record(deleted(matched))
+	return nil
+}
+
+// BulkWrite records the ops that succeeded. An atomic write that was rolled
+// back records nothing.
+func (s *Store) BulkWrite(ops []store.BulkOp, opts store.BulkOptions) ([]error, error) {
+	before := make([]any, len(ops))
+	for i, op := range ops {
+		switch op.Kind {
+		case store.BulkUpdate:
+			before[i] = s.previous(op.Value)
+		case store.BulkDelete:
+			before[i] = s.matching(op.Value, nil)
+		}
+	}
+
+	errs, err := store.BulkWrite(s.next, ops, opts)
+	if err != nil {
+		return errs, err
+	}
+	for i, op := range ops {
+		if errs[i] != nil {
+			continue
+		}
+		switch op.Kind {
+		case store.BulkCreate:
+			s.record(created(op.Value))
+		case store.BulkUpdate:
+			s.record(saved(before[i], op.Value))
+		case store.BulkDelete:
+			s.record(deleted(before[i].([]any)))
+		}
+	}
+	return errs, nil
+}
+
+// record writes the feed entries of one write. The write itself has already
+// succeeded, so a failure here is logged rather than returned.
+func (s *Store) record(entries []models.Activity) {
+	for i := range entries {
+		entries[i].Actor = s.actor
+		if err := s.next.Create(&entries[i]); err != nil {
+			log.Printf("activity: recording %s of todo %d: %v", entries[i].Action, entries[i].TodoID, err)
+		}
+	}
+}
+
+// previous loads the stored version of a todo or comment about to be saved.
+func (s *Store) previous(value any) any {
+	switch v := value.(type) {
+	case *models.Todo:
+		var old models.Todo
+		if v.ID != 0 && s.next.First(&old, v.ID) == nil {
+			return &old
+		}
+	case *models.Comment:
+		var old models.Comment
+		if v.ID != 0 && s.next.First(&old, v.ID) == nil {
+			return &old
+		}
+	}
+	return nil
+}
+
+// matching loads the todos, comments or assignees a delete is about to
+// remove: those matching conds, or value itself without conds.
+func (s *Store) matching(value any, conds []any) []any {
+	var err error
+	var matched []any
+	switch v := value.(type) {
+	case *models.Todo:
+		if len(conds) == 0 {
+			conds = []any{"id = ?", v.ID}
+		}
+		var rows []models.Todo
+		err = s.next.Find(&rows, conds...)
+		for i := range rows {
+			matched = append(matched, &rows[i])
+		}
+	case *models.Comment:
+		if len(conds) == 0 {
+			conds = []any{"id = ?", v.ID}
+		}
+		var rows []models.Comment
+		err = s.next.Find(&rows, conds...)
+		for i := range rows {
+			matched = append(matched, &rows[i])
+		}
+	case *models.TodoAssignee:
+		if len(conds) == 0 {
+			conds = []any{"todo_id = ? AND assignee = ?", v.TodoID, v.Assignee}
+		}
+		var rows []models.TodoAssignee
+		err = s.next.Find(&rows, conds...)
+		for i := range rows {
+			matched = append(matched, &rows[i])
+		}
+	}
+	if err != nil && !store.IsNotFound(err) {
+		log.Printf("activity: finding the records to delete: %v", err)
+	}
+	return matched
+}
+
+func created(value any) []models.Activity {
+	switch v := value.(type) {
+	case *models.Todo:
+		return []models.Activity{{TodoID: v.ID, Action: models.ActionCreated}}
+	case *models.Comment:
+		return []models.Activity{{TodoID: v.TodoID, Action: models.ActionCommented, CommentID: &v.ID}}
+	case *models.TodoAssignee:
+		return []models.Activity{{TodoID: v.TodoID, Action: models.ActionAssigned, Detail: v.Assignee}}
+	}
+	return nil
+}
+
+func saved(before, value any) []models.Activity {
+	if before == nil {
+		return created(value)
+	}
+	switch v := value.(type) {
+	case *models.Todo:
+		return todoChanges(before.(*models.Todo), v)
+	case *models.Comment:
+		if before.(*models.Comment).Body != v.Body {
+			return []models.Activity{{TodoID: v.TodoID, Action: models.ActionCommentEdited, CommentID: &v.ID}}
+		}
+	}
+	return nil
+}
+
+func deleted(matched []any) []models.Activity {
+	var entries []models.Activity
+	for _, value := range matched {
+		switch v := value.(type) {
+		case *models.Todo:
+			entries = append(entries, models.Activity{TodoID: v.ID, Action: models.ActionDeleted})
+		case *models.Comment:
+			entries = append(entries, models.Activity{TodoID: v.TodoID, Action: models.ActionCommentDeleted, CommentID: &v.ID})
+		case *models.TodoAssignee:
+			entries = append(entries, models.Activity{TodoID: v.TodoID, Action: models.ActionUnassigned, Detail: v.Assignee})
+		}
+	}
+	return entries
+}
+
+// editableFields are the todo fields whose changes count as edits, by their
+// JSON names. Completed and Position have actions of their own.
+var editableFields = []struct {
+	name string
+	get  func(*models.Todo) any
+}{
+	{"title", func(t *models.Todo) any { return t.Title }},
+	{"owner", func(t *models.Todo) any { return t.Owner }},
+	{"due_at", func(t *models.Todo) any { return timeValue(t.DueAt) }},
+	{"reminder_minutes", func(t *models.Todo) any { return t.ReminderMinutes }},
+	{"rrule", func(t *models.Todo) any { return t.RRule }},
+	{"list_id", func(t *models.Todo) any { return idValue(t.ListID) }},
+	{"parent_id", func(t *models.Todo) any { return idValue(t.ParentID) }},
+	{"priority", func(t *models.Todo) any { return t.Priority }},
+}
+
+func todoChanges(before, after *models.Todo) []models.Activity {
+	var entries []models.Activity
+	var changed []string
+	for _, field := range editableFields {
+		if field.get(before) != field.get(after) {
+			changed = append(changed, field.name)
+		}
+	}
+	if len(changed) > 0 {
+		entries = append(entries, models.Activity{TodoID: after.ID, Action: models.ActionEdited, Detail: strings.Join(changed, ", ")})
+	}
+	if before.Position != after.Position {
+		entries = append(entries, models.Activity{TodoID: after.ID, Action: models.ActionMoved})
+	}
+	switch {
+	case !before.Completed && after.Completed:
+		entries = append(entries, models.Activity{TodoID: after.ID, Action: models.ActionCompleted})
+	case before.Completed && !after.Completed:
+		entries = append(entries, models.Activity{TodoID: after.ID, Action: models.ActionReopened})
+	}
+	return entries
+}
+
+func timeValue(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UnixNano()
+}
+
+func idValue(id *uint) any {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
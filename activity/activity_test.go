@@ -0,0 +1,116 @@
+package activity_test
+
+import (
+	"testing"
+
+	"github.com/sing3demons/go-example/activity"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func feed(t *testing.T, db store.Storer) []models.Activity {
+	var all []models.Activity
+	require.NoError(t, db.Find(&all))
+	return all
+}
+
+func TestStoreRecordsWrites(t *testing.T) {
+	inner := store.NewMemoryStore()
+	s := activity.NewStore(inner)
+	alice := store.As(s, "alice")
+
+	todo := models.Todo{Title: "Draft"}
+	require.NoError(t, alice.Create(&todo))
+
+	todo.Title = "Draft the report"
+	todo.Priority = models.PriorityHigh
+	require.NoError(t, store.As(s, "bob").Save(&todo))
+
+	todo.Completed = true
+	todo.Position = "V"
+	require.NoError(t, alice.Save(&todo))
+
+	require.NoError(t, alice.Save(&todo), "a save that changes nothing is not recorded")
+
+	comment := models.Comment{TodoID: todo.ID, Author: "alice", Body: "*soon*"}
+	require.NoError(t, alice.Create(&comment))
+	require.NoError(t, alice.Create(&models.TodoAssignee{TodoID: todo.ID, Assignee: "bob"}))
+	require.NoError(t, store.Delete(alice, &models.TodoAssignee{}, "todo_id = ?", todo.ID))
+	require.NoError(t, store.Delete(alice, &comment))
+
+	var got []string
+	for _, a := range feed(t, inner) {
+		assert.Equal(t, todo.ID, a.TodoID)
+		got = append(got, a.Actor+" "+a.Action+" "+a.Detail)
+	}
+	assert.Equal(t, []string{
+		"alice created ",
+		"bob edited title, priority",
+		"alice moved ",
+		"alice completed ",
+		"alice commented ",
+		"alice assigned bob",
+		"alice unassigned bob",
+		"alice comment_deleted ",
+	}, got)
+}
+
+func TestStoreRecordsBulkWrites(t *testing.T) {
+	inner := store.NewMemoryStore()
+	s := store.As(activity.NewStore(inner), "alice")
+
+	first := models.Todo{Title: "One"}
+	require.NoError(t, s.Create(&first))
+
+	updated := first
+	updated.Completed = true
+	errs, err := store.BulkWrite(s, []store.BulkOp{
+		{Kind: store.BulkCreate, Value: &models.Todo{Title: "Two"}},
+		{Kind: store.BulkUpdate, Value: &updated},
+		{Kind: store.BulkDelete, Value: &models.Todo{Model: gorm.Model{ID: first.ID}}},
+		{Kind: store.BulkUpdate, Value: &models.Todo{Model: gorm.Model{ID: 99}, Title: "Missing"}},
+	}, store.BulkOptions{})
+	require.NoError(t, err)
+	assert.Error(t, errs[3])
+
+	var got []string
+	for _, a := range feed(t, inner)[1:] {
+		got = append(got, a.Action)
+	}
+	assert.Equal(t, []string{models.ActionCreated, models.ActionCompleted, models.ActionDeleted}, got)
+}
+
+func TestStoreRecordsDeletesByCondition(t *testing.T) {
+	inner := store.NewMemoryStore()
+	s := activity.NewStore(inner)
+	list := uint(1)
+	for _, title := range []string{"a", "b"} {
+		require.NoError(t, inner.Create(&models.Todo{Title: title, ListID: &list}))
+	}
+	require.NoError(t, inner.Create(&models.Todo{Title: "c"}))
+
+	require.NoError(t, store.Delete(s, &models.Todo{}, "list_id = ?", list))
+	entries := feed(t, inner)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint(1), entries[0].TodoID)
+	assert.Equal(t, uint(2), entries[1].TodoID)
+	assert.Equal(t, models.ActionDeleted, entries[1].Action)
+}
+
+func TestStoreRecordsUpdates(t *testing.T) {
+	inner := store.NewMemoryStore()
+	s := activity.NewStore(inner).As("bob")
+	require.NoError(t, inner.Create(&models.Todo{Title: "a"}))
+
+	require.NoError(t, store.Update(s, &models.Todo{}, map[string]any{"completed": true}, "id = ? AND completed = ?", 1, false))
+	require.NoError(t, store.Update(s, &models.Todo{}, map[string]any{"next_materialized": true}, "id = ?", 1))
+	assert.True(t, store.IsNotFound(store.Update(s, &models.Todo{}, map[string]any{"completed": true}, "id = ? AND completed = ?", 1, false)))
+
+	entries := feed(t, inner)
+	require.Len(t, entries, 1, "only the completion shows in the feed")
+	assert.Equal(t, models.ActionCompleted, entries[0].Action)
+	assert.Equal(t, "bob", entries[0].Actor)
+}
@@ -0,0 +1,23 @@
+// Package blob stores files, such as product images, under slash-separated
+// keys like products/<id>/<name>.jpg.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob: not found")
+
+type Store interface {
+	// Put stores the contents of r under key, replacing any blob there.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the blob under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the blob under key.
+	URL(key string) string
+}
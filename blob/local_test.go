@@ -0,0 +1,46 @@
+package blob_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sing3demons/go-example/blob"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	l, err := blob.NewLocal(dir, "/images/")
+	require.NoError(t, err)
+
+	require.NoError(t, l.Put(ctx, "products/1/a.jpg", strings.NewReader("jpeg")))
+	assert.Equal(t, "/images/products/1/a.jpg", l.URL("products/1/a.jpg"))
+
+	r, err := l.Open(ctx, "products/1/a.jpg")
+	require.NoError(t, err)
+	b, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "jpeg", string(b))
+
+	_, err = l.Open(ctx, "products/1/b.jpg")
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+	_, err = l.Open(ctx, "products")
+	assert.ErrorIs(t, err, blob.ErrNotFound, "directories are not blobs")
+
+	for _, key := range []string{"", "/etc/passwd", "../x", "products/../../x", "products//a"} {
+		assert.Error(t, l.Put(ctx, key, strings.NewReader("x")), key)
+	}
+
+	require.NoError(t, l.Delete(ctx, "products/1/a.jpg"))
+	require.NoError(t, l.Delete(ctx, "products/1/a.jpg"), "deleting twice is fine")
+	_, err = os.Stat(filepath.Join(dir, "products"))
+	assert.True(t, os.IsNotExist(err), "empty directories are removed")
+	_, err = os.Stat(dir)
+	assert.NoError(t, err)
+}
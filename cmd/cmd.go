@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"serve":   {"start the HTTP server", serve},
+	"migrate": {"migrate the postgres and mongo schemas", migrate},
+	"seed":    {"load fixtures or generated data, optionally truncating first", seed},
+	"export":  {"write every todo and product to a JSON file", export},
+	"import":  {"load a file written by export", importData},
+	"routes":  {"print the HTTP route table", routes},
+	"config":  {"inspect the configuration (config check)", config},
+}
+
+// Execute runs the subcommand named by args[0]. With no arguments it starts
+// the server, so existing deployments keep working.
+func Execute(args []string) error {
+	if len(args) == 0 {
+		return serve(nil)
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(os.Stdout)
+		return nil
+	}
+
+	c, ok := commands[name]
+	if !ok {
+		printUsage(os.Stderr)
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	return c.run(args[1:])
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: go-example <command> [flags]")
+	fmt.Fprintln(w)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].usage)
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
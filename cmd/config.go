@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/sing3demons/go-example/db"
+)
+
+func config(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("usage: config check [-offline]")
+	}
+
+	fs := newFlagSet("config check")
+	offline := fs.Bool("offline", false, "skip connecting to the databases")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	return checkConfig(os.Stdout, !*offline)
+}
+
+// checkConfig validates the environment and, when connect is set, that both
+// databases are reachable. Every problem is reported before returning.
+func checkConfig(w io.Writer, connect bool) error {
+	failed := 0
+	report := func(name string, err error) {
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(w, "ok   %s\n", name)
+	}
+
+	report("PORT", checkPort(os.Getenv("PORT")))
+	if os.Getenv("STORE") == "memory" {
+		fmt.Fprintln(w, "ok   STORE: memory, databases not required")
+		connect = false
+	} else {
+		report("DATABASE_URL", checkURL(os.Getenv("DATABASE_URL"), "postgres", "postgresql", "sqlite", "file"))
+		report("MONGO_URL", checkURL(os.Getenv("MONGO_URL"), "mongodb", "mongodb+srv"))
+	}
+
+	if connect && failed == 0 {
+		_, err := db.ConnectDB()
+		report("postgres connection", err)
+
+		_, err = db.OpenMongo()
+		report("mongo connection", err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d configuration check(s) failed", failed)
+	}
+	return nil
+}
+
+func checkPort(port string) error {
+	if port == "" {
+		return fmt.Errorf("is empty")
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%q is not a valid port", port)
+	}
+	return nil
+}
+
+func checkURL(raw string, schemes ...string) error {
+	if raw == "" {
+		return fmt.Errorf("is empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("scheme %q is not one of %v", u.Scheme, schemes)
+}
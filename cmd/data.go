@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sing3demons/go-example/blob"
+	"github.com/sing3demons/go-example/db"
+	"github.com/sing3demons/go-example/fixtures"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/promotion"
+	"github.com/sing3demons/go-example/search"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm"
+)
+
+// stores are the stores and search backends the server runs on.
+type stores struct {
+	todos      store.Storer
+	products   store.Storer
+	categories store.Storer
+	inventory  store.Storer
+	movements  store.Storer
+	// prices keeps the price history and schedules the scheduled prices.
+	prices     store.Storer
+	schedules  store.Storer
+	carts      store.Storer
+	orders     store.Storer
+	promotions store.Storer
+	backends   []search.Backend
+	// sql is the database todos are kept in, nil for the memory store.
+	sql *gorm.DB
+	// blobs keeps product images and promotionPolicy decides between the
+	// promotions of a product; only serve sets them.
+	blobs           blob.Store
+	promotionPolicy promotion.Policy
+}
+
+// connectStores opens the stores selected by the STORE environment variable:
+// "memory" keeps everything in process, anything else uses postgres and mongo.
+func connectStores() (todos, products store.Storer, err error) {
+	s, err := connectAll(os.Getenv("STORE"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.todos, s.products, nil
+}
+
+// connectAll opens the stores of backend, "memory" or else postgres and
+// mongo, together with the category and stock stores and the search
+// backends over the same databases: the databases' own full-text indexes
+// where there are any, and a scan of the store elsewhere.
+func connectAll(backend string) (*stores, error) {
+	if backend == "memory" {
+		s := &stores{
+			todos:      store.NewMemoryStore(),
+			products:   store.NewMemoryStore(),
+			categories: store.NewMemoryStore(),
+			inventory:  store.NewMemoryStore(),
+		}
+		s.movements = s.inventory
+		s.prices = store.NewMemoryStore()
+		s.schedules = s.prices
+		s.carts = store.NewMemoryStore()
+		s.orders = s.carts
+		s.promotions = store.NewMemoryStore()
+		s.backends = []search.Backend{search.ScanProducts(s.products), search.ScanTodos(s.todos)}
+		return s, nil
+	}
+
+	gorm, err := db.ConnectDB()
+	if err != nil {
+		return nil, err
+	}
+
+	mongo, err := db.ConnectMonoDB()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &stores{
+		todos:      store.NewGormStore(gorm),
+		products:   store.NewMongoStore(mongo),
+		categories: store.NewMongoStore(mongo.Database().Collection(db.CategoriesCollection)),
+		inventory:  store.NewMongoStore(mongo.Database().Collection(db.InventoryCollection)),
+		movements:  store.NewMongoStore(mongo.Database().Collection(db.StockMovementsCollection)),
+		prices:     store.NewMongoStore(mongo.Database().Collection(db.PriceHistoryCollection)),
+		schedules:  store.NewMongoStore(mongo.Database().Collection(db.ScheduledPricesCollection)),
+		carts:      store.NewMongoStore(mongo.Database().Collection(db.CartsCollection)),
+		orders:     store.NewMongoStore(mongo.Database().Collection(db.OrdersCollection)),
+		promotions: store.NewMongoStore(mongo.Database().Collection(db.PromotionsCollection)),
+		backends:   []search.Backend{search.NewMongoProducts(mongo), search.NewPostgresTodos(gorm)},
+		sql:        gorm,
+	}
+	if gorm.Dialector.Name() != "postgres" {
+		s.backends[1] = search.ScanTodos(s.todos)
+	}
+	return s, nil
+}
+
+func seed(args []string) error {
+	fs := newFlagSet("seed")
+	file := fs.String("file", "", "fixture file (.json, .yaml or .yml)")
+	truncate := fs.Bool("truncate", false, "delete existing todos and products first")
+	fakeTodos := fs.Int("fake-todos", 0, "number of generated todos to add")
+	fakeProducts := fs.Int("fake-products", 0, "number of generated products to add")
+	fakeSeed := fs.Int64("fake-seed", 1, "seed for generated data")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	set := &fixtures.Set{}
+	if *file != "" {
+		loaded, err := fixtures.Load(*file)
+		if err != nil {
+			return err
+		}
+		set = loaded
+	}
+	if *fakeTodos > 0 || *fakeProducts > 0 {
+		set.Merge(fixtures.Fake(*fakeSeed, *fakeTodos, *fakeProducts))
+	}
+	if len(set.Todos) == 0 && len(set.Products) == 0 && !*truncate {
+		return fmt.Errorf("nothing to seed: pass -file, -fake-todos, -fake-products or -truncate")
+	}
+
+	todos, products, err := connectStores()
+	if err != nil {
+		return err
+	}
+
+	loader := fixtures.Loader{Todos: todos, Products: products}
+	result, err := loader.Load(set, fixtures.Options{Truncate: *truncate})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created %d todos and %d products\n", result.Todos, result.Products)
+	return nil
+}
+
+// importData loads a file written by export, in the fixture format seed
+// reads.
+func importData(args []string) error {
+	fs := newFlagSet("import")
+	file := fs.String("file", "export.json", "file written by export")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	set, err := fixtures.Load(*file)
+	if err != nil {
+		return err
+	}
+
+	todos, products, err := connectStores()
+	if err != nil {
+		return err
+	}
+
+	loader := fixtures.Loader{Todos: todos, Products: products}
+	result, err := loader.Load(set, fixtures.Options{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created %d todos and %d products\n", result.Todos, result.Products)
+	return nil
+}
+
+// export writes the todos and products as a fixture set.
+func export(args []string) error {
+	fs := newFlagSet("export")
+	out := fs.String("o", "export.json", "output file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	todos, products, err := connectStores()
+	if err != nil {
+		return err
+	}
+
+	var storedTodos []models.Todo
+	if err := todos.Find(&storedTodos); err != nil {
+		return err
+	}
+	var storedProducts []models.Product
+	if err := products.Find(&storedProducts, bson.D{}); err != nil {
+		return err
+	}
+	set := fixtures.FromModels(storedTodos, storedProducts)
+
+	b, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		return err
+	}
+
+	fmt.Printf("exported %d todos and %d products to %s\n", len(set.Todos), len(set.Products), *out)
+	return nil
+}
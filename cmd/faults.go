@@ -0,0 +1,23 @@
+//go:build !production
+
+package cmd
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/router"
+	"github.com/sing3demons/go-example/store"
+)
+
+// withFaultInjection wraps the stores in FaultStores configurable through
+// /admin/faults. Production builds (-tags production) leave them untouched.
+func withFaultInjection(r *gin.Engine, todos, products store.Storer) (store.Storer, store.Storer) {
+	faultyTodos := store.NewFaultStore(todos)
+	faultyProducts := store.NewFaultStore(products)
+
+	router.AdminRouter(r, map[string]*store.FaultStore{
+		"todos":    faultyTodos,
+		"products": faultyProducts,
+	})
+
+	return faultyTodos, faultyProducts
+}
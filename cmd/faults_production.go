@@ -0,0 +1,12 @@
+//go:build production
+
+package cmd
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/store"
+)
+
+func withFaultInjection(r *gin.Engine, todos, products store.Storer) (store.Storer, store.Storer) {
+	return todos, products
+}
@@ -0,0 +1,20 @@
+//go:build !production
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminRoutesInDevelopmentBuilds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var out bytes.Buffer
+	printRoutes(&out, newEngine(&stores{}, nil))
+
+	assert.Regexp(t, `PUT\s+/admin/faults/:store/:method\s+`, out.String())
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sing3demons/go-example/db"
+	"github.com/sing3demons/go-example/store"
+)
+
+func migrate(args []string) error {
+	fs := newFlagSet("migrate")
+	dryRun := fs.Bool("dry-run", false, "only report how the mongo schema differs")
+	priceCurrency := fs.String("price-currency", "USD", "currency of prices stored as bare numbers of minor units")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	col, err := db.OpenMongo()
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		for _, c := range db.MongoCollections(col) {
+			diff, err := store.DiffMongoSchema(c.Collection, c.Schema)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: %s\n", c.Collection.Name(), diff)
+		}
+
+		n, err := db.CountLegacyPrices(col)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d product prices to convert to %s\n", n, *priceCurrency)
+		return nil
+	}
+
+	if err := db.MigrateMongo(col); err != nil {
+		return err
+	}
+
+	// Prices are converted under the new validator: documents that fail it
+	// are not validated on update, and the old one rejects converted prices.
+	n, err := db.MigratePrices(col, *priceCurrency)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("converted %d product prices to %s\n", n, *priceCurrency)
+
+	// ConnectDB runs AutoMigrate for the gorm models.
+	if _, err := db.ConnectDB(); err != nil {
+		return err
+	}
+	fmt.Println("postgres migrated")
+
+	return nil
+}
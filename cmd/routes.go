@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin"
+)
+
+func routes(args []string) error {
+	if err := newFlagSet("routes").Parse(args); err != nil {
+		return err
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	printRoutes(os.Stdout, newEngine(&stores{}, nil))
+	return nil
+}
+
+func printRoutes(w io.Writer, r *gin.Engine) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tHANDLER")
+	for _, route := range r.Routes() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", route.Method, route.Path, route.Handler)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/blob"
+	"github.com/sing3demons/go-example/fixtures"
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/pricing"
+	"github.com/sing3demons/go-example/promotion"
+	"github.com/sing3demons/go-example/router"
+	"github.com/sing3demons/go-example/scheduler"
+	"github.com/sing3demons/go-example/shop"
+	"github.com/sing3demons/go-example/store"
+)
+
+func serve(args []string) error {
+	fs := newFlagSet("serve")
+	port := fs.String("port", os.Getenv("PORT"), "port to listen on")
+	backend := fs.String("store", os.Getenv("STORE"), `"memory" to run without postgres and mongo`)
+	fixtureFile := fs.String("fixtures", "", "fixture file to load at startup")
+	interval := fs.Duration("scheduler-interval", time.Minute, "how often background jobs run; 0 disables them")
+	imageDir := fs.String("image-dir", os.Getenv("IMAGE_DIR"), `directory product images are kept in, "uploads" by default`)
+	promotionPolicy := fs.String("promotion-policy", os.Getenv("PROMOTION_POLICY"), `how promotions of a product combine: "priority" (default) or "best_price"`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	stores, err := connectAll(*backend)
+	if err != nil {
+		return err
+	}
+	if *imageDir == "" {
+		*imageDir = "uploads"
+	}
+	if stores.blobs, err = blob.NewLocal(*imageDir, "/images"); err != nil {
+		return err
+	}
+	if stores.promotionPolicy, err = promotion.ParsePolicy(*promotionPolicy); err != nil {
+		return err
+	}
+
+	if *fixtureFile != "" {
+		set, err := fixtures.Load(*fixtureFile)
+		if err != nil {
+			return err
+		}
+		loader := fixtures.Loader{Todos: stores.todos, Products: stores.products}
+		if _, err := loader.Load(set, fixtures.Options{}); err != nil {
+			return err
+		}
+	}
+
+	var jobs *scheduler.Scheduler
+	if *interval > 0 {
+		leader, err := newLeader(stores)
+		if err != nil {
+			return err
+		}
+		opts := scheduler.DefaultOptions()
+		opts.Interval = *interval
+		opts.Leader = leader
+		jobs = scheduler.New(opts)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: ":" + *port, Handler: newEngine(stores, jobs)}
+	served := make(chan error, 1)
+	go func() { served <- srv.ListenAndServe() }()
+
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		if jobs != nil {
+			jobs.Run(jobsCtx)
+		}
+	}()
+
+	var serveErr error
+	select {
+	case serveErr = <-served:
+	case <-ctx.Done():
+	}
+	// The scheduler stops before the server, giving up leadership to
+	// another replica; then requests in flight get shutdownTimeout to
+	// finish.
+	stopJobs()
+	<-jobsDone
+	if serveErr != nil {
+		return serveErr
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// shutdownTimeout is how long requests in flight have to finish once the
+// server is told to stop.
+const shutdownTimeout = 10 * time.Second
+
+// schedulerLockKey is the postgres advisory lock that elects the replica
+// running background jobs.
+const schedulerLockKey = 0x676f6578 // "goex"
+
+// newLeader elects the scheduler leader through a postgres advisory lock,
+// taken on the stores' own database. The memory store and SQLite are
+// single-process, so they always lead.
+func newLeader(s *stores) (scheduler.Leader, error) {
+	if s.sql == nil || s.sql.Dialector.Name() != "postgres" {
+		return scheduler.LocalLeader{}, nil
+	}
+
+	sqlDB, err := s.sql.DB()
+	if err != nil {
+		return nil, err
+	}
+	return scheduler.NewAdvisoryLockLeader(sqlDB, schedulerLockKey), nil
+}
+
+// newEngine sets up the routes on the stores, adding the jobs they need to
+// jobs when it is not nil.
+func newEngine(s *stores, jobs *scheduler.Scheduler) *gin.Engine {
+	r := gin.Default()
+	todos, products := withFaultInjection(r, s.todos, s.products)
+
+	resilientTodos := store.NewResilientStore(todos, store.DefaultResilienceOptions("todos"))
+	resilientProducts := store.NewResilientStore(products, store.DefaultResilienceOptions("products"))
+
+	router.HealthRouter(r, map[string]*store.Breaker{
+		"todos":    resilientTodos.Breaker(),
+		"products": resilientProducts.Breaker(),
+	})
+	cachedProducts := store.NewCachingStore(resilientProducts, store.NewLRUCache(1000), store.DefaultCacheOptions("products"))
+
+	// Writes to products record their price changes.
+	prices := pricing.NewStore(cachedProducts, s.prices)
+	schedules := pricing.NewSchedules(prices, s.schedules)
+	if jobs != nil {
+		jobs.Add("prices", schedules.Run)
+	}
+
+	promotions := promotion.New(s.promotions, s.categories, s.promotionPolicy)
+
+	router.Router(r, resilientTodos, jobs)
+	router.ProductRouter(r, prices, s.categories, s.blobs, promotions)
+	router.PriceRouter(r, prices, schedules)
+	router.CategoryRouter(r, s.categories, prices)
+	router.PromotionRouter(r, promotions, prices, s.categories)
+	router.SearchRouter(r, s.backends...)
+
+	stock := inventory.New(s.inventory, s.movements)
+	stock.OnLowStock(inventory.LogLowStock)
+	router.InventoryRouter(r, prices, stock)
+	// Checkout reads prices past the cache, which another replica or a
+	// schedule may have changed since this replica cached them.
+	sales := shop.New(s.carts, s.orders, resilientProducts, stock, promotions)
+	if jobs != nil {
+		jobs.Add("checkouts", sales.AbandonCheckouts)
+	}
+	router.ShopRouter(r, sales)
+
+	return r
+}
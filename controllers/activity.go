@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+// Activity returns the feed of a todo, oldest first. The entries are written
+// by the activity store; see package activity.
+func (t *TodoController) Activity(c *gin.Context) {
+	todo, ok := loadTodo(c, t.db)
+	if !ok {
+		return
+	}
+
+	feed := []models.Activity{}
+	if err := t.db.Find(&feed, "todo_id = ?", todo.ID); err != nil && !store.IsNotFound(err) {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	sort.Slice(feed, func(i, j int) bool { return feed[i].ID < feed[j].ID })
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": feed,
+	})
+}
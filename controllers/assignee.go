@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+type TodoAssigneesRequest struct {
+	Assignees []string `json:"assignees" binding:"max=20,dive,required,max=100"`
+}
+
+// setTodoAssignees makes assignees the assignees of a todo. Only the users
+// that changed are added or removed, so that the feed shows who was assigned
+// or unassigned. It returns the new assignees, sorted.
+func setTodoAssignees(db store.Storer, todoID uint, assignees []string) ([]string, error) {
+	want := map[string]bool{}
+	for _, assignee := range assignees {
+		if assignee = strings.TrimSpace(assignee); assignee != "" {
+			want[assignee] = true
+		}
+	}
+
+	var current []models.TodoAssignee
+	if err := db.Find(&current, "todo_id = ?", todoID); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	have := map[string]bool{}
+	for _, row := range current {
+		have[row.Assignee] = true
+		if want[row.Assignee] {
+			continue
+		}
+		if err := store.Delete(db, &models.TodoAssignee{}, "todo_id = ? AND assignee = ?", todoID, row.Assignee); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]string, 0, len(want))
+	for assignee := range want {
+		result = append(result, assignee)
+	}
+	sort.Strings(result)
+	for _, assignee := range result {
+		if have[assignee] {
+			continue
+		}
+		if err := db.Create(&models.TodoAssignee{TodoID: todoID, Assignee: assignee}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// attachAssignees fills in the assignees of todos with one query.
+func attachAssignees(db store.Storer, todos []models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	byTodo := make(map[uint]int, len(todos))
+	ids := make([]uint, len(todos))
+	for i, todo := range todos {
+		byTodo[todo.ID] = i
+		ids[i] = todo.ID
+	}
+
+	var rows []models.TodoAssignee
+	if err := db.Find(&rows, "todo_id IN ?", ids); err != nil && !store.IsNotFound(err) {
+		return err
+	}
+	for _, row := range rows {
+		if i, ok := byTodo[row.TodoID]; ok {
+			todos[i].Assignees = append(todos[i].Assignees, row.Assignee)
+		}
+	}
+	for i := range todos {
+		sort.Strings(todos[i].Assignees)
+	}
+	return nil
+}
+
+// assignedTodos returns the IDs of the todos assigned to user.
+func assignedTodos(db store.Storer, user string) ([]uint, error) {
+	var rows []models.TodoAssignee
+	if err := db.Find(&rows, "assignee = ?", user); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.TodoID
+	}
+	return ids, nil
+}
+
+// SetAssignees replaces the users assigned to a todo.
+func (t *TodoController) SetAssignees(c *gin.Context) {
+	var req TodoAssigneesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	todo, ok := loadTodo(c, t.db)
+	if !ok {
+		return
+	}
+	assignees, err := setTodoAssignees(store.As(t.db, currentUser(c)), todo.ID, req.Assignees)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	todo.Assignees = assignees
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": todo,
+	})
+}
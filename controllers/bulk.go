@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sing3demons/go-example/store"
+)
+
+const maxBulkItems = 10000
+
+var errTooManyItems = fmt.Errorf("a bulk request takes at most %d items", maxBulkItems)
+
+// BulkItemResult is the outcome of one item of a bulk request. Status is the
+// HTTP status the item would have had on its own; 424 means the item was
+// valid but rolled back because another item of an atomic request failed.
+type BulkItemResult struct {
+	Index  int              `json:"index"`
+	Op     store.BulkOpKind `json:"op,omitempty"`
+	Status int              `json:"status"`
+	ID     any              `json:"id,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+type BulkResponse struct {
+	Mode      string           `json:"mode"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// bulkModel adapts a bulk request to one model type.
+type bulkModel struct {
+	// decode parses and validates an item and returns a pointer to the record.
+	decode func(op store.BulkOpKind, raw []byte) (any, error)
+	id     func(record any) any
+	// written, when set, is called with every record written once the
+	// request has not been rolled back.
+	written func(op store.BulkOpKind, record any)
+	// keep are the fields updates leave as stored; see store.BulkOp.
+	keep []string
+}
+
+// bulkWrite handles POST /<resource>/bulk. The body is a JSON array, or
+// NDJSON when sent as application/x-ndjson. Every item is a record with an
+// optional "op" of create (the default), update or delete. An update only
+// changes the fields the item has: decode merges it into the stored record.
+// With ?mode=atomic either every item is written or none is; the default
+// best_effort mode writes every valid item it can.
+func bulkWrite(c *gin.Context, db store.Storer, m bulkModel) {
+	mode := c.DefaultQuery("mode", "best_effort")
+	if mode != "best_effort" && mode != "atomic" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": `mode must be "best_effort" or "atomic"`,
+		})
+		return
+	}
+	atomic := mode == "atomic"
+
+	items, err := readBulkItems(c)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errTooManyItems) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "no items",
+		})
+		return
+	}
+
+	results := make([]BulkItemResult, len(items))
+	var ops []store.BulkOp
+	var index []int // the item of every op
+	for i, raw := range items {
+		results[i].Index = i
+
+		var envelope struct {
+			Op store.BulkOpKind `json:"op"`
+		}
+		if err := json.Unmarshal(raw, &envelope); err != nil {
+			results[i].fail(http.StatusBadRequest, err)
+			continue
+		}
+		if envelope.Op == "" {
+			envelope.Op = store.BulkCreate
+		}
+		results[i].Op = envelope.Op
+
+		switch envelope.Op {
+		case store.BulkCreate, store.BulkUpdate, store.BulkDelete:
+		default:
+			results[i].fail(http.StatusBadRequest, fmt.Errorf("unknown op %q", envelope.Op))
+			continue
+		}
+
+		record, err := m.decode(envelope.Op, raw)
+		if err != nil {
+			results[i].fail(http.StatusBadRequest, err)
+			continue
+		}
+		ops = append(ops, store.BulkOp{Kind: envelope.Op, Value: record, Keep: m.keep})
+		index = append(index, i)
+	}
+
+	aborted := atomic && len(ops) < len(items)
+	if !aborted && len(ops) > 0 {
+		errs, err := store.BulkWrite(db, ops, store.BulkOptions{Atomic: atomic})
+		if errs == nil {
+			c.JSON(errorStatus(err), gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		for k, i := range index {
+			switch {
+			case errs[k] == nil:
+				results[i].Status = bulkSuccessStatus(ops[k].Kind)
+				results[i].ID = m.id(ops[k].Value)
+			case store.IsNotFound(errs[k]):
+				results[i].fail(http.StatusNotFound, errs[k])
+			case store.IsDuplicateKey(errs[k]):
+				results[i].fail(http.StatusConflict, errs[k])
+			default:
+				results[i].fail(errorStatus(errs[k]), errs[k])
+			}
+		}
+		aborted = err != nil
+		if !aborted && m.written != nil {
+			for k := range index {
+				if errs[k] == nil {
+					m.written(ops[k].Kind, ops[k].Value)
+				}
+			}
+		}
+	}
+
+	response := BulkResponse{Mode: mode, Results: results}
+	for i := range results {
+		if aborted && results[i].Error == "" {
+			results[i].Status = http.StatusFailedDependency
+			results[i].ID = nil
+			results[i].Error = "rolled back"
+		}
+		if results[i].Error == "" {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	status := http.StatusOK
+	switch {
+	case aborted:
+		status = http.StatusUnprocessableEntity
+	case response.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+
+	c.JSON(status, gin.H{
+		"data": response,
+	})
+}
+
+func (r *BulkItemResult) fail(status int, err error) {
+	r.Status = status
+	r.Error = err.Error()
+}
+
+func bulkSuccessStatus(op store.BulkOpKind) int {
+	if op == store.BulkCreate {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+// validateBulkItem applies the binding rules of record to creates and
+// updates, and requires an ID for updates and deletes.
+func validateBulkItem(op store.BulkOpKind, record any, hasID bool) error {
+	if op != store.BulkDelete {
+		if err := binding.Validator.ValidateStruct(record); err != nil {
+			return err
+		}
+	}
+	if op != store.BulkCreate && !hasID {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+// mergeJSON decodes raw onto record, a pointer to a stored record, so that
+// the fields raw leaves out keep their stored values. Unlike with
+// json.Unmarshal alone, a map in raw replaces the stored map rather than
+// adding to it.
+func mergeJSON(record any, raw []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+
+	val := reflect.ValueOf(record).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		f := val.Type().Field(i)
+		if f.Type.Kind() != reflect.Map {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		for key := range fields {
+			if strings.EqualFold(key, name) {
+				val.Field(i).Set(reflect.Zero(f.Type))
+			}
+		}
+	}
+	return json.Unmarshal(raw, record)
+}
+
+func readBulkItems(c *gin.Context) ([]json.RawMessage, error) {
+	switch c.ContentType() {
+	case "application/x-ndjson", "application/ndjson":
+		return readNDJSON(c.Request.Body)
+	}
+
+	dec := json.NewDecoder(c.Request.Body)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, errors.New("body must be a JSON array, or NDJSON sent as application/x-ndjson")
+	}
+
+	var items []json.RawMessage
+	for dec.More() {
+		if len(items) == maxBulkItems {
+			return nil, errTooManyItems
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		items = append(items, raw)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// readNDJSON returns one item per non-empty line. Lines that are not valid
+// JSON are returned as they are and fail on their own.
+func readNDJSON(r io.Reader) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if len(items) == maxBulkItems {
+				return nil, errTooManyItems
+			}
+			items = append(items, line)
+		}
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBulkApp(db store.Storer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	productController := NewProductController(db, store.NewMemoryStore(), nil, nil)
+	todoController := NewTodoController(db, nil)
+
+	r := gin.New()
+	r.POST(pathProducts+"/bulk", productController.Bulk)
+	r.POST("/todos/bulk", todoController.Bulk)
+
+	return r
+}
+
+func postBulk(r *gin.Engine, path, contentType, body string) (*httptest.ResponseRecorder, BulkResponse) {
+	req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var response struct {
+		Data BulkResponse `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec, response.Data
+}
+
+func statuses(response BulkResponse) []int {
+	var out []int
+	for _, r := range response.Results {
+		out = append(out, r.Status)
+	}
+	return out
+}
+
+func TestBulkProducts(t *testing.T) {
+	t.Run("best effort writes every valid item", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		existing := models.Product{Name: "Laptop", Price: money.New(100, "USD"), Description: "old"}
+		require.NoError(t, db.Create(&existing))
+		r := setupBulkApp(db)
+
+		rec, response := postBulk(r, pathProducts+"/bulk", "application/json", `[
+			{"name": "Phone", "price": {"amount": 50, "currency": "USD"}, "description": "new"},
+			{"name": "Tablet"},
+			{"op": "update", "id": "`+existing.ID.Hex()+`", "name": "Laptop", "price": {"amount": 90, "currency": "USD"}, "description": "cheaper"},
+			{"op": "delete", "id": "000000000000000000000001"},
+			{"op": "rename", "name": "x"}
+		]`)
+
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, []int{201, 400, 200, 404, 400}, statuses(response))
+		assert.Equal(t, 2, response.Succeeded)
+		assert.Equal(t, 3, response.Failed)
+		assert.NotEmpty(t, response.Results[0].ID)
+
+		var products []models.Product
+		require.NoError(t, db.Find(&products))
+		assert.Len(t, products, 2)
+		assert.Equal(t, int64(90), products[0].Price.Amount)
+	})
+
+	t.Run("updates keep the fields the item leaves out", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		existing := models.Product{
+			Name:        "Lamp",
+			Price:       money.New(100, "USD"),
+			Description: "desk",
+			Tags:        []string{"home"},
+			Attributes:  models.Attributes{"color": "red", "size": "m"},
+			Images:      []models.ProductImage{{ID: "a"}},
+		}
+		require.NoError(t, db.Create(&existing))
+		r := setupBulkApp(db)
+
+		rec, response := postBulk(r, pathProducts+"/bulk", "application/json", `[
+			{"op": "update", "id": "`+existing.ID.Hex()+`", "price": {"amount": 90, "currency": "USD"}, "attributes": {"color": "blue"}, "images": []}
+		]`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, []int{200}, statuses(response))
+
+		var product models.Product
+		require.NoError(t, db.First(&product))
+		assert.Equal(t, int64(90), product.Price.Amount)
+		assert.Equal(t, "desk", product.Description)
+		assert.Equal(t, []string{"home"}, product.Tags)
+		assert.Equal(t, models.Attributes{"color": "blue"}, product.Attributes, "a map sent replaces the stored one")
+		assert.Equal(t, existing.Images, product.Images, "images are only changed by uploads")
+	})
+
+	t.Run("atomic request with an invalid item writes nothing", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		r := setupBulkApp(db)
+
+		rec, response := postBulk(r, pathProducts+"/bulk?mode=atomic", "application/json", `[
+			{"name": "Phone", "price": {"amount": 50, "currency": "USD"}, "description": "new"},
+			{"name": "Tablet"}
+		]`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, []int{424, 400}, statuses(response))
+
+		var products []models.Product
+		require.NoError(t, db.Find(&products))
+		assert.Empty(t, products)
+	})
+
+	t.Run("atomic request rolls back when a write fails", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		r := setupBulkApp(db)
+
+		rec, response := postBulk(r, pathProducts+"/bulk?mode=atomic", "application/json", `[
+			{"name": "Phone", "price": {"amount": 50, "currency": "USD"}, "description": "new"},
+			{"op": "delete", "id": "000000000000000000000001"}
+		]`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, []int{424, 404}, statuses(response))
+		assert.Nil(t, response.Results[0].ID)
+
+		var products []models.Product
+		require.NoError(t, db.Find(&products))
+		assert.Empty(t, products)
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		r := setupBulkApp(db)
+
+		rec, response := postBulk(r, pathProducts+"/bulk", "application/x-ndjson",
+			"{\"name\": \"Phone\", \"price\": {\"amount\": 50, \"currency\": \"USD\"}, \"description\": \"new\"}\n"+
+				"not json\n"+
+				"\n"+
+				"{\"name\": \"Laptop\", \"price\": {\"amount\": 100, \"currency\": \"USD\"}, \"description\": \"new\"}\n")
+
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, []int{201, 400, 201}, statuses(response))
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		r := setupBulkApp(store.NewMemoryStore())
+
+		rec, _ := postBulk(r, pathProducts+"/bulk?mode=all", "application/json", `[]`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, _ = postBulk(r, pathProducts+"/bulk", "application/json", `{"name": "Phone"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, _ = postBulk(r, pathProducts+"/bulk", "application/json", `[]`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, _ = postBulk(r, pathProducts+"/bulk", "application/x-ndjson", strings.Repeat("{}\n", maxBulkItems+1))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		r := setupBulkApp(&store.MockStore{})
+
+		rec, _ := postBulk(r, pathProducts+"/bulk?mode=atomic", "application/json",
+			`[{"name": "Phone", "price": {"amount": 50, "currency": "USD"}, "description": "new"}]`)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func TestBulkTodos(t *testing.T) {
+	db := store.NewMemoryStore()
+	r := setupBulkApp(db)
+
+	rec, response := postBulk(r, "/todos/bulk", "application/json", `[
+		{"title": "Buy milk"},
+		{"title": ""},
+		{"op": "update", "title": "No ID"}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.Equal(t, []int{201, 400, 400}, statuses(response))
+	assert.EqualValues(t, 1, response.Results[0].ID)
+
+	rec, response = postBulk(r, "/todos/bulk?mode=atomic", "application/json", `[
+		{"op": "update", "id": 1, "title": "Buy milk", "completed": true},
+		{"title": "Walk the dog"}
+	]`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []int{200, 201}, statuses(response))
+
+	var todos []models.Todo
+	require.NoError(t, db.Find(&todos))
+	require.Len(t, todos, 2)
+	assert.True(t, todos[0].Completed)
+
+	t.Run("updates keep the fields the item leaves out", func(t *testing.T) {
+		due := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+		series := uint(7)
+		todo := models.Todo{Title: "Water plants", Owner: "alice", DueAt: &due, RRule: "FREQ=WEEKLY", SeriesID: &series, Occurrence: 2, NextMaterialized: true, Priority: models.PriorityHigh}
+		require.NoError(t, db.Create(&todo))
+
+		req, _ := http.NewRequest(http.MethodPost, "/todos/bulk", strings.NewReader(`[{"op": "update", "id": `+strconv.Itoa(int(todo.ID))+`, "completed": true}]`))
+		req.Header.Set("X-User", "bob")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var updated models.Todo
+		require.NoError(t, db.First(&updated, todo.ID))
+		assert.True(t, updated.Completed)
+		assert.Equal(t, "Water plants", updated.Title)
+		assert.Equal(t, "alice", updated.Owner)
+		assert.Equal(t, &series, updated.SeriesID)
+		assert.Equal(t, 2, updated.Occurrence)
+		assert.True(t, updated.NextMaterialized)
+		assert.Equal(t, models.PriorityHigh, updated.Priority)
+		assert.True(t, due.Equal(*updated.DueAt))
+	})
+}
+
+func TestBulkTodosPlacement(t *testing.T) {
+	db := store.NewMemoryStore()
+	home, work := models.TodoList{Name: "Home"}, models.TodoList{Name: "Work"}
+	require.NoError(t, db.Create(&home))
+	require.NoError(t, db.Create(&work))
+	parent := models.Todo{Title: "Move house", ListID: &home.ID}
+	require.NoError(t, db.Create(&parent))
+	child := models.Todo{Title: "Pack", ListID: &home.ID, ParentID: &parent.ID}
+	require.NoError(t, db.Create(&child))
+	r := setupBulkApp(db)
+
+	id := func(n uint) string { return strconv.Itoa(int(n)) }
+	rec, response := postBulk(r, "/todos/bulk", "application/json", `[
+		{"title": "Label boxes", "parent_id": `+id(parent.ID)+`},
+		{"title": "Lost", "list_id": 99},
+		{"title": "Elsewhere", "parent_id": `+id(parent.ID)+`, "list_id": `+id(work.ID)+`},
+		{"op": "update", "id": `+id(parent.ID)+`, "parent_id": `+id(parent.ID)+`},
+		{"op": "update", "id": `+id(parent.ID)+`, "parent_id": `+id(child.ID)+`},
+		{"op": "update", "id": `+id(child.ID)+`, "list_id": `+id(work.ID)+`}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.Equal(t, []int{201, 400, 400, 400, 400, 400}, statuses(response))
+	assert.Contains(t, response.Results[4].Error, "below itself")
+
+	var labelled models.Todo
+	require.NoError(t, db.First(&labelled, "title = ?", "Label boxes"))
+	assert.Equal(t, &home.ID, labelled.ListID, "a subtask goes into its parent's list")
+}
@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/ical"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+const (
+	maxCalendarImportBytes = 10 << 20
+	// eventLength is how long a todo lasts when it is shown as an event.
+	eventLength = 30 * time.Minute
+)
+
+// CalendarController serves a user's open todos as an iCalendar feed and
+// imports todos from .ics files. Calendar apps cannot send headers, so feed
+// URLs carry the user and a token signed with the calendar secret instead.
+type CalendarController struct {
+	db     store.Storer
+	secret []byte
+	now    func() time.Time
+}
+
+// NewCalendarController returns a controller whose feeds are signed with
+// secret. With an empty secret the feeds are disabled.
+func NewCalendarController(db store.Storer, secret string) *CalendarController {
+	return &CalendarController{db: db, secret: []byte(secret), now: time.Now}
+}
+
+func (cc *CalendarController) token(user string) string {
+	mac := hmac.New(sha256.New, cc.secret)
+	mac.Write([]byte("calendar:" + user))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Link returns the feed URL of the user in the X-User header.
+func (cc *CalendarController) Link(c *gin.Context) {
+	user := currentUser(c)
+	if user == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "the X-User header is required",
+		})
+		return
+	}
+	if len(cc.secret) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "calendar feeds are disabled",
+		})
+		return
+	}
+
+	query := url.Values{"user": {user}, "token": {cc.token(user)}}
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"url": "/todos/calendar.ics?" + query.Encode(),
+		},
+	})
+}
+
+// Feed handles GET /todos/calendar.ics?user=&token=. Every open todo of the
+// user is a VTODO, or with ?as=vevent every open todo with a due date is a
+// VEVENT, for calendar apps that do not show tasks.
+func (cc *CalendarController) Feed(c *gin.Context) {
+	user := c.Query("user")
+	token, _ := hex.DecodeString(c.Query("token"))
+	expected, _ := hex.DecodeString(cc.token(user))
+	if len(cc.secret) == 0 || user == "" || !hmac.Equal(token, expected) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Forbidden",
+		})
+		return
+	}
+
+	as := c.DefaultQuery("as", "vtodo")
+	if as != "vtodo" && as != "vevent" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": `as must be "vtodo" or "vevent"`,
+		})
+		return
+	}
+
+	cal := ical.Component{Name: "VCALENDAR"}
+	cal.Add("VERSION", "2.0")
+	cal.Add("PRODID", "-//go-example//todos//EN")
+	cal.Add("CALSCALE", "GREGORIAN")
+	cal.Add("METHOD", "PUBLISH")
+	cal.AddText("X-WR-CALNAME", "Todos of "+user)
+
+	now := cc.now()
+	var todo models.Todo
+	err := store.Each(cc.db, &todo, func() error {
+		if as == "vtodo" {
+			cal.Components = append(cal.Components, todoComponent(todo, now))
+		} else if todo.DueAt != nil {
+			cal.Components = append(cal.Components, eventComponent(todo, now))
+		}
+		return nil
+	}, "owner = ? AND completed = ?", user, false)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, cal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.Header("Content-Disposition", `inline; filename="todos.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+func todoUID(todo models.Todo) string {
+	return fmt.Sprintf("todo-%d@go-example", todo.ID)
+}
+
+// todoComponent renders an open todo. DUE anchors the recurrence, and the
+// reminder is relative to it.
+func todoComponent(todo models.Todo, now time.Time) ical.Component {
+	vtodo := ical.Component{Name: "VTODO"}
+	vtodo.Add("UID", todoUID(todo))
+	vtodo.AddTime("DTSTAMP", now)
+	vtodo.AddTime("CREATED", todo.CreatedAt)
+	vtodo.AddTime("LAST-MODIFIED", todo.UpdatedAt)
+	vtodo.AddText("SUMMARY", todo.Title)
+	vtodo.Add("STATUS", "NEEDS-ACTION")
+	if todo.DueAt != nil {
+		vtodo.AddTime("DUE", *todo.DueAt)
+		if todo.RRule != "" {
+			vtodo.Add("RRULE", todo.RRule)
+		}
+		if todo.ReminderMinutes > 0 {
+			vtodo.Components = append(vtodo.Components, alarm(todo, "END"))
+		}
+	}
+	return vtodo
+}
+
+// eventComponent renders an open todo with a due date as an event starting
+// at the due date.
+func eventComponent(todo models.Todo, now time.Time) ical.Component {
+	event := ical.Component{Name: "VEVENT"}
+	event.Add("UID", todoUID(todo))
+	event.AddTime("DTSTAMP", now)
+	event.AddTime("CREATED", todo.CreatedAt)
+	event.AddTime("LAST-MODIFIED", todo.UpdatedAt)
+	event.AddText("SUMMARY", todo.Title)
+	event.AddTime("DTSTART", *todo.DueAt)
+	event.AddTime("DTEND", todo.DueAt.Add(eventLength))
+	if todo.RRule != "" {
+		event.Add("RRULE", todo.RRule)
+	}
+	if todo.ReminderMinutes > 0 {
+		event.Components = append(event.Components, alarm(todo, "START"))
+	}
+	return event
+}
+
+func alarm(todo models.Todo, related string) ical.Component {
+	valarm := ical.Component{Name: "VALARM"}
+	valarm.Add("ACTION", "DISPLAY")
+	valarm.AddText("DESCRIPTION", todo.Title)
+	valarm.Add("TRIGGER", ical.FormatDuration(-time.Duration(todo.ReminderMinutes)*time.Minute), "RELATED", related)
+	return valarm
+}
+
+// Import handles POST /todos/calendar.ics. Every VTODO of the uploaded
+// calendar becomes a new todo of the user in the X-User header; the report
+// lists failures by the line the VTODO begins on.
+func (cc *CalendarController) Import(c *gin.Context) {
+	user := currentUser(c)
+	if user == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "the X-User header is required",
+		})
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxCalendarImportBytes)
+	cal, err := ical.Decode(body)
+	if err == nil && cal.Name != "VCALENDAR" {
+		err = fmt.Errorf("expected a VCALENDAR, found %s", cal.Name)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	im := newImporter(store.As(cc.db, user), todoTransfer)
+	for _, vtodo := range cal.All("VTODO") {
+		todo, column, err := todoFromComponent(vtodo)
+		if err != nil {
+			im.fail(vtodo.Line, column, err)
+			continue
+		}
+		todo.Owner = user
+		if err := im.add(vtodo.Line, todo); err != nil {
+			im.respond(c, err)
+			return
+		}
+	}
+	im.respond(c, nil)
+}
+
+// todoFromComponent reads a VTODO. On error it also returns the lower-cased
+// property at fault.
+func todoFromComponent(vtodo ical.Component) (*models.Todo, string, error) {
+	todo := &models.Todo{}
+	if summary, ok := vtodo.Get("SUMMARY"); ok {
+		todo.Title = summary.Text()
+	}
+
+	switch vtodo.Value("STATUS") {
+	case "CANCELLED":
+		return nil, "status", errors.New("cancelled todos are not imported")
+	case "COMPLETED":
+		todo.Completed = true
+	}
+	if _, ok := vtodo.Get("COMPLETED"); ok {
+		todo.Completed = true
+	}
+
+	// A todo without DUE may still have an end in DTSTART and DURATION.
+	if prop, ok := vtodo.Get("DUE"); ok {
+		due, err := prop.Time()
+		if err != nil {
+			return nil, "due", err
+		}
+		todo.DueAt = &due
+	} else if prop, ok := vtodo.Get("DTSTART"); ok {
+		start, err := prop.Time()
+		if err != nil {
+			return nil, "dtstart", err
+		}
+		if d := vtodo.Value("DURATION"); d != "" {
+			length, err := ical.ParseDuration(d)
+			if err != nil {
+				return nil, "duration", err
+			}
+			start = start.Add(length)
+		}
+		todo.DueAt = &start
+	}
+	if todo.DueAt != nil {
+		utc := todo.DueAt.UTC()
+		todo.DueAt = &utc
+	}
+
+	todo.RRule = vtodo.Value("RRULE")
+
+	// Only the first alarm is kept, and only if it fires before the due date.
+	if alarms := vtodo.All("VALARM"); len(alarms) > 0 && todo.DueAt != nil {
+		trigger, _ := alarms[0].Get("TRIGGER")
+		var before time.Duration
+		if trigger.Params["VALUE"] == "DATE-TIME" {
+			at, err := trigger.Time()
+			if err != nil {
+				return nil, "trigger", err
+			}
+			before = todo.DueAt.Sub(at)
+		} else if trigger.Value != "" {
+			offset, err := ical.ParseDuration(trigger.Value)
+			if err != nil {
+				return nil, "trigger", err
+			}
+			before = -offset
+		}
+		if before > 0 {
+			todo.ReminderMinutes = int(before.Round(time.Minute) / time.Minute)
+		}
+	}
+	return todo, "", nil
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/ical"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCalendarApp(db store.Storer, secret string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	calendarController := NewCalendarController(db, secret)
+	calendarController.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	r := gin.New()
+	r.GET("/todos/calendar", calendarController.Link)
+	r.GET("/todos/calendar.ics", calendarController.Feed)
+	r.POST("/todos/calendar.ics", calendarController.Import)
+	return r
+}
+
+func serveAs(r *gin.Engine, user, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-User", user)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func feedURL(t *testing.T, r *gin.Engine, user string) string {
+	rec := serveAs(r, user, http.MethodGet, "/todos/calendar", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Data struct {
+			URL string `json:"url"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response.Data.URL
+}
+
+func TestCalendarFeed(t *testing.T) {
+	due := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	db := store.NewMemoryStore()
+	for _, todo := range []models.Todo{
+		{Title: "Weekly report", Owner: "alice", DueAt: &due, ReminderMinutes: 15, RRule: "FREQ=WEEKLY;BYDAY=FR"},
+		{Title: "Someday", Owner: "alice"},
+		{Title: "Done", Owner: "alice", Completed: true},
+		{Title: "Not mine", Owner: "bob"},
+	} {
+		todo := todo
+		require.NoError(t, db.Create(&todo))
+	}
+	r := setupCalendarApp(db, "s3cret")
+	url := feedURL(t, r, "alice")
+
+	t.Run("vtodo", func(t *testing.T) {
+		rec := serve(r, http.MethodGet, url, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/calendar; charset=utf-8", rec.Header().Get("Content-Type"))
+
+		cal, err := ical.Decode(rec.Body)
+		require.NoError(t, err)
+		todos := cal.All("VTODO")
+		require.Len(t, todos, 2)
+
+		weekly := todos[0]
+		assert.Equal(t, "todo-1@go-example", weekly.Value("UID"))
+		assert.Equal(t, "Weekly report", weekly.Value("SUMMARY"))
+		assert.Equal(t, "20240301T090000Z", weekly.Value("DUE"))
+		assert.Equal(t, "FREQ=WEEKLY;BYDAY=FR", weekly.Value("RRULE"))
+		assert.Equal(t, "NEEDS-ACTION", weekly.Value("STATUS"))
+		require.Len(t, weekly.All("VALARM"), 1)
+		trigger, _ := weekly.All("VALARM")[0].Get("TRIGGER")
+		assert.Equal(t, ical.Property{Name: "TRIGGER", Params: map[string]string{"RELATED": "END"}, Value: "-PT15M"}, trigger)
+
+		assert.Equal(t, "Someday", todos[1].Value("SUMMARY"))
+		assert.Empty(t, todos[1].Value("DUE"))
+	})
+
+	t.Run("vevent", func(t *testing.T) {
+		rec := serve(r, http.MethodGet, url+"&as=vevent", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		cal, err := ical.Decode(rec.Body)
+		require.NoError(t, err)
+		events := cal.All("VEVENT")
+		require.Len(t, events, 1)
+		assert.Equal(t, "20240301T090000Z", events[0].Value("DTSTART"))
+		assert.Equal(t, "20240301T093000Z", events[0].Value("DTEND"))
+	})
+
+	t.Run("bad token", func(t *testing.T) {
+		rec := serve(r, http.MethodGet, strings.Replace(url, "user=alice", "user=bob", 1), "")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = serve(r, http.MethodGet, "/todos/calendar.ics?user=alice", "")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("disabled without a secret", func(t *testing.T) {
+		r := setupCalendarApp(db, "")
+		assert.Equal(t, http.StatusForbidden, serveAs(r, "alice", http.MethodGet, "/todos/calendar", "").Code)
+		assert.Equal(t, http.StatusForbidden, serve(r, http.MethodGet, url, "").Code)
+	})
+
+	t.Run("link needs a user", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodGet, "/todos/calendar", "").Code)
+	})
+}
+
+func TestCalendarImport(t *testing.T) {
+	db := store.NewMemoryStore()
+	r := setupCalendarApp(db, "s3cret")
+
+	body := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		"SUMMARY:Pay rent\\, again",
+		"DUE;TZID=Europe/Berlin:20240301T100000",
+		"RRULE:FREQ=MONTHLY;BYMONTHDAY=1",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER;RELATED=END:-P1D",
+		"END:VALARM",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"SUMMARY:Filed taxes",
+		"STATUS:COMPLETED",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"DUE:20240301T100000Z",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"SUMMARY:Every now and then",
+		"DUE:20240301T100000Z",
+		"RRULE:FREQ=HOURLY",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"SUMMARY:Not a todo",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	req, _ := http.NewRequest(http.MethodPost, "/todos/calendar.ics", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/calendar")
+	req.Header.Set("X-User", "alice")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var response struct {
+		Data ImportReport `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.Equal(t, 2, response.Data.Created)
+	assert.Equal(t, []ImportRowError{
+		{Line: 16, Column: "title", Error: "is required"},
+		{Line: 19, Column: "rrule", Error: "rrule: FREQ HOURLY is not supported"},
+	}, response.Data.Errors)
+
+	var todos []models.Todo
+	require.NoError(t, db.Find(&todos))
+	require.Len(t, todos, 2)
+	assert.Equal(t, "Pay rent, again", todos[0].Title)
+	assert.Equal(t, "alice", todos[0].Owner)
+	assert.Equal(t, "2024-03-01T09:00:00Z", todos[0].DueAt.Format(time.RFC3339))
+	assert.Equal(t, 24*60, todos[0].ReminderMinutes)
+	assert.Equal(t, "FREQ=MONTHLY;BYMONTHDAY=1", todos[0].RRule)
+	assert.True(t, todos[1].Completed)
+
+	t.Run("needs a user", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPost, "/todos/calendar.ics", body).Code)
+	})
+
+	t.Run("malformed calendar", func(t *testing.T) {
+		rec := serveAs(r, "alice", http.MethodPost, "/todos/calendar.ics", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\n")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestCreateTodoSchedule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST(pathTodo, NewTodoController(store.NewMemoryStore(), nil).Create)
+
+	rec := serveAs(r, "alice", http.MethodPost, pathTodo, `{"title":"Gym","due_at":"2024-03-01T18:00:00Z","rrule":"freq=daily;interval=2","reminder_minutes":30}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var response struct {
+		Data models.Todo `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "alice", response.Data.Owner)
+	assert.Equal(t, "FREQ=DAILY;INTERVAL=2", response.Data.RRule)
+	assert.Equal(t, 30, response.Data.ReminderMinutes)
+
+	for body, message := range map[string]string{
+		`{"title":"Gym","rrule":"FREQ=DAILY"}`:                                    "due_at is required when rrule is set",
+		`{"title":"Gym","reminder_minutes":5}`:                                    "due_at is required when reminder_minutes is set",
+		`{"title":"Gym","due_at":"2024-03-01T18:00:00Z","rrule":"FREQ=SECONDLY"}`: "rrule: FREQ SECONDLY is not supported",
+	} {
+		rec := serveAs(r, "alice", http.MethodPost, pathTodo, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), message, body)
+	}
+}
@@ -0,0 +1,369 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CategoryRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	// ParentID places the category below another; without it the category
+	// is a root.
+	ParentID *primitive.ObjectID `json:"parent_id"`
+}
+
+type CategoryController struct {
+	db       store.Storer
+	products store.Storer
+}
+
+func NewCategoryController(db, products store.Storer) *CategoryController {
+	return &CategoryController{db: db, products: products}
+}
+
+// Index handles GET /categories: every category, as a tree of roots sorted
+// by name.
+func (cc *CategoryController) Index(c *gin.Context) {
+	categories := []models.Category{}
+	if err := cc.db.Find(&categories, bson.M{}); err != nil && !store.IsNotFound(err) {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": categoryTree(categories, nil),
+	})
+}
+
+// FindOne handles GET /categories/:id: the category with the tree below it.
+func (cc *CategoryController) FindOne(c *gin.Context) {
+	category, ok := cc.category(c)
+	if !ok {
+		return
+	}
+
+	descendants, err := categoryDescendants(cc.db, category.ID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	category.Children = categoryTree(descendants, &category.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": category,
+	})
+}
+
+func (cc *CategoryController) Create(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	category := models.Category{Name: req.Name}
+	if !cc.place(c, &category, req) {
+		return
+	}
+	if err := cc.db.Create(&category); err != nil {
+		cc.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": category,
+	})
+}
+
+// Update handles PUT /categories/:id, which renames the category or moves
+// it, with everything below it, to another parent.
+func (cc *CategoryController) Update(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	category, ok := cc.category(c)
+	if !ok {
+		return
+	}
+	before := category.Ancestors
+	category.Name = req.Name
+	if !cc.place(c, &category, req) {
+		return
+	}
+	if err := cc.db.Save(&category); err != nil {
+		cc.writeError(c, err)
+		return
+	}
+
+	if !sameIDs(before, category.Ancestors) {
+		if err := cc.moveDescendants(category); err != nil {
+			c.JSON(errorStatus(err), gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": category,
+	})
+}
+
+// Delete handles DELETE /categories/:id. Only a category without
+// subcategories and products can be deleted.
+func (cc *CategoryController) Delete(c *gin.Context) {
+	category, ok := cc.category(c)
+	if !ok {
+		return
+	}
+
+	var child models.Category
+	err := cc.db.First(&child, bson.M{"parent_id": category.ID})
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Category has subcategories",
+		})
+		return
+	} else if !store.IsNotFound(err) {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var product models.Product
+	err = cc.products.First(&product, bson.M{"category_ids": category.ID})
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Category has products",
+		})
+		return
+	} else if !store.IsNotFound(err) {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := store.Delete(cc.db, &category); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": category,
+	})
+}
+
+// category parses the :id parameter and loads the category, answering the
+// request itself when it cannot.
+func (cc *CategoryController) category(c *gin.Context) (models.Category, bool) {
+	var category models.Category
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+		return category, false
+	}
+
+	if err := cc.db.First(&category, bson.M{"_id": id}); err != nil {
+		if store.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Category not found",
+			})
+			return category, false
+		}
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return category, false
+	}
+	return category, true
+}
+
+// place sets the parent and ancestors of category from req, answering the
+// request itself when the parent does not exist, lies below the category,
+// or already has a child of the same name.
+func (cc *CategoryController) place(c *gin.Context, category *models.Category, req CategoryRequest) bool {
+	category.ParentID = req.ParentID
+	category.Ancestors = []primitive.ObjectID{}
+	if req.ParentID != nil {
+		parents, err := categoriesByID(cc.db, "parent_id", []primitive.ObjectID{*req.ParentID})
+		if err != nil {
+			cc.writeError(c, err)
+			return false
+		}
+		parent := parents[0]
+		if parent.ID == category.ID || containsID(parent.Ancestors, category.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "parent_id: a category cannot be moved below itself",
+			})
+			return false
+		}
+		category.Ancestors = append(append(category.Ancestors, parent.Ancestors...), parent.ID)
+	}
+
+	var parentID any
+	if category.ParentID != nil {
+		parentID = *category.ParentID
+	}
+	var sibling models.Category
+	err := cc.db.First(&sibling, bson.M{"parent_id": parentID, "name": category.Name})
+	switch {
+	case err == nil && sibling.ID != category.ID:
+		c.JSON(http.StatusConflict, gin.H{
+			"message": fmt.Sprintf("A category named %q already exists there", category.Name),
+		})
+		return false
+	case err != nil && !store.IsNotFound(err):
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// moveDescendants rewrites the ancestors of everything below category after
+// it moved.
+func (cc *CategoryController) moveDescendants(category models.Category) error {
+	descendants, err := categoryDescendants(cc.db, category.ID)
+	if err != nil {
+		return err
+	}
+	for i := range descendants {
+		d := &descendants[i]
+		for j, id := range d.Ancestors {
+			if id == category.ID {
+				below := append([]primitive.ObjectID{}, d.Ancestors[j:]...)
+				d.Ancestors = append(append([]primitive.ObjectID{}, category.Ancestors...), below...)
+				break
+			}
+		}
+		if err := cc.db.Save(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cc *CategoryController) writeError(c *gin.Context, err error) {
+	var colErr *columnError
+	if errors.As(err, &colErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if store.IsDuplicateKey(err) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "A category of that name already exists there",
+		})
+		return
+	}
+	c.JSON(errorStatus(err), gin.H{
+		"message": err.Error(),
+	})
+}
+
+// categoriesByID loads the categories with ids, failing with a columnError
+// for column when one does not exist.
+func categoriesByID(db store.Storer, column string, ids []primitive.ObjectID) ([]models.Category, error) {
+	categories := []models.Category{}
+	if len(ids) == 0 {
+		return categories, nil
+	}
+	if err := db.Find(&categories, bson.M{"_id": bson.M{"$in": ids}}); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	found := make(map[primitive.ObjectID]bool, len(categories))
+	for _, category := range categories {
+		found[category.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, &columnError{column, fmt.Errorf("%s: no category has id %s", column, id.Hex())}
+		}
+	}
+	return categories, nil
+}
+
+// categoryDescendants returns every category below id.
+func categoryDescendants(db store.Storer, id primitive.ObjectID) ([]models.Category, error) {
+	descendants := []models.Category{}
+	if err := db.Find(&descendants, bson.M{"ancestors": id}); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	return descendants, nil
+}
+
+// categoryTree nests categories below their parents and returns the children
+// of parent, or the roots when parent is nil, sorted by name.
+func categoryTree(categories []models.Category, parent *primitive.ObjectID) []models.Category {
+	children := map[primitive.ObjectID][]models.Category{}
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil || (parent != nil && *category.ParentID == *parent) {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], category)
+	}
+
+	var build func([]models.Category) []models.Category
+	build = func(level []models.Category) []models.Category {
+		sort.Slice(level, func(i, j int) bool { return level[i].Name < level[j].Name })
+		for i := range level {
+			level[i].Children = build(children[level[i].ID])
+		}
+		return level
+	}
+
+	if roots == nil {
+		return []models.Category{}
+	}
+	return build(roots)
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sameIDs(a, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
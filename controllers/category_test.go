@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const pathCategories = "/categories"
+
+func setupCategoryApp() (*gin.Engine, store.Storer) {
+	gin.SetMode(gin.TestMode)
+
+	categories, products := store.NewMemoryStore(), store.NewMemoryStore()
+	categoryController := NewCategoryController(categories, products)
+	productController := NewProductController(products, categories, nil, nil)
+
+	r := gin.New()
+	r.GET(pathCategories, categoryController.Index)
+	r.POST(pathCategories, categoryController.Create)
+	r.GET(pathCategories+"/:id", categoryController.FindOne)
+	r.PUT(pathCategories+"/:id", categoryController.Update)
+	r.DELETE(pathCategories+"/:id", categoryController.Delete)
+	r.GET(pathProducts, productController.Find)
+	r.POST(pathProducts, productController.Create)
+	r.PUT(pathProducts+"/:id/categories", productController.SetCategories)
+
+	return r, products
+}
+
+func createCategory(t *testing.T, r *gin.Engine, body string) models.Category {
+	rec := serve(r, http.MethodPost, pathCategories, body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var response struct {
+		Data models.Category `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response.Data
+}
+
+func TestCategoryTree(t *testing.T) {
+	r, _ := setupCategoryApp()
+
+	clothing := createCategory(t, r, `{"name":"Clothing"}`)
+	shirts := createCategory(t, r, `{"name":"Shirts","parent_id":"`+clothing.ID.Hex()+`"}`)
+	polos := createCategory(t, r, `{"name":"Polos","parent_id":"`+shirts.ID.Hex()+`"}`)
+	shoes := createCategory(t, r, `{"name":"Shoes"}`)
+	assert.Equal(t, []string{clothing.ID.Hex(), shirts.ID.Hex()}, idsOf(polos.Ancestors))
+
+	rec := serve(r, http.MethodPost, pathCategories, `{"name":"Shirts","parent_id":"`+clothing.ID.Hex()+`"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code, "sibling names are unique")
+	rec = serve(r, http.MethodPost, pathCategories, `{"name":"Shirts","parent_id":"`+shoes.ID.Hex()+`"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, "names repeat under other parents")
+	rec = serve(r, http.MethodPost, pathCategories, `{"name":"Hats","parent_id":"000000000000000000000000"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var tree struct {
+		Data []models.Category `json:"data"`
+	}
+	rec = serve(r, http.MethodGet, pathCategories, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tree))
+	require.Len(t, tree.Data, 2)
+	assert.Equal(t, "Clothing", tree.Data[0].Name)
+	require.Len(t, tree.Data[0].Children, 1)
+	assert.Equal(t, "Polos", tree.Data[0].Children[0].Children[0].Name)
+
+	t.Run("moving a category moves everything below it", func(t *testing.T) {
+		rec := serve(r, http.MethodPut, pathCategories+"/"+clothing.ID.Hex(), `{"name":"Clothing","parent_id":"`+polos.ID.Hex()+`"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "no cycles")
+
+		rec = serve(r, http.MethodPut, pathCategories+"/"+shirts.ID.Hex(), `{"name":"Shirts","parent_id":"`+shoes.ID.Hex()+`"}`)
+		assert.Equal(t, http.StatusConflict, rec.Code, "Shoes already has a Shirts")
+
+		rec = serve(r, http.MethodPut, pathCategories+"/"+shirts.ID.Hex(), `{"name":"Tops"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var one struct {
+			Data models.Category `json:"data"`
+		}
+		rec = serve(r, http.MethodGet, pathCategories+"/"+polos.ID.Hex(), "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &one))
+		assert.Equal(t, []string{shirts.ID.Hex()}, idsOf(one.Data.Ancestors))
+
+		rec = serve(r, http.MethodGet, pathCategories+"/"+shirts.ID.Hex(), "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &one))
+		assert.Equal(t, "Tops", one.Data.Name)
+		require.Len(t, one.Data.Children, 1)
+		assert.Equal(t, "Polos", one.Data.Children[0].Name)
+	})
+
+	t.Run("only empty leaves can be deleted", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, serve(r, http.MethodDelete, pathCategories+"/"+shirts.ID.Hex(), "").Code)
+
+		rec := serve(r, http.MethodPost, pathProducts, `{"name":"Polo","price":{"amount":2500,"currency":"USD"},"description":"Blue","category_ids":["`+polos.ID.Hex()+`"]}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var product struct {
+			Data models.Product `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+		assert.Equal(t, http.StatusConflict, serve(r, http.MethodDelete, pathCategories+"/"+polos.ID.Hex(), "").Code)
+
+		rec = serve(r, http.MethodPut, pathProducts+"/"+product.Data.ID.Hex()+"/categories", `{"category_ids":[]}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, http.StatusOK, serve(r, http.MethodDelete, pathCategories+"/"+polos.ID.Hex(), "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, pathCategories+"/"+polos.ID.Hex(), "").Code)
+	})
+}
+
+func idsOf(ids []primitive.ObjectID) []string {
+	hex := []string{}
+	for _, id := range ids {
+		hex = append(hex, id.Hex())
+	}
+	return hex
+}
+
+func TestProductFacets(t *testing.T) {
+	r, _ := setupCategoryApp()
+
+	clothing := createCategory(t, r, `{"name":"Clothing"}`)
+	shirts := createCategory(t, r, `{"name":"Shirts","parent_id":"`+clothing.ID.Hex()+`"}`)
+	shoes := createCategory(t, r, `{"name":"Shoes"}`)
+
+	for _, body := range []string{
+		`{"name":"Tee","price":{"amount":1500,"currency":"USD"},"description":"x","category_ids":["` + shirts.ID.Hex() + `"],"tags":["Cotton"," summer"],"attributes":{"size":"M","color":"red"}}`,
+		`{"name":"Polo","price":{"amount":2500,"currency":"USD"},"description":"x","category_ids":["` + shirts.ID.Hex() + `"],"tags":["cotton"],"attributes":{"size":"L","color":"blue"}}`,
+		`{"name":"Boot","price":{"amount":9000,"currency":"USD"},"description":"x","category_ids":["` + shoes.ID.Hex() + `"],"tags":["leather"],"attributes":{"size":42,"waterproof":true}}`,
+	} {
+		rec := serve(r, http.MethodPost, pathProducts, body)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	var response struct {
+		Data   []models.Product              `json:"data"`
+		Facets map[string][]store.FacetCount `json:"facets"`
+	}
+	find := func(query string) {
+		t.Helper()
+		response.Data, response.Facets = nil, nil
+		rec := serve(r, http.MethodGet, pathProducts+query, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	}
+	names := func() []string {
+		var names []string
+		for _, p := range response.Data {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	find("?facets=true")
+	assert.Len(t, response.Data, 3)
+	assert.Equal(t, []store.FacetCount{{Value: "cotton", Count: 2}, {Value: "leather", Count: 1}, {Value: "summer", Count: 1}}, response.Facets["tags"])
+	assert.Equal(t, []store.FacetCount{{Value: shirts.ID.Hex(), Count: 2}, {Value: shoes.ID.Hex(), Count: 1}}, response.Facets["category_ids"])
+	assert.Equal(t, []store.FacetCount{{Value: 42.0, Count: 1}, {Value: "L", Count: 1}, {Value: "M", Count: 1}}, response.Facets["attributes.size"])
+	assert.Equal(t, []store.FacetCount{{Value: true, Count: 1}}, response.Facets["attributes.waterproof"])
+
+	find("?category=" + clothing.ID.Hex() + "&facets=true")
+	assert.ElementsMatch(t, []string{"Tee", "Polo"}, names(), "subcategories are included")
+	assert.Equal(t, []store.FacetCount{{Value: "blue", Count: 1}, {Value: "red", Count: 1}}, response.Facets["attributes.color"])
+
+	find("?tags=Cotton&attributes.color=red&attributes.color=green")
+	assert.Equal(t, []string{"Tee"}, names())
+	assert.Nil(t, response.Facets, "facets are counted on request")
+
+	find("?attributes.size=42&attributes.waterproof=true")
+	assert.Equal(t, []string{"Boot"}, names())
+
+	find("?tags=cotton&category=" + shoes.ID.Hex())
+	assert.Empty(t, names())
+
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodGet, pathProducts+"?category=nope", "").Code)
+	for _, attributes := range []string{`{"size":[1]}`, `{"a.b":1}`, `{"size":null}`} {
+		rec := serve(r, http.MethodPost, pathProducts, `{"name":"Bad","price":{"amount":1,"currency":"USD"},"description":"x","attributes":`+attributes+`}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, attributes)
+	}
+	rec := serve(r, http.MethodPost, pathProducts, `{"name":"Bad","price":{"amount":1,"currency":"USD"},"description":"x","category_ids":["000000000000000000000000"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
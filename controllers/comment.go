@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+// CommentController serves /todos/:id/comments. Anyone may read and write
+// comments; only their author may edit or delete them.
+type CommentController struct {
+	db store.Storer
+}
+
+func NewCommentController(db store.Storer) *CommentController {
+	return &CommentController{db}
+}
+
+type CommentRequest struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+func (cc *CommentController) Index(c *gin.Context) {
+	todo, ok := loadTodo(c, cc.db)
+	if !ok {
+		return
+	}
+
+	comments := []models.Comment{}
+	if err := cc.db.Find(&comments, "todo_id = ?", todo.ID); err != nil && !store.IsNotFound(err) {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": comments,
+	})
+}
+
+// author returns the user in the X-User header, answering the request itself
+// when there is none.
+func author(c *gin.Context) (string, bool) {
+	user := currentUser(c)
+	if user == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "the X-User header is required",
+		})
+	}
+	return user, user != ""
+}
+
+func (cc *CommentController) Create(c *gin.Context) {
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+	todo, ok := loadTodo(c, cc.db)
+	if !ok {
+		return
+	}
+
+	comment := models.Comment{TodoID: todo.ID, Author: user, Body: req.Body}
+	if err := store.As(cc.db, user).Create(&comment); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": comment,
+	})
+}
+
+// comment loads the :comment_id comment of the :id todo for its author,
+// answering the request itself when it cannot.
+func (cc *CommentController) comment(c *gin.Context, user string) (models.Comment, bool) {
+	var comment models.Comment
+	todoID, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+		return comment, false
+	}
+	id, err := strconv.ParseUint(c.Param("comment_id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+		return comment, false
+	}
+
+	err = cc.db.First(&comment, uint(id))
+	if store.IsNotFound(err) || (err == nil && comment.TodoID != uint(todoID)) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Comment not found",
+		})
+		return comment, false
+	} else if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return comment, false
+	}
+
+	if comment.Author != user {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "only the author can change a comment",
+		})
+		return comment, false
+	}
+	return comment, true
+}
+
+func (cc *CommentController) Update(c *gin.Context) {
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+	comment, ok := cc.comment(c, user)
+	if !ok {
+		return
+	}
+
+	comment.Body = req.Body
+	if err := store.As(cc.db, user).Save(&comment); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": comment,
+	})
+}
+
+func (cc *CommentController) Delete(c *gin.Context) {
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+	comment, ok := cc.comment(c, user)
+	if !ok {
+		return
+	}
+
+	if err := store.Delete(store.As(cc.db, user), &comment); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/activity"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCommentApp(db store.Storer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	db = activity.NewStore(db)
+	todoController := NewTodoController(db, nil)
+	commentController := NewCommentController(db)
+
+	r := gin.New()
+	r.GET("/todos", todoController.Index)
+	r.POST("/todos", todoController.Create)
+	r.POST("/todos/:id/complete", todoController.Complete)
+	r.PUT("/todos/:id/assignees", todoController.SetAssignees)
+	r.GET("/todos/:id/activity", todoController.Activity)
+	r.GET("/todos/:id/comments", commentController.Index)
+	r.POST("/todos/:id/comments", commentController.Create)
+	r.PUT("/todos/:id/comments/:comment_id", commentController.Update)
+	r.DELETE("/todos/:id/comments/:comment_id", commentController.Delete)
+	return r
+}
+
+func TestComments(t *testing.T) {
+	r := setupCommentApp(store.NewMemoryStore())
+	createTodo(t, r, `{"title":"Plan offsite"}`)
+	createTodo(t, r, `{"title":"Other"}`)
+
+	rec := serveAs(r, "alice", http.MethodPost, "/todos/1/comments", `{"body":"How about **Lisbon**?"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"author":"alice"`)
+	require.Equal(t, http.StatusCreated, serveAs(r, "bob", http.MethodPost, "/todos/1/comments", `{"body":"+1"}`).Code)
+
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPost, "/todos/1/comments", `{"body":"anonymous"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serveAs(r, "alice", http.MethodPost, "/todos/1/comments", `{"body":""}`).Code)
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "alice", http.MethodPost, "/todos/9/comments", `{"body":"x"}`).Code)
+
+	rec = serve(r, http.MethodGet, "/todos/1/comments", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response struct {
+		Data []models.Comment `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Data, 2)
+	assert.Equal(t, "How about **Lisbon**?", response.Data[0].Body)
+	assert.Equal(t, "bob", response.Data[1].Author)
+
+	assert.Equal(t, http.StatusForbidden, serveAs(r, "bob", http.MethodPut, "/todos/1/comments/1", `{"body":"Porto"}`).Code)
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "alice", http.MethodPut, "/todos/2/comments/1", `{"body":"Porto"}`).Code)
+	rec = serveAs(r, "alice", http.MethodPut, "/todos/1/comments/1", `{"body":"How about Porto?"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Porto")
+
+	assert.Equal(t, http.StatusForbidden, serveAs(r, "alice", http.MethodDelete, "/todos/1/comments/2", "").Code)
+	assert.Equal(t, http.StatusNoContent, serveAs(r, "bob", http.MethodDelete, "/todos/1/comments/2", "").Code)
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "bob", http.MethodDelete, "/todos/1/comments/2", "").Code)
+}
+
+func TestAssigneesAndActivity(t *testing.T) {
+	r := setupCommentApp(store.NewMemoryStore())
+
+	rec := serveAs(r, "alice", http.MethodPost, "/todos", `{"title":"Ship it","assignees":["bob"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"assignees":["bob"]`)
+	createTodo(t, r, `{"title":"Unassigned"}`)
+
+	rec = serveAs(r, "bob", http.MethodPut, "/todos/1/assignees", `{"assignees":["carol","bob","carol"]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"assignees":["bob","carol"]`)
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPut, "/todos/1/assignees", `{"assignees":[""]}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodPut, "/todos/9/assignees", `{"assignees":[]}`).Code)
+
+	assert.Equal(t, []string{"Ship it"}, titles(listTodos(t, r, "?assignee=carol")))
+	assert.Empty(t, listTodos(t, r, "?assignee=dave"))
+	assert.Equal(t, []string{"bob", "carol"}, listTodos(t, r, "")[0].Assignees)
+
+	require.Equal(t, http.StatusOK, serveAs(r, "carol", http.MethodPut, "/todos/1/assignees", `{"assignees":["carol"]}`).Code)
+	require.Equal(t, http.StatusCreated, serveAs(r, "carol", http.MethodPost, "/todos/1/comments", `{"body":"Done!"}`).Code)
+	require.Equal(t, http.StatusOK, serveAs(r, "carol", http.MethodPost, "/todos/1/complete", "").Code)
+
+	rec = serve(r, http.MethodGet, "/todos/1/activity", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response struct {
+		Data []models.Activity `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	var got []string
+	for _, a := range response.Data {
+		got = append(got, a.Actor+" "+a.Action+" "+a.Detail)
+	}
+	assert.Equal(t, []string{
+		"alice created ",
+		"alice assigned bob",
+		"bob assigned carol",
+		"carol unassigned bob",
+		"carol commented ",
+		"carol completed ",
+	}, got)
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/todos/9/activity", "").Code)
+}
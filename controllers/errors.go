@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sing3demons/go-example/store"
+)
+
+// errorStatus is the status for a store error that is not the caller's fault:
+// 503 while the circuit breaker is open so clients back off, 500 otherwise.
+func errorStatus(err error) int {
+	if errors.Is(err, store.ErrCircuitOpen) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/store"
+)
+
+// csvColumn maps a CSV column to a field of a record. Columns without set
+// are exported but ignored on import.
+type csvColumn struct {
+	name string
+	get  func(record any) string
+	set  func(record any, value string) error
+}
+
+// transferModel describes how a model is exported and imported.
+type transferModel struct {
+	// name is used for download file names.
+	name      string
+	newRecord func() any
+	columns   []csvColumn
+	// validate applies the model's binding rules to a record being imported.
+	validate func(record any) error
+	// hasID reports whether an imported record updates an existing one.
+	hasID func(record any) bool
+	id    func(record any) any
+	// load returns the stored record with the ID of record, which an
+	// imported row is merged into.
+	load func(db store.Storer, record any) (any, error)
+	// protect, when set, takes the fields only the server sets out of
+	// stored, the record a row updates or a new one, and returns a func
+	// putting them back into the record written.
+	protect func(stored any) func(record any)
+	// keep are the fields updates leave as stored; see store.BulkOp.
+	keep []string
+}
+
+var exportContentTypes = map[string]string{
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+	"csv":    "text/csv; charset=utf-8",
+}
+
+// recordEncoder writes records in one export format.
+type recordEncoder interface {
+	Begin() error
+	Encode(record any) error
+	End() error
+}
+
+// exportRecords handles GET /<resource>/export?format=json|ndjson|csv. The
+// records are streamed from the store and flushed as they are written, so
+// an export does not hold the whole collection in memory.
+func exportRecords(c *gin.Context, db store.Storer, m transferModel) {
+	format := c.DefaultQuery("format", "json")
+
+	var enc recordEncoder
+	switch format {
+	case "json":
+		enc = &jsonArrayEncoder{w: c.Writer}
+	case "ndjson":
+		enc = &ndjsonEncoder{enc: json.NewEncoder(c.Writer)}
+	case "csv":
+		enc = &csvEncoder{w: csv.NewWriter(c.Writer), columns: m.columns}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": `format must be "json", "ndjson" or "csv"`,
+		})
+		return
+	}
+
+	// The status is only sent with the first record, so that a store that
+	// fails straight away still gets an error response.
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", exportContentTypes[format])
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, m.name, format))
+		c.Status(http.StatusOK)
+		return enc.Begin()
+	}
+
+	record := m.newRecord()
+	n := 0
+	err := store.Each(db, record, func() error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+		if n++; n%100 == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err != nil {
+		if !started {
+			c.JSON(errorStatus(err), gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		// The status has been sent; the client sees a truncated body.
+		_ = c.Error(err)
+		return
+	}
+
+	if err := enc.End(); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+type jsonArrayEncoder struct {
+	w io.Writer
+	n int
+}
+
+func (e *jsonArrayEncoder) Begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonArrayEncoder) Encode(record any) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if e.n++; e.n > 1 {
+		b = append([]byte(","), b...)
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonArrayEncoder) End() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Begin() error { return nil }
+
+func (e *ndjsonEncoder) Encode(record any) error { return e.enc.Encode(record) }
+
+func (e *ndjsonEncoder) End() error { return nil }
+
+type csvEncoder struct {
+	w       *csv.Writer
+	columns []csvColumn
+}
+
+func (e *csvEncoder) Begin() error {
+	header := make([]string, len(e.columns))
+	for i, col := range e.columns {
+		header[i] = col.name
+	}
+	return e.w.Write(header)
+}
+
+func (e *csvEncoder) Encode(record any) error {
+	row := make([]string, len(e.columns))
+	for i, col := range e.columns {
+		row[i] = col.get(record)
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvEncoder) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
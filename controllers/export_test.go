@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTransferApp(db store.Storer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	productController := NewProductController(db, db, nil, nil)
+	todoController := NewTodoController(db, nil)
+
+	r := gin.New()
+	r.GET(pathProducts+"/export", productController.Export)
+	r.POST(pathProducts+"/import", productController.Import)
+	r.GET("/todos/export", todoController.Export)
+	r.POST("/todos/import", todoController.Import)
+
+	return r
+}
+
+func exportApp(t *testing.T) (*gin.Engine, models.Product) {
+	db := store.NewMemoryStore()
+	phone := models.Product{Name: "Phone", Price: money.New(50, "USD"), Description: "small, black"}
+	require.NoError(t, db.Create(&phone))
+	require.NoError(t, db.Create(&models.Product{Name: "Laptop", Price: money.New(100, "USD"), Description: "big"}))
+	return setupTransferApp(db), phone
+}
+
+func TestExportProducts(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		r, phone := exportApp(t)
+
+		rec := serve(r, http.MethodGet, pathProducts+"/export", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		var products []models.Product
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &products))
+		require.Len(t, products, 2)
+		assert.Equal(t, phone, products[0])
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		r, _ := exportApp(t)
+
+		rec := serve(r, http.MethodGet, pathProducts+"/export?format=ndjson", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		assert.Len(t, lines, 2)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		r, phone := exportApp(t)
+
+		rec := serve(r, http.MethodGet, pathProducts+"/export?format=csv", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename="products.csv"`, rec.Header().Get("Content-Disposition"))
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, "id,name,price,currency,description", lines[0])
+		assert.Equal(t, phone.ID.Hex()+`,Phone,50,USD,"small, black"`, lines[1])
+	})
+
+	t.Run("empty collection", func(t *testing.T) {
+		r := setupTransferApp(store.NewMemoryStore())
+
+		rec := serve(r, http.MethodGet, pathProducts+"/export", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "[]\n", rec.Body.String())
+
+		rec = serve(r, http.MethodGet, pathProducts+"/export?format=csv", "")
+		assert.Equal(t, "id,name,price,currency,description\n", rec.Body.String())
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		r := setupTransferApp(store.NewMemoryStore())
+
+		rec := serve(r, http.MethodGet, pathProducts+"/export?format=xml", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		r := setupTransferApp(&store.MockStore{Err: store.ErrCircuitOpen})
+
+		rec := serve(r, http.MethodGet, pathProducts+"/export", "")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+}
+
+func TestExportTodos(t *testing.T) {
+	db := store.NewMemoryStore()
+	require.NoError(t, db.Create(&models.Todo{Title: "Buy milk"}))
+	r := setupTransferApp(db)
+
+	rec := serve(r, http.MethodGet, "/todos/export?format=csv", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "id,title,completed,owner,due_at,reminder_minutes,rrule,list_id,parent_id,priority,position,created_at,updated_at\n1,Buy milk,false,,,0,,,,none,,"), rec.Body.String())
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/store"
+)
+
+// FaultController configures the FaultStore wrapped around each store, keyed
+// by name ("todos", "products").
+type FaultController struct {
+	stores map[string]*store.FaultStore
+}
+
+func NewFaultController(stores map[string]*store.FaultStore) *FaultController {
+	return &FaultController{stores}
+}
+
+type FaultRequest struct {
+	Latency   string  `json:"latency"`
+	ErrorRate float64 `json:"error_rate" binding:"gte=0,lte=1"`
+	Error     string  `json:"error"`
+	Partial   bool    `json:"partial"`
+}
+
+type FaultResponse struct {
+	Latency   string  `json:"latency"`
+	ErrorRate float64 `json:"error_rate"`
+	Error     string  `json:"error"`
+	Partial   bool    `json:"partial"`
+}
+
+// faultMethods are the methods FaultStore injects faults into.
+var faultMethods = map[string]bool{
+	"*": true, "Find": true, "First": true, "Create": true, "Save": true, "Update": true, "Delete": true,
+	"BulkWrite": true, "Each": true, "Facets": true, "Truncate": true,
+}
+
+func (f *FaultController) Index(c *gin.Context) {
+	data := gin.H{}
+	for name, s := range f.stores {
+		faults := map[string]FaultResponse{}
+		for method, fault := range s.Faults() {
+			faults[method] = FaultResponse{
+				Latency:   fault.Latency.String(),
+				ErrorRate: fault.ErrorRate,
+				Error:     fault.Err,
+				Partial:   fault.Partial,
+			}
+		}
+		data[name] = faults
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
+
+func (f *FaultController) Set(c *gin.Context) {
+	s, method, ok := f.target(c)
+	if !ok {
+		return
+	}
+
+	var req FaultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var latency time.Duration
+	if req.Latency != "" {
+		d, err := time.ParseDuration(req.Latency)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "latency must be a duration such as 250ms",
+			})
+			return
+		}
+		latency = d
+	}
+
+	s.Set(method, store.Fault{
+		Latency:   latency,
+		ErrorRate: req.ErrorRate,
+		Err:       req.Error,
+		Partial:   req.Partial,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": req,
+	})
+}
+
+func (f *FaultController) Clear(c *gin.Context) {
+	if c.Param("store") == "" {
+		for _, s := range f.stores {
+			s.Clear("")
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	s, method, ok := f.target(c)
+	if !ok {
+		return
+	}
+
+	s.Clear(method)
+	c.Status(http.StatusNoContent)
+}
+
+func (f *FaultController) target(c *gin.Context) (*store.FaultStore, string, bool) {
+	s, ok := f.stores[c.Param("store")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Store not found",
+		})
+		return nil, "", false
+	}
+
+	method := c.Param("method")
+	if !faultMethods[method] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "method must be one of *, Find, First, Create, Save, Update, Delete, BulkWrite, Each, Facets or Truncate",
+		})
+		return nil, "", false
+	}
+
+	return s, method, true
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	pathFaults = "/admin/faults"
+)
+
+func setupFaultApp() (*gin.Engine, *store.FaultStore) {
+	gin.SetMode(gin.TestMode)
+
+	products := store.NewFaultStore(store.NewMemoryStore())
+	faultController := NewFaultController(map[string]*store.FaultStore{"products": products})
+	productController := NewProductController(products, store.NewMemoryStore(), nil, nil)
+
+	r := gin.New()
+	r.GET(pathFaults, faultController.Index)
+	r.PUT(pathFaults+"/:store/:method", faultController.Set)
+	r.DELETE(pathFaults, faultController.Clear)
+	r.DELETE(pathFaults+"/:store/:method", faultController.Clear)
+	r.GET(pathProducts, productController.Find)
+
+	return r, products
+}
+
+func serve(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSetFault(t *testing.T) {
+	t.Run("Set fault success", func(t *testing.T) {
+		r, products := setupFaultApp()
+
+		rec := serve(r, http.MethodPut, pathFaults+"/products/Find", `{"error_rate":1,"error":"timeout","latency":"1ms"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1.0, products.Faults()["Find"].ErrorRate)
+
+		rec = serve(r, http.MethodGet, pathProducts, "")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		rec = serve(r, http.MethodGet, pathFaults, "")
+		var response struct {
+			Data map[string]map[string]FaultResponse `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "1ms", response.Data["products"]["Find"].Latency)
+	})
+
+	t.Run("Set fault unknown store", func(t *testing.T) {
+		r, _ := setupFaultApp()
+		rec := serve(r, http.MethodPut, pathFaults+"/orders/Find", `{}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Set fault invalid method", func(t *testing.T) {
+		r, _ := setupFaultApp()
+		rec := serve(r, http.MethodPut, pathFaults+"/products/Drop", `{}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Set fault on conditional updates", func(t *testing.T) {
+		r, products := setupFaultApp()
+		product := models.Product{Name: "Lamp"}
+		assert.NoError(t, products.Create(&product))
+
+		rec := serve(r, http.MethodPut, pathFaults+"/products/Update", `{"error_rate":1,"error":"not_found"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		err := store.Update(products, &models.Product{}, map[string]any{"name": "Desk lamp"}, bson.M{"_id": product.ID})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.NoError(t, products.First(&product, bson.M{"_id": product.ID}), "other methods are left alone")
+		assert.Equal(t, "Lamp", product.Name)
+	})
+
+	t.Run("Set fault invalid body", func(t *testing.T) {
+		r, _ := setupFaultApp()
+
+		rec := serve(r, http.MethodPut, pathFaults+"/products/Find", `{"error_rate":2}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = serve(r, http.MethodPut, pathFaults+"/products/Find", `{"latency":"soon"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestClearFault(t *testing.T) {
+	r, products := setupFaultApp()
+	products.Set("Find", store.Fault{ErrorRate: 1})
+	products.Set("Save", store.Fault{ErrorRate: 1})
+
+	rec := serve(r, http.MethodDelete, pathFaults+"/products/Find", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, pathProducts, "").Code)
+
+	rec = serve(r, http.MethodDelete, pathFaults, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, products.Faults())
+}
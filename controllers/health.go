@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/store"
+)
+
+type HealthController struct {
+	breakers map[string]*store.Breaker
+}
+
+func NewHealthController(breakers map[string]*store.Breaker) *HealthController {
+	return &HealthController{breakers}
+}
+
+func (h *HealthController) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+// Ready fails while any store's circuit breaker is open, so load balancers
+// stop sending traffic until the database recovers.
+func (h *HealthController) Ready(c *gin.Context) {
+	status := http.StatusOK
+	breakers := gin.H{}
+	for name, b := range h.breakers {
+		state := b.State()
+		if state == store.BreakerOpen {
+			status = http.StatusServiceUnavailable
+		}
+		breakers[name] = state
+	}
+
+	message := "ready"
+	if status != http.StatusOK {
+		message = "unavailable"
+	}
+
+	c.JSON(status, gin.H{
+		"status":   message,
+		"breakers": breakers,
+	})
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupHealthApp(breakers map[string]*store.Breaker, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+
+	healthController := NewHealthController(breakers)
+
+	r := gin.New()
+	r.GET("/healthz", healthController.Live)
+	r.GET("/readyz", healthController.Ready)
+
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("Live", func(t *testing.T) {
+		rec := setupHealthApp(nil, "/healthz")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Ready with closed breakers", func(t *testing.T) {
+		rec := setupHealthApp(map[string]*store.Breaker{
+			"todos": store.NewBreaker(1, time.Minute),
+		}, "/readyz")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var response struct {
+			Status   string            `json:"status"`
+			Breakers map[string]string `json:"breakers"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "ready", response.Status)
+		assert.Equal(t, "closed", response.Breakers["todos"])
+	})
+
+	t.Run("Not ready with an open breaker", func(t *testing.T) {
+		open := store.NewBreaker(1, time.Minute)
+		open.Record(context.DeadlineExceeded)
+
+		rec := setupHealthApp(map[string]*store.Breaker{
+			"todos":    store.NewBreaker(1, time.Minute),
+			"products": open,
+		}, "/readyz")
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+}
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/blob"
+	"github.com/sing3demons/go-example/imaging"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxImageBytes = 10 << 20
+	// maxImagePixels bounds the memory a decoded image takes, whatever the
+	// size of its file.
+	maxImagePixels = 25_000_000
+	thumbnailSize  = 256
+)
+
+// UploadImage handles POST /products/:id/images, a multipart form with the
+// image in its "image" field. The type is sniffed from the contents; JPEG,
+// PNG and GIF images of up to 10 MiB are stored with a thumbnail.
+func (p *ProductController) UploadImage(c *gin.Context) {
+	product, ok := loadProduct(c, p.db)
+	if !ok {
+		return
+	}
+
+	// The form around the image gets a little room of its own.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageBytes+64<<10)
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"message": fmt.Sprintf("image: larger than %d bytes", maxImageBytes),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "image: a multipart file field is required",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if header.Size > maxImageBytes || len(data) > maxImageBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"message": fmt.Sprintf("image: larger than %d bytes", maxImageBytes),
+		})
+		return
+	}
+
+	contentType, err := imaging.Sniff(data)
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"message": fmt.Sprintf("image: %s is not a JPEG, PNG or GIF image", contentType),
+		})
+		return
+	}
+	img, err := imaging.Decode(data, maxImagePixels)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var thumbnail bytes.Buffer
+	if err := imaging.Encode(&thumbnail, imaging.Thumbnail(img, thumbnailSize), contentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	id := primitive.NewObjectID().Hex()
+	prefix := "products/" + product.ID.Hex() + "/" + id
+	image := models.ProductImage{
+		ID:           id,
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        img.Bounds().Dx(),
+		Height:       img.Bounds().Dy(),
+		Key:          prefix + imaging.Types[contentType],
+		ThumbnailKey: prefix + "_thumb" + imaging.Types[imaging.ThumbnailType(contentType)],
+		CreatedAt:    time.Now(),
+	}
+	image.URL, image.ThumbnailURL = p.blobs.URL(image.Key), p.blobs.URL(image.ThumbnailKey)
+
+	ctx := c.Request.Context()
+	err = p.blobs.Put(ctx, image.Key, bytes.NewReader(data))
+	if err == nil {
+		err = p.blobs.Put(ctx, image.ThumbnailKey, &thumbnail)
+	}
+	if err == nil {
+		err = p.updateImages(product, func(images []models.ProductImage) []models.ProductImage {
+			return append(images, image)
+		})
+	}
+	if err != nil {
+		p.removeImages(image)
+		imageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": image,
+	})
+}
+
+// DeleteImage handles DELETE /products/:id/images/:image_id.
+func (p *ProductController) DeleteImage(c *gin.Context) {
+	product, ok := loadProduct(c, p.db)
+	if !ok {
+		return
+	}
+
+	var removed []models.ProductImage
+	err := p.updateImages(product, func(images []models.ProductImage) []models.ProductImage {
+		kept := make([]models.ProductImage, 0, len(images))
+		removed = nil
+		for _, image := range images {
+			if image.ID == c.Param("image_id") {
+				removed = append(removed, image)
+			} else {
+				kept = append(kept, image)
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		imageError(c, err)
+		return
+	}
+	if len(removed) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Image not found",
+		})
+		return
+	}
+	p.removeImages(removed...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": removed[0],
+	})
+}
+
+// maxImageUpdates bounds the attempts of updateImages.
+const maxImageUpdates = 10
+
+// errImagesContended is returned by updateImages when the images of a
+// product keep changing under it.
+var errImagesContended = errors.New("images: the product's images keep changing, try again")
+
+// updateImages sets the images of product to change(images), where images
+// are the stored ones. The update only applies while the stored images are
+// still those change was given, and is retried with the new ones otherwise,
+// so concurrent uploads and deletes never overwrite each other or the rest
+// of the product.
+func (p *ProductController) updateImages(product models.Product, change func([]models.ProductImage) []models.ProductImage) error {
+	for attempt := 0; attempt < maxImageUpdates; attempt++ {
+		images := change(product.Images)
+		if images == nil {
+			images = []models.ProductImage{}
+		}
+		err := store.Update(p.db, &models.Product{}, map[string]any{"images": images}, bson.M{"_id": product.ID, "images": product.Images})
+		if !store.IsNotFound(err) {
+			return err
+		}
+		if err := p.db.First(&product, bson.M{"_id": product.ID}); err != nil {
+			return err
+		}
+	}
+	return errImagesContended
+}
+
+// imageError answers a failed updateImages.
+func imageError(c *gin.Context, err error) {
+	switch {
+	case store.IsNotFound(err):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Product not found",
+		})
+	case errors.Is(err, errImagesContended):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+	}
+}
+
+// ServeImage handles GET /images/*key, the URLs of images kept in local
+// blob storage.
+func (p *ProductController) ServeImage(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !strings.HasPrefix(key, "products/") {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Image not found",
+		})
+		return
+	}
+
+	r, err := p.blobs.Open(c.Request.Context(), key)
+	if err != nil {
+		status := errorStatus(err)
+		if errors.Is(err, blob.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	defer r.Close()
+
+	// Keys are never reused, so an image never changes.
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.DataFromReader(http.StatusOK, -1, mime.TypeByExtension(path.Ext(key)), r, nil)
+}
+
+// removeImages deletes the blobs of images. The images are already gone
+// from the product, so a blob that cannot be deleted is only logged.
+func (p *ProductController) removeImages(images ...models.ProductImage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, image := range images {
+		for _, key := range []string{image.Key, image.ThumbnailKey} {
+			if err := p.blobs.Delete(ctx, key); err != nil {
+				log.Printf("images: delete %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// Delete handles DELETE /products/:id, removing the product's images too.
+func (p *ProductController) Delete(c *gin.Context) {
+	product, ok := loadProduct(c, p.db)
+	if !ok {
+		return
+	}
+
+	if err := store.Delete(p.db, &product); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	p.removeImages(product.Images...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": product,
+	})
+}
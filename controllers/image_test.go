@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/blob"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func setupImageApp(t *testing.T) (*gin.Engine, string) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	blobs, err := blob.NewLocal(dir, "/images")
+	require.NoError(t, err)
+	productController := NewProductController(store.NewMemoryStore(), store.NewMemoryStore(), blobs, nil)
+
+	r := gin.New()
+	r.GET(pathProducts+"/:id", productController.FindOne)
+	r.POST(pathProducts, productController.Create)
+	r.DELETE(pathProducts+"/:id", productController.Delete)
+	r.POST(pathProducts+"/bulk", productController.Bulk)
+	r.POST(pathProducts+"/:id/images", productController.UploadImage)
+	r.DELETE(pathProducts+"/:id/images/:image_id", productController.DeleteImage)
+	r.GET("/images/*key", productController.ServeImage)
+
+	return r, dir
+}
+
+func testImage(t *testing.T, contentType string, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	} else {
+		require.NoError(t, png.Encode(&buf, img))
+	}
+	return buf.Bytes()
+}
+
+func uploadImage(r *gin.Engine, productID string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("image", "upload.bin")
+	fw.Write(data)
+	mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, pathProducts+"/"+productID+"/images", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProductImages(t *testing.T) {
+	r, dir := setupImageApp(t)
+
+	rec := serve(r, http.MethodPost, pathProducts, `{"name":"Lamp","price":{"amount":4500,"currency":"USD"},"description":"Desk lamp","images":[{"url":"/elsewhere"}]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Empty(t, created.Data.Images, "images are only added by uploading them")
+	productID := created.Data.ID.Hex()
+
+	var uploaded []models.ProductImage
+	for _, contentType := range []string{"image/png", "image/jpeg"} {
+		rec := uploadImage(r, productID, testImage(t, contentType, 1024, 512))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var response struct {
+			Data models.ProductImage `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		img := response.Data
+		assert.Equal(t, contentType, img.ContentType)
+		assert.Equal(t, 1024, img.Width)
+		assert.Equal(t, 512, img.Height)
+		uploaded = append(uploaded, img)
+
+		rec = serve(r, http.MethodGet, img.URL, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+
+		rec = serve(r, http.MethodGet, img.ThumbnailURL, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		thumbnail, _, err := image.Decode(bytes.NewReader(rec.Body.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 256, 128), thumbnail.Bounds(), "thumbnails keep the aspect ratio")
+	}
+
+	var product struct {
+		Data models.Product `json:"data"`
+	}
+	rec = serve(r, http.MethodGet, pathProducts+"/"+productID, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+	require.Len(t, product.Data.Images, 2)
+
+	t.Run("rejected uploads", func(t *testing.T) {
+		rec := uploadImage(r, productID, []byte("just some text, not an image"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+		rec = uploadImage(r, productID, append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "a PNG header alone is not an image")
+
+		rec = uploadImage(r, productID, bytes.Repeat([]byte{0}, maxImageBytes+1))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		rec = uploadImage(r, "000000000000000000000000", testImage(t, "image/png", 8, 8))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/images/elsewhere/x.png", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/images/products/nope.png", "").Code)
+	})
+
+	t.Run("deleting an image removes its files", func(t *testing.T) {
+		rec := serve(r, http.MethodDelete, pathProducts+"/"+productID+"/images/"+uploaded[0].ID, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, uploaded[0].URL, "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, uploaded[0].ThumbnailURL, "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodDelete, pathProducts+"/"+productID+"/images/"+uploaded[0].ID, "").Code)
+
+		rec = serve(r, http.MethodGet, pathProducts+"/"+productID, "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+		require.Len(t, product.Data.Images, 1)
+		assert.Equal(t, uploaded[1].ID, product.Data.Images[0].ID)
+	})
+
+	t.Run("bulk updates keep the images", func(t *testing.T) {
+		rec := serve(r, http.MethodPost, pathProducts+"/bulk", `[{"op":"update","id":"`+productID+`","name":"Floor lamp","price":{"amount":9000,"currency":"USD"},"description":"Tall","images":[]}]`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = serve(r, http.MethodGet, pathProducts+"/"+productID, "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+		assert.Equal(t, "Floor lamp", product.Data.Name)
+		assert.Len(t, product.Data.Images, 1)
+	})
+
+	t.Run("deleting the product removes its images", func(t *testing.T) {
+		rec := serve(r, http.MethodDelete, pathProducts+"/"+productID, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, uploaded[1].URL, "").Code)
+
+		_, err := os.Stat(filepath.Join(dir, "products", productID))
+		assert.True(t, os.IsNotExist(err), "empty directories are removed")
+	})
+}
+
+func TestProductImagesConcurrentUploads(t *testing.T) {
+	r, _ := setupImageApp(t)
+	rec := serve(r, http.MethodPost, pathProducts, `{"name":"Lamp","price":{"amount":4500,"currency":"USD"},"description":"Desk lamp"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	productID := created.Data.ID.Hex()
+
+	data := testImage(t, "image/png", 16, 16)
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = uploadImage(r, productID, data).Code
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, []int{201, 201, 201, 201, 201}, codes)
+
+	var product struct {
+		Data models.Product `json:"data"`
+	}
+	rec = serve(r, http.MethodGet, pathProducts+"/"+productID, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+	assert.Len(t, product.Data.Images, 5, "no upload overwrites another")
+}
+
+// uploadedAfterRead is a product store in which an image is uploaded right
+// after the next product is read.
+type uploadedAfterRead struct {
+	store.Storer
+	upload func()
+}
+
+func (s *uploadedAfterRead) First(dest any, conds ...any) error {
+	err := s.Storer.First(dest, conds...)
+	if upload := s.upload; upload != nil {
+		s.upload = nil
+		upload()
+	}
+	return err
+}
+
+func (s *uploadedAfterRead) Update(model any, set map[string]any, conds ...any) error {
+	return store.Update(s.Storer, model, set, conds...)
+}
+
+func (s *uploadedAfterRead) BulkWrite(ops []store.BulkOp, opts store.BulkOptions) ([]error, error) {
+	return store.BulkWrite(s.Storer, ops, opts)
+}
+
+func TestProductEditsKeepImagesUploadedMeanwhile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	blobs, err := blob.NewLocal(t.TempDir(), "/images")
+	require.NoError(t, err)
+	products := &uploadedAfterRead{Storer: store.NewMemoryStore()}
+	productController := NewProductController(products, store.NewMemoryStore(), blobs, nil)
+
+	r := gin.New()
+	r.GET(pathProducts+"/:id", productController.FindOne)
+	r.POST(pathProducts+"/:id/images", productController.UploadImage)
+	r.PUT(pathProducts+"/:id/categories", productController.SetCategories)
+	r.POST(pathProducts+"/bulk", productController.Bulk)
+	r.POST(pathProducts+"/import", productController.Import)
+
+	product := models.Product{Name: "Lamp", Price: money.New(4500, "USD"), Description: "Desk lamp"}
+	require.NoError(t, products.Create(&product))
+	productID := product.ID.Hex()
+	data := testImage(t, "image/png", 16, 16)
+
+	for _, edit := range []struct {
+		name string
+		run  func() *httptest.ResponseRecorder
+	}{
+		{"categories", func() *httptest.ResponseRecorder {
+			return serve(r, http.MethodPut, pathProducts+"/"+productID+"/categories", `{"category_ids":[]}`)
+		}},
+		{"bulk", func() *httptest.ResponseRecorder {
+			return serve(r, http.MethodPost, pathProducts+"/bulk", `[{"op":"update","id":"`+productID+`","name":"Floor lamp"}]`)
+		}},
+		{"import", func() *httptest.ResponseRecorder {
+			rec, _ := postImport(r, pathProducts+"/import", "text/csv", "id,name\n"+productID+",Big lamp\n")
+			return rec
+		}},
+	} {
+		products.upload = func() {
+			require.Equal(t, http.StatusCreated, uploadImage(r, productID, data).Code)
+		}
+		rec := edit.run()
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Nil(t, products.upload, edit.name)
+	}
+
+	var stored models.Product
+	require.NoError(t, products.Storer.First(&stored, bson.M{"_id": product.ID}))
+	assert.Len(t, stored.Images, 3, "no edit drops an image uploaded while it ran")
+	assert.Equal(t, "Big lamp", stored.Name)
+}
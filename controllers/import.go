@@ -0,0 +1,370 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sing3demons/go-example/store"
+)
+
+const importBatchSize = 500
+
+type ImportRowError struct {
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+type ImportReport struct {
+	Created        int              `json:"created"`
+	Updated        int              `json:"updated"`
+	Failed         int              `json:"failed"`
+	Errors         []ImportRowError `json:"errors"`
+	IgnoredColumns []string         `json:"ignored_columns,omitempty"`
+}
+
+// importer validates rows one at a time and writes the valid ones in
+// batches, keeping a row-level report.
+type importer struct {
+	db     store.Storer
+	m      transferModel
+	report ImportReport
+	failed map[int]bool
+
+	ops   []store.BulkOp
+	lines []int
+}
+
+func newImporter(db store.Storer, m transferModel) *importer {
+	return &importer{db: db, m: m, failed: map[int]bool{}, report: ImportReport{Errors: []ImportRowError{}}}
+}
+
+func (im *importer) fail(line int, column string, err error) {
+	im.report.Errors = append(im.report.Errors, ImportRowError{Line: line, Column: column, Error: err.Error()})
+	if !im.failed[line] {
+		im.failed[line] = true
+		im.report.Failed++
+	}
+}
+
+// add validates a decoded record and queues it for writing.
+func (im *importer) add(line int, record any) error {
+	if err := im.m.validate(record); err != nil {
+		var fieldErrs validator.ValidationErrors
+		var colErr *columnError
+		if errors.As(err, &colErr) {
+			im.fail(line, colErr.column, colErr.err)
+			return nil
+		}
+		if !errors.As(err, &fieldErrs) {
+			im.fail(line, "", err)
+			return nil
+		}
+		for _, fe := range fieldErrs {
+			im.fail(line, strings.ToLower(fe.Field()), ruleError(fe))
+		}
+		return nil
+	}
+
+	kind := store.BulkCreate
+	if im.m.hasID(record) {
+		kind = store.BulkUpdate
+	}
+	im.ops = append(im.ops, store.BulkOp{Kind: kind, Value: record, Keep: im.m.keep})
+	im.lines = append(im.lines, line)
+
+	if len(im.ops) == importBatchSize {
+		return im.flush()
+	}
+	return nil
+}
+
+func (im *importer) flush() error {
+	if len(im.ops) == 0 {
+		return nil
+	}
+
+	errs, err := store.BulkWrite(im.db, im.ops, store.BulkOptions{})
+	if errs == nil {
+		return err
+	}
+	for k, op := range im.ops {
+		switch {
+		case errs[k] == nil && op.Kind == store.BulkCreate:
+			im.report.Created++
+		case errs[k] == nil:
+			im.report.Updated++
+		case store.IsNotFound(errs[k]):
+			im.fail(im.lines[k], "id", errors.New("no record has this id"))
+		default:
+			im.fail(im.lines[k], "", errs[k])
+		}
+	}
+
+	im.ops, im.lines = im.ops[:0], im.lines[:0]
+	return nil
+}
+
+// columnError is a validation error that belongs to one column.
+type columnError struct {
+	column string
+	err    error
+}
+
+func (e *columnError) Error() string { return e.err.Error() }
+
+func ruleError(fe validator.FieldError) error {
+	if fe.Tag() == "required" {
+		return errors.New("is required")
+	}
+	if fe.Param() != "" {
+		return fmt.Errorf("must satisfy %s=%s", fe.Tag(), fe.Param())
+	}
+	return fmt.Errorf("must satisfy %s", fe.Tag())
+}
+
+// importRecords handles POST /<resource>/import. The body is CSV with a
+// header row, or NDJSON when sent as application/x-ndjson. CSV headers are
+// matched to columns by name; ?map[Header]=column renames a header first.
+// Rows with an id update the record, keeping the fields the row does not
+// have, and other rows create one. Valid rows are
+// written even when others fail, and every failure is listed by line.
+func importRecords(c *gin.Context, db store.Storer, m transferModel) {
+	im := newImporter(db, m)
+
+	var err error
+	switch c.ContentType() {
+	case "application/x-ndjson", "application/ndjson":
+		err = im.readNDJSON(c.Request.Body)
+	default:
+		err = im.readCSV(c.Request.Body, c.QueryMap("map"))
+	}
+	im.respond(c, err)
+}
+
+// respond writes the remaining rows and the report. err is the error that
+// stopped reading, if any.
+func (im *importer) respond(c *gin.Context, err error) {
+	if err == nil {
+		err = im.flush()
+	}
+
+	var badRequest *importRequestError
+	switch {
+	case errors.As(err, &badRequest):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+			"data":    im.report,
+		})
+		return
+	}
+
+	status := http.StatusOK
+	if im.report.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{
+		"data": im.report,
+	})
+}
+
+// importRequestError rejects the whole request before any row is written.
+type importRequestError struct {
+	msg string
+}
+
+func (e *importRequestError) Error() string { return e.msg }
+
+func (im *importer) readCSV(r io.Reader, mapping map[string]string) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return &importRequestError{"the CSV has no header row"}
+	}
+	if err != nil {
+		return &importRequestError{err.Error()}
+	}
+
+	columns, err := im.mapColumns(header, mapping)
+	if err != nil {
+		return err
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			im.fail(parseErr.StartLine, "", parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := cr.FieldPos(0)
+		record := im.m.newRecord()
+		if !im.setColumns(line, record, columns, row) {
+			continue
+		}
+		// Columns the file does not have keep their stored values.
+		record, err = im.merge(record, func(stored any) error {
+			im.setColumns(line, stored, columns, row)
+			return nil
+		})
+		if err != nil {
+			im.fail(line, "", err)
+			continue
+		}
+		if err := im.add(line, record); err != nil {
+			return err
+		}
+	}
+}
+
+// setColumns sets the fields of record from a CSV row, skipping empty
+// cells, and reports whether every cell was valid.
+func (im *importer) setColumns(line int, record any, columns []*csvColumn, row []string) bool {
+	valid := true
+	for i, value := range row {
+		if i >= len(columns) || columns[i] == nil {
+			continue
+		}
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		if err := columns[i].set(record, value); err != nil {
+			im.fail(line, columns[i].name, err)
+			valid = false
+		}
+	}
+	return valid
+}
+
+// merge returns the record an imported row writes. A row with the ID of a
+// stored record is applied to that record by apply; other rows, including
+// those whose record does not exist, which the write reports, are written
+// as decoded. Either way the fields protect covers keep the values of the
+// stored or a new record.
+func (im *importer) merge(record any, apply func(stored any) error) (any, error) {
+	base, found := im.m.newRecord(), false
+	if im.m.hasID(record) {
+		stored, err := im.m.load(im.db, record)
+		switch {
+		case err == nil:
+			base, found = stored, true
+		case !store.IsNotFound(err):
+			return nil, err
+		}
+	}
+
+	restore := func(any) {}
+	if im.m.protect != nil {
+		restore = im.m.protect(base)
+	}
+	if found {
+		if err := apply(base); err != nil {
+			return nil, err
+		}
+		record = base
+	}
+	restore(record)
+	return record, nil
+}
+
+// mapColumns returns the column of every header field, nil for fields that
+// are ignored.
+func (im *importer) mapColumns(header []string, mapping map[string]string) ([]*csvColumn, error) {
+	for _, target := range mapping {
+		if im.column(target) == nil {
+			return nil, &importRequestError{fmt.Sprintf("map: unknown column %q", target)}
+		}
+	}
+
+	columns := make([]*csvColumn, len(header))
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		target := name
+		if mapped, ok := mapping[name]; ok {
+			target = mapped
+		}
+
+		col := im.column(target)
+		if col == nil || col.set == nil {
+			im.report.IgnoredColumns = append(im.report.IgnoredColumns, name)
+			continue
+		}
+		if seen[col.name] {
+			return nil, &importRequestError{fmt.Sprintf("column %q appears twice", col.name)}
+		}
+		seen[col.name] = true
+		columns[i] = col
+	}
+	sort.Strings(im.report.IgnoredColumns)
+	return columns, nil
+}
+
+func (im *importer) column(name string) *csvColumn {
+	for i := range im.m.columns {
+		if strings.EqualFold(im.m.columns[i].name, name) {
+			return &im.m.columns[i]
+		}
+	}
+	return nil
+}
+
+// addJSON decodes an NDJSON line, merged into the stored record when it
+// has an ID, and queues it.
+func (im *importer) addJSON(line int, raw []byte) error {
+	record := im.m.newRecord()
+	if err := json.Unmarshal(raw, record); err != nil {
+		im.fail(line, "", err)
+		return nil
+	}
+	record, err := im.merge(record, func(stored any) error {
+		return mergeJSON(stored, raw)
+	})
+	if err != nil {
+		im.fail(line, "", err)
+		return nil
+	}
+	return im.add(line, record)
+}
+
+func (im *importer) readNDJSON(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		raw, err := br.ReadBytes('\n')
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 {
+			if err := im.addJSON(line, raw); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
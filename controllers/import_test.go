@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func postImport(r *gin.Engine, path, contentType, body string) (*httptest.ResponseRecorder, ImportReport) {
+	req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var response struct {
+		Data ImportReport `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec, response.Data
+}
+
+func TestImportProducts(t *testing.T) {
+	t.Run("csv with a row-level report", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		existing := models.Product{Name: "Laptop", Price: money.New(100, "USD"), Description: "big"}
+		require.NoError(t, db.Create(&existing))
+		r := setupTransferApp(db)
+
+		rec, report := postImport(r, pathProducts+"/import", "text/csv", "\ufeffid,name,price,currency,description,notes\n"+
+			",Phone,50,usd,\"small, black\",x\n"+
+			existing.ID.Hex()+",Laptop,90,USD,cheaper,\n"+
+			",Tablet,abc,USD,flat,\n"+
+			",,10,,,\n"+
+			"000000000000000000000001,Ghost,1,USD,gone,\n"+
+			",Pen,5,XYZ,blue,\n")
+
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Updated)
+		assert.Equal(t, 4, report.Failed)
+		assert.Equal(t, []string{"notes"}, report.IgnoredColumns)
+		assert.Equal(t, []ImportRowError{
+			{Line: 4, Column: "price", Error: "must be a whole number"},
+			{Line: 5, Column: "name", Error: "is required"},
+			{Line: 5, Column: "currency", Error: "is required"},
+			{Line: 5, Column: "description", Error: "is required"},
+			{Line: 7, Column: "currency", Error: `unsupported currency "XYZ", must be one of CHF, EUR, GBP, JPY, KWD, SGD, THB, USD`},
+			{Line: 6, Column: "id", Error: "no record has this id"},
+		}, report.Errors)
+
+		var laptop models.Product
+		require.NoError(t, db.First(&laptop, existing.ID))
+		assert.Equal(t, int64(90), laptop.Price.Amount)
+	})
+
+	t.Run("column mapping", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		r := setupTransferApp(db)
+
+		rec, report := postImport(r, pathProducts+"/import?map[Product Name]=name&map[Cost]=price", "text/csv",
+			"Product Name,Cost,Currency,Description\nPhone,50,THB,small\n")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, report.Created)
+
+		var products []models.Product
+		require.NoError(t, db.Find(&products))
+		require.Len(t, products, 1)
+		assert.Equal(t, "Phone", products[0].Name)
+		assert.Equal(t, money.New(50, "THB"), products[0].Price)
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		r := setupTransferApp(store.NewMemoryStore())
+
+		rec, report := postImport(r, pathProducts+"/import", "application/x-ndjson",
+			"{\"name\":\"Phone\",\"price\": {\"amount\": 50, \"currency\": \"USD\"},\"description\":\"small\"}\n\n{oops\n")
+
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, 1, report.Created)
+		require.Len(t, report.Errors, 1)
+		assert.Equal(t, 3, report.Errors[0].Line)
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		r := setupTransferApp(store.NewMemoryStore())
+
+		rec, _ := postImport(r, pathProducts+"/import", "text/csv", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, _ = postImport(r, pathProducts+"/import?map[Cost]=cost", "text/csv", "Cost\n1\n")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec, _ = postImport(r, pathProducts+"/import?map[Title]=name", "text/csv", "name,Title\na,b\n")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("export round trip", func(t *testing.T) {
+		r, phone := exportApp(t)
+		exported := serve(r, http.MethodGet, pathProducts+"/export?format=csv", "").Body.String()
+
+		rec, report := postImport(r, pathProducts+"/import", "text/csv", exported)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, ImportReport{Updated: 2, Errors: []ImportRowError{}}, report)
+		assert.Contains(t, exported, phone.ID.Hex())
+	})
+
+	t.Run("re-import keeps the fields the file does not have", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		lighting := models.Category{Name: "Lighting"}
+		require.NoError(t, db.Create(&lighting))
+		lamp := models.Product{
+			Name:        "Lamp",
+			Price:       money.New(100, "USD"),
+			Description: "desk",
+			CategoryIDs: []primitive.ObjectID{lighting.ID},
+			Tags:        []string{"home"},
+			Attributes:  models.Attributes{"color": "red"},
+			Images:      []models.ProductImage{{ID: "a"}},
+		}
+		require.NoError(t, db.Create(&lamp))
+		r := setupTransferApp(db)
+
+		for _, format := range []string{"csv", "ndjson"} {
+			exported := serve(r, http.MethodGet, pathProducts+"/export?format="+format, "").Body.String()
+			contentType := "text/csv"
+			if format == "ndjson" {
+				contentType = "application/x-ndjson"
+			}
+			rec, report := postImport(r, pathProducts+"/import", contentType, exported)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Equal(t, 1, report.Updated, format)
+
+			var product models.Product
+			require.NoError(t, db.First(&product, bson.M{"_id": lamp.ID}))
+			assert.Equal(t, lamp.CategoryIDs, product.CategoryIDs, format)
+			assert.Equal(t, lamp.Tags, product.Tags, format)
+			assert.Equal(t, lamp.Attributes, product.Attributes, format)
+			assert.Equal(t, lamp.Images, product.Images, format)
+		}
+
+		rec, _ := postImport(r, pathProducts+"/import", "text/csv", "id,price\n"+lamp.ID.Hex()+",80\n")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var product models.Product
+		require.NoError(t, db.First(&product, bson.M{"_id": lamp.ID}))
+		assert.Equal(t, int64(80), product.Price.Amount)
+		assert.Equal(t, "desk", product.Description)
+	})
+
+	t.Run("images are not imported", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		lamp := models.Product{Name: "Lamp", Price: money.New(100, "USD"), Description: "desk", Images: []models.ProductImage{{ID: "a", URL: "/images/a.png"}}}
+		require.NoError(t, db.Create(&lamp))
+		r := setupTransferApp(db)
+
+		rec, report := postImport(r, pathProducts+"/import", "application/x-ndjson",
+			`{"id": "`+lamp.ID.Hex()+`", "images": [{"id": "b", "url": "/elsewhere"}]}`+"\n"+
+				`{"name": "Mug", "price": {"amount": 5, "currency": "USD"}, "description": "tea", "images": [{"id": "c", "url": "/elsewhere"}]}`+"\n")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, 1, report.Updated)
+		assert.Equal(t, 1, report.Created)
+
+		var stored models.Product
+		require.NoError(t, db.First(&stored, bson.M{"_id": lamp.ID}))
+		assert.Equal(t, lamp.Images, stored.Images)
+		require.NoError(t, db.First(&stored, bson.M{"name": "Mug"}))
+		assert.Empty(t, stored.Images)
+	})
+
+	t.Run("tags are normalized and categories must exist", func(t *testing.T) {
+		db := store.NewMemoryStore()
+		r := setupTransferApp(db)
+
+		rec, report := postImport(r, pathProducts+"/import", "application/x-ndjson",
+			`{"name": "Mug", "price": {"amount": 5, "currency": "USD"}, "description": "tea", "tags": [" Kitchen", "kitchen", ""]}`+"\n"+
+				`{"name": "Cup", "price": {"amount": 4, "currency": "USD"}, "description": "tea", "category_ids": ["000000000000000000000001"]}`+"\n")
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, []ImportRowError{
+			{Line: 2, Column: "category_ids", Error: "category_ids: no category has id 000000000000000000000001"},
+		}, report.Errors)
+
+		var products []models.Product
+		require.NoError(t, db.Find(&products))
+		require.Len(t, products, 1)
+		assert.Equal(t, []string{"kitchen"}, products[0].Tags)
+	})
+}
+
+func TestImportTodos(t *testing.T) {
+	db := store.NewMemoryStore()
+	r := setupTransferApp(db)
+
+	rec, report := postImport(r, "/todos/import", "text/csv",
+		"title,completed,created_at\nBuy milk,true,2020-01-01T00:00:00Z\nWalk,maybe,\n")
+
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, []string{"created_at"}, report.IgnoredColumns)
+	assert.Equal(t, []ImportRowError{{Line: 3, Column: "completed", Error: "must be true or false"}}, report.Errors)
+
+	var todos []models.Todo
+	require.NoError(t, db.Find(&todos))
+	require.Len(t, todos, 1)
+	assert.True(t, todos[0].Completed)
+}
+
+func TestImportTodosKeepsUnmappedFields(t *testing.T) {
+	db := store.NewMemoryStore()
+	series := uint(3)
+	due := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+	todo := models.Todo{Title: "Water plants", Owner: "alice", DueAt: &due, RRule: "FREQ=WEEKLY", SeriesID: &series, Occurrence: 2, NextMaterialized: true}
+	require.NoError(t, db.Create(&todo))
+	r := setupTransferApp(db)
+
+	for _, format := range []string{"csv", "ndjson"} {
+		exported := serve(r, http.MethodGet, "/todos/export?format="+format, "").Body.String()
+		contentType := "text/csv"
+		if format == "ndjson" {
+			contentType = "application/x-ndjson"
+		}
+		rec, report := postImport(r, "/todos/import", contentType, exported)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, 1, report.Updated, format)
+	}
+
+	// Series fields in a file neither move a todo between series nor copy
+	// one into a new todo.
+	rec, report := postImport(r, "/todos/import", "application/x-ndjson",
+		`{"ID":`+strconv.Itoa(int(todo.ID))+`,"series_id":9,"occurrence":5,"next_materialized":false}`+"\n"+
+			`{"title":"Copy","series_id":3,"occurrence":2}`+"\n")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 1, report.Created)
+	var copied models.Todo
+	require.NoError(t, db.First(&copied, "title = ?", "Copy"))
+	assert.Nil(t, copied.SeriesID)
+	assert.Zero(t, copied.Occurrence)
+
+	rec, _ = postImport(r, "/todos/import", "text/csv", "id,completed\n"+strconv.Itoa(int(todo.ID))+",true\n")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var updated models.Todo
+	require.NoError(t, db.First(&updated, todo.ID))
+	assert.True(t, updated.Completed)
+	assert.Equal(t, "Water plants", updated.Title)
+	assert.Equal(t, "alice", updated.Owner)
+	assert.Equal(t, "FREQ=WEEKLY", updated.RRule)
+	assert.Equal(t, &series, updated.SeriesID)
+	assert.Equal(t, 2, updated.Occurrence)
+	assert.True(t, updated.NextMaterialized)
+}
+
+func TestImportTodosOwner(t *testing.T) {
+	db := store.NewMemoryStore()
+	todo := models.Todo{Title: "Water plants", Owner: "alice"}
+	require.NoError(t, db.Create(&todo))
+	r := setupTransferApp(db)
+
+	importAs := func(contentType, body string) ImportReport {
+		req, _ := http.NewRequest(http.MethodPost, "/todos/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-User", "bob")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var response struct {
+			Data ImportReport `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response.Data
+	}
+
+	report := importAs("text/csv", "id,title,owner\n"+strconv.Itoa(int(todo.ID))+",Water the plants,mallory\n,Buy milk,mallory\n")
+	assert.Equal(t, []string{"owner"}, report.IgnoredColumns, "owner is only exported")
+	importAs("application/x-ndjson", `{"ID":`+strconv.Itoa(int(todo.ID))+`,"owner":"mallory"}`+"\n"+`{"title":"Read","owner":"mallory"}`+"\n")
+
+	var todos []models.Todo
+	require.NoError(t, db.Find(&todos))
+	require.Len(t, todos, 3)
+	assert.Equal(t, "Water the plants", todos[0].Title)
+	assert.Equal(t, []string{"alice", "bob", "bob"}, []string{todos[0].Owner, todos[1].Owner, todos[2].Owner})
+}
+
+func TestImportTodosPlacement(t *testing.T) {
+	db := store.NewMemoryStore()
+	parent := models.Todo{Title: "Move house"}
+	require.NoError(t, db.Create(&parent))
+	child := models.Todo{Title: "Pack", ParentID: &parent.ID}
+	require.NoError(t, db.Create(&child))
+	r := setupTransferApp(db)
+
+	rec, report := postImport(r, "/todos/import", "text/csv", "id,title,parent_id,list_id\n"+
+		strconv.Itoa(int(parent.ID))+",,"+strconv.Itoa(int(child.ID))+",\n"+
+		",Lost,,99\n"+
+		",Label boxes,"+strconv.Itoa(int(parent.ID))+",\n")
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, []ImportRowError{
+		{Line: 2, Column: "parent_id", Error: "parent_id: a todo cannot be below itself"},
+		{Line: 3, Column: "list_id", Error: "list_id: no list has this id"},
+	}, report.Errors)
+}
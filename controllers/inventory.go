@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/store"
+)
+
+type StockChangeRequest struct {
+	// Warehouse defaults to inventory.DefaultWarehouse.
+	Warehouse string `json:"warehouse"`
+	Quantity  int64  `json:"quantity" binding:"required"`
+	Reason    string `json:"reason"`
+}
+
+type StockThresholdRequest struct {
+	Warehouse         string `json:"warehouse"`
+	LowStockThreshold *int64 `json:"low_stock_threshold" binding:"required"`
+}
+
+type InventoryController struct {
+	products  store.Storer
+	inventory *inventory.Service
+}
+
+func NewInventoryController(products store.Storer, inventory *inventory.Service) *InventoryController {
+	return &InventoryController{products: products, inventory: inventory}
+}
+
+// Levels handles GET /products/:id/stock: the stock in every warehouse.
+func (i *InventoryController) Levels(c *gin.Context) {
+	product, ok := loadProduct(c, i.products)
+	if !ok {
+		return
+	}
+
+	levels, err := i.inventory.Levels(product.ID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": levels,
+	})
+}
+
+// change returns a handler for POST /products/:id/stock/<op>, applying op
+// to the product's stock. Asking for more stock than there is answers 409.
+func (i *InventoryController) change(op func(*inventory.Service, inventory.Change) (any, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, ok := loadProduct(c, i.products)
+		if !ok {
+			return
+		}
+
+		var req StockChangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		movement, err := op(i.inventory, inventory.Change{
+			ProductID: product.ID,
+			Warehouse: req.Warehouse,
+			Quantity:  req.Quantity,
+			Reason:    req.Reason,
+			Actor:     currentUser(c),
+		})
+		switch {
+		case errors.Is(err, inventory.ErrInsufficientStock), errors.Is(err, inventory.ErrNotReserved):
+			c.JSON(http.StatusConflict, gin.H{
+				"message": err.Error(),
+			})
+			return
+		case errors.Is(err, inventory.ErrInvalidQuantity), errors.Is(err, inventory.ErrReasonRequired):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		case err != nil:
+			c.JSON(errorStatus(err), gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": movement,
+		})
+	}
+}
+
+// Adjust adds stock, or removes it with a negative quantity.
+func (i *InventoryController) Adjust(c *gin.Context) {
+	i.change(func(s *inventory.Service, ch inventory.Change) (any, error) { return s.Adjust(ch) })(c)
+}
+
+func (i *InventoryController) Reserve(c *gin.Context) {
+	i.change(func(s *inventory.Service, ch inventory.Change) (any, error) { return s.Reserve(ch) })(c)
+}
+
+func (i *InventoryController) Release(c *gin.Context) {
+	i.change(func(s *inventory.Service, ch inventory.Change) (any, error) { return s.Release(ch) })(c)
+}
+
+func (i *InventoryController) Commit(c *gin.Context) {
+	i.change(func(s *inventory.Service, ch inventory.Change) (any, error) { return s.Commit(ch) })(c)
+}
+
+// SetThreshold handles PUT /products/:id/stock/threshold.
+func (i *InventoryController) SetThreshold(c *gin.Context) {
+	product, ok := loadProduct(c, i.products)
+	if !ok {
+		return
+	}
+
+	var req StockThresholdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if *req.LowStockThreshold < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "low_stock_threshold must not be negative",
+		})
+		return
+	}
+
+	level, err := i.inventory.SetThreshold(product.ID, req.Warehouse, *req.LowStockThreshold)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": level,
+	})
+}
+
+// History handles GET /products/:id/stock/history[?warehouse=].
+func (i *InventoryController) History(c *gin.Context) {
+	product, ok := loadProduct(c, i.products)
+	if !ok {
+		return
+	}
+
+	movements, err := i.inventory.History(product.ID, c.Query("warehouse"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": movements,
+	})
+}
+
+// LowStock handles GET /stock/low: every level at or below its threshold.
+func (i *InventoryController) LowStock(c *gin.Context) {
+	levels, err := i.inventory.LowStock()
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": levels,
+	})
+}
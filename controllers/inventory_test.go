@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func setupInventoryApp(t *testing.T) (*gin.Engine, string) {
+	gin.SetMode(gin.TestMode)
+
+	products := store.NewMemoryStore()
+	product := models.Product{ID: primitive.NewObjectID(), Name: "Mug", Price: money.New(500, "USD")}
+	require.NoError(t, products.Create(&product))
+
+	inventoryController := NewInventoryController(products, inventory.New(store.NewMemoryStore(), store.NewMemoryStore()))
+	r := gin.New()
+	r.GET("/products/:id/stock", inventoryController.Levels)
+	r.POST("/products/:id/stock/adjust", inventoryController.Adjust)
+	r.POST("/products/:id/stock/reserve", inventoryController.Reserve)
+	r.POST("/products/:id/stock/release", inventoryController.Release)
+	r.POST("/products/:id/stock/commit", inventoryController.Commit)
+	r.PUT("/products/:id/stock/threshold", inventoryController.SetThreshold)
+	r.GET("/products/:id/stock/history", inventoryController.History)
+	r.GET("/stock/low", inventoryController.LowStock)
+
+	return r, "/products/" + product.ID.Hex() + "/stock"
+}
+
+func TestInventory(t *testing.T) {
+	r, path := setupInventoryApp(t)
+
+	rec := serve(r, http.MethodPost, path+"/reserve", `{"quantity":1}`)
+	assert.Equal(t, http.StatusConflict, rec.Code, "no stock yet")
+
+	rec = serve(r, http.MethodPost, path+"/adjust", `{"quantity":10}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "adjustments need a reason")
+	rec = serve(r, http.MethodPost, path+"/adjust", `{"quantity":10,"reason":"delivery"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = serve(r, http.MethodPut, path+"/threshold", `{"low_stock_threshold":5}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = serveAs(r, "alice", http.MethodPost, path+"/reserve", `{"quantity":6}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var movement struct {
+		Data models.StockMovement `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &movement))
+	assert.Equal(t, "alice", movement.Data.Actor)
+	assert.Equal(t, int64(4), movement.Data.Available)
+	assert.True(t, movement.Data.LowStock)
+
+	for body, want := range map[string]int{
+		`{"quantity":5}`:  http.StatusConflict,
+		`{"quantity":-1}`: http.StatusBadRequest,
+		`{}`:              http.StatusBadRequest,
+	} {
+		assert.Equal(t, want, serve(r, http.MethodPost, path+"/reserve", body).Code, body)
+	}
+	assert.Equal(t, http.StatusConflict, serve(r, http.MethodPost, path+"/commit", `{"quantity":7}`).Code)
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodPost, path+"/commit", `{"quantity":6}`).Code)
+
+	rec = serve(r, http.MethodGet, path, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"warehouse":"main","on_hand":4,"reserved":0,"available":4,"low_stock_threshold":5`)
+
+	rec = serve(r, http.MethodGet, "/stock/low", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"available":4`)
+
+	rec = serve(r, http.MethodGet, path+"/history", "")
+	var history struct {
+		Data []models.StockMovement `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+	require.Len(t, history.Data, 3)
+	assert.Equal(t, "delivery", history.Data[0].Reason)
+	assert.Equal(t, models.StockCommitted, history.Data[2].Kind)
+	assert.Equal(t, `{"data":[]}`, serve(r, http.MethodGet, path+"/history?warehouse=bangkok", "").Body.String())
+
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/products/"+primitive.NewObjectID().Hex()+"/stock", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodGet, "/products/nope/stock", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPut, path+"/threshold", `{"low_stock_threshold":-1}`).Code)
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+type LabelController struct {
+	db store.Storer
+}
+
+func NewLabelController(db store.Storer) *LabelController {
+	return &LabelController{db}
+}
+
+type LabelRequest struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Color string `json:"color" binding:"omitempty,hexcolor"`
+}
+
+func (l *LabelController) Index(c *gin.Context) {
+	labels := []models.Label{}
+	if err := l.db.Find(&labels); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": labels,
+	})
+}
+
+// label parses the :id parameter and loads the label, answering the request
+// itself when it cannot.
+func (l *LabelController) label(c *gin.Context) (models.Label, bool) {
+	var label models.Label
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+		return label, false
+	}
+
+	if err := l.db.First(&label, uint(id)); err != nil {
+		if store.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Label not found",
+			})
+			return label, false
+		}
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return label, false
+	}
+	return label, true
+}
+
+// nameTaken reports whether another label is called name. The unique index
+// enforces this in postgres; checking first gives the memory store the same
+// behaviour and a clearer message.
+func (l *LabelController) nameTaken(name string, id uint) (bool, error) {
+	var other models.Label
+	err := l.db.First(&other, "name = ?", name)
+	if store.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil && other.ID != id, err
+}
+
+// save creates or updates label, answering 409 when its name is taken.
+func (l *LabelController) save(c *gin.Context, label *models.Label, status int) {
+	taken, err := l.nameTaken(label.Name, label.ID)
+	if err == nil && taken {
+		err = store.ErrDuplicateKey
+	}
+	if err == nil {
+		if label.ID == 0 {
+			err = l.db.Create(label)
+		} else {
+			err = l.db.Save(label)
+		}
+	}
+
+	switch {
+	case store.IsDuplicateKey(err):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "a label called " + strconv.Quote(label.Name) + " already exists",
+		})
+	case err != nil:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(status, gin.H{
+			"data": label,
+		})
+	}
+}
+
+func (l *LabelController) Create(c *gin.Context) {
+	var req LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	l.save(c, &models.Label{Name: req.Name, Color: req.Color}, http.StatusCreated)
+}
+
+func (l *LabelController) Update(c *gin.Context) {
+	var req LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	label, ok := l.label(c)
+	if !ok {
+		return
+	}
+	label.Name, label.Color = req.Name, req.Color
+	l.save(c, &label, http.StatusOK)
+}
+
+// Delete removes a label from every todo and then deletes it.
+func (l *LabelController) Delete(c *gin.Context) {
+	label, ok := l.label(c)
+	if !ok {
+		return
+	}
+
+	err := store.Delete(l.db, &models.TodoLabel{}, "label_id = ?", label.ID)
+	if err != nil && !store.IsNotFound(err) {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := store.Delete(l.db, &label); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// maxLabels is how many labels a todo, or a ?label filter, takes at most,
+// bounding the IN lists they query.
+const maxLabels = 20
+
+// labelsByID loads the labels with the given ids, failing on the first id
+// that has no label.
+func labelsByID(db store.Storer, ids []uint) ([]models.Label, error) {
+	labels := []models.Label{}
+	if len(ids) == 0 {
+		return labels, nil
+	}
+	if len(ids) > maxLabels {
+		return nil, &columnError{"label_ids", fmt.Errorf("label_ids: at most %d labels", maxLabels)}
+	}
+	if err := db.Find(&labels, "id IN ?", ids); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	found := make(map[uint]bool, len(labels))
+	for _, label := range labels {
+		found[label.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, &columnError{"label_ids", fmt.Errorf("label_ids: no label has id %d", id)}
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels, nil
+}
+
+// setTodoLabels replaces the labels of a todo.
+func setTodoLabels(db store.Storer, todoID uint, labels []models.Label) error {
+	err := store.Delete(db, &models.TodoLabel{}, "todo_id = ?", todoID)
+	if err != nil && !store.IsNotFound(err) {
+		return err
+	}
+	for _, label := range labels {
+		if err := db.Create(&models.TodoLabel{TodoID: todoID, LabelID: label.ID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachLabels fills in the labels of todos with two queries, whatever the
+// number of todos.
+func attachLabels(db store.Storer, todos []models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	byTodo := make(map[uint]int, len(todos))
+	ids := make([]uint, len(todos))
+	for i, todo := range todos {
+		byTodo[todo.ID] = i
+		ids[i] = todo.ID
+	}
+
+	var links []models.TodoLabel
+	if err := db.Find(&links, "todo_id IN ?", ids); err != nil && !store.IsNotFound(err) {
+		return err
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	labelIDs := make([]uint, 0, len(links))
+	for _, link := range links {
+		labelIDs = append(labelIDs, link.LabelID)
+	}
+	var labels []models.Label
+	if err := db.Find(&labels, "id IN ?", labelIDs); err != nil && !store.IsNotFound(err) {
+		return err
+	}
+	byLabel := make(map[uint]models.Label, len(labels))
+	for _, label := range labels {
+		byLabel[label.ID] = label
+	}
+	for _, link := range links {
+		if label, ok := byLabel[link.LabelID]; ok {
+			i := byTodo[link.TodoID]
+			todos[i].Labels = append(todos[i].Labels, label)
+		}
+	}
+	for i := range todos {
+		labels := todos[i].Labels
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	}
+	return nil
+}
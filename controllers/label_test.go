@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLabelApp(db store.Storer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	labelController := NewLabelController(db)
+	todoController := NewTodoController(db, nil)
+
+	r := gin.New()
+	r.GET("/labels", labelController.Index)
+	r.POST("/labels", labelController.Create)
+	r.PUT("/labels/:id", labelController.Update)
+	r.DELETE("/labels/:id", labelController.Delete)
+	r.GET("/todos", todoController.Index)
+	r.POST("/todos", todoController.Create)
+	r.PUT("/todos/:id/labels", todoController.SetLabels)
+	return r
+}
+
+func listTodos(t *testing.T, r *gin.Engine, query string) []models.Todo {
+	rec := serve(r, http.MethodGet, "/todos"+query, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var response struct {
+		Data []models.Todo `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response.Data
+}
+
+func titles(todos []models.Todo) []string {
+	titles := make([]string, len(todos))
+	for i, todo := range todos {
+		titles[i] = todo.Title
+	}
+	return titles
+}
+
+func TestLabels(t *testing.T) {
+	db := store.NewMemoryStore()
+	r := setupLabelApp(db)
+
+	require.Equal(t, http.StatusCreated, serve(r, http.MethodPost, "/labels", `{"name":"home","color":"#00ff00"}`).Code)
+	require.Equal(t, http.StatusCreated, serve(r, http.MethodPost, "/labels", `{"name":"work"}`).Code)
+	assert.Equal(t, http.StatusConflict, serve(r, http.MethodPost, "/labels", `{"name":"home"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPost, "/labels", `{"name":"x","color":"green"}`).Code)
+
+	rec := serve(r, http.MethodPut, "/labels/2", `{"name":"home"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `a label called \"home\" already exists`)
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodPut, "/labels/2", `{"name":"office"}`).Code)
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodPut, "/labels/9", `{"name":"x"}`).Code)
+
+	createTodo(t, r, `{"title":"Water plants","priority":"low","label_ids":[1]}`)
+	createTodo(t, r, `{"title":"Send report","priority":"urgent","label_ids":[2,1]}`)
+	createTodo(t, r, `{"title":"Read"}`)
+
+	rec = serve(r, http.MethodPost, "/todos", `{"title":"x","label_ids":[7]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no label has id 7")
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPost, "/todos", `{"title":"x","priority":"soon"}`).Code)
+
+	t.Run("filters", func(t *testing.T) {
+		all := listTodos(t, r, "")
+		require.Len(t, all, 3)
+		assert.Equal(t, models.PriorityUrgent, all[1].Priority)
+		require.Len(t, all[1].Labels, 2)
+		assert.Equal(t, "home", all[1].Labels[0].Name)
+		assert.Equal(t, "office", all[1].Labels[1].Name)
+
+		assert.Equal(t, []string{"Water plants", "Send report"}, titles(listTodos(t, r, "?label=home")))
+		assert.Equal(t, []string{"Send report"}, titles(listTodos(t, r, "?label=office&priority=high,urgent")))
+		assert.Equal(t, []string{"Read"}, titles(listTodos(t, r, "?priority=none")))
+		assert.Empty(t, listTodos(t, r, "?label=garden"))
+		assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodGet, "/todos?priority=soon", "").Code)
+
+		rec := serve(r, http.MethodGet, "/todos?label="+strings.Repeat("home,", maxLabels)+"office", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "at most 20 labels")
+	})
+
+	t.Run("set labels", func(t *testing.T) {
+		rec := serve(r, http.MethodPut, "/todos/3/labels", `{"label_ids":[2]}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"office"`)
+		assert.Equal(t, []string{"Send report", "Read"}, titles(listTodos(t, r, "?label=office")))
+
+		assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPut, "/todos/3/labels", `{"label_ids":[9]}`).Code)
+		ids := strings.TrimSuffix(strings.Repeat("2,", maxLabels+1), ",")
+		assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPut, "/todos/3/labels", `{"label_ids":[`+ids+`]}`).Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodPut, "/todos/9/labels", `{"label_ids":[]}`).Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(r, http.MethodDelete, "/labels/1", "").Code)
+		assert.Empty(t, listTodos(t, r, "?label=home"))
+		assert.Len(t, listTodos(t, r, "")[0].Labels, 0)
+
+		var links []models.TodoLabel
+		require.NoError(t, db.Find(&links))
+		assert.Len(t, links, 2)
+
+		assert.Equal(t, http.StatusCreated, serve(r, http.MethodPost, "/labels", `{"name":"home"}`).Code, "the name is free again")
+	})
+}
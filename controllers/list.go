@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+type TodoListController struct {
+	db store.Storer
+}
+
+func NewTodoListController(db store.Storer) *TodoListController {
+	return &TodoListController{db}
+}
+
+type Progress struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Percent   int `json:"percent"`
+}
+
+func (p *Progress) add(todo models.Todo) {
+	p.Total++
+	if todo.Completed {
+		p.Completed++
+	}
+	p.Percent = p.Completed * 100 / p.Total
+}
+
+type TodoListResponse struct {
+	models.TodoList
+	Progress Progress `json:"progress"`
+}
+
+type TodoListRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// list parses the :id parameter and loads the list, answering the request
+// itself when it cannot.
+func (l *TodoListController) list(c *gin.Context) (models.TodoList, bool) {
+	var list models.TodoList
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+		return list, false
+	}
+
+	if err := l.db.First(&list, uint(id)); err != nil {
+		if store.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "List not found",
+			})
+			return list, false
+		}
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return list, false
+	}
+	return list, true
+}
+
+// progress counts the todos of every list in one pass, subtasks included.
+func (l *TodoListController) progress(conds ...any) (map[uint]*Progress, error) {
+	progress := map[uint]*Progress{}
+	var todo models.Todo
+	err := store.Each(l.db, &todo, func() error {
+		if todo.ListID == nil {
+			return nil
+		}
+		p := progress[*todo.ListID]
+		if p == nil {
+			p = &Progress{}
+			progress[*todo.ListID] = p
+		}
+		p.add(todo)
+		return nil
+	}, conds...)
+	return progress, err
+}
+
+func withProgress(list models.TodoList, progress map[uint]*Progress) TodoListResponse {
+	resp := TodoListResponse{TodoList: list}
+	if p := progress[list.ID]; p != nil {
+		resp.Progress = *p
+	}
+	return resp
+}
+
+func (l *TodoListController) Index(c *gin.Context) {
+	lists := []models.TodoList{}
+	if err := l.db.Find(&lists); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	progress, err := l.progress("list_id IS NOT NULL")
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	data := make([]TodoListResponse, len(lists))
+	for i, list := range lists {
+		data[i] = withProgress(list, progress)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
+
+func (l *TodoListController) Create(c *gin.Context) {
+	var req TodoListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	list := models.TodoList{Name: req.Name, Owner: currentUser(c)}
+	if err := l.db.Create(&list); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": withProgress(list, nil),
+	})
+}
+
+func (l *TodoListController) FindOne(c *gin.Context) {
+	list, ok := l.list(c)
+	if !ok {
+		return
+	}
+
+	progress, err := l.progress("list_id = ?", list.ID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": withProgress(list, progress),
+	})
+}
+
+func (l *TodoListController) Update(c *gin.Context) {
+	var req TodoListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	list, ok := l.list(c)
+	if !ok {
+		return
+	}
+	list.Name = req.Name
+	if err := l.db.Save(&list); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": list,
+	})
+}
+
+// Delete removes a list together with its todos.
+func (l *TodoListController) Delete(c *gin.Context) {
+	list, ok := l.list(c)
+	if !ok {
+		return
+	}
+
+	err := store.Delete(store.As(l.db, currentUser(c)), &models.Todo{}, "list_id = ?", list.ID)
+	if err != nil && !store.IsNotFound(err) {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := store.Delete(l.db, &list); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Todos returns the todos of a list as a tree: top-level todos with their
+// subtasks nested under them, both in the order set with Move.
+func (l *TodoListController) Todos(c *gin.Context) {
+	list, ok := l.list(c)
+	if !ok {
+		return
+	}
+
+	todos := []models.Todo{}
+	if err := l.db.Find(&todos, "list_id = ?", list.ID); err != nil && !store.IsNotFound(err) {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": todoTree(todos),
+	})
+}
+
+// todoTree nests todos under their parents. Todos whose parent is not among
+// todos are roots.
+func todoTree(todos []models.Todo) []models.Todo {
+	sortByPosition(todos)
+
+	byID := make(map[uint]int, len(todos))
+	for i, todo := range todos {
+		byID[todo.ID] = i
+	}
+	children := map[uint][]int{}
+	var roots []int
+	for i, todo := range todos {
+		if todo.ParentID != nil {
+			if _, ok := byID[*todo.ParentID]; ok && *todo.ParentID != todo.ID {
+				children[*todo.ParentID] = append(children[*todo.ParentID], i)
+				continue
+			}
+		}
+		roots = append(roots, i)
+	}
+
+	// visited stops a parent cycle, which only a hand-edited database has,
+	// from recursing forever.
+	visited := map[uint]bool{}
+	var build func(i int) models.Todo
+	build = func(i int) models.Todo {
+		todo := todos[i]
+		visited[todo.ID] = true
+		todo.Subtasks = nil
+		for _, child := range children[todo.ID] {
+			if !visited[todos[child].ID] {
+				todo.Subtasks = append(todo.Subtasks, build(child))
+			}
+		}
+		return todo
+	}
+
+	tree := []models.Todo{}
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+	return tree
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupListApp(db store.Storer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	listController := NewTodoListController(db)
+	todoController := NewTodoController(db, nil)
+
+	r := gin.New()
+	r.GET("/lists", listController.Index)
+	r.POST("/lists", listController.Create)
+	r.GET("/lists/:id", listController.FindOne)
+	r.PUT("/lists/:id", listController.Update)
+	r.DELETE("/lists/:id", listController.Delete)
+	r.GET("/lists/:id/todos", listController.Todos)
+	r.POST("/todos", todoController.Create)
+	r.POST("/todos/:id/complete", todoController.Complete)
+	return r
+}
+
+func createTodo(t *testing.T, r *gin.Engine, body string) models.Todo {
+	rec := serve(r, http.MethodPost, "/todos", body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var response struct {
+		Data models.Todo `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response.Data
+}
+
+func TestTodoLists(t *testing.T) {
+	db := store.NewMemoryStore()
+	r := setupListApp(db)
+
+	rec := serveAs(r, "alice", http.MethodPost, "/lists", `{"name":"Move house"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPost, "/lists", `{}`).Code)
+
+	pack := createTodo(t, r, `{"title":"Pack","list_id":1}`)
+	books := createTodo(t, r, `{"title":"Books","parent_id":1}`)
+	createTodo(t, r, `{"title":"Paperbacks","parent_id":2}`)
+	createTodo(t, r, `{"title":"Call movers","list_id":1}`)
+	createTodo(t, r, `{"title":"Unrelated"}`)
+	assert.Equal(t, pack.ListID, books.ListID, "subtasks inherit the list of their parent")
+
+	for body, message := range map[string]string{
+		`{"title":"x","list_id":9}`:               "no list has this id",
+		`{"title":"x","parent_id":9}`:             "no todo has this id",
+		`{"title":"x","parent_id":5,"list_id":1}`: "a subtask must be in its parent's list",
+	} {
+		rec := serve(r, http.MethodPost, "/todos", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), message, body)
+	}
+
+	t.Run("tree", func(t *testing.T) {
+		rec := serve(r, http.MethodGet, "/lists/1/todos", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Data []models.Todo `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Data, 2)
+		assert.Equal(t, "Pack", response.Data[0].Title)
+		require.Len(t, response.Data[0].Subtasks, 1)
+		assert.Equal(t, "Books", response.Data[0].Subtasks[0].Title)
+		require.Len(t, response.Data[0].Subtasks[0].Subtasks, 1)
+		assert.Equal(t, "Paperbacks", response.Data[0].Subtasks[0].Subtasks[0].Title)
+		assert.Equal(t, "Call movers", response.Data[1].Title)
+	})
+
+	t.Run("cascading completion and progress", func(t *testing.T) {
+		rec := serve(r, http.MethodPost, "/todos/1/complete?cascade=true", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"subtasks_completed":2`)
+
+		rec = serve(r, http.MethodGet, "/lists/1", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response struct {
+			Data TodoListResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "Move house", response.Data.Name)
+		assert.Equal(t, "alice", response.Data.Owner)
+		assert.Equal(t, Progress{Total: 4, Completed: 3, Percent: 75}, response.Data.Progress)
+
+		rec = serve(r, http.MethodGet, "/lists", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"progress":{"total":4,"completed":3,"percent":75}`)
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		rec := serve(r, http.MethodPut, "/lists/1", `{"name":"New flat"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"New flat"`)
+	})
+
+	t.Run("delete removes the todos", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(r, http.MethodDelete, "/lists/1", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/lists/1", "").Code)
+
+		var todos []models.Todo
+		require.NoError(t, db.Find(&todos))
+		require.Len(t, todos, 1)
+		assert.Equal(t, "Unrelated", todos[0].Title)
+	})
+
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodGet, "/lists/x", "").Code)
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/fracindex"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+type TodoMoveRequest struct {
+	AfterID  *uint `json:"after_id"`
+	BeforeID *uint `json:"before_id"`
+}
+
+// listScope is the condition matching the todos ordered together with todo:
+// those in the same list, or those in no list.
+func listScope(todo models.Todo) []any {
+	if todo.ListID == nil {
+		return []any{"list_id IS NULL"}
+	}
+	return []any{"list_id = ?", *todo.ListID}
+}
+
+// sortByPosition orders todos by position, and todos that share a position,
+// such as those created before positions existed, by creation.
+func sortByPosition(todos []models.Todo) {
+	sort.SliceStable(todos, func(i, j int) bool {
+		if todos[i].Position != todos[j].Position {
+			return todos[i].Position < todos[j].Position
+		}
+		return todos[i].ID < todos[j].ID
+	})
+}
+
+// lastPosition returns a position after every todo in the scope of todo.
+func (t *TodoController) lastPosition(todo models.Todo) (string, error) {
+	var last string
+	var sibling models.Todo
+	err := store.Each(t.db, &sibling, func() error {
+		if sibling.Position > last {
+			last = sibling.Position
+		}
+		return nil
+	}, listScope(todo)...)
+	if err != nil && !store.IsNotFound(err) {
+		return "", err
+	}
+	if fracindex.Validate(last) != nil {
+		last = ""
+	}
+	return fracindex.Between(last, "")
+}
+
+// siblings returns the other todos in the scope of todo in order. When two of
+// them share a position or one has none, every position in the scope is
+// rewritten first so that there is room between any two neighbours.
+func siblings(db store.Storer, todo models.Todo) ([]models.Todo, error) {
+	var all []models.Todo
+	if err := db.Find(&all, listScope(todo)...); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	siblings := make([]models.Todo, 0, len(all))
+	for _, sibling := range all {
+		if sibling.ID != todo.ID {
+			siblings = append(siblings, sibling)
+		}
+	}
+	sortByPosition(siblings)
+
+	rebalance := false
+	for i, sibling := range siblings {
+		if fracindex.Validate(sibling.Position) != nil || (i > 0 && sibling.Position == siblings[i-1].Position) {
+			rebalance = true
+			break
+		}
+	}
+	if !rebalance {
+		return siblings, nil
+	}
+
+	key := ""
+	for i := range siblings {
+		next, err := fracindex.Between(key, "")
+		if err != nil {
+			return nil, err
+		}
+		key = next
+		siblings[i].Position = key
+		if err := db.Save(&siblings[i]); err != nil {
+			return nil, err
+		}
+	}
+	return siblings, nil
+}
+
+// moveKey returns the position that puts a todo after the sibling after and
+// before the sibling before. Either may be nil; when both are given they
+// must be neighbours.
+func moveKey(siblings []models.Todo, after, before *uint) (string, error) {
+	index := func(id uint, column string) (int, error) {
+		for i, sibling := range siblings {
+			if sibling.ID == id {
+				return i, nil
+			}
+		}
+		return 0, &columnError{column, fmt.Errorf("%s: no other todo in the same list has this id", column)}
+	}
+
+	lo, hi := -1, len(siblings)
+	if after != nil {
+		i, err := index(*after, "after_id")
+		if err != nil {
+			return "", err
+		}
+		lo, hi = i, i+1
+	}
+	if before != nil {
+		j, err := index(*before, "before_id")
+		if err != nil {
+			return "", err
+		}
+		if after != nil && j != hi {
+			return "", &columnError{"before_id", errors.New("before_id: must directly follow after_id")}
+		}
+		lo, hi = j-1, j
+	}
+
+	var a, b string
+	if lo >= 0 {
+		a = siblings[lo].Position
+	}
+	if hi < len(siblings) {
+		b = siblings[hi].Position
+	}
+	return fracindex.Between(a, b)
+}
+
+// Move puts a todo after the todo after_id or before the todo before_id of
+// the same list. Only the moved todo gets a new position.
+func (t *TodoController) Move(c *gin.Context) {
+	var req TodoMoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.AfterID == nil && req.BeforeID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "after_id or before_id is required",
+		})
+		return
+	}
+
+	todo, ok := loadTodo(c, t.db)
+	if !ok {
+		return
+	}
+	db := store.As(t.db, currentUser(c))
+	siblings, err := siblings(db, todo)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	key, err := moveKey(siblings, req.AfterID, req.BeforeID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	todo.Position = key
+	if err := db.Save(&todo); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": todo,
+	})
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupOrderApp(db store.Storer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	todoController := NewTodoController(db, nil)
+	listController := NewTodoListController(db)
+
+	r := gin.New()
+	r.GET("/todos", todoController.Index)
+	r.POST("/todos", todoController.Create)
+	r.POST("/todos/:id/move", todoController.Move)
+	r.POST("/lists", listController.Create)
+	r.GET("/lists/:id/todos", listController.Todos)
+	return r
+}
+
+func TestMoveTodo(t *testing.T) {
+	db := store.NewMemoryStore()
+	r := setupOrderApp(db)
+
+	for _, title := range []string{"a", "b", "c", "d"} {
+		createTodo(t, r, `{"title":"`+title+`"}`)
+	}
+	require.Equal(t, []string{"a", "b", "c", "d"}, titles(listTodos(t, r, "")))
+
+	for _, move := range []struct {
+		id, body string
+		want     []string
+	}{
+		{"4", `{"after_id":1}`, []string{"a", "d", "b", "c"}},
+		{"1", `{"before_id":3}`, []string{"d", "b", "a", "c"}},
+		{"3", `{"before_id":4}`, []string{"c", "d", "b", "a"}},
+		{"2", `{"after_id":1}`, []string{"c", "d", "a", "b"}},
+		{"1", `{"after_id":3,"before_id":4}`, []string{"c", "a", "d", "b"}},
+	} {
+		rec := serve(r, http.MethodPost, "/todos/"+move.id+"/move", move.body)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, move.want, titles(listTodos(t, r, "")), move.body)
+	}
+
+	rec := serve(r, http.MethodPost, "/lists", `{"name":"Elsewhere"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	createTodo(t, r, `{"title":"e","list_id":1}`)
+
+	for body, message := range map[string]string{
+		`{}`:                           "after_id or before_id is required",
+		`{"after_id":5}`:               "after_id: no other todo in the same list has this id",
+		`{"after_id":1}`:               "after_id: no other todo in the same list has this id",
+		`{"after_id":3,"before_id":2}`: "before_id: must directly follow after_id",
+	} {
+		rec := serve(r, http.MethodPost, "/todos/1/move", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), message, body)
+	}
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodPost, "/todos/9/move", `{"after_id":1}`).Code)
+}
+
+func TestMoveRebalancesSharedPositions(t *testing.T) {
+	db := store.NewMemoryStore()
+	for _, title := range []string{"a", "b", "c"} {
+		require.NoError(t, db.Create(&models.Todo{Title: title}))
+	}
+	r := setupOrderApp(db)
+
+	rec := serve(r, http.MethodPost, "/todos/3/move", `{"after_id":1}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{"a", "c", "b"}, titles(listTodos(t, r, "")))
+
+	var todos []models.Todo
+	require.NoError(t, db.Find(&todos))
+	for _, todo := range todos {
+		assert.NotEmpty(t, todo.Position, todo.Title)
+	}
+}
+
+func TestListTreeFollowsPositions(t *testing.T) {
+	db := store.NewMemoryStore()
+	r := setupOrderApp(db)
+
+	require.Equal(t, http.StatusCreated, serve(r, http.MethodPost, "/lists", `{"name":"Trip"}`).Code)
+	createTodo(t, r, `{"title":"Book","list_id":1}`)
+	createTodo(t, r, `{"title":"Pack","list_id":1}`)
+	createTodo(t, r, `{"title":"Socks","parent_id":2}`)
+	createTodo(t, r, `{"title":"Passport","parent_id":2}`)
+	require.Equal(t, http.StatusOK, serve(r, http.MethodPost, "/todos/2/move", `{"before_id":1}`).Code)
+	require.Equal(t, http.StatusOK, serve(r, http.MethodPost, "/todos/4/move", `{"before_id":3}`).Code)
+
+	rec := serve(r, http.MethodGet, "/lists/1/todos", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Regexp(t, `"title":"Pack".*"title":"Passport".*"title":"Socks".*"title":"Book"`, rec.Body.String())
+}
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/pricing"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PriceRequest struct {
+	Price money.Money `json:"price" binding:"required"`
+}
+
+type ScheduledPriceRequest struct {
+	Price    money.Money `json:"price" binding:"required"`
+	StartsAt time.Time   `json:"starts_at" binding:"required"`
+	// Without EndsAt the price is changed for good.
+	EndsAt *time.Time `json:"ends_at"`
+}
+
+type PriceController struct {
+	prices    *pricing.Store
+	schedules *pricing.Schedules
+}
+
+func NewPriceController(prices *pricing.Store, schedules *pricing.Schedules) *PriceController {
+	return &PriceController{prices: prices, schedules: schedules}
+}
+
+// History handles GET /products/:id/prices: every price the product has
+// had, oldest first, with who set it and when.
+func (p *PriceController) History(c *gin.Context) {
+	product, ok := loadProduct(c, p.prices)
+	if !ok {
+		return
+	}
+
+	changes, err := p.prices.History(product.ID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	locale := priceLocale(c)
+	for i := range changes {
+		formatPrices(locale, &changes[i].Price, changes[i].Previous)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": changes,
+	})
+}
+
+// Set handles PUT /products/:id/price, changing the price at once.
+func (p *PriceController) Set(c *gin.Context) {
+	var req PriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	product, ok := loadProduct(c, p.prices)
+	if !ok {
+		return
+	}
+	product.Price = req.Price
+	if err := store.As(p.prices, currentUser(c)).Save(&product); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	product.Price.Formatted = product.Price.Format(priceLocale(c))
+	c.JSON(http.StatusOK, gin.H{
+		"data": product,
+	})
+}
+
+// Scheduled handles GET /products/:id/prices/scheduled.
+func (p *PriceController) Scheduled(c *gin.Context) {
+	product, ok := loadProduct(c, p.prices)
+	if !ok {
+		return
+	}
+
+	scheduled, err := p.schedules.List(product.ID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	locale := priceLocale(c)
+	for i := range scheduled {
+		formatPrices(locale, &scheduled[i].Price, scheduled[i].Previous)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": scheduled,
+	})
+}
+
+// Schedule handles POST /products/:id/prices/scheduled. The price is applied
+// at starts_at by the scheduler, and the previous one restored at ends_at.
+// A schedule overlapping another of the product answers 409.
+func (p *PriceController) Schedule(c *gin.Context) {
+	var req ScheduledPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	product, ok := loadProduct(c, p.prices)
+	if !ok {
+		return
+	}
+
+	sp := models.ScheduledPrice{
+		ProductID: product.ID,
+		Price:     req.Price,
+		StartsAt:  req.StartsAt.UTC(),
+		CreatedBy: currentUser(c),
+	}
+	if req.EndsAt != nil {
+		ends := req.EndsAt.UTC()
+		sp.EndsAt = &ends
+	}
+	err := p.schedules.Add(&sp, time.Now())
+	switch {
+	case errors.Is(err, pricing.ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, pricing.ErrScheduleOverlaps):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	formatPrices(priceLocale(c), &sp.Price)
+	c.JSON(http.StatusCreated, gin.H{
+		"data": sp,
+	})
+}
+
+// Cancel handles DELETE /products/:id/prices/scheduled/:schedule_id. A
+// scheduled price in effect ends at once.
+func (p *PriceController) Cancel(c *gin.Context) {
+	product, ok := loadProduct(c, p.prices)
+	if !ok {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("schedule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+		return
+	}
+
+	sp, err := p.schedules.Cancel(product.ID, id, currentUser(c))
+	switch {
+	case store.IsNotFound(err):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Scheduled price not found",
+		})
+		return
+	case errors.Is(err, pricing.ErrScheduleFinished):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	formatPrices(priceLocale(c), &sp.Price, sp.Previous)
+	c.JSON(http.StatusOK, gin.H{
+		"data": sp,
+	})
+}
+
+func formatPrices(locale string, prices ...*money.Money) {
+	for _, m := range prices {
+		if m != nil {
+			m.Formatted = m.Format(locale)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/pricing"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupPriceApp() (*gin.Engine, *pricing.Schedules) {
+	gin.SetMode(gin.TestMode)
+
+	prices := pricing.NewStore(store.NewMemoryStore(), store.NewMemoryStore())
+	schedules := pricing.NewSchedules(prices, store.NewMemoryStore())
+	productController := NewProductController(prices, store.NewMemoryStore(), nil, nil)
+	priceController := NewPriceController(prices, schedules)
+
+	r := gin.New()
+	r.POST(pathProducts, productController.Create)
+	r.GET(pathProducts+"/:id", productController.FindOne)
+	r.POST(pathProducts+"/bulk", productController.Bulk)
+	r.GET(pathProducts+"/:id/prices", priceController.History)
+	r.PUT(pathProducts+"/:id/price", priceController.Set)
+	r.GET(pathProducts+"/:id/prices/scheduled", priceController.Scheduled)
+	r.POST(pathProducts+"/:id/prices/scheduled", priceController.Schedule)
+	r.DELETE(pathProducts+"/:id/prices/scheduled/:schedule_id", priceController.Cancel)
+
+	return r, schedules
+}
+
+func TestPriceHistory(t *testing.T) {
+	r, schedules := setupPriceApp()
+
+	rec := serveAs(r, "alice", http.MethodPost, pathProducts, `{"name":"Lamp","price":{"amount":4500,"currency":"USD"},"description":"Desk lamp"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	path := pathProducts + "/" + created.Data.ID.Hex()
+
+	rec = serveAs(r, "bob", http.MethodPut, path+"/price", `{"price":{"amount":3900,"currency":"USD"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPut, path+"/price", `{"price":{"amount":-1,"currency":"USD"}}`).Code)
+	rec = serveAs(r, "carol", http.MethodPost, pathProducts+"/bulk", `[{"op":"update","id":"`+created.Data.ID.Hex()+`","name":"Lamp","price":{"amount":3500,"currency":"USD"},"description":"Desk lamp"}]`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var history struct {
+		Data []models.PriceChange `json:"data"`
+	}
+	rec = serve(r, http.MethodGet, path+"/prices", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+	require.Len(t, history.Data, 3)
+	assert.Equal(t, []string{"alice", "bob", "carol"}, []string{history.Data[0].Actor, history.Data[1].Actor, history.Data[2].Actor})
+	assert.Equal(t, int64(3900), history.Data[2].Previous.Amount)
+	assert.Contains(t, rec.Body.String(), `"formatted":"$35.00"`)
+
+	t.Run("scheduled prices", func(t *testing.T) {
+		starts := time.Now().Add(time.Hour)
+		ends := starts.Add(24 * time.Hour)
+		schedule := func(body string) *httptest.ResponseRecorder {
+			return serveAs(r, "dave", http.MethodPost, path+"/prices/scheduled", body)
+		}
+		window := `"starts_at":"` + starts.Format(time.RFC3339) + `","ends_at":"` + ends.Format(time.RFC3339) + `"`
+
+		rec := schedule(`{"price":{"amount":2500,"currency":"USD"},` + window + `}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var sale struct {
+			Data models.ScheduledPrice `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sale))
+		assert.Equal(t, models.SchedulePending, sale.Data.Status)
+		assert.Equal(t, "dave", sale.Data.CreatedBy)
+
+		assert.Equal(t, http.StatusConflict, schedule(`{"price":{"amount":2000,"currency":"USD"},`+window+`}`).Code)
+		assert.Equal(t, http.StatusBadRequest, schedule(`{"price":{"amount":2000,"currency":"USD"},"starts_at":"`+ends.Format(time.RFC3339)+`","ends_at":"`+starts.Format(time.RFC3339)+`"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, schedule(`{"price":{"amount":2000,"currency":"USD"}}`).Code)
+
+		require.NoError(t, schedules.Run(context.Background(), starts))
+		var product struct {
+			Data models.Product `json:"data"`
+		}
+		rec = serve(r, http.MethodGet, path, "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+		assert.Equal(t, int64(2500), product.Data.Price.Amount)
+
+		rec = serve(r, http.MethodDelete, path+"/prices/scheduled/"+sale.Data.ID.Hex(), "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		rec = serve(r, http.MethodGet, path, "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+		assert.Equal(t, int64(3500), product.Data.Price.Amount, "cancelling a sale in effect ends it")
+		assert.Equal(t, http.StatusConflict, serve(r, http.MethodDelete, path+"/prices/scheduled/"+sale.Data.ID.Hex(), "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(r, http.MethodDelete, path+"/prices/scheduled/000000000000000000000000", "").Code)
+
+		var scheduled struct {
+			Data []models.ScheduledPrice `json:"data"`
+		}
+		rec = serve(r, http.MethodGet, path+"/prices/scheduled", "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &scheduled))
+		require.Len(t, scheduled.Data, 1)
+		assert.Equal(t, models.ScheduleCanceled, scheduled.Data[0].Status)
+
+		rec = serve(r, http.MethodGet, path+"/prices", "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+		require.Len(t, history.Data, 5)
+		assert.Equal(t, pricing.SchedulerActor, history.Data[3].Actor)
+		assert.Equal(t, sale.Data.ID, *history.Data[3].ScheduleID)
+	})
+}
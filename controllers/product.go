@@ -1,48 +1,197 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sing3demons/go-example/blob"
 	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/promotion"
 	"github.com/sing3demons/go-example/store"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type ProductController struct {
-	db store.Storer
+	db         store.Storer
+	categories store.Storer
+	blobs      blob.Store
+	// promotions may be nil, for listings without them.
+	promotions *promotion.Engine
 }
 
-func NewProductController(db store.Storer) *ProductController {
-	return &ProductController{db}
+func NewProductController(db, categories store.Storer, blobs blob.Store, promotions *promotion.Engine) *ProductController {
+	return &ProductController{db: db, categories: categories, blobs: blobs, promotions: promotions}
 }
 
+type ProductCategoriesRequest struct {
+	CategoryIDs []primitive.ObjectID `json:"category_ids"`
+}
+
+// productFacets are the fields GET /products?facets=true counts values of.
+var productFacets = []string{"category_ids", "tags", "attributes"}
+
+// Find handles GET /products, filtered by facet: ?category= (including its
+// subcategories), ?tags= and ?attributes.<name>=. Several values of one
+// facet match any of them; different facets must all match. With
+// ?facets=true the response counts the values of every facet among the
+// products found. Each product comes with its price once the promotions
+// running, and those of the ?coupon= codes given, are applied.
 func (p *ProductController) Find(c *gin.Context) {
 	products := []models.Product{}
 
-	if err := p.db.Find(&products, bson.D{}); err != nil {
-		switch err {
-		case mongo.ErrNoDocuments:
+	filter, err := p.filter(c)
+	if err != nil {
+		var colErr *columnError
+		if errors.As(err, &colErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := p.db.Find(&products, filter); err != nil {
+		switch {
+		case store.IsNotFound(err):
 			c.JSON(http.StatusNotFound, gin.H{
 				"data": products,
 			})
 		default:
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(errorStatus(err), gin.H{
 				"message": err.Error(),
 			})
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	locale := priceLocale(c)
+	for i := range products {
+		products[i].Price.Formatted = products[i].Price.Format(locale)
+	}
+	if !p.price(c, products) {
+		return
+	}
+	response := gin.H{
 		"data": products,
-	})
+	}
+	if c.Query("facets") == "true" {
+		facets, err := store.Facets(p.db, &models.Product{}, productFacets, filter)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		response["facets"] = facets
+	}
+	c.JSON(http.StatusOK, response)
+
+}
+
+// filter builds the bson filter of the facet parameters of Find.
+func (p *ProductController) filter(c *gin.Context) (bson.M, error) {
+	filter := bson.M{}
+	query := c.Request.URL.Query()
+
+	if values := query["category"]; len(values) > 0 {
+		var ids []primitive.ObjectID
+		for _, v := range values {
+			id, err := primitive.ObjectIDFromHex(v)
+			if err != nil {
+				return nil, &columnError{"category", fmt.Errorf("category: invalid id %q", v)}
+			}
+			descendants, err := categoryDescendants(p.categories, id)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+			for _, d := range descendants {
+				ids = append(ids, d.ID)
+			}
+		}
+		filter["category_ids"] = bson.M{"$in": ids}
+	}
+
+	if tags := models.NormalizeTags(query["tags"]); len(tags) > 0 {
+		filter["tags"] = bson.M{"$in": tags}
+	}
+
+	for key, values := range query {
+		if !strings.HasPrefix(key, "attributes.") {
+			continue
+		}
+		// Query values are text; the attribute may be a number or boolean.
+		var candidates bson.A
+		for _, v := range values {
+			candidates = append(candidates, v)
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				candidates = append(candidates, n)
+			}
+			if v == "true" || v == "false" {
+				candidates = append(candidates, v == "true")
+			}
+		}
+		filter[key] = bson.M{"$in": candidates}
+	}
 
+	return filter, nil
 }
 
 func (p *ProductController) FindOne(c *gin.Context) {
+	product, ok := loadProduct(c, p.db)
+	if !ok {
+		return
+	}
+
+	product.Price.Formatted = product.Price.Format(priceLocale(c))
+	products := []models.Product{product}
+	if !p.price(c, products) {
+		return
+	}
+	c.JSON(200, gin.H{
+		"data": products[0],
+	})
+}
+
+// price adds to products their pricing with the promotions running for the
+// ?coupon= codes, answering the request itself when it cannot.
+func (p *ProductController) price(c *gin.Context, products []models.Product) bool {
+	if p.promotions == nil {
+		return true
+	}
+	rules, err := p.promotions.Rules(time.Now(), c.QueryArray("coupon"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return false
+	}
+
+	locale := priceLocale(c)
+	for i := range products {
+		quote := rules.Quote(products[i], 1)
+		formatQuote(locale, &quote)
+		products[i].Pricing = &quote
+	}
+	return true
+}
+
+// loadProduct reads the product named by the :id parameter, answering the
+// request itself when it cannot.
+func loadProduct(c *gin.Context, db store.Storer) (models.Product, bool) {
 	var product models.Product
 
 	id, err := primitive.ObjectIDFromHex(c.Param("id"))
@@ -50,27 +199,23 @@ func (p *ProductController) FindOne(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "Invalid ID format",
 		})
-		return
+		return product, false
 	}
 
-	if err := p.db.First(&product, bson.M{"_id": id}); err != nil {
-		switch err {
-		case mongo.ErrNoDocuments:
+	if err := db.First(&product, bson.M{"_id": id}); err != nil {
+		switch {
+		case store.IsNotFound(err):
 			c.JSON(http.StatusNotFound, gin.H{
 				"message": "Product not found",
 			})
-			return
 		default:
-			c.JSON(500, gin.H{
+			c.JSON(errorStatus(err), gin.H{
 				"message": err.Error(),
 			})
-			return
 		}
+		return product, false
 	}
-
-	c.JSON(200, gin.H{
-		"data": product,
-	})
+	return product, true
 }
 
 func (p *ProductController) Create(c *gin.Context) {
@@ -81,15 +226,234 @@ func (p *ProductController) Create(c *gin.Context) {
 		})
 		return
 	}
+	product.Tags = models.NormalizeTags(product.Tags)
+	// Images are only added by uploading them.
+	product.Images = nil
+	if _, err := categoriesByID(p.categories, "category_ids", product.CategoryIDs); err != nil {
+		var colErr *columnError
+		if errors.As(err, &colErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 
-	if err := p.db.Create(&product); err != nil {
-		c.JSON(500, gin.H{
+	if err := store.As(p.db, currentUser(c)).Create(&product); err != nil {
+		c.JSON(errorStatus(err), gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
+	product.Price.Formatted = product.Price.Format(priceLocale(c))
 	c.JSON(201, gin.H{
 		"data": product,
 	})
 }
+
+// SetCategories handles PUT /products/:id/categories, replacing the
+// categories the product is assigned to.
+func (p *ProductController) SetCategories(c *gin.Context) {
+	var req ProductCategoriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	product, ok := loadProduct(c, p.db)
+	if !ok {
+		return
+	}
+	if _, err := categoriesByID(p.categories, "category_ids", req.CategoryIDs); err != nil {
+		var colErr *columnError
+		if errors.As(err, &colErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Only the categories are written, so that images uploaded meanwhile
+	// are kept.
+	err := store.Update(store.As(p.db, currentUser(c)), &models.Product{}, map[string]any{"category_ids": req.CategoryIDs}, bson.M{"_id": product.ID})
+	if store.IsNotFound(err) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Product not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	product.CategoryIDs = req.CategoryIDs
+
+	product.Price.Formatted = product.Price.Format(priceLocale(c))
+	c.JSON(http.StatusOK, gin.H{
+		"data": product,
+	})
+}
+
+// priceLocale is the locale prices are formatted for in responses: ?locale,
+// or else the one the Accept-Language header prefers.
+func priceLocale(c *gin.Context) string {
+	if locale := c.Query("locale"); locale != "" {
+		return locale
+	}
+	return money.Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// Bulk creates, updates and deletes products in one request; see bulkWrite.
+// Updates keep the images of the product, and deletes remove them.
+func (p *ProductController) Bulk(c *gin.Context) {
+	bulkWrite(c, store.As(p.db, currentUser(c)), bulkModel{
+		decode: func(op store.BulkOpKind, raw []byte) (any, error) {
+			var product models.Product
+			if err := json.Unmarshal(raw, &product); err != nil {
+				return nil, err
+			}
+
+			var existing models.Product
+			if op != store.BulkCreate && !product.ID.IsZero() {
+				err := p.db.First(&existing, bson.M{"_id": product.ID})
+				switch {
+				case err == nil && op == store.BulkUpdate:
+					product = existing
+					product.Images = nil
+					if err := mergeJSON(&product, raw); err != nil {
+						return nil, err
+					}
+				case err != nil && !store.IsNotFound(err):
+					return nil, err
+				}
+			}
+			product.Images = existing.Images
+
+			product.Tags = models.NormalizeTags(product.Tags)
+			if _, err := categoriesByID(p.categories, "category_ids", product.CategoryIDs); err != nil {
+				return nil, err
+			}
+			if err := validateBulkItem(op, &product, !product.ID.IsZero()); err != nil {
+				return nil, err
+			}
+			return &product, nil
+		},
+		id: func(record any) any { return record.(*models.Product).ID },
+		written: func(op store.BulkOpKind, record any) {
+			if op == store.BulkDelete {
+				p.removeImages(record.(*models.Product).Images...)
+			}
+		},
+		keep: []string{"images"},
+	})
+}
+
+var productTransfer = transferModel{
+	name:      "products",
+	newRecord: func() any { return &models.Product{} },
+	columns: []csvColumn{
+		{
+			name: "id",
+			get: func(r any) string {
+				if id := r.(*models.Product).ID; !id.IsZero() {
+					return id.Hex()
+				}
+				return ""
+			},
+			set: func(r any, v string) (err error) {
+				r.(*models.Product).ID, err = primitive.ObjectIDFromHex(v)
+				return err
+			},
+		},
+		{
+			name: "name",
+			get:  func(r any) string { return r.(*models.Product).Name },
+			set:  func(r any, v string) error { r.(*models.Product).Name = v; return nil },
+		},
+		{
+			name: "price",
+			get:  func(r any) string { return strconv.FormatInt(r.(*models.Product).Price.Amount, 10) },
+			set: func(r any, v string) error {
+				amount, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return errors.New("must be a whole number")
+				}
+				r.(*models.Product).Price.Amount = amount
+				return nil
+			},
+		},
+		{
+			name: "currency",
+			get:  func(r any) string { return r.(*models.Product).Price.Currency },
+			set: func(r any, v string) error {
+				if v == "" {
+					return nil
+				}
+				currency, err := money.Lookup(v)
+				if err != nil {
+					return err
+				}
+				r.(*models.Product).Price.Currency = currency.Code
+				return nil
+			},
+		},
+		{
+			name: "description",
+			get:  func(r any) string { return r.(*models.Product).Description },
+			set:  func(r any, v string) error { r.(*models.Product).Description = v; return nil },
+		},
+	},
+	validate: func(r any) error { return binding.Validator.ValidateStruct(r) },
+	hasID:    func(r any) bool { return !r.(*models.Product).ID.IsZero() },
+	id:       func(r any) any { return r.(*models.Product).ID },
+	load: func(db store.Storer, r any) (any, error) {
+		var product models.Product
+		err := db.First(&product, bson.M{"_id": r.(*models.Product).ID})
+		return &product, err
+	},
+	// Images are only changed by uploads. They are taken out of the stored
+	// product so that a row with images cannot decode into them.
+	protect: func(stored any) func(any) {
+		product := stored.(*models.Product)
+		images := product.Images
+		product.Images = nil
+		return func(r any) { r.(*models.Product).Images = images }
+	},
+	// Nor are they written back, which would undo uploads made meanwhile.
+	keep: []string{"images"},
+}
+
+// Export streams every product as JSON, NDJSON or CSV; see exportRecords.
+func (p *ProductController) Export(c *gin.Context) {
+	exportRecords(c, p.db, productTransfer)
+}
+
+// Import creates and updates products from CSV or NDJSON; see importRecords.
+// Tags are normalized and categories must exist, as on Create.
+func (p *ProductController) Import(c *gin.Context) {
+	m := productTransfer
+	m.validate = func(r any) error {
+		if err := productTransfer.validate(r); err != nil {
+			return err
+		}
+		product := r.(*models.Product)
+		product.Tags = models.NormalizeTags(product.Tags)
+		_, err := categoriesByID(p.categories, "category_ids", product.CategoryIDs)
+		return err
+	}
+	importRecords(c, store.As(p.db, currentUser(c)), m)
+}
@@ -9,8 +9,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
 	"github.com/sing3demons/go-example/store"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -22,7 +24,7 @@ const (
 func setupProductApp(db store.Storer) *httptest.ResponseRecorder {
 	gin.SetMode(gin.TestMode)
 
-	productController := NewProductController(db)
+	productController := NewProductController(db, store.NewMemoryStore(), nil, nil)
 
 	r := gin.New()
 	r.GET(pathProducts, productController.Find)
@@ -42,7 +44,7 @@ func TestFindProducts(t *testing.T) {
 			{
 				ID:          primitive.NewObjectID(),
 				Name:        "Product 1",
-				Price:       99,
+				Price:       money.New(99, "USD"),
 				Description: "Description for Product 1",
 			},
 		}
@@ -79,6 +81,15 @@ func TestFindProducts(t *testing.T) {
 		assert.Empty(t, response["data"], "Expected empty data in response")
 	})
 
+	t.Run("Find Products circuit open", func(t *testing.T) {
+		db := store.MockStore{
+			Err: store.ErrCircuitOpen,
+		}
+		rec := setupProductApp(&db)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
 	t.Run("Find Products error", func(t *testing.T) {
 		db := store.MockStore{
 			Err: mongo.ErrClientDisconnected,
@@ -100,7 +111,7 @@ func TestFindProducts(t *testing.T) {
 func setupProductPost(db store.Storer, body *strings.Reader) *httptest.ResponseRecorder {
 	gin.SetMode(gin.TestMode)
 
-	productController := NewProductController(db)
+	productController := NewProductController(db, store.NewMemoryStore(), nil, nil)
 
 	r := gin.New()
 	r.POST(pathProducts, productController.Create)
@@ -114,7 +125,7 @@ func setupProductPost(db store.Storer, body *strings.Reader) *httptest.ResponseR
 func setupProductGetByID(db store.Storer, id string) *httptest.ResponseRecorder {
 	gin.SetMode(gin.TestMode)
 
-	productController := NewProductController(db)
+	productController := NewProductController(db, store.NewMemoryStore(), nil, nil)
 
 	r := gin.New()
 	r.GET(pathProducts+"/:id", productController.FindOne)
@@ -132,7 +143,7 @@ func TestFindOneProduct(t *testing.T) {
 		product := models.Product{
 			ID:          productID,
 			Name:        "Product 1",
-			Price:       99,
+			Price:       money.New(99, "USD"),
 			Description: "Description for Product 1",
 		}
 
@@ -148,7 +159,7 @@ func TestFindOneProduct(t *testing.T) {
 		assert.NoError(t, err, "Failed to unmarshal response")
 
 		assert.NotNil(t, response["data"], "expected 'data' key in response")
-
+		db.AssertCalled(t, "First", bson.M{"_id": productID})
 	})
 
 	t.Run("Find One Product Invalid ID format", func(t *testing.T) {
@@ -208,7 +219,7 @@ func TestCreateProduct(t *testing.T) {
 	t.Run("Create Product success", func(t *testing.T) {
 		product := models.Product{
 			Name:        "New Product",
-			Price:       100,
+			Price:       money.New(100, "USD"),
 			Description: "Description for New Product",
 		}
 
@@ -248,7 +259,7 @@ func TestCreateProduct(t *testing.T) {
 	t.Run("Create Product error", func(t *testing.T) {
 		product := models.Product{
 			Name:        "New Product",
-			Price:       100,
+			Price:       money.New(100, "USD"),
 			Description: "Description for New Product",
 		}
 
@@ -270,3 +281,63 @@ func TestCreateProduct(t *testing.T) {
 		}
 	})
 }
+
+func TestCreateThenFindProduct(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	productController := NewProductController(store.NewMemoryStore(), store.NewMemoryStore(), nil, nil)
+	r := gin.New()
+	r.GET(pathProducts, productController.Find)
+	r.GET(pathProducts+"/:id", productController.FindOne)
+	r.POST(pathProducts, productController.Create)
+
+	req, _ := http.NewRequest(http.MethodPost, pathProducts, strings.NewReader(`{"name":"Mug","price": {"amount": 250, "currency": "USD"},"description":"A mug"}`))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.False(t, created.Data.ID.IsZero())
+
+	req, _ = http.NewRequest(http.MethodGet, pathProducts+"/"+created.Data.ID.Hex(), nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req, _ = http.NewRequest(http.MethodGet, pathProducts+"/"+primitive.NewObjectID().Hex(), nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestProductPrices(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	productController := NewProductController(store.NewMemoryStore(), store.NewMemoryStore(), nil, nil)
+	r := gin.New()
+	r.GET(pathProducts, productController.Find)
+	r.POST(pathProducts, productController.Create)
+
+	rec := serve(r, http.MethodPost, pathProducts, `{"name":"Mug","price":{"amount":123450,"currency":"eur"},"description":"A mug"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"price":{"amount":123450,"currency":"EUR","formatted":"€1,234.50"}`)
+
+	rec = serve(r, http.MethodPost, pathProducts, `{"name":"Sample","price":{"amount":0,"currency":"EUR"},"description":"Free"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, "a price of 0 is a price")
+
+	req, _ := http.NewRequest(http.MethodGet, pathProducts, nil)
+	req.Header.Set("Accept-Language", "de-DE,de;q=0.9")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Contains(t, rec.Body.String(), "\"formatted\":\"1.234,50\u00a0€\"")
+	assert.Contains(t, rec.Body.String(), "\"formatted\":\"0,00\u00a0€\"")
+	assert.Contains(t, serve(r, http.MethodGet, pathProducts+"?locale=en", "").Body.String(), "€1,234.50")
+
+	for _, price := range []string{`{"amount":100,"currency":"ABC"}`, `{"amount":-1,"currency":"EUR"}`, `{"amount":100}`, `100`} {
+		rec = serve(r, http.MethodPost, pathProducts, `{"name":"Bad","price":`+price+`,"description":"x"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, price)
+	}
+}
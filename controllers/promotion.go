@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/promotion"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PromotionController struct {
+	promotions *promotion.Engine
+	products   store.Storer
+	categories store.Storer
+	// adminToken is the X-Admin-Token that shows coupon codes.
+	adminToken string
+}
+
+func NewPromotionController(promotions *promotion.Engine, products, categories store.Storer, adminToken string) *PromotionController {
+	return &PromotionController{promotions: promotions, products: products, categories: categories, adminToken: adminToken}
+}
+
+// Index handles GET /promotions, in the order they are considered. Coupon
+// codes are only shown to admins.
+func (pc *PromotionController) Index(c *gin.Context) {
+	promotions, err := pc.promotions.List()
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	locale := priceLocale(c)
+	for i := range promotions {
+		pc.hideCode(c, &promotions[i])
+		formatPrices(locale, promotions[i].Amount)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": promotions,
+	})
+}
+
+// Create handles POST /promotions.
+func (pc *PromotionController) Create(c *gin.Context) {
+	var p models.Promotion
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := pc.targets(p); err != nil {
+		var colErr *columnError
+		if errors.As(err, &colErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	p.CreatedBy = currentUser(c)
+	err := pc.promotions.Add(&p, time.Now())
+	switch {
+	case errors.Is(err, promotion.ErrInvalidPromotion):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	formatPrices(priceLocale(c), p.Amount)
+	c.JSON(http.StatusCreated, gin.H{
+		"data": p,
+	})
+}
+
+// targets checks that the products and categories p targets exist.
+func (pc *PromotionController) targets(p models.Promotion) error {
+	for _, id := range p.ProductIDs {
+		var product models.Product
+		if err := pc.products.First(&product, bson.M{"_id": id}); err != nil {
+			if store.IsNotFound(err) {
+				return &columnError{"product_ids", fmt.Errorf("product_ids: no product has id %s", id.Hex())}
+			}
+			return err
+		}
+	}
+	_, err := categoriesByID(pc.categories, "category_ids", p.CategoryIDs)
+	return err
+}
+
+// FindOne handles GET /promotions/:id. Its coupon code is only shown to
+// admins.
+func (pc *PromotionController) FindOne(c *gin.Context) {
+	id, ok := promotionID(c)
+	if !ok {
+		return
+	}
+
+	p, err := pc.promotions.Get(id)
+	if err != nil {
+		writePromotionError(c, err)
+		return
+	}
+
+	pc.hideCode(c, &p)
+	formatPrices(priceLocale(c), p.Amount)
+	c.JSON(http.StatusOK, gin.H{
+		"data": p,
+	})
+}
+
+// Delete handles DELETE /promotions/:id, ending the promotion at once.
+func (pc *PromotionController) Delete(c *gin.Context) {
+	id, ok := promotionID(c)
+	if !ok {
+		return
+	}
+
+	p, err := pc.promotions.Delete(id)
+	if err != nil {
+		writePromotionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": p,
+	})
+}
+
+// hideCode takes the coupon code out of p unless the request is an admin's,
+// so that listing promotions does not give their coupons away.
+func (pc *PromotionController) hideCode(c *gin.Context, p *models.Promotion) {
+	if !IsAdmin(c, pc.adminToken) {
+		p.Code = ""
+	}
+}
+
+func promotionID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+	}
+	return id, err == nil
+}
+
+func writePromotionError(c *gin.Context, err error) {
+	if store.IsNotFound(err) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Promotion not found",
+		})
+		return
+	}
+	c.JSON(errorStatus(err), gin.H{
+		"message": err.Error(),
+	})
+}
+
+func formatQuote(locale string, quote *models.PriceQuote) {
+	formatPrices(locale, &quote.Subtotal, &quote.Discount, &quote.Total)
+	for i := range quote.Applied {
+		formatPrices(locale, &quote.Applied[i].Discount)
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/promotion"
+	"github.com/sing3demons/go-example/shop"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromotions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	products, categories := store.NewMemoryStore(), store.NewMemoryStore()
+	promotions := promotion.New(store.NewMemoryStore(), categories, promotion.PolicyPriority)
+	stock := inventory.New(store.NewMemoryStore(), store.NewMemoryStore())
+	productController := NewProductController(products, categories, nil, promotions)
+	promotionController := NewPromotionController(promotions, products, categories, "secret")
+	cartController := NewCartController(shop.New(store.NewMemoryStore(), store.NewMemoryStore(), products, stock, promotions))
+
+	r := gin.New()
+	r.GET(pathProducts, productController.Find)
+	r.GET(pathProducts+"/:id", productController.FindOne)
+	r.POST(pathProducts, productController.Create)
+	r.GET("/promotions", promotionController.Index)
+	r.POST("/promotions", promotionController.Create)
+	r.GET("/promotions/:id", promotionController.FindOne)
+	r.DELETE("/promotions/:id", promotionController.Delete)
+	r.POST("/cart/items", cartController.AddItem)
+	r.POST("/cart/coupons", cartController.AddCoupon)
+	r.DELETE("/cart/coupons/:code", cartController.RemoveCoupon)
+	r.GET("/cart", cartController.Show)
+
+	rec := serve(r, http.MethodPost, pathProducts, `{"name":"Lamp","price":{"amount":4000,"currency":"USD"},"description":"Desk lamp"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var lamp struct {
+		Data models.Product `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &lamp))
+	path := pathProducts + "/" + lamp.Data.ID.Hex()
+
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPost, "/promotions", `{"name":"Huge","kind":"percent","percent":150}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPost, "/promotions", `{"name":"Odd","kind":"double"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPost, "/promotions", `{"name":"Lost","kind":"percent","percent":10,"category_ids":["000000000000000000000000"]}`).Code)
+
+	rec = serveAs(r, "marketing", http.MethodPost, "/promotions", `{"name":"Lamp sale","kind":"fixed","amount":{"amount":500,"currency":"USD"},"product_ids":["`+lamp.Data.ID.Hex()+`"],"stacking":"stackable"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var sale struct {
+		Data models.Promotion `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sale))
+	assert.Equal(t, "marketing", sale.Data.CreatedBy)
+	rec = serve(r, http.MethodPost, "/promotions", `{"name":"Newsletter","kind":"percent","percent":10,"code":"news","stacking":"stackable"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var product struct {
+		Data models.Product `json:"data"`
+	}
+	rec = serve(r, http.MethodGet, path, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+	require.NotNil(t, product.Data.Pricing)
+	assert.Equal(t, int64(3500), product.Data.Pricing.Total.Amount)
+	assert.Contains(t, rec.Body.String(), `"formatted":"$35.00"`)
+
+	rec = serve(r, http.MethodGet, pathProducts+"?coupon=NEWS", "")
+	var listed struct {
+		Data []models.Product `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed.Data, 1)
+	assert.Len(t, listed.Data[0].Pricing.Applied, 2)
+	assert.Equal(t, int64(3150), listed.Data[0].Pricing.Total.Amount)
+
+	t.Run("coupon codes are only shown to admins", func(t *testing.T) {
+		var listed struct {
+			Data []models.Promotion `json:"data"`
+		}
+		rec := serve(r, http.MethodGet, "/promotions", "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+		require.Len(t, listed.Data, 2)
+		assert.NotContains(t, rec.Body.String(), "NEWS")
+
+		rec = serveAdmin(r, "secret", http.MethodGet, "/promotions", "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+		assert.Equal(t, []string{"", "NEWS"}, []string{listed.Data[0].Code, listed.Data[1].Code})
+	})
+
+	t.Run("cart", func(t *testing.T) {
+		rec := serveAs(r, "alice", http.MethodPost, "/cart/items", `{"product_id":"`+lamp.Data.ID.Hex()+`","quantity":2}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, http.StatusBadRequest, serveAs(r, "alice", http.MethodPost, "/cart/coupons", `{"code":"nope"}`).Code)
+		rec = serveAs(r, "alice", http.MethodPost, "/cart/coupons", `{"code":"news"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var cart struct {
+			Data models.Cart `json:"data"`
+		}
+		rec = serveAs(r, "alice", http.MethodGet, "/cart", "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cart))
+		assert.Equal(t, []string{"NEWS"}, cart.Data.Coupons)
+		require.NotNil(t, cart.Data.Pricing)
+		assert.Equal(t, int64(6300), cart.Data.Pricing.Total.Amount)
+
+		assert.Equal(t, http.StatusOK, serveAs(r, "alice", http.MethodDelete, "/cart/coupons/news", "").Code)
+		assert.Equal(t, http.StatusNotFound, serveAs(r, "alice", http.MethodDelete, "/cart/coupons/news", "").Code)
+	})
+
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodDelete, "/promotions/"+sale.Data.ID.Hex(), "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/promotions/"+sale.Data.ID.Hex(), "").Code)
+	rec = serve(r, http.MethodGet, path, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+	assert.Equal(t, int64(4000), product.Data.Pricing.Total.Amount)
+}
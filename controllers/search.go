@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/search"
+)
+
+const (
+	searchPerPage    = 20
+	searchMaxPerPage = 100
+	// searchMaxPage bounds how deep a query can page, as every backend
+	// returns all the hits up to the requested page.
+	searchMaxPage = 50
+)
+
+type SearchController struct {
+	searcher *search.Searcher
+}
+
+func NewSearchController(backends ...search.Backend) *SearchController {
+	return &SearchController{search.New(backends...)}
+}
+
+// positiveQuery reads a positive number from the query string, or def when
+// it is missing.
+func positiveQuery(c *gin.Context, name string, def, max int) (int, error) {
+	s := c.Query(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > max {
+		return 0, errors.New(name + " must be a whole number from 1 to " + strconv.Itoa(max))
+	}
+	return n, nil
+}
+
+// Search handles GET /search?q=blue+cotton. Hits of every type are ranked
+// together; ?type=product,todo limits the types, and ?page and ?per_page
+// page through the hits.
+func (s *SearchController) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "q is required",
+		})
+		return
+	}
+	page, err := positiveQuery(c, "page", 1, searchMaxPage)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	perPage, err := positiveQuery(c, "per_page", searchPerPage, searchMaxPerPage)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var types []string
+	if param := c.Query("type"); param != "" {
+		known := s.searcher.Types()
+		for _, t := range strings.Split(param, ",") {
+			t = strings.TrimSpace(t)
+			if !contains(known, t) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "type must be one of " + strings.Join(known, ", "),
+				})
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	result, err := s.searcher.Search(c.Request.Context(), q, types, page, perPage)
+	if errors.Is(err, search.ErrNoTerms) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	hits := result.Hits
+	if hits == nil {
+		hits = []search.Hit{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":     hits,
+		"page":     page,
+		"per_page": perPage,
+		"has_more": result.HasMore,
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/search"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	products, todos := store.NewMemoryStore(), store.NewMemoryStore()
+	require.NoError(t, products.Create(&models.Product{Name: "Blue cotton shirt", Price: money.New(20, "USD"), Description: "Soft"}))
+	require.NoError(t, products.Create(&models.Product{Name: "Red scarf", Price: money.New(10, "USD"), Description: "Wool"}))
+	require.NoError(t, todos.Create(&models.Todo{Title: "Return the blue shirt"}))
+
+	r := gin.New()
+	r.GET("/search", NewSearchController(search.ScanProducts(products), search.ScanTodos(todos)).Search)
+
+	rec := serve(r, http.MethodGet, "/search?q=blue+shirt", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var response struct {
+		Data    []search.Hit `json:"data"`
+		Page    int          `json:"page"`
+		PerPage int          `json:"per_page"`
+		HasMore bool         `json:"has_more"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Data, 2)
+	assert.Equal(t, "product", response.Data[0].Type)
+	assert.Equal(t, "todo", response.Data[1].Type)
+	assert.Equal(t, "Return the <mark>blue</mark> <mark>shirt</mark>", response.Data[1].Highlights["title"])
+	assert.Equal(t, 1, response.Page)
+	assert.Equal(t, 20, response.PerPage)
+	assert.False(t, response.HasMore)
+
+	rec = serve(r, http.MethodGet, "/search?q=blue&type=todo&per_page=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"type":"todo"`)
+	assert.NotContains(t, rec.Body.String(), `"type":"product"`)
+
+	rec = serve(r, http.MethodGet, "/search?q=blue&per_page=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"has_more":true`)
+
+	assert.Contains(t, serve(r, http.MethodGet, "/search?q=purple", "").Body.String(), `"data":[]`)
+
+	for _, query := range []string{"", "?q=", "?q=%21%21", "?q=blue&page=0", "?q=blue&per_page=1000", "?q=blue&type=order"} {
+		assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodGet, "/search"+query, "").Code, query)
+	}
+}
@@ -0,0 +1,400 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/promotion"
+	"github.com/sing3demons/go-example/shop"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CartItemRequest and CartQuantityRequest take up to shop.MaxQuantity.
+type CartItemRequest struct {
+	ProductID primitive.ObjectID `json:"product_id" binding:"required"`
+	Quantity  int64              `json:"quantity" binding:"required,min=1,max=1000"`
+}
+
+type CartQuantityRequest struct {
+	Quantity int64 `json:"quantity" binding:"required,min=1,max=1000"`
+}
+
+type CartCouponRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// CartController serves the cart of the user in the X-User header.
+type CartController struct {
+	shop *shop.Service
+}
+
+func NewCartController(shop *shop.Service) *CartController {
+	return &CartController{shop: shop}
+}
+
+// Show handles GET /cart, with what checking it out would cost.
+func (cc *CartController) Show(c *gin.Context) {
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+
+	cart, err := cc.shop.Cart(user)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !cc.price(c, &cart) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": cart,
+	})
+}
+
+// AddItem handles POST /cart/items, adding to the quantity of a product
+// already in the cart.
+func (cc *CartController) AddItem(c *gin.Context) {
+	var req CartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+
+	cart, err := cc.shop.AddItem(user, req.ProductID, req.Quantity)
+	cc.respond(c, cart, err)
+}
+
+// SetQuantity handles PUT /cart/items/:product_id.
+func (cc *CartController) SetQuantity(c *gin.Context) {
+	var req CartQuantityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+	productID, ok := cartProductID(c)
+	if !ok {
+		return
+	}
+
+	cart, err := cc.shop.SetQuantity(user, productID, req.Quantity)
+	cc.respond(c, cart, err)
+}
+
+// RemoveItem handles DELETE /cart/items/:product_id.
+func (cc *CartController) RemoveItem(c *gin.Context) {
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+	productID, ok := cartProductID(c)
+	if !ok {
+		return
+	}
+
+	cart, err := cc.shop.RemoveItem(user, productID)
+	cc.respond(c, cart, err)
+}
+
+// AddCoupon handles POST /cart/coupons. A code no promotion runs with
+// answers 400.
+func (cc *CartController) AddCoupon(c *gin.Context) {
+	var req CartCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+
+	cart, err := cc.shop.AddCoupon(user, req.Code)
+	cc.respond(c, cart, err)
+}
+
+// RemoveCoupon handles DELETE /cart/coupons/:code.
+func (cc *CartController) RemoveCoupon(c *gin.Context) {
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+
+	cart, err := cc.shop.RemoveCoupon(user, c.Param("code"))
+	if store.IsNotFound(err) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Coupon not in cart",
+		})
+		return
+	}
+	cc.respond(c, cart, err)
+}
+
+func (cc *CartController) respond(c *gin.Context, cart models.Cart, err error) {
+	switch {
+	case errors.Is(err, shop.ErrInvalidQuantity), errors.Is(err, shop.ErrUnknownProduct), errors.Is(err, promotion.ErrUnknownCoupon):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case store.IsNotFound(err):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Product not in cart",
+		})
+		return
+	case errors.Is(err, shop.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !cc.price(c, &cart) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": cart,
+	})
+}
+
+// price adds to cart what checking it out would cost, answering the request
+// itself when it cannot. An empty cart, and one that checkout would refuse
+// for mixing currencies or holding a product that is gone, go without.
+func (cc *CartController) price(c *gin.Context, cart *models.Cart) bool {
+	if len(cart.Items) == 0 {
+		return true
+	}
+	pricing, err := cc.shop.Price(*cart)
+	switch {
+	case errors.Is(err, shop.ErrMixedCurrencies), errors.Is(err, shop.ErrUnknownProduct):
+		return true
+	case err != nil:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return false
+	}
+
+	locale := priceLocale(c)
+	formatPrices(locale, &pricing.Subtotal, &pricing.Discount, &pricing.Total)
+	for i := range pricing.Items {
+		formatPrices(locale, &pricing.Items[i].Price)
+		formatQuote(locale, &pricing.Items[i].PriceQuote)
+	}
+	cart.Pricing = &pricing
+	return true
+}
+
+// Checkout handles POST /cart/checkout, placing an order for the cart. A
+// cart that is empty, mixes currencies, holds a product that is gone or
+// more than is in stock, or was changed meanwhile answers 409.
+func (cc *CartController) Checkout(c *gin.Context) {
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+
+	order, err := cc.shop.Checkout(user)
+	switch {
+	case errors.Is(err, shop.ErrEmptyCart), errors.Is(err, shop.ErrMixedCurrencies), errors.Is(err, shop.ErrUnknownProduct),
+		errors.Is(err, shop.ErrConflict), errors.Is(err, inventory.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	formatOrder(priceLocale(c), &order)
+	c.JSON(http.StatusCreated, gin.H{
+		"data": order,
+	})
+}
+
+func cartProductID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+	}
+	return id, err == nil
+}
+
+type OrderStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=paid shipped cancelled"`
+	Reason string `json:"reason"`
+}
+
+type OrderController struct {
+	shop *shop.Service
+	// adminToken is the X-Admin-Token of the staff who take payments and
+	// ship orders; see IsAdmin.
+	adminToken string
+}
+
+func NewOrderController(shop *shop.Service, adminToken string) *OrderController {
+	return &OrderController{shop: shop, adminToken: adminToken}
+}
+
+// Index handles GET /orders: the orders of the user in the X-User header,
+// newest first.
+func (o *OrderController) Index(c *gin.Context) {
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+
+	orders, err := o.shop.Orders(user)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	locale := priceLocale(c)
+	for i := range orders {
+		formatOrder(locale, &orders[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": orders,
+	})
+}
+
+// FindOne handles GET /orders/:id, an order of the user in the X-User
+// header.
+func (o *OrderController) FindOne(c *gin.Context) {
+	user, ok := author(c)
+	if !ok {
+		return
+	}
+	id, ok := orderID(c)
+	if !ok {
+		return
+	}
+
+	order, err := o.shop.Order(user, id)
+	if err != nil {
+		o.writeError(c, err)
+		return
+	}
+
+	formatOrder(priceLocale(c), &order)
+	c.JSON(http.StatusOK, gin.H{
+		"data": order,
+	})
+}
+
+// SetStatus handles PUT /orders/:id/status. Pending orders can be paid or
+// cancelled, paid ones shipped or cancelled; any other change answers 409.
+// Only admins mark orders paid or shipped, and users can only cancel their
+// own orders.
+func (o *OrderController) SetStatus(c *gin.Context) {
+	var req OrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	id, ok := orderID(c)
+	if !ok {
+		return
+	}
+	if !IsAdmin(c, o.adminToken) {
+		if req.Status != models.OrderCancelled {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "only admins can mark an order " + req.Status,
+			})
+			return
+		}
+		user, ok := author(c)
+		if !ok {
+			return
+		}
+		if _, err := o.shop.Order(user, id); err != nil {
+			o.writeError(c, err)
+			return
+		}
+	}
+
+	order, err := o.shop.Transition(id, req.Status, currentUser(c), req.Reason)
+	if err != nil {
+		o.writeError(c, err)
+		return
+	}
+
+	formatOrder(priceLocale(c), &order)
+	c.JSON(http.StatusOK, gin.H{
+		"data": order,
+	})
+}
+
+func (o *OrderController) writeError(c *gin.Context, err error) {
+	switch {
+	case store.IsNotFound(err):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Order not found",
+		})
+	case errors.Is(err, shop.ErrInvalidTransition), errors.Is(err, shop.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+	}
+}
+
+func orderID(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+	}
+	return id, err == nil
+}
+
+func formatOrder(locale string, order *models.Order) {
+	formatPrices(locale, &order.Total, order.Discount)
+	for i := range order.Items {
+		item := &order.Items[i]
+		formatPrices(locale, &item.Price, &item.Subtotal, item.Discount)
+		for j := range item.Promotions {
+			formatPrices(locale, &item.Promotions[j].Discount)
+		}
+	}
+}
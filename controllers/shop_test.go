@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/shop"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveAdmin(r *gin.Engine, token, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-Admin-Token", token)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCheckoutAndOrders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	products := store.NewMemoryStore()
+	stock := inventory.New(store.NewMemoryStore(), store.NewMemoryStore())
+	cartController := NewCartController(shop.New(store.NewMemoryStore(), store.NewMemoryStore(), products, stock, nil))
+	orderController := NewOrderController(cartController.shop, "secret")
+
+	r := gin.New()
+	r.GET("/cart", cartController.Show)
+	r.POST("/cart/items", cartController.AddItem)
+	r.PUT("/cart/items/:product_id", cartController.SetQuantity)
+	r.DELETE("/cart/items/:product_id", cartController.RemoveItem)
+	r.POST("/cart/checkout", cartController.Checkout)
+	r.GET("/orders", orderController.Index)
+	r.GET("/orders/:id", orderController.FindOne)
+	r.PUT("/orders/:id/status", orderController.SetStatus)
+
+	mug := models.Product{Name: "Mug", Price: money.New(1250, "USD"), Description: "Mug"}
+	require.NoError(t, products.Create(&mug))
+	_, err := stock.Adjust(inventory.Change{ProductID: mug.ID, Quantity: 2, Reason: "delivery"})
+	require.NoError(t, err)
+	item := `{"product_id":"` + mug.ID.Hex() + `","quantity":2}`
+
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodGet, "/cart", "").Code, "carts need a user")
+	assert.Equal(t, http.StatusBadRequest, serveAs(r, "alice", http.MethodPost, "/cart/items", `{"product_id":"000000000000000000000000","quantity":1}`).Code)
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "alice", http.MethodPut, "/cart/items/"+mug.ID.Hex(), `{"quantity":1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serveAs(r, "alice", http.MethodPost, "/cart/items", `{"product_id":"`+mug.ID.Hex()+`","quantity":-1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serveAs(r, "alice", http.MethodPost, "/cart/items", `{"product_id":"`+mug.ID.Hex()+`","quantity":9223372036854775807}`).Code)
+	assert.Equal(t, http.StatusConflict, serveAs(r, "alice", http.MethodPost, "/cart/checkout", "").Code)
+
+	rec := serveAs(r, "alice", http.MethodPost, "/cart/items", item)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = serveAs(r, "bob", http.MethodPost, "/cart/items", item)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = serveAs(r, "alice", http.MethodPost, "/cart/checkout", "")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"formatted":"$25.00"`)
+	var placed struct {
+		Data models.Order `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &placed))
+	assert.Equal(t, int64(2500), placed.Data.Total.Amount)
+	path := "/orders/" + placed.Data.ID.Hex()
+
+	assert.Equal(t, http.StatusConflict, serveAs(r, "bob", http.MethodPost, "/cart/checkout", "").Code, "alice holds the only stock")
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "bob", http.MethodGet, path, "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPut, path+"/status", `{"status":"lost"}`).Code)
+	assert.Equal(t, http.StatusForbidden, serveAs(r, "alice", http.MethodPut, path+"/status", `{"status":"paid"}`).Code, "only admins take payments")
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPut, path+"/status", `{"status":"cancelled"}`).Code, "cancelling needs a user")
+	assert.Equal(t, http.StatusNotFound, serveAs(r, "bob", http.MethodPut, path+"/status", `{"status":"cancelled"}`).Code, "users only cancel their own orders")
+	assert.Equal(t, http.StatusConflict, serveAdmin(r, "secret", http.MethodPut, path+"/status", `{"status":"shipped"}`).Code)
+	assert.Equal(t, http.StatusForbidden, serveAdmin(r, "guess", http.MethodPut, path+"/status", `{"status":"shipped"}`).Code)
+	rec = serveAs(r, "alice", http.MethodPut, path+"/status", `{"status":"cancelled","reason":"ordered twice"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = serveAs(r, "bob", http.MethodPost, "/cart/checkout", "")
+	assert.Equal(t, http.StatusCreated, rec.Code, "cancelling released the stock")
+
+	var orders struct {
+		Data []models.Order `json:"data"`
+	}
+	rec = serveAs(r, "alice", http.MethodGet, "/orders", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &orders))
+	require.Len(t, orders.Data, 1)
+	assert.Equal(t, models.OrderCancelled, orders.Data[0].Status)
+	assert.Equal(t, "ordered twice", orders.Data[0].History[1].Reason)
+}
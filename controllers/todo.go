@@ -1,34 +1,163 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sing3demons/go-example/fracindex"
+	"github.com/sing3demons/go-example/ical"
 	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/scheduler"
 	"github.com/sing3demons/go-example/store"
-	"gorm.io/gorm"
 )
 
 type TodoController struct {
-	db store.Storer
+	db         store.Storer
+	recurrence *scheduler.Recurrence
+	now        func() time.Time
 }
 
-func NewTodoController(db store.Storer) *TodoController {
-	return &TodoController{db}
+// NewTodoController returns a controller creating the next occurrences of
+// recurring todos with recurrence, the one the scheduler runs, or with one
+// of its own when recurrence is nil.
+func NewTodoController(db store.Storer, recurrence *scheduler.Recurrence) *TodoController {
+	if recurrence == nil {
+		recurrence = scheduler.NewRecurrence(db)
+	}
+	return &TodoController{
+		db:         db,
+		recurrence: recurrence,
+		now:        time.Now,
+	}
+}
+
+// todoFilter turns ?priority=high,urgent, ?label=home,work and
+// ?assignee=bob into a condition on todos. A todo matches any of the
+// priorities and any of the labels, of which there are at most maxLabels.
+// none is true when no todo can match.
+func (t *TodoController) todoFilter(c *gin.Context) (conds []any, none bool, err error) {
+	var where []string
+	var args []any
+
+	if param := c.Query("priority"); param != "" {
+		var priorities []int
+		for _, name := range strings.Split(param, ",") {
+			p, err := models.ParsePriority(strings.TrimSpace(name))
+			if err != nil {
+				return nil, false, &columnError{"priority", err}
+			}
+			priorities = append(priorities, int(p))
+		}
+		where = append(where, "priority IN ?")
+		args = append(args, priorities)
+	}
+
+	if param := c.Query("label"); param != "" {
+		var names []string
+		for _, name := range strings.Split(param, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+		if len(names) > maxLabels {
+			return nil, false, &columnError{"label", fmt.Errorf("label: at most %d labels", maxLabels)}
+		}
+		var labels []models.Label
+		if err := t.db.Find(&labels, "name IN ?", names); err != nil && !store.IsNotFound(err) {
+			return nil, false, err
+		}
+		if len(labels) == 0 {
+			return nil, true, nil
+		}
+		labelIDs := make([]uint, len(labels))
+		for i, label := range labels {
+			labelIDs[i] = label.ID
+		}
+
+		var links []models.TodoLabel
+		if err := t.db.Find(&links, "label_id IN ?", labelIDs); err != nil && !store.IsNotFound(err) {
+			return nil, false, err
+		}
+		if len(links) == 0 {
+			return nil, true, nil
+		}
+		ids := make([]uint, len(links))
+		for i, link := range links {
+			ids[i] = link.TodoID
+		}
+		where = append(where, "id IN ?")
+		args = append(args, ids)
+	}
+
+	if user := c.Query("assignee"); user != "" {
+		ids, err := assignedTodos(t.db, user)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(ids) == 0 {
+			return nil, true, nil
+		}
+		where = append(where, "id IN ?")
+		args = append(args, ids)
+	}
+
+	if len(where) == 0 {
+		return nil, false, nil
+	}
+	return append([]any{strings.Join(where, " AND ")}, args...), false, nil
 }
 
+// Index lists todos in the order set with Move, with their labels and
+// assignees.
 func (t *TodoController) Index(c *gin.Context) {
 	todos := []models.Todo{}
 
-	if err := t.db.Find(&todos); err != nil {
-		if err == gorm.ErrRecordNotFound {
+	conds, none, err := t.todoFilter(c)
+	var colErr *columnError
+	if errors.As(err, &colErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if none {
+		c.JSON(http.StatusOK, gin.H{
+			"data": todos,
+		})
+		return
+	}
+
+	if err := t.db.Find(&todos, conds...); err != nil {
+		if store.IsNotFound(err) {
 			c.JSON(404, gin.H{
 				"message": "No todos found",
 			})
 			return
 		}
 
-		c.JSON(500, gin.H{
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	sortByPosition(todos)
+	err = attachLabels(t.db, todos)
+	if err == nil {
+		err = attachAssignees(t.db, todos)
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
 			"message": err.Error(),
 		})
 		return
@@ -40,7 +169,92 @@ func (t *TodoController) Index(c *gin.Context) {
 }
 
 type TodoCreateRequest struct {
-	Title string `json:"title" binding:"required"`
+	Title           string          `json:"title" binding:"required"`
+	DueAt           *time.Time      `json:"due_at"`
+	ReminderMinutes int             `json:"reminder_minutes" binding:"gte=0"`
+	RRule           string          `json:"rrule"`
+	ListID          *uint           `json:"list_id"`
+	ParentID        *uint           `json:"parent_id"`
+	Priority        models.Priority `json:"priority"`
+	LabelIDs        []uint          `json:"label_ids"`
+	Assignees       []string        `json:"assignees" binding:"max=20,dive,required,max=100"`
+}
+
+// checkSchedule validates the due date, reminder and recurrence rule of a
+// todo and rewrites the rule in its canonical form.
+func checkSchedule(todo *models.Todo) error {
+	if todo.DueAt == nil {
+		switch {
+		case todo.RRule != "":
+			return &columnError{"due_at", errors.New("due_at is required when rrule is set")}
+		case todo.ReminderMinutes > 0:
+			return &columnError{"due_at", errors.New("due_at is required when reminder_minutes is set")}
+		}
+		return nil
+	}
+	if todo.RRule != "" {
+		rule, err := ical.ParseRecur(todo.RRule)
+		if err != nil {
+			return &columnError{"rrule", err}
+		}
+		todo.RRule = rule.String()
+	}
+	return nil
+}
+
+// place checks the list and parent of a todo being written. A subtask goes
+// into its parent's list unless a list is given, which then has to match,
+// and cannot be below itself.
+func (t *TodoController) place(todo *models.Todo) error {
+	if todo.ParentID != nil {
+		if err := t.checkCycle(todo); err != nil {
+			return err
+		}
+		var parent models.Todo
+		if err := t.db.First(&parent, *todo.ParentID); store.IsNotFound(err) {
+			return &columnError{"parent_id", errors.New("parent_id: no todo has this id")}
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case todo.ListID == nil:
+			todo.ListID = parent.ListID
+		case parent.ListID == nil || *parent.ListID != *todo.ListID:
+			return &columnError{"list_id", errors.New("list_id: a subtask must be in its parent's list")}
+		}
+	}
+	if todo.ListID != nil {
+		var list models.TodoList
+		if err := t.db.First(&list, *todo.ListID); store.IsNotFound(err) {
+			return &columnError{"list_id", errors.New("list_id: no list has this id")}
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCycle rejects a parent that is the todo itself or one of its
+// subtasks, at any depth.
+func (t *TodoController) checkCycle(todo *models.Todo) error {
+	if todo.ID == 0 {
+		return nil
+	}
+	seen := map[uint]bool{}
+	for id := todo.ParentID; id != nil && !seen[*id]; {
+		if *id == todo.ID {
+			return &columnError{"parent_id", errors.New("parent_id: a todo cannot be below itself")}
+		}
+		seen[*id] = true
+		var parent models.Todo
+		if err := t.db.First(&parent, *id); store.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		id = parent.ParentID
+	}
+	return nil
 }
 
 func (t *TodoController) Create(c *gin.Context) {
@@ -53,12 +267,69 @@ func (t *TodoController) Create(c *gin.Context) {
 	}
 
 	todo := models.Todo{
-		Title:     req.Title,
-		Completed: false,
+		Title:           req.Title,
+		Completed:       false,
+		Owner:           currentUser(c),
+		DueAt:           req.DueAt,
+		ReminderMinutes: req.ReminderMinutes,
+		RRule:           req.RRule,
+		ListID:          req.ListID,
+		ParentID:        req.ParentID,
+		Priority:        req.Priority,
+	}
+	if err := checkSchedule(&todo); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var colErr *columnError
+	if err := t.place(&todo); errors.As(err, &colErr) {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	labels, err := labelsByID(t.db, req.LabelIDs)
+	if errors.As(err, &colErr) {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
 	}
 
-	if err := t.db.Create(&todo); err != nil {
-		c.JSON(500, gin.H{
+	if todo.Position, err = t.lastPosition(todo); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	db := store.As(t.db, currentUser(c))
+	if err := db.Create(&todo); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := setTodoLabels(db, todo.ID, labels); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	todo.Labels = labels
+	if todo.Assignees, err = setTodoAssignees(db, todo.ID, req.Assignees); err != nil {
+		c.JSON(errorStatus(err), gin.H{
 			"message": err.Error(),
 		})
 		return
@@ -68,3 +339,424 @@ func (t *TodoController) Create(c *gin.Context) {
 		"data": todo,
 	})
 }
+
+// loadTodo parses the :id parameter and loads the todo, answering the
+// request itself when it cannot.
+func loadTodo(c *gin.Context, db store.Storer) (models.Todo, bool) {
+	var todo models.Todo
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid ID format",
+		})
+		return todo, false
+	}
+
+	if err := db.First(&todo, uint(id)); err != nil {
+		if store.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Todo not found",
+			})
+			return todo, false
+		}
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return todo, false
+	}
+	return todo, true
+}
+
+// Complete marks a todo as done. For a recurring todo the next occurrence is
+// created straight away and returned as "next". With ?cascade=true every
+// subtask below the todo is completed too.
+func (t *TodoController) Complete(c *gin.Context) {
+	todo, ok := loadTodo(c, t.db)
+	if !ok {
+		return
+	}
+
+	db := store.As(t.db, currentUser(c))
+	if !todo.Completed {
+		// Only the field changed is written, so that the series fields the
+		// scheduler may be setting meanwhile are not reset.
+		err := store.Update(db, &models.Todo{}, map[string]any{"completed": true}, "id = ?", todo.ID)
+		if err == nil {
+			err = t.db.First(&todo, todo.ID)
+		}
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	// The scheduler retries on its next tick if this fails.
+	next, err := t.recurrence.Materialize(&todo, t.now())
+	if err != nil {
+		_ = c.Error(err)
+	}
+
+	response := gin.H{
+		"data": todo,
+	}
+	if next != nil {
+		response["next"] = next
+	}
+	if c.Query("cascade") == "true" {
+		n, err := t.completeSubtasks(db, todo.ID)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		response["subtasks_completed"] = n
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type TodoLabelsRequest struct {
+	LabelIDs []uint `json:"label_ids"`
+}
+
+// SetLabels replaces the labels of a todo.
+func (t *TodoController) SetLabels(c *gin.Context) {
+	var req TodoLabelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	todo, ok := loadTodo(c, t.db)
+	if !ok {
+		return
+	}
+	labels, err := labelsByID(t.db, req.LabelIDs)
+	var colErr *columnError
+	if errors.As(err, &colErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := setTodoLabels(store.As(t.db, currentUser(c)), todo.ID, labels); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	todo.Labels = labels
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": todo,
+	})
+}
+
+// completeSubtasks completes every open todo below id, level by level, and
+// returns how many it completed.
+func (t *TodoController) completeSubtasks(db store.Storer, id uint) (int, error) {
+	n := 0
+	seen := map[uint]bool{id: true}
+	for queue := []uint{id}; len(queue) > 0; queue = queue[1:] {
+		var children []models.Todo
+		if err := t.db.Find(&children, "parent_id = ?", queue[0]); err != nil && !store.IsNotFound(err) {
+			return n, err
+		}
+		for i := range children {
+			child := &children[i]
+			if seen[child.ID] {
+				continue
+			}
+			seen[child.ID] = true
+			queue = append(queue, child.ID)
+			if child.Completed {
+				continue
+			}
+
+			if err := store.Update(db, &models.Todo{}, map[string]any{"completed": true}, "id = ?", child.ID); err != nil {
+				return n, err
+			}
+			child.Completed = true
+			n++
+			if _, err := t.recurrence.Materialize(child, t.now()); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+type TodoBulkItem struct {
+	ID              uint            `json:"id"`
+	Title           string          `json:"title" binding:"required"`
+	Completed       bool            `json:"completed"`
+	DueAt           *time.Time      `json:"due_at"`
+	ReminderMinutes int             `json:"reminder_minutes" binding:"gte=0"`
+	RRule           string          `json:"rrule"`
+	ListID          *uint           `json:"list_id"`
+	ParentID        *uint           `json:"parent_id"`
+	Priority        models.Priority `json:"priority"`
+	Position        string          `json:"position"`
+}
+
+// checkPosition rejects a position that fracindex could not have produced.
+func checkPosition(todo *models.Todo) error {
+	if todo.Position == "" {
+		return nil
+	}
+	if err := fracindex.Validate(todo.Position); err != nil {
+		return &columnError{"position", err}
+	}
+	return nil
+}
+
+// Bulk creates, updates and deletes todos in one request; see bulkWrite.
+// Created todos belong to the X-User; updates keep the owner and the
+// series of the todo. Lists and parents are checked as on Create.
+func (t *TodoController) Bulk(c *gin.Context) {
+	owner := currentUser(c)
+	bulkWrite(c, store.As(t.db, owner), bulkModel{
+		decode: func(op store.BulkOpKind, raw []byte) (any, error) {
+			var item TodoBulkItem
+			if err := json.Unmarshal(raw, &item); err != nil {
+				return nil, err
+			}
+
+			todo := models.Todo{Owner: owner}
+			if op == store.BulkUpdate && item.ID != 0 {
+				err := t.db.First(&todo, item.ID)
+				switch {
+				case err == nil:
+					item = TodoBulkItem{
+						ID:              todo.ID,
+						Title:           todo.Title,
+						Completed:       todo.Completed,
+						DueAt:           todo.DueAt,
+						ReminderMinutes: todo.ReminderMinutes,
+						RRule:           todo.RRule,
+						ListID:          todo.ListID,
+						ParentID:        todo.ParentID,
+						Priority:        todo.Priority,
+						Position:        todo.Position,
+					}
+					if err := mergeJSON(&item, raw); err != nil {
+						return nil, err
+					}
+				case !store.IsNotFound(err):
+					return nil, err
+				}
+			}
+			if err := validateBulkItem(op, &item, item.ID != 0); err != nil {
+				return nil, err
+			}
+
+			todo.ID = item.ID
+			todo.Title = item.Title
+			todo.Completed = item.Completed
+			todo.DueAt = item.DueAt
+			todo.ReminderMinutes = item.ReminderMinutes
+			todo.RRule = item.RRule
+			todo.ListID = item.ListID
+			todo.ParentID = item.ParentID
+			todo.Priority = item.Priority
+			todo.Position = item.Position
+			if op != store.BulkDelete {
+				if err := checkSchedule(&todo); err != nil {
+					return nil, err
+				}
+				if err := checkPosition(&todo); err != nil {
+					return nil, err
+				}
+				if err := t.place(&todo); err != nil {
+					return nil, err
+				}
+			}
+			return &todo, nil
+		},
+		id: func(record any) any { return record.(*models.Todo).ID },
+	})
+}
+
+var todoTransfer = transferModel{
+	name:      "todos",
+	newRecord: func() any { return &models.Todo{} },
+	columns: []csvColumn{
+		{
+			name: "id",
+			get:  func(r any) string { return strconv.FormatUint(uint64(r.(*models.Todo).ID), 10) },
+			set: func(r any, v string) error {
+				id, err := strconv.ParseUint(v, 10, 0)
+				if err != nil {
+					return errors.New("must be a positive whole number")
+				}
+				r.(*models.Todo).ID = uint(id)
+				return nil
+			},
+		},
+		{
+			name: "title",
+			get:  func(r any) string { return r.(*models.Todo).Title },
+			set:  func(r any, v string) error { r.(*models.Todo).Title = v; return nil },
+		},
+		{
+			name: "completed",
+			get:  func(r any) string { return strconv.FormatBool(r.(*models.Todo).Completed) },
+			set: func(r any, v string) error {
+				completed, err := strconv.ParseBool(v)
+				if err != nil {
+					return errors.New("must be true or false")
+				}
+				r.(*models.Todo).Completed = completed
+				return nil
+			},
+		},
+		{
+			name: "owner",
+			get:  func(r any) string { return r.(*models.Todo).Owner },
+		},
+		{
+			name: "due_at",
+			get: func(r any) string {
+				if due := r.(*models.Todo).DueAt; due != nil {
+					return due.Format(time.RFC3339)
+				}
+				return ""
+			},
+			set: func(r any, v string) error {
+				due, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					return errors.New("must be an RFC 3339 time")
+				}
+				r.(*models.Todo).DueAt = &due
+				return nil
+			},
+		},
+		{
+			name: "reminder_minutes",
+			get:  func(r any) string { return strconv.Itoa(r.(*models.Todo).ReminderMinutes) },
+			set: func(r any, v string) error {
+				minutes, err := strconv.Atoi(v)
+				if err != nil {
+					return errors.New("must be a whole number")
+				}
+				r.(*models.Todo).ReminderMinutes = minutes
+				return nil
+			},
+		},
+		{
+			name: "rrule",
+			get:  func(r any) string { return r.(*models.Todo).RRule },
+			set:  func(r any, v string) error { r.(*models.Todo).RRule = v; return nil },
+		},
+		todoRefColumn("list_id", func(t *models.Todo) **uint { return &t.ListID }),
+		todoRefColumn("parent_id", func(t *models.Todo) **uint { return &t.ParentID }),
+		{
+			name: "priority",
+			get:  func(r any) string { return r.(*models.Todo).Priority.String() },
+			set: func(r any, v string) error {
+				p, err := models.ParsePriority(v)
+				if err != nil {
+					return err
+				}
+				r.(*models.Todo).Priority = p
+				return nil
+			},
+		},
+		{
+			name: "position",
+			get:  func(r any) string { return r.(*models.Todo).Position },
+			set:  func(r any, v string) error { r.(*models.Todo).Position = v; return nil },
+		},
+		{
+			name: "created_at",
+			get:  func(r any) string { return r.(*models.Todo).CreatedAt.Format(time.RFC3339) },
+		},
+		{
+			name: "updated_at",
+			get:  func(r any) string { return r.(*models.Todo).UpdatedAt.Format(time.RFC3339) },
+		},
+	},
+	validate: func(r any) error {
+		todo := r.(*models.Todo)
+		req := TodoCreateRequest{Title: todo.Title, ReminderMinutes: todo.ReminderMinutes}
+		if err := binding.Validator.ValidateStruct(req); err != nil {
+			return err
+		}
+		if err := checkSchedule(todo); err != nil {
+			return err
+		}
+		return checkPosition(todo)
+	},
+	hasID: func(r any) bool { return r.(*models.Todo).ID != 0 },
+	id:    func(r any) any { return r.(*models.Todo).ID },
+	load: func(db store.Storer, r any) (any, error) {
+		var todo models.Todo
+		err := db.First(&todo, r.(*models.Todo).ID)
+		return &todo, err
+	},
+	protect: func(stored any) func(any) {
+		s := *stored.(*models.Todo)
+		if s.SeriesID != nil {
+			// A decoded row would write through the stored pointer.
+			id := *s.SeriesID
+			s.SeriesID = &id
+		}
+		return func(r any) {
+			todo := r.(*models.Todo)
+			todo.Owner = s.Owner
+			todo.SeriesID, todo.Occurrence, todo.NextMaterialized = s.SeriesID, s.Occurrence, s.NextMaterialized
+		}
+	},
+}
+
+// todoRefColumn is a column holding an optional ID of another record.
+func todoRefColumn(name string, field func(*models.Todo) **uint) csvColumn {
+	return csvColumn{
+		name: name,
+		get: func(r any) string {
+			if id := *field(r.(*models.Todo)); id != nil {
+				return strconv.FormatUint(uint64(*id), 10)
+			}
+			return ""
+		},
+		set: func(r any, v string) error {
+			id, err := strconv.ParseUint(v, 10, 0)
+			if err != nil {
+				return errors.New("must be a positive whole number")
+			}
+			ref := uint(id)
+			*field(r.(*models.Todo)) = &ref
+			return nil
+		},
+	}
+}
+
+// Export streams every todo as JSON, NDJSON or CSV; see exportRecords.
+func (t *TodoController) Export(c *gin.Context) {
+	exportRecords(c, t.db, todoTransfer)
+}
+
+// Import creates and updates todos from CSV or NDJSON; see importRecords.
+// Created todos belong to the X-User and updated ones keep their owner;
+// lists and parents are checked as on Create.
+func (t *TodoController) Import(c *gin.Context) {
+	owner := currentUser(c)
+	m := todoTransfer
+	m.newRecord = func() any { return &models.Todo{Owner: owner} }
+	m.validate = func(r any) error {
+		if err := todoTransfer.validate(r); err != nil {
+			return err
+		}
+		return t.place(r.(*models.Todo))
+	}
+	importRecords(c, store.As(t.db, owner), m)
+}
@@ -31,7 +31,7 @@ const (
 func setupApp(db store.Storer) *httptest.ResponseRecorder {
 	gin.SetMode(gin.TestMode)
 
-	todoController := NewTodoController(db)
+	todoController := NewTodoController(db, nil)
 
 	r := gin.New()
 	r.GET(pathTodo, todoController.Index)
@@ -46,7 +46,7 @@ func setupApp(db store.Storer) *httptest.ResponseRecorder {
 func setupPost(db store.Storer, body *strings.Reader) *httptest.ResponseRecorder {
 	gin.SetMode(gin.TestMode)
 
-	todoController := NewTodoController(db)
+	todoController := NewTodoController(db, nil)
 
 	r := gin.New()
 	r.POST(pathTodo, todoController.Create)
@@ -174,3 +174,63 @@ func TestCreateTodo(t *testing.T) {
 		assert.Equal(t, gorm.ErrInvalidData.Error(), response.Message)
 	})
 }
+
+func TestCreateThenListTodos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	todoController := NewTodoController(store.NewMemoryStore(), nil)
+	r := gin.New()
+	r.GET(pathTodo, todoController.Index)
+	r.POST(pathTodo, todoController.Create)
+
+	for _, title := range []string{"Buy groceries", "Read a book"} {
+		req, _ := http.NewRequest(http.MethodPost, pathTodo, strings.NewReader(`{"title":"`+title+`"}`))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, pathTodo, nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var response struct {
+		Data []models.Todo `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Len(t, response.Data, 2)
+	assert.Equal(t, uint(1), response.Data[0].ID)
+	assert.Equal(t, "Read a book", response.Data[1].Title)
+}
+
+func TestCompleteTodo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := store.NewMemoryStore()
+	due := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	chore := models.Todo{Title: "Water the plants", DueAt: &due, RRule: "FREQ=WEEKLY"}
+	assert.NoError(t, db.Create(&chore))
+
+	todoController := NewTodoController(db, nil)
+	todoController.now = func() time.Time { return due.Add(-time.Hour) }
+	r := gin.New()
+	r.POST(pathTodo+"/:id/complete", todoController.Complete)
+
+	rec := serve(r, http.MethodPost, pathTodo+"/1/complete", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response struct {
+		Data models.Todo  `json:"data"`
+		Next *models.Todo `json:"next"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.True(t, response.Data.Completed)
+	if assert.NotNil(t, response.Next) {
+		assert.Equal(t, due.AddDate(0, 0, 7), response.Next.DueAt.UTC())
+	}
+
+	rec = serve(r, http.MethodPost, pathTodo+"/1/complete", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"next"`)
+
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodPost, pathTodo+"/9/complete", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodPost, pathTodo+"/x/complete", "").Code)
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser is the user making the request. There are no accounts yet:
+// callers name themselves in the X-User header, which a gateway in front of
+// the service is expected to set.
+func currentUser(c *gin.Context) string {
+	return strings.TrimSpace(c.GetHeader("X-User"))
+}
+
+// IsAdmin reports whether the request carries token in its X-Admin-Token
+// header. Without a token configured nobody is an admin.
+func IsAdmin(c *gin.Context, token string) bool {
+	return token != "" && c.GetHeader("X-Admin-Token") == token
+}
@@ -3,7 +3,9 @@ package db
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"github.com/sing3demons/go-example/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -15,14 +17,71 @@ func ConnectDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("DATABASE_URL is empty")
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	return Open(dsn)
+}
+
+// Open connects to dsn with the driver matching its scheme and migrates the
+// schema. postgres:// and postgresql:// use postgres; sqlite:// and file: use
+// the embedded SQLite driver, with sqlite://:memory: for a throwaway database.
+func Open(dsn string) (*gorm.DB, error) {
+	dialector, err := Dialector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
 
+	if dialector.Name() == "sqlite" {
+		// SQLite allows a single writer, and every connection to :memory:
+		// would get its own empty database.
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if err := db.SetupJoinTable(&models.Todo{}, "Labels", &models.TodoLabel{}); err != nil {
+		return nil, err
+	}
+
 	// Migrate the schema
-	db.AutoMigrate(&models.Todo{})
+	if err := db.AutoMigrate(&models.TodoList{}, &models.Label{}, &models.Todo{}, &models.TodoAssignee{}, &models.Comment{}, &models.Activity{}); err != nil {
+		return nil, err
+	}
+
+	if dialector.Name() == "postgres" {
+		for _, ddl := range todoSearchDDL {
+			if err := db.Exec(ddl).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	return db, nil
+}
+
+// todoSearchDDL adds the full-text index searched by search.PostgresTodos.
+// The column is generated, so gorm never writes it.
+var todoSearchDDL = []string{
+	`ALTER TABLE todos ADD COLUMN IF NOT EXISTS search tsvector
+		GENERATED ALWAYS AS (to_tsvector('english', coalesce(title, ''))) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_todos_search ON todos USING GIN (search)`,
+}
+
+func Dialector(dsn string) (gorm.Dialector, error) {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return postgres.Open(dsn), nil
+	case strings.HasPrefix(dsn, "sqlite://"):
+		return sqlite.Open(strings.TrimPrefix(dsn, "sqlite://")), nil
+	case strings.HasPrefix(dsn, "file:"):
+		return sqlite.Open(dsn), nil
+	}
 
+	scheme, _, _ := strings.Cut(dsn, ":")
+	return nil, fmt.Errorf("DATABASE_URL: unsupported scheme %q", scheme)
 }
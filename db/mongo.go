@@ -12,6 +12,22 @@ import (
 )
 
 func ConnectMonoDB() (*mongo.Collection, error) {
+	col, err := OpenMongo()
+	if err != nil {
+		return nil, err
+	}
+
+	// Migrate the schema
+	if err := MigrateMongo(col); err != nil {
+		return nil, err
+	}
+
+	return col, nil
+}
+
+// OpenMongo connects to MONGO_URL and returns the products collection
+// without touching its schema.
+func OpenMongo() (*mongo.Collection, error) {
 	uri := os.Getenv("MONGO_URL")
 	if uri == "" {
 		return nil, fmt.Errorf("MONGO_URL is empty")
@@ -22,11 +38,11 @@ func ConnectMonoDB() (*mongo.Collection, error) {
 
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	if err := client.Ping(ctx, readpref.Primary()); err != nil {
-		panic(err)
+		return nil, err
 	}
 	fmt.Println("Connected to MongoDB!")
 
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func ProductSchema() store.MongoSchema {
+	return store.MongoSchema{
+		Indexes: []store.MongoIndex{
+			{Name: "name_unique", Keys: bson.D{{Key: "name", Value: 1}}, Unique: true},
+			{Name: "price", Keys: bson.D{{Key: "price.currency", Value: 1}, {Key: "price.amount", Value: 1}}},
+			{Name: "category_ids", Keys: bson.D{{Key: "category_ids", Value: 1}}},
+			{Name: "tags", Keys: bson.D{{Key: "tags", Value: 1}}},
+			{Name: "name_description_text", Keys: bson.D{
+				{Key: "name", Value: "text"},
+				{Key: "description", Value: "text"},
+			}},
+		},
+		Validator: store.JSONSchema(models.Product{}),
+	}
+}
+
+// Collections holding categories, stock, prices, carts and orders, next to
+// the products collection.
+const (
+	CategoriesCollection      = "categories"
+	InventoryCollection       = "inventory"
+	StockMovementsCollection  = "stock_movements"
+	PriceHistoryCollection    = "price_history"
+	ScheduledPricesCollection = "scheduled_prices"
+	CartsCollection           = "carts"
+	OrdersCollection          = "orders"
+	PromotionsCollection      = "promotions"
+)
+
+func CategorySchema() store.MongoSchema {
+	return store.MongoSchema{
+		Indexes: []store.MongoIndex{
+			// Roots have no parent_id, which the index takes as null, so
+			// their names are unique too.
+			{Name: "parent_name_unique", Keys: bson.D{{Key: "parent_id", Value: 1}, {Key: "name", Value: 1}}, Unique: true},
+			{Name: "ancestors", Keys: bson.D{{Key: "ancestors", Value: 1}}},
+		},
+		Validator: store.JSONSchema(models.Category{}),
+	}
+}
+
+func InventorySchema() store.MongoSchema {
+	return store.MongoSchema{
+		Indexes: []store.MongoIndex{
+			{Name: "product", Keys: bson.D{{Key: "product_id", Value: 1}}},
+		},
+		Validator: store.JSONSchema(models.StockLevel{}),
+	}
+}
+
+func StockMovementSchema() store.MongoSchema {
+	return store.MongoSchema{
+		Indexes: []store.MongoIndex{
+			{Name: "product_warehouse_created", Keys: bson.D{
+				{Key: "product_id", Value: 1},
+				{Key: "warehouse", Value: 1},
+				{Key: "created_at", Value: 1},
+			}},
+		},
+		Validator: store.JSONSchema(models.StockMovement{}),
+	}
+}
+
+func PriceHistorySchema() store.MongoSchema {
+	return store.MongoSchema{
+		Indexes: []store.MongoIndex{
+			{Name: "product_changed", Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "changed_at", Value: 1}}},
+		},
+		Validator: store.JSONSchema(models.PriceChange{}),
+	}
+}
+
+func ScheduledPriceSchema() store.MongoSchema {
+	return store.MongoSchema{
+		Indexes: []store.MongoIndex{
+			{Name: "product_starts", Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "starts_at", Value: 1}}},
+			// The scheduler looks for pending and active schedules.
+			{Name: "status_starts", Keys: bson.D{{Key: "status", Value: 1}, {Key: "starts_at", Value: 1}}},
+		},
+		Validator: store.JSONSchema(models.ScheduledPrice{}),
+	}
+}
+
+func CartSchema() store.MongoSchema {
+	return store.MongoSchema{
+		// Carts are found by _id, their user.
+		Validator: store.JSONSchema(models.Cart{}),
+	}
+}
+
+func OrderSchema() store.MongoSchema {
+	return store.MongoSchema{
+		Indexes: []store.MongoIndex{
+			{Name: "user_created", Keys: bson.D{{Key: "user", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+		Validator: store.JSONSchema(models.Order{}),
+	}
+}
+
+func PromotionSchema() store.MongoSchema {
+	return store.MongoSchema{
+		Indexes: []store.MongoIndex{
+			{Name: "code", Keys: bson.D{{Key: "code", Value: 1}}},
+		},
+		Validator: store.JSONSchema(models.Promotion{}),
+	}
+}
+
+// MongoCollection is a collection together with the schema it should have.
+type MongoCollection struct {
+	Collection *mongo.Collection
+	Schema     store.MongoSchema
+}
+
+// MongoCollections returns the products collection and those stored next to
+// it, in the database of products, with their schemas.
+func MongoCollections(products *mongo.Collection) []MongoCollection {
+	database := products.Database()
+	return []MongoCollection{
+		{products, ProductSchema()},
+		{database.Collection(CategoriesCollection), CategorySchema()},
+		{database.Collection(InventoryCollection), InventorySchema()},
+		{database.Collection(StockMovementsCollection), StockMovementSchema()},
+		{database.Collection(PriceHistoryCollection), PriceHistorySchema()},
+		{database.Collection(ScheduledPricesCollection), ScheduledPriceSchema()},
+		{database.Collection(CartsCollection), CartSchema()},
+		{database.Collection(OrdersCollection), OrderSchema()},
+		{database.Collection(PromotionsCollection), PromotionSchema()},
+	}
+}
+
+// MigrateMongo applies the schema of every collection in MongoCollections
+// and prints what had to change.
+func MigrateMongo(products *mongo.Collection) error {
+	for _, c := range MongoCollections(products) {
+		diff, err := store.ApplyMongoSchema(c.Collection, c.Schema)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s\n", c.Collection.Name(), diff)
+	}
+	return nil
+}
+
+// legacyPrices matches products whose price predates money.Money: a bare
+// number, or no price at all where a price of 0 was dropped by omitempty.
+var legacyPrices = bson.M{"$or": bson.A{
+	bson.M{"price": bson.M{"$type": "number"}},
+	bson.M{"price": bson.M{"$exists": false}},
+}}
+
+// CountLegacyPrices counts the products MigratePrices would convert.
+func CountLegacyPrices(col *mongo.Collection) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return col.CountDocuments(ctx, legacyPrices)
+}
+
+// MigratePrices converts legacy prices, read as minor units of currency, to
+// the stored form of money.Money, and returns how many it converted.
+func MigratePrices(col *mongo.Collection, currency string) (int64, error) {
+	c, err := money.Lookup(currency)
+	if err != nil {
+		return 0, err
+	}
+	scale, _ := primitive.ParseDecimal128(fmt.Sprintf("1e%d", c.Digits))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	amount := bson.M{"$divide": bson.A{
+		bson.M{"$toDecimal": bson.M{"$ifNull": bson.A{"$price", 0}}},
+		scale,
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"price": bson.M{"amount": amount, "currency": c.Code},
+	}}}}
+	result, err := col.UpdateMany(ctx, legacyPrices, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
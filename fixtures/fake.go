@@ -0,0 +1,49 @@
+package fixtures
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/sing3demons/go-example/money"
+)
+
+var (
+	verbs      = []string{"buy", "call", "clean", "fix", "read", "review", "write", "plan", "book", "send"}
+	nouns      = []string{"groceries", "report", "garden", "invoice", "book", "car", "email", "slides", "tickets", "budget"}
+	adjectives = []string{"blue", "red", "cotton", "wooden", "classic", "compact", "large", "small", "organic", "steel"}
+	items      = []string{"shirt", "mug", "chair", "lamp", "notebook", "backpack", "bottle", "pen", "table", "towel"}
+)
+
+// Fake generates a set with the given number of todos and products. The same
+// seed always produces the same set, including product IDs.
+func Fake(seed int64, todos, products int) *Set {
+	r := rand.New(rand.NewSource(seed))
+	pick := func(words []string) string { return words[r.Intn(len(words))] }
+
+	set := &Set{}
+	for i := 0; i < todos; i++ {
+		set.Todos = append(set.Todos, Todo{
+			Title:     fmt.Sprintf("%s %s", pick(verbs), pick(nouns)),
+			Completed: r.Intn(4) == 0,
+		})
+	}
+
+	for i := 0; i < products; i++ {
+		adjective, item := pick(adjectives), pick(items)
+		// The index keeps names unique, which the products collection requires.
+		set.Products = append(set.Products, Product{
+			Name:        fmt.Sprintf("%s %s %d", strings.ToUpper(adjective[:1])+adjective[1:], item, i+1),
+			Price:       money.New(int64(100+r.Intn(9900)), "USD"),
+			Description: fmt.Sprintf("A %s %s made of %s.", adjective, item, pick(adjectives)),
+		})
+	}
+
+	return set
+}
+
+// Merge appends the todos and products of other to s.
+func (s *Set) Merge(other *Set) {
+	s.Todos = append(s.Todos, other.Todos...)
+	s.Products = append(s.Products, other.Products...)
+}
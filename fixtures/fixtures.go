@@ -0,0 +1,194 @@
+package fixtures
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+)
+
+// Todo is a todo fixture. Todos are inserted in file order, so after a reset
+// the first todo gets ID 1, the second ID 2 and so on.
+type Todo struct {
+	Title     string `json:"title" yaml:"title"`
+	Completed bool   `json:"completed" yaml:"completed"`
+}
+
+// Product is a product fixture. ID is a hex ObjectID; when it is empty the ID
+// is derived from Key, or from Name when Key is empty too, so loading the same
+// file twice gives the same IDs.
+type Product struct {
+	ID          string      `json:"id" yaml:"id"`
+	Key         string      `json:"key" yaml:"key"`
+	Name        string      `json:"name" yaml:"name"`
+	Price       money.Money `json:"price" yaml:"price"`
+	Description string      `json:"description" yaml:"description"`
+}
+
+type Set struct {
+	Todos    []Todo    `json:"todos" yaml:"todos"`
+	Products []Product `json:"products" yaml:"products"`
+}
+
+// Load reads a fixture set from a .json, .yaml or .yml file.
+func Load(path string) (*Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := Parse(b, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return set, nil
+}
+
+// Parse decodes a fixture set. format is a file extension such as ".yaml".
+func Parse(b []byte, format string) (*Set, error) {
+	var set Set
+	var err error
+	switch format {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &set)
+	case ".json":
+		err = json.Unmarshal(b, &set)
+	default:
+		return nil, fmt.Errorf("unsupported fixture format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &set, nil
+}
+
+// Models converts the fixtures into the models written to the stores.
+func (s *Set) Models() ([]models.Todo, []models.Product, error) {
+	todos := make([]models.Todo, 0, len(s.Todos))
+	for _, t := range s.Todos {
+		todos = append(todos, models.Todo{Title: t.Title, Completed: t.Completed})
+	}
+
+	products := make([]models.Product, 0, len(s.Products))
+	for i, p := range s.Products {
+		id, err := p.objectID()
+		if err != nil {
+			return nil, nil, fmt.Errorf("products[%d]: %w", i, err)
+		}
+		products = append(products, models.Product{
+			ID:          id,
+			Name:        p.Name,
+			Price:       p.Price,
+			Description: p.Description,
+		})
+	}
+
+	return todos, products, nil
+}
+
+// FromModels converts stored todos and products back into a set, keeping
+// the product IDs so that loading the set again gives the same products.
+func FromModels(todos []models.Todo, products []models.Product) *Set {
+	set := &Set{Todos: []Todo{}, Products: []Product{}}
+	for _, t := range todos {
+		set.Todos = append(set.Todos, Todo{Title: t.Title, Completed: t.Completed})
+	}
+	for _, p := range products {
+		set.Products = append(set.Products, Product{
+			ID:          p.ID.Hex(),
+			Name:        p.Name,
+			Price:       p.Price,
+			Description: p.Description,
+		})
+	}
+	return set
+}
+
+func (p Product) objectID() (primitive.ObjectID, error) {
+	if p.ID != "" {
+		return primitive.ObjectIDFromHex(p.ID)
+	}
+
+	key := p.Key
+	if key == "" {
+		key = p.Name
+	}
+	if key == "" {
+		return primitive.NilObjectID, fmt.Errorf("product needs an id, key or name")
+	}
+	return DeterministicID("products/" + key), nil
+}
+
+// DeterministicID derives an ObjectID from key.
+func DeterministicID(key string) primitive.ObjectID {
+	var id primitive.ObjectID
+	sum := sha1.Sum([]byte(key))
+	copy(id[:], sum[:])
+	return id
+}
+
+type Options struct {
+	// Truncate removes existing todos and products before loading.
+	Truncate bool
+}
+
+type Result struct {
+	Todos    int
+	Products int
+}
+
+// Loader writes fixture sets through the configured stores.
+type Loader struct {
+	Todos    store.Storer
+	Products store.Storer
+}
+
+// Reset removes every todo and product.
+func (l *Loader) Reset() error {
+	if err := store.Truncate(l.Todos, &models.Todo{}); err != nil {
+		return fmt.Errorf("truncate todos: %w", err)
+	}
+	if err := store.Truncate(l.Products, &models.Product{}); err != nil {
+		return fmt.Errorf("truncate products: %w", err)
+	}
+	return nil
+}
+
+func (l *Loader) Load(set *Set, opts Options) (Result, error) {
+	var result Result
+
+	todos, products, err := set.Models()
+	if err != nil {
+		return result, err
+	}
+
+	if opts.Truncate {
+		if err := l.Reset(); err != nil {
+			return result, err
+		}
+	}
+
+	for i := range todos {
+		if err := l.Todos.Create(&todos[i]); err != nil {
+			return result, fmt.Errorf("todo %q: %w", todos[i].Title, err)
+		}
+		result.Todos++
+	}
+
+	for i := range products {
+		if err := l.Products.Create(&products[i]); err != nil {
+			return result, fmt.Errorf("product %q: %w", products[i].Name, err)
+		}
+		result.Products++
+	}
+
+	return result, nil
+}
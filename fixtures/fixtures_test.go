@@ -0,0 +1,106 @@
+package fixtures_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sing3demons/go-example/fixtures"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	set, err := fixtures.Parse([]byte(`
+todos:
+  - title: Buy groceries
+    completed: true
+products:
+  - id: 683c5aa378692349cc47a0a7
+    name: Test2
+    price: {amount: 1010, currency: USD}
+    description: Test
+  - key: mug
+    name: Blue mug
+    price: {amount: 250, currency: THB}
+    description: A mug
+`), ".yaml")
+	assert.NoError(t, err)
+
+	todos, products, err := set.Models()
+	assert.NoError(t, err)
+	assert.Equal(t, "Buy groceries", todos[0].Title)
+	assert.True(t, todos[0].Completed)
+	assert.Equal(t, "683c5aa378692349cc47a0a7", products[0].ID.Hex())
+	assert.Equal(t, fixtures.DeterministicID("products/mug"), products[1].ID)
+	assert.Equal(t, money.New(250, "THB"), products[1].Price)
+}
+
+func TestFromModels(t *testing.T) {
+	set, err := fixtures.Parse([]byte(`{"todos":[{"title":"Read","completed":true}],"products":[{"key":"pen","name":"Pen","price":{"amount":10,"currency":"usd"},"description":"Blue pen"}]}`), ".json")
+	assert.NoError(t, err)
+	todos, products, err := set.Models()
+	assert.NoError(t, err)
+
+	roundTrip, err := json.Marshal(fixtures.FromModels(todos, products))
+	assert.NoError(t, err)
+	set, err = fixtures.Parse(roundTrip, ".json")
+	assert.NoError(t, err)
+	todos2, products2, err := set.Models()
+	assert.NoError(t, err)
+	assert.Equal(t, todos, todos2)
+	assert.Equal(t, products, products2, "the product IDs are kept")
+	assert.Equal(t, money.New(10, "USD"), products2[0].Price)
+}
+
+func TestParseUnsupportedFormat(t *testing.T) {
+	_, err := fixtures.Parse([]byte(`todos = []`), ".toml")
+	assert.Error(t, err)
+}
+
+func TestModelsInvalidID(t *testing.T) {
+	set := &fixtures.Set{Products: []fixtures.Product{{ID: "not-hex", Name: "Pen"}}}
+
+	_, _, err := set.Models()
+	assert.Error(t, err)
+}
+
+func TestFakeIsDeterministic(t *testing.T) {
+	a := fixtures.Fake(42, 5, 5)
+	b := fixtures.Fake(42, 5, 5)
+	assert.Equal(t, a, b)
+	assert.Len(t, a.Todos, 5)
+	assert.Len(t, a.Products, 5)
+
+	_, products, err := a.Models()
+	assert.NoError(t, err)
+	names := map[string]bool{}
+	for _, p := range products {
+		assert.False(t, names[p.Name], "duplicate name %s", p.Name)
+		names[p.Name] = true
+	}
+}
+
+func TestLoaderLoad(t *testing.T) {
+	set := fixtures.Fake(1, 3, 2)
+
+	t.Run("writes through the stores", func(t *testing.T) {
+		loader := fixtures.Loader{Todos: &store.MockStore{}, Products: &store.MockStore{}}
+
+		result, err := loader.Load(set, fixtures.Options{Truncate: true})
+		assert.NoError(t, err)
+		assert.Equal(t, fixtures.Result{Todos: 3, Products: 2}, result)
+	})
+
+	t.Run("stops on truncate error", func(t *testing.T) {
+		loader := fixtures.Loader{
+			Todos:    &store.MockStore{Err: errors.New("truncate failed")},
+			Products: &store.MockStore{},
+		}
+
+		result, err := loader.Load(set, fixtures.Options{Truncate: true})
+		assert.EqualError(t, err, "truncate todos: truncate failed")
+		assert.Zero(t, result.Todos)
+	})
+}
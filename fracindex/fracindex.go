@@ -0,0 +1,111 @@
+// Package fracindex generates keys for manual ordering. A key sorts between
+// any two others by plain byte comparison, so moving an item only rewrites
+// that item's key.
+package fracindex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// digits are in ascending byte order.
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+// Validate reports whether key is a key Between can produce: digits only,
+// not ending in the smallest digit.
+func Validate(key string) error {
+	if key == "" {
+		return errors.New("fracindex: empty key")
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return fmt.Errorf("fracindex: invalid character %q in key %q", key[i], key)
+		}
+	}
+	if key[len(key)-1] == digits[0] {
+		return fmt.Errorf("fracindex: key %q ends in %q", key, digits[0])
+	}
+	return nil
+}
+
+// Between returns a key greater than a and less than b. An empty a means
+// before everything, an empty b after everything.
+func Between(a, b string) (string, error) {
+	if a != "" {
+		if err := Validate(a); err != nil {
+			return "", err
+		}
+	}
+	if b != "" {
+		if err := Validate(b); err != nil {
+			return "", err
+		}
+		if a >= b {
+			return "", fmt.Errorf("fracindex: %q is not less than %q", a, b)
+		}
+	}
+	if a != "" && b == "" {
+		return increment(a), nil
+	}
+	return midpoint(a, b), nil
+}
+
+// increment returns the shortest key after a, so that appending one item
+// after another grows keys by a digit only every 61 appends.
+func increment(a string) string {
+	for i := 0; i < len(a); i++ {
+		if d := strings.IndexByte(digits, a[i]); d < base-1 {
+			return a[:i] + string(digits[d+1])
+		}
+	}
+	return a + string(digits[1])
+}
+
+// midpoint treats a and b as fractions in base 62; b == "" is 1.
+func midpoint(a, b string) string {
+	if b != "" {
+		// Keep the common prefix, padding a with zeros.
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(tail(a, n), b[n:])
+		}
+	}
+
+	da := 0
+	if a != "" {
+		da = strings.IndexByte(digits, a[0])
+	}
+	db := base
+	if b != "" {
+		db = strings.IndexByte(digits, b[0])
+	}
+
+	if db-da > 1 {
+		return string(digits[(da+db+1)/2])
+	}
+	// The first digits are adjacent.
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(digits[da]) + midpoint(tail(a, 1), "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return digits[0]
+}
+
+func tail(s string, n int) string {
+	if n >= len(s) {
+		return ""
+	}
+	return s[n:]
+}
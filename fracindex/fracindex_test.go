@@ -0,0 +1,72 @@
+package fracindex_test
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/sing3demons/go-example/fracindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBetween(t *testing.T) {
+	for _, tc := range []struct{ a, b, want string }{
+		{"", "", "V"},
+		{"V", "", "W"},
+		{"", "V", "G"},
+		{"1", "2", "1V"},
+		{"1", "11", "10V"},
+		{"a", "b", "aV"},
+		{"az", "b", "azV"},
+		{"y", "z", "yV"},
+		{"z", "", "z1"},
+	} {
+		got, err := fracindex.Between(tc.a, tc.b)
+		require.NoError(t, err, tc)
+		assert.Equal(t, tc.want, got, tc)
+		assert.NoError(t, fracindex.Validate(got))
+	}
+
+	for _, tc := range []struct{ a, b string }{
+		{"b", "a"},
+		{"a", "a"},
+		{"a0", ""},
+		{"a!", ""},
+	} {
+		_, err := fracindex.Between(tc.a, tc.b)
+		assert.Error(t, err, tc)
+	}
+}
+
+func TestBetweenKeepsOrder(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for i := 0; i < 2000; i++ {
+		at := rng.Intn(len(keys) + 1)
+		a, b := "", ""
+		if at > 0 {
+			a = keys[at-1]
+		}
+		if at < len(keys) {
+			b = keys[at]
+		}
+
+		key, err := fracindex.Between(a, b)
+		require.NoError(t, err)
+		require.True(t, a < key && (b == "" || key < b), "%q < %q < %q", a, key, b)
+		keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+	}
+	assert.True(t, sort.StringsAreSorted(keys))
+}
+
+func TestAppendKeepsKeysShort(t *testing.T) {
+	key := ""
+	for i := 0; i < 1000; i++ {
+		next, err := fracindex.Between(key, "")
+		require.NoError(t, err)
+		require.Less(t, key, next)
+		key = next
+	}
+	assert.LessOrEqual(t, len(key), 17)
+}
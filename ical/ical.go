@@ -0,0 +1,367 @@
+// Package ical reads and writes the subset of iCalendar (RFC 5545) used for
+// todo feeds: components, properties with parameters, dates, durations and
+// recurrence rules.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Property struct {
+	Name   string
+	Params map[string]string
+	// Value is the raw value; TEXT values are escaped, see Text.
+	Value string
+}
+
+// Text returns the value of a TEXT property with its escapes removed.
+func (p Property) Text() string {
+	return UnescapeText(p.Value)
+}
+
+// Time parses a DATE-TIME or DATE value. Values without a zone are read in
+// the TZID parameter's location, or as UTC.
+func (p Property) Time() (time.Time, error) {
+	loc := time.UTC
+	if tzid := p.Params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("%s: unknown TZID %q", p.Name, tzid)
+		}
+	}
+	return ParseTime(p.Value, loc)
+}
+
+type Component struct {
+	Name       string
+	Props      []Property
+	Components []Component
+	// Line is where the component began in the decoded input.
+	Line int
+}
+
+// Get returns the first property called name.
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Props {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Value returns the raw value of the first property called name, or "".
+func (c *Component) Value(name string) string {
+	p, _ := c.Get(name)
+	return p.Value
+}
+
+// Add appends a property. params are name/value pairs.
+func (c *Component) Add(name, value string, params ...string) {
+	p := Property{Name: name, Value: value}
+	if len(params) > 0 {
+		p.Params = map[string]string{}
+		for i := 0; i+1 < len(params); i += 2 {
+			p.Params[params[i]] = params[i+1]
+		}
+	}
+	c.Props = append(c.Props, p)
+}
+
+// AddText appends a TEXT property, escaping value.
+func (c *Component) AddText(name, value string) {
+	c.Add(name, EscapeText(value))
+}
+
+// AddTime appends a DATE-TIME property in UTC.
+func (c *Component) AddTime(name string, t time.Time) {
+	c.Add(name, FormatTime(t))
+}
+
+// All returns the nested components called name.
+func (c *Component) All(name string) []Component {
+	var out []Component
+	for _, sub := range c.Components {
+		if sub.Name == name {
+			out = append(out, sub)
+		}
+	}
+	return out
+}
+
+// Encode writes c with CRLF line endings, folding lines longer than 75 octets.
+func Encode(w io.Writer, c Component) error {
+	bw := bufio.NewWriter(w)
+	encode(bw, c)
+	return bw.Flush()
+}
+
+func encode(w *bufio.Writer, c Component) {
+	writeLine(w, "BEGIN:"+c.Name)
+	for _, p := range c.Props {
+		var b strings.Builder
+		b.WriteString(p.Name)
+		for _, k := range sortedKeys(p.Params) {
+			v := p.Params[k]
+			if strings.ContainsAny(v, ";:,") {
+				v = `"` + v + `"`
+			}
+			b.WriteString(";" + k + "=" + v)
+		}
+		b.WriteString(":" + p.Value)
+		writeLine(w, b.String())
+	}
+	for _, sub := range c.Components {
+		encode(w, sub)
+	}
+	writeLine(w, "END:"+c.Name)
+}
+
+// writeLine folds line into chunks of at most 75 octets without splitting a
+// UTF-8 sequence; continuation lines start with a space.
+func writeLine(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	w.WriteString(line + "\r\n")
+}
+
+// Decode reads a single top-level component, usually a VCALENDAR.
+func Decode(r io.Reader) (Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return Component{}, err
+	}
+
+	var stack []Component
+	for _, l := range lines {
+		if strings.TrimSpace(l.text) == "" {
+			continue
+		}
+		p, err := parseLine(l.text)
+		if err != nil {
+			return Component{}, fmt.Errorf("line %d: %w", l.n, err)
+		}
+
+		switch p.Name {
+		case "BEGIN":
+			stack = append(stack, Component{Name: strings.ToUpper(p.Value), Line: l.n})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return Component{}, fmt.Errorf("line %d: unexpected END:%s", l.n, p.Value)
+			}
+			done := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return done, nil
+			}
+			parent := &stack[len(stack)-1]
+			parent.Components = append(parent.Components, done)
+		default:
+			if len(stack) == 0 {
+				return Component{}, fmt.Errorf("line %d: property %s outside a component", l.n, p.Name)
+			}
+			top := &stack[len(stack)-1]
+			top.Props = append(top.Props, p)
+		}
+	}
+
+	if len(stack) > 0 {
+		return Component{}, fmt.Errorf("%s that began on line %d is not closed", stack[0].Name, stack[0].Line)
+	}
+	return Component{}, fmt.Errorf("no component found")
+}
+
+type line struct {
+	n    int
+	text string
+}
+
+// unfold joins continuation lines, which start with a space or a tab, to the
+// line before them.
+func unfold(r io.Reader) ([]line, error) {
+	var lines []line
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimRight(sc.Text(), "\r")
+		if n == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if len(lines) > 0 && (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		lines = append(lines, line{n: n, text: text})
+	}
+	return lines, sc.Err()
+}
+
+// parseLine splits NAME;PARAM=value;PARAM="quoted":value.
+func parseLine(s string) (Property, error) {
+	p := Property{}
+	i := strings.IndexAny(s, ";:")
+	if i < 0 {
+		return p, fmt.Errorf("missing ':' in %q", s)
+	}
+	p.Name = strings.ToUpper(s[:i])
+
+	for s[i] == ';' {
+		s = s[i+1:]
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return p, fmt.Errorf("%s: parameter without '='", p.Name)
+		}
+		key := strings.ToUpper(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return p, fmt.Errorf("%s: unterminated quote", p.Name)
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexAny(s, ";:")
+			if end < 0 {
+				return p, fmt.Errorf("missing ':' after %s", p.Name)
+			}
+			value, s = s[:end], s[end:]
+		}
+		if p.Params == nil {
+			p.Params = map[string]string{}
+		}
+		p.Params[key] = value
+
+		if i = 0; len(s) == 0 || (s[0] != ';' && s[0] != ':') {
+			return p, fmt.Errorf("%s: expected ';' or ':' after parameter %s", p.Name, key)
+		}
+	}
+
+	p.Value = s[i+1:]
+	return p, nil
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func EscapeText(s string) string   { return textEscaper.Replace(s) }
+func UnescapeText(s string) string { return textUnescaper.Replace(s) }
+
+const (
+	dateTimeUTC = "20060102T150405Z"
+	dateTime    = "20060102T150405"
+	date        = "20060102"
+)
+
+// FormatTime formats t as a UTC DATE-TIME.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeUTC)
+}
+
+// ParseTime parses a UTC or floating DATE-TIME, or a DATE, which becomes
+// midnight. Floating values are read in loc.
+func ParseTime(s string, loc *time.Location) (time.Time, error) {
+	switch {
+	case strings.HasSuffix(s, "Z"):
+		return time.Parse(dateTimeUTC, s)
+	case len(s) == len(date):
+		return time.ParseInLocation(date, s, loc)
+	}
+	return time.ParseInLocation(dateTime, s, loc)
+}
+
+// FormatDuration formats d as a DURATION such as -PT15M or P1DT2H.
+func FormatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	if d == 0 {
+		return "PT0S"
+	}
+
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	var b strings.Builder
+	b.WriteString(sign + "P")
+	if days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+	}
+	if d > 0 {
+		b.WriteString("T")
+		if h := d / time.Hour; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+			d -= h * time.Hour
+		}
+		if m := d / time.Minute; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+			d -= m * time.Minute
+		}
+		if s := d / time.Second; s > 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
+
+// ParseDuration parses a DURATION such as -PT15M, P1W or P1DT12H.
+func ParseDuration(s string) (time.Duration, error) {
+	orig := s
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	s = s[1:]
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	var d time.Duration
+	n, digits := 0, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			n, digits = n*10+int(c-'0'), true
+		case c == 'T':
+			units = map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+		case units[c] != 0 && digits:
+			d += time.Duration(n) * units[c]
+			n, digits = 0, false
+		default:
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+	}
+	if digits {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	return sign * d, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
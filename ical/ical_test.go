@@ -0,0 +1,186 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	due := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	todo := ical.Component{Name: "VTODO"}
+	todo.Add("UID", "todo-1@example")
+	todo.AddText("SUMMARY", "Buy milk, eggs; and "+strings.Repeat("bread ", 20)+"\nthen go home")
+	todo.AddTime("DUE", due)
+	todo.Add("TRIGGER", "-PT15M", "RELATED", "END")
+	cal := ical.Component{Name: "VCALENDAR", Components: []ical.Component{todo}}
+	cal.Add("VERSION", "2.0")
+
+	var buf bytes.Buffer
+	require.NoError(t, ical.Encode(&buf, cal))
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+
+	got, err := ical.Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "VCALENDAR", got.Name)
+	assert.Equal(t, "2.0", got.Value("VERSION"))
+
+	todos := got.All("VTODO")
+	require.Len(t, todos, 1)
+	assert.Equal(t, 3, todos[0].Line)
+
+	summary, _ := todos[0].Get("SUMMARY")
+	assert.Equal(t, "Buy milk, eggs; and "+strings.Repeat("bread ", 20)+"\nthen go home", summary.Text())
+
+	dueProp, _ := todos[0].Get("DUE")
+	gotDue, err := dueProp.Time()
+	require.NoError(t, err)
+	assert.True(t, due.Equal(gotDue))
+
+	trigger, _ := todos[0].Get("TRIGGER")
+	assert.Equal(t, map[string]string{"RELATED": "END"}, trigger.Params)
+}
+
+func TestDecodeParametersAndZones(t *testing.T) {
+	input := "BEGIN:VCALENDAR\n" +
+		"BEGIN:VTODO\n" +
+		"DUE;TZID=\"America/New_York\":20240301T090000\n" +
+		"DTSTART;VALUE=DATE:20240229\n" +
+		"END:VTODO\n" +
+		"END:VCALENDAR\n"
+
+	cal, err := ical.Decode(strings.NewReader(input))
+	require.NoError(t, err)
+	todo := cal.All("VTODO")[0]
+
+	due, _ := todo.Get("DUE")
+	got, err := due.Time()
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-01T14:00:00Z", got.UTC().Format(time.RFC3339))
+
+	start, _ := todo.Get("DTSTART")
+	got, err = start.Time()
+	require.NoError(t, err)
+	assert.Equal(t, "2024-02-29T00:00:00Z", got.Format(time.RFC3339))
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"unclosed":     "BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VTODO\n",
+		"mismatched":   "BEGIN:VCALENDAR\nEND:VTODO\n",
+		"no colon":     "BEGIN:VCALENDAR\nSUMMARY\nEND:VCALENDAR\n",
+		"bad param":    "BEGIN:VCALENDAR\nDUE;TZID\nEND:VCALENDAR\n",
+		"outside":      "SUMMARY:hi\n",
+		"empty":        "",
+		"open quote":   "BEGIN:VCALENDAR\nDUE;TZID=\"x:1\nEND:VCALENDAR\n",
+		"after quoted": "BEGIN:VCALENDAR\nDUE;TZID=\"x\"y:1\nEND:VCALENDAR\n",
+	} {
+		_, err := ical.Decode(strings.NewReader(input))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestDurations(t *testing.T) {
+	for s, d := range map[string]time.Duration{
+		"-PT15M":    -15 * time.Minute,
+		"P1DT2H":    26 * time.Hour,
+		"PT1H30M5S": time.Hour + 30*time.Minute + 5*time.Second,
+		"PT0S":      0,
+	} {
+		got, err := ical.ParseDuration(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, d, got, s)
+		assert.Equal(t, s, ical.FormatDuration(d))
+	}
+
+	week, err := ical.ParseDuration("P1W")
+	require.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, week)
+
+	for _, s := range []string{"", "P", "PT", "-PT15", "PT15X", "15M"} {
+		_, err := ical.ParseDuration(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseRecur(t *testing.T) {
+	r, err := ical.ParseRecur("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=10")
+	require.NoError(t, err)
+	assert.Equal(t, "WEEKLY", r.Freq)
+	assert.Equal(t, 2, r.Interval)
+	assert.Equal(t, 10, r.Count)
+	assert.Equal(t, []time.Weekday{time.Monday, time.Friday}, r.ByDay)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;COUNT=10;BYDAY=MO,FR", r.String())
+
+	r, err = ical.ParseRecur("freq=monthly;bymonthday=1,-1;until=20241231T000000Z")
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=MONTHLY;UNTIL=20241231T000000Z;BYMONTHDAY=1,-1", r.String())
+
+	for _, s := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=MONTHLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=YEARLY;BYSETPOS=1",
+	} {
+		_, err := ical.ParseRecur(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRecurNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		require.NoError(t, err)
+		return v
+	}
+
+	for _, tc := range []struct {
+		rule, start, after, want string
+		n                        int
+	}{
+		{"FREQ=DAILY", "2024-03-01 09:00", "2024-03-01 09:00", "2024-03-02 09:00", 2},
+		{"FREQ=DAILY;INTERVAL=3", "2024-03-01 09:00", "2024-03-05 12:00", "2024-03-07 09:00", 3},
+		{"FREQ=WEEKLY", "2024-03-01 09:00", "2024-03-01 10:00", "2024-03-08 09:00", 2},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", "2024-03-01 09:00", "2024-03-01 09:00", "2024-03-11 09:00", 2},
+		{"FREQ=WEEKLY;BYDAY=MO,WE", "2024-03-04 09:00", "2024-03-04 09:00", "2024-03-06 09:00", 2},
+		{"FREQ=MONTHLY", "2024-01-31 09:00", "2024-01-31 09:00", "2024-03-31 09:00", 2},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "2024-01-31 09:00", "2024-01-31 09:00", "2024-02-29 09:00", 2},
+		{"FREQ=MONTHLY;BYDAY=SA;BYMONTHDAY=13", "2024-01-13 09:00", "2024-01-13 09:00", "2024-04-13 09:00", 2},
+		{"FREQ=YEARLY", "2024-02-29 09:00", "2024-03-01 00:00", "2028-02-29 09:00", 2},
+		{"FREQ=DAILY", "2024-03-01 09:00", "2024-02-01 00:00", "2024-03-01 09:00", 1},
+	} {
+		rule, err := ical.ParseRecur(tc.rule)
+		require.NoError(t, err)
+		got, n, ok := rule.Next(at(tc.start), at(tc.after))
+		require.True(t, ok, tc.rule)
+		assert.Equal(t, at(tc.want), got, tc.rule)
+		assert.Equal(t, tc.n, n, tc.rule)
+	}
+
+	rule, _ := ical.ParseRecur("FREQ=DAILY;COUNT=3")
+	_, n, ok := rule.Next(at("2024-03-01 09:00"), at("2024-03-02 09:00"))
+	assert.True(t, ok)
+	assert.Equal(t, 3, n)
+	_, _, ok = rule.Next(at("2024-03-01 09:00"), at("2024-03-03 09:00"))
+	assert.False(t, ok)
+
+	rule, _ = ical.ParseRecur("FREQ=WEEKLY;UNTIL=20240310T000000Z")
+	_, _, ok = rule.Next(at("2024-03-01 09:00"), at("2024-03-08 09:00"))
+	assert.False(t, ok)
+
+	rule, _ = ical.ParseRecur("FREQ=YEARLY;BYMONTHDAY=30")
+	_, _, ok = rule.Next(at("2024-02-01 09:00"), at("2024-02-01 09:00"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,248 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recur is a recurrence rule (RRULE). Only the parts todos need are
+// supported: FREQ, INTERVAL, COUNT, UNTIL, BYDAY without ordinals,
+// BYMONTHDAY and WKST.
+type Recur struct {
+	Freq     string
+	Interval int
+	// Count and Until are exclusive; both zero means the rule never ends.
+	Count      int
+	Until      time.Time
+	ByDay      []time.Weekday
+	ByMonthDay []int
+}
+
+var frequencies = map[string]bool{"DAILY": true, "WEEKLY": true, "MONTHLY": true, "YEARLY": true}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var weekdayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ParseRecur parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE".
+func ParseRecur(s string) (Recur, error) {
+	r := Recur{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(strings.TrimSpace(s), ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(key)
+		if !ok || value == "" {
+			return Recur{}, fmt.Errorf("rrule: %q is not NAME=value", part)
+		}
+		if seen[key] {
+			return Recur{}, fmt.Errorf("rrule: %s appears twice", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			if !frequencies[r.Freq] {
+				err = fmt.Errorf("rrule: FREQ %s is not supported", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = positive(key, value)
+		case "COUNT":
+			r.Count, err = positive(key, value)
+		case "UNTIL":
+			r.Until, err = ParseTime(value, time.UTC)
+			if err != nil {
+				err = fmt.Errorf("rrule: invalid UNTIL %q", value)
+			}
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := weekdays[strings.ToUpper(code)]
+				if !ok {
+					return Recur{}, fmt.Errorf("rrule: BYDAY %q is not supported", code)
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				day, convErr := strconv.Atoi(v)
+				if convErr != nil || day == 0 || day < -31 || day > 31 {
+					return Recur{}, fmt.Errorf("rrule: invalid BYMONTHDAY %q", v)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, day)
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				err = fmt.Errorf("rrule: only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("rrule: %s is not supported", key)
+		}
+		if err != nil {
+			return Recur{}, err
+		}
+	}
+
+	switch {
+	case r.Freq == "":
+		return Recur{}, fmt.Errorf("rrule: FREQ is required")
+	case r.Count > 0 && !r.Until.IsZero():
+		return Recur{}, fmt.Errorf("rrule: COUNT and UNTIL cannot both be set")
+	}
+	return r, nil
+}
+
+func positive(key, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("rrule: %s must be a positive number", key)
+	}
+	return n, nil
+}
+
+// String formats r as an RRULE value.
+func (r Recur) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+FormatTime(r.Until))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			codes[i] = weekdayCodes[d]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// maxPeriods bounds the search for an occurrence, for rules such as
+// FREQ=YEARLY;BYMONTHDAY=30 starting in February that never match.
+const maxPeriods = 10000
+
+// Next returns the first occurrence of r after after, for a series whose
+// first occurrence is start, and its 1-based number in the series. It
+// reports false when COUNT or UNTIL end the series first. Occurrences keep
+// the clock time of start.
+func (r Recur) Next(start, after time.Time) (time.Time, int, bool) {
+	n := 1
+	if start.After(after) {
+		return start, n, r.Until.IsZero() || !start.After(r.Until)
+	}
+
+	for k := 0; k < maxPeriods; k++ {
+		for _, day := range r.candidates(start, k) {
+			t := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+			if !t.After(start) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return time.Time{}, 0, false
+			}
+			if n++; r.Count > 0 && n > r.Count {
+				return time.Time{}, 0, false
+			}
+			if t.After(after) {
+				return t, n, true
+			}
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// candidates returns the days of the k-th period of the rule, in order.
+func (r Recur) candidates(start time.Time, k int) []time.Time {
+	y, m, d := start.Date()
+	loc := start.Location()
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	step := k * interval
+
+	var from, to time.Time
+	// Without BYDAY and BYMONTHDAY a rule repeats the day of start.
+	sameDay := len(r.ByDay) == 0 && len(r.ByMonthDay) == 0
+	switch r.Freq {
+	case "DAILY":
+		from = time.Date(y, m, d+step, 0, 0, 0, 0, loc)
+		to = from.AddDate(0, 0, 1)
+		sameDay = false
+	case "WEEKLY":
+		monday := d - (int(start.Weekday())+6)%7
+		from = time.Date(y, m, monday+7*step, 0, 0, 0, 0, loc)
+		to = from.AddDate(0, 0, 7)
+		if len(r.ByDay) == 0 {
+			r.ByDay = []time.Weekday{start.Weekday()}
+		}
+		sameDay = false
+	case "MONTHLY":
+		from = time.Date(y, m+time.Month(step), 1, 0, 0, 0, 0, loc)
+		to = from.AddDate(0, 1, 0)
+	case "YEARLY":
+		from = time.Date(y+step, 1, 1, 0, 0, 0, 0, loc)
+		to = from.AddDate(1, 0, 0)
+		if len(r.ByDay) == 0 {
+			// BYMONTHDAY applies to the month of start.
+			from = time.Date(y+step, m, 1, 0, 0, 0, 0, loc)
+			to = from.AddDate(0, 1, 0)
+		}
+	default:
+		return nil
+	}
+
+	var days []time.Time
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if sameDay {
+			if day.Day() == d && day.Month() == from.Month() {
+				days = append(days, day)
+			}
+			continue
+		}
+		if r.matches(day) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+func (r Recur) matches(day time.Time) bool {
+	if len(r.ByDay) > 0 {
+		found := false
+		for _, wd := range r.ByDay {
+			found = found || wd == day.Weekday()
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.ByMonthDay) > 0 {
+		last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+		found := false
+		for _, md := range r.ByMonthDay {
+			found = found || md == day.Day() || md == day.Day()-last-1
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
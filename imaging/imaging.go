@@ -0,0 +1,122 @@
+// Package imaging checks uploaded images and makes thumbnails of them, in
+// pure Go with the standard library's JPEG, PNG and GIF codecs.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+// Types are the content types of the images that can be decoded, with the
+// extension of their files.
+var Types = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+var ErrUnsupported = errors.New("imaging: not a JPEG, PNG or GIF image")
+
+// Sniff returns the content type of data by its contents, ignoring whatever
+// the client claimed, or ErrUnsupported when it is not an image Types has.
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := Types[contentType]; !ok {
+		return contentType, ErrUnsupported
+	}
+	return contentType, nil
+}
+
+// Decode decodes an image of at most maxPixels pixels. The size is checked
+// from the header first, so that a small file claiming to be huge is not
+// decoded into memory.
+func Decode(data []byte, maxPixels int) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("imaging: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("imaging: %dx%d is more than %d pixels", cfg.Width, cfg.Height, maxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("imaging: %w", err)
+	}
+	return img, nil
+}
+
+// Thumbnail scales img down to fit in a size by size square, keeping its
+// aspect ratio. Every pixel of the thumbnail is the average of the pixels it
+// covers. Images that already fit are copied as they are.
+func Thumbnail(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	if sw <= size && sh <= size {
+		return src
+	}
+
+	dw, dh := size, sh*size/sw
+	if sh > sw {
+		dw, dh = sw*size/sh, size
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	// RGBA is premultiplied, so channels average without weighting by alpha.
+	sums := make([]uint64, dw*dh*4)
+	counts := make([]uint64, dw*dh)
+	for y := 0; y < sh; y++ {
+		dy := y * dh / sh
+		row := src.Pix[y*src.Stride:]
+		for x := 0; x < sw; x++ {
+			d := dy*dw + x*dw/sw
+			counts[d]++
+			for c := 0; c < 4; c++ {
+				sums[d*4+c] += uint64(row[x*4+c])
+			}
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for d, n := range counts {
+		for c := 0; c < 4; c++ {
+			dst.Pix[d*4+c] = uint8((sums[d*4+c] + n/2) / n)
+		}
+	}
+	return dst
+}
+
+// Encode writes img as contentType. GIFs are written as PNGs, which keep
+// their transparency; ThumbnailType names the type written.
+func Encode(w io.Writer, img image.Image, contentType string) error {
+	switch ThumbnailType(contentType) {
+	case "image/jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	default:
+		return png.Encode(w, img)
+	}
+}
+
+// ThumbnailType is the content type Encode writes for an image of
+// contentType.
+func ThumbnailType(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
@@ -0,0 +1,88 @@
+package imaging_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/sing3demons/go-example/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encoded(t *testing.T, encode func(*bytes.Buffer, image.Image) error, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			// Left half red, right half blue.
+			if x < w/2 {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, encode(&buf, img))
+	return buf.Bytes()
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error  { return png.Encode(buf, img) }
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) }
+func encodeGIF(buf *bytes.Buffer, img image.Image) error  { return gif.Encode(buf, img, nil) }
+
+func TestSniff(t *testing.T) {
+	for want, data := range map[string][]byte{
+		"image/png":  encoded(t, encodePNG, 2, 2),
+		"image/jpeg": encoded(t, encodeJPEG, 2, 2),
+		"image/gif":  encoded(t, encodeGIF, 2, 2),
+	} {
+		got, err := imaging.Sniff(data)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	got, err := imaging.Sniff([]byte("<html><body>not an image"))
+	assert.ErrorIs(t, err, imaging.ErrUnsupported)
+	assert.Equal(t, "text/html; charset=utf-8", got)
+}
+
+func TestDecodeLimitsPixels(t *testing.T) {
+	data := encoded(t, encodePNG, 100, 50)
+
+	img, err := imaging.Decode(data, 5000)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), img.Bounds())
+
+	_, err = imaging.Decode(data, 4999)
+	assert.EqualError(t, err, "imaging: 100x50 is more than 4999 pixels")
+
+	_, err = imaging.Decode(data[:40], 5000)
+	assert.Error(t, err)
+}
+
+func TestThumbnail(t *testing.T) {
+	img, err := imaging.Decode(encoded(t, encodePNG, 400, 100), 1e6)
+	require.NoError(t, err)
+
+	thumb := imaging.Thumbnail(img, 100)
+	assert.Equal(t, image.Rect(0, 0, 100, 25), thumb.Bounds())
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, thumb.At(10, 10))
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, thumb.At(90, 10))
+
+	tall := imaging.Thumbnail(image.NewRGBA(image.Rect(0, 0, 10, 1000)), 100)
+	assert.Equal(t, image.Rect(0, 0, 1, 100), tall.Bounds())
+
+	small := imaging.Thumbnail(img, 1000)
+	assert.Equal(t, image.Rect(0, 0, 400, 100), small.Bounds(), "images are not scaled up")
+
+	var buf bytes.Buffer
+	require.NoError(t, imaging.Encode(&buf, thumb, "image/gif"))
+	contentType, err := imaging.Sniff(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, imaging.ThumbnailType("image/gif"), contentType)
+}
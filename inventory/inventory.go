@@ -0,0 +1,257 @@
+// Package inventory tracks the stock of products per warehouse. Every change
+// is a single conditional increment (store.Increment, $inc in mongo), so
+// concurrent reservations can never take the available stock below zero,
+// and is recorded in the product's stock history.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultWarehouse holds the stock of changes that name no warehouse.
+const DefaultWarehouse = "main"
+
+var (
+	ErrInsufficientStock = errors.New("not enough stock available")
+	ErrNotReserved       = errors.New("not that much stock is reserved")
+	ErrInvalidQuantity   = errors.New("invalid quantity")
+	ErrReasonRequired    = errors.New("an adjustment needs a reason")
+)
+
+// Change is a change to the stock of a product in a warehouse.
+type Change struct {
+	ProductID primitive.ObjectID
+	// Warehouse defaults to DefaultWarehouse.
+	Warehouse string
+	Quantity  int64
+	Reason    string
+	Actor     string
+}
+
+// LowStockEvent is fired by the change that takes the available stock of a
+// level from above its threshold to or below it.
+type LowStockEvent struct {
+	Level    models.StockLevel
+	Movement models.StockMovement
+}
+
+type Service struct {
+	levels  store.Storer
+	history store.Storer
+
+	mu        sync.RWMutex
+	listeners []func(LowStockEvent)
+}
+
+// New returns a service keeping stock levels in levels and movements in
+// history, which may be the same store.
+func New(levels, history store.Storer) *Service {
+	return &Service{levels: levels, history: history}
+}
+
+// OnLowStock registers fn to be called, synchronously, with every
+// LowStockEvent.
+func (s *Service) OnLowStock(fn func(LowStockEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// LogLowStock is a listener that logs the event.
+func LogLowStock(e LowStockEvent) {
+	log.Printf("inventory: product %s is low in %s: %d available, threshold %d",
+		e.Level.ProductID.Hex(), e.Level.Warehouse, e.Level.Available, e.Level.LowStockThreshold)
+}
+
+func warehouse(name string) string {
+	if name == "" {
+		return DefaultWarehouse
+	}
+	return name
+}
+
+// Levels returns the stock of a product in every warehouse that has had any.
+func (s *Service) Levels(productID primitive.ObjectID) ([]models.StockLevel, error) {
+	levels := []models.StockLevel{}
+	if err := s.levels.Find(&levels, bson.M{"product_id": productID}); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Warehouse < levels[j].Warehouse })
+	return levels, nil
+}
+
+// LowStock returns every level at or below its threshold.
+func (s *Service) LowStock() ([]models.StockLevel, error) {
+	var all []models.StockLevel
+	if err := s.levels.Find(&all, bson.M{}); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	low := []models.StockLevel{}
+	for _, l := range all {
+		if l.Low() {
+			low = append(low, l)
+		}
+	}
+	sort.Slice(low, func(i, j int) bool { return low[i].ID < low[j].ID })
+	return low, nil
+}
+
+// History returns the movements of a product, oldest first, in one
+// warehouse or, when warehouse is empty, in all of them.
+func (s *Service) History(productID primitive.ObjectID, warehouse string) ([]models.StockMovement, error) {
+	filter := bson.M{"product_id": productID}
+	if warehouse != "" {
+		filter["warehouse"] = warehouse
+	}
+	movements := []models.StockMovement{}
+	if err := s.history.Find(&movements, filter); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	sort.SliceStable(movements, func(i, j int) bool { return movements[i].CreatedAt.Before(movements[j].CreatedAt) })
+	return movements, nil
+}
+
+// Adjust adds Quantity to the stock on hand, or removes it when negative,
+// as after a delivery or a stock take, for the Reason given. Stock that is
+// reserved cannot be removed.
+func (s *Service) Adjust(c Change) (models.StockMovement, error) {
+	if c.Quantity == 0 {
+		return models.StockMovement{}, fmt.Errorf("%w: must not be zero", ErrInvalidQuantity)
+	}
+	if c.Reason == "" {
+		return models.StockMovement{}, ErrReasonRequired
+	}
+	inc := map[string]int64{"on_hand": c.Quantity, "available": c.Quantity}
+	if c.Quantity < 0 {
+		return s.apply(models.StockAdjusted, c, bson.M{"available": bson.M{"$gte": -c.Quantity}}, inc, ErrInsufficientStock)
+	}
+
+	// Stock arriving in a new warehouse starts its level.
+	c.Warehouse = warehouse(c.Warehouse)
+	level := models.StockLevel{
+		ID:        models.StockLevelID(c.ProductID, c.Warehouse),
+		ProductID: c.ProductID,
+		Warehouse: c.Warehouse,
+	}
+	if err := s.levels.Create(&level); err != nil && !store.IsDuplicateKey(err) {
+		return models.StockMovement{}, err
+	}
+	return s.apply(models.StockAdjusted, c, bson.M{}, inc, store.ErrNotFound)
+}
+
+// Reserve sets Quantity aside, for an order that is being placed. It fails
+// with ErrInsufficientStock, changing nothing, when less is available.
+func (s *Service) Reserve(c Change) (models.StockMovement, error) {
+	if c.Quantity <= 0 {
+		return models.StockMovement{}, fmt.Errorf("%w: must be positive", ErrInvalidQuantity)
+	}
+	return s.apply(models.StockReserved, c, bson.M{"available": bson.M{"$gte": c.Quantity}},
+		map[string]int64{"available": -c.Quantity, "reserved": c.Quantity}, ErrInsufficientStock)
+}
+
+// Release makes reserved stock available again, for an order that was not
+// placed after all.
+func (s *Service) Release(c Change) (models.StockMovement, error) {
+	if c.Quantity <= 0 {
+		return models.StockMovement{}, fmt.Errorf("%w: must be positive", ErrInvalidQuantity)
+	}
+	return s.apply(models.StockReleased, c, bson.M{"reserved": bson.M{"$gte": c.Quantity}},
+		map[string]int64{"available": c.Quantity, "reserved": -c.Quantity}, ErrNotReserved)
+}
+
+// Commit takes reserved stock out of the warehouse, for an order that was
+// placed.
+func (s *Service) Commit(c Change) (models.StockMovement, error) {
+	if c.Quantity <= 0 {
+		return models.StockMovement{}, fmt.Errorf("%w: must be positive", ErrInvalidQuantity)
+	}
+	return s.apply(models.StockCommitted, c, bson.M{"reserved": bson.M{"$gte": c.Quantity}},
+		map[string]int64{"on_hand": -c.Quantity, "reserved": -c.Quantity}, ErrNotReserved)
+}
+
+// apply increments the level of c when it matches filter, or fails with
+// unmatched, and records the movement.
+func (s *Service) apply(kind string, c Change, filter bson.M, inc map[string]int64, unmatched error) (models.StockMovement, error) {
+	c.Warehouse = warehouse(c.Warehouse)
+	filter["_id"] = models.StockLevelID(c.ProductID, c.Warehouse)
+
+	var level models.StockLevel
+	if err := store.Increment(s.levels, &level, filter, inc); err != nil {
+		if store.IsNotFound(err) {
+			return models.StockMovement{}, unmatched
+		}
+		return models.StockMovement{}, err
+	}
+
+	before := level.Available - inc["available"]
+	m := models.StockMovement{
+		ProductID: c.ProductID,
+		Warehouse: c.Warehouse,
+		Kind:      kind,
+		Quantity:  c.Quantity,
+		Reason:    c.Reason,
+		Actor:     c.Actor,
+		OnHand:    level.OnHand,
+		Reserved:  level.Reserved,
+		Available: level.Available,
+		LowStock:  level.Low() && before > level.LowStockThreshold,
+		CreatedAt: time.Now(),
+	}
+	// The stock has changed whether or not its history can be written.
+	if err := s.history.Create(&m); err != nil {
+		log.Printf("inventory: record %s of %s: %v", kind, filter["_id"], err)
+	}
+
+	if m.LowStock {
+		s.mu.RLock()
+		listeners := s.listeners
+		s.mu.RUnlock()
+		for _, fn := range listeners {
+			fn(LowStockEvent{Level: level, Movement: m})
+		}
+	}
+	return m, nil
+}
+
+// SetThreshold sets the level at or below which the available stock of a
+// product in a warehouse is low.
+func (s *Service) SetThreshold(productID primitive.ObjectID, warehouseName string, threshold int64) (models.StockLevel, error) {
+	if threshold < 0 {
+		return models.StockLevel{}, errors.New("threshold must not be negative")
+	}
+	level := models.StockLevel{
+		ID:        models.StockLevelID(productID, warehouse(warehouseName)),
+		ProductID: productID,
+		Warehouse: warehouse(warehouseName),
+	}
+	if err := s.levels.Create(&level); err != nil && !store.IsDuplicateKey(err) {
+		return level, err
+	}
+
+	// The threshold is swapped by an increment conditional on its current
+	// value, so that it never overwrites a concurrent change to the stock.
+	for {
+		if err := s.levels.First(&level, bson.M{"_id": level.ID}); err != nil {
+			return level, err
+		}
+		if level.LowStockThreshold == threshold {
+			return level, nil
+		}
+		err := store.Increment(s.levels, &level,
+			bson.M{"_id": level.ID, "low_stock_threshold": level.LowStockThreshold},
+			map[string]int64{"low_stock_threshold": threshold - level.LowStockThreshold})
+		if !store.IsNotFound(err) {
+			return level, err
+		}
+	}
+}
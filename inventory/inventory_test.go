@@ -0,0 +1,152 @@
+package inventory_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReserveReleaseCommit(t *testing.T) {
+	s := inventory.New(store.NewMemoryStore(), store.NewMemoryStore())
+	product := primitive.NewObjectID()
+	change := func(quantity int64) inventory.Change {
+		return inventory.Change{ProductID: product, Quantity: quantity, Actor: "alice"}
+	}
+
+	_, err := s.Reserve(change(1))
+	assert.ErrorIs(t, err, inventory.ErrInsufficientStock, "no stock yet")
+
+	m, err := s.Adjust(inventory.Change{ProductID: product, Quantity: 10, Reason: "delivery"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), m.Available)
+
+	m, err = s.Reserve(change(4))
+	require.NoError(t, err)
+	assert.Equal(t, models.StockMovement{
+		ID: m.ID, ProductID: product, Warehouse: inventory.DefaultWarehouse, Kind: models.StockReserved,
+		Quantity: 4, Actor: "alice", OnHand: 10, Reserved: 4, Available: 6, CreatedAt: m.CreatedAt,
+	}, m)
+
+	_, err = s.Reserve(change(7))
+	assert.ErrorIs(t, err, inventory.ErrInsufficientStock)
+	_, err = s.Release(change(5))
+	assert.ErrorIs(t, err, inventory.ErrNotReserved)
+	_, err = s.Reserve(change(0))
+	assert.ErrorIs(t, err, inventory.ErrInvalidQuantity)
+
+	m, err = s.Release(change(1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), m.Available)
+
+	m, err = s.Commit(change(3))
+	require.NoError(t, err)
+	assert.Equal(t, [3]int64{7, 0, 7}, [3]int64{m.OnHand, m.Reserved, m.Available})
+
+	_, err = s.Adjust(inventory.Change{ProductID: product, Quantity: -1})
+	assert.ErrorIs(t, err, inventory.ErrReasonRequired)
+	_, err = s.Adjust(inventory.Change{ProductID: product, Quantity: -8, Reason: "stock take"})
+	assert.ErrorIs(t, err, inventory.ErrInsufficientStock)
+	_, err = s.Adjust(inventory.Change{ProductID: product, Quantity: -2, Reason: "stock take"})
+	require.NoError(t, err)
+
+	history, err := s.History(product, "")
+	require.NoError(t, err)
+	var kinds []string
+	for _, m := range history {
+		kinds = append(kinds, m.Kind)
+	}
+	assert.Equal(t, []string{"adjusted", "reserved", "released", "committed", "adjusted"}, kinds)
+	assert.Equal(t, int64(-2), history[4].Quantity)
+	assert.Equal(t, "stock take", history[4].Reason)
+}
+
+func TestWarehouses(t *testing.T) {
+	s := inventory.New(store.NewMemoryStore(), store.NewMemoryStore())
+	product := primitive.NewObjectID()
+
+	_, err := s.Adjust(inventory.Change{ProductID: product, Warehouse: "bangkok", Quantity: 5, Reason: "delivery"})
+	require.NoError(t, err)
+	_, err = s.Adjust(inventory.Change{ProductID: product, Quantity: 2, Reason: "delivery"})
+	require.NoError(t, err)
+	_, err = s.Reserve(inventory.Change{ProductID: product, Quantity: 3})
+	assert.ErrorIs(t, err, inventory.ErrInsufficientStock, "warehouses do not share stock")
+
+	levels, err := s.Levels(product)
+	require.NoError(t, err)
+	require.Len(t, levels, 2)
+	assert.Equal(t, "bangkok", levels[0].Warehouse)
+	assert.Equal(t, int64(5), levels[0].Available)
+
+	history, err := s.History(product, "bangkok")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestLowStockEvents(t *testing.T) {
+	s := inventory.New(store.NewMemoryStore(), store.NewMemoryStore())
+	product := primitive.NewObjectID()
+	var events []inventory.LowStockEvent
+	s.OnLowStock(func(e inventory.LowStockEvent) { events = append(events, e) })
+
+	level, err := s.SetThreshold(product, "", 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), level.LowStockThreshold)
+	_, err = s.SetThreshold(product, "", -1)
+	assert.Error(t, err)
+
+	_, err = s.Adjust(inventory.Change{ProductID: product, Quantity: 10, Reason: "delivery"})
+	require.NoError(t, err)
+	_, err = s.Reserve(inventory.Change{ProductID: product, Quantity: 5})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	m, err := s.Reserve(inventory.Change{ProductID: product, Quantity: 3})
+	require.NoError(t, err)
+	assert.True(t, m.LowStock)
+	require.Len(t, events, 1)
+	assert.Equal(t, int64(2), events[0].Level.Available)
+
+	_, err = s.Reserve(inventory.Change{ProductID: product, Quantity: 1})
+	require.NoError(t, err)
+	assert.Len(t, events, 1, "only crossing the threshold fires")
+
+	low, err := s.LowStock()
+	require.NoError(t, err)
+	require.Len(t, low, 1)
+	assert.Equal(t, product, low[0].ProductID)
+}
+
+func TestConcurrentReservationsNeverOversell(t *testing.T) {
+	s := inventory.New(store.NewMemoryStore(), store.NewMemoryStore())
+	product := primitive.NewObjectID()
+	_, err := s.Adjust(inventory.Change{ProductID: product, Quantity: 20, Reason: "delivery"})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Reserve(inventory.Change{ProductID: product, Quantity: 3}); err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 6, reserved)
+	levels, err := s.Levels(product)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), levels[0].Available)
+	assert.Equal(t, int64(18), levels[0].Reserved)
+}
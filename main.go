@@ -1,37 +1,24 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 
-	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"github.com/sing3demons/go-example/db"
-	"github.com/sing3demons/go-example/router"
-	"github.com/sing3demons/go-example/store"
+	"github.com/sing3demons/go-example/cmd"
 )
 
 func main() {
 	if os.Getenv("GO_ENV") != "production" {
-		if err := godotenv.Load(); err != nil {
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			panic(err)
 		}
 	}
 
-	gorm, err := db.ConnectDB()
-	if err != nil {
-		panic(err)
+	if err := cmd.Execute(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-
-	mongo, err := db.ConnectMonoDB()
-
-	if err != nil {
-		panic(err)
-	}
-
-	r := gin.Default()
-	router.Router(r, store.NewGormStore(gorm))
-	router.ProductRouter(r, store.NewMongoStore(mongo))
-
-	r.Run(":" + os.Getenv("PORT"))
-
 }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Activity is one entry of a todo's feed. Entries are written by the
+// activity store as todos, comments and assignees are written, never by hand.
+type Activity struct {
+	ID        uint      `json:"ID" gorm:"primarykey"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	TodoID    uint      `json:"todo_id" gorm:"index"`
+	Actor     string    `json:"actor,omitempty"`
+	// Action is one of the Action constants; Detail depends on it, such as
+	// the fields an edit changed or the user an assignment names.
+	Action    string `json:"action"`
+	Detail    string `json:"detail,omitempty"`
+	CommentID *uint  `json:"comment_id,omitempty"`
+}
+
+const (
+	ActionCreated        = "created"
+	ActionEdited         = "edited"
+	ActionMoved          = "moved"
+	ActionCompleted      = "completed"
+	ActionReopened       = "reopened"
+	ActionDeleted        = "deleted"
+	ActionAssigned       = "assigned"
+	ActionUnassigned     = "unassigned"
+	ActionCommented      = "commented"
+	ActionCommentEdited  = "comment_edited"
+	ActionCommentDeleted = "comment_deleted"
+)
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// TodoAssignee records that the user Assignee, as sent in the X-User header,
+// is working on a todo.
+type TodoAssignee struct {
+	TodoID    uint   `json:"todo_id" gorm:"primaryKey"`
+	Assignee  string `json:"assignee" gorm:"primaryKey;index"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Attributes are the typed properties of a product, such as its size or
+// color. Values are strings, numbers or booleans.
+type Attributes map[string]any
+
+func (a *Attributes) UnmarshalJSON(b []byte) error {
+	var decoded map[string]any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return err
+	}
+	return a.set(decoded)
+}
+
+func (a *Attributes) UnmarshalYAML(node *yaml.Node) error {
+	var decoded map[string]any
+	if err := node.Decode(&decoded); err != nil {
+		return err
+	}
+	return a.set(decoded)
+}
+
+// set validates decoded attributes. Names become field paths such as
+// attributes.color, so they cannot contain dots or start with $.
+func (a *Attributes) set(decoded map[string]any) error {
+	names := make([]string, 0, len(decoded))
+	for name := range decoded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "" || strings.Contains(name, ".") || strings.HasPrefix(name, "$") {
+			return fmt.Errorf("attributes: invalid name %q", name)
+		}
+		switch decoded[name].(type) {
+		case string, bool, float64, int:
+		default:
+			return fmt.Errorf("attributes: %s must be a string, a number or a boolean", name)
+		}
+	}
+	*a = decoded
+	return nil
+}
+
+// NormalizeTags lowercases and trims tags, and sorts them without blanks or
+// duplicates.
+func NormalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
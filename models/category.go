@@ -0,0 +1,13 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Category groups products. Categories form a tree through ParentID, and
+// Ancestors, root first, lets a single query find every category below one.
+type Category struct {
+	ID        primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	Name      string               `json:"name" binding:"required" bson:"name"`
+	ParentID  *primitive.ObjectID  `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	Ancestors []primitive.ObjectID `json:"ancestors" bson:"ancestors"`
+	Children  []Category           `json:"children,omitempty" bson:"-"`
+}
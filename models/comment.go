@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// Comment is a note on a todo. Body is markdown, stored as written and
+// rendered by clients.
+type Comment struct {
+	gorm.Model
+	TodoID uint   `json:"todo_id" gorm:"index"`
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
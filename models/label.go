@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Label tags todos. Labels are deleted for good, not soft-deleted, so that a
+// name is free again once its label is gone.
+type Label struct {
+	ID        uint      `json:"ID" gorm:"primarykey"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+	Name      string    `json:"name" gorm:"uniqueIndex"`
+	Color     string    `json:"color,omitempty"`
+}
+
+// TodoLabel is the join table behind Todo.Labels.
+type TodoLabel struct {
+	TodoID    uint `json:"todo_id" gorm:"primaryKey"`
+	LabelID   uint `json:"label_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time
+}
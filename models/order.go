@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sing3demons/go-example/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cart is the shopping cart of a user. A user has one cart, which checkout
+// turns into an order and empties.
+type Cart struct {
+	// ID is the user the cart belongs to.
+	ID    string     `json:"user" bson:"_id"`
+	Items []CartItem `json:"items" bson:"items"`
+	// Coupons are the promotion codes given for the cart.
+	Coupons []string `json:"coupons" bson:"coupons"`
+	// Version is bumped by every write, which only goes through while the
+	// cart is at the version read, so that a cart is only ordered once.
+	Version   int64     `json:"-" bson:"version"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	// Pricing is what checking the cart out would cost, which responses add.
+	Pricing *CartPricing `json:"pricing,omitempty" bson:"-"`
+}
+
+type CartItem struct {
+	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Quantity  int64              `json:"quantity" bson:"quantity"`
+}
+
+// Order is a checked out cart. Its items keep the name and price their
+// products had at checkout, whatever happens to the products later.
+type Order struct {
+	ID    primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	User  string             `json:"user" bson:"user"`
+	Items []OrderItem        `json:"items" bson:"items"`
+	// Discount is what the promotions of the items took off, if any, and
+	// Total what is left to pay.
+	Discount *money.Money `json:"discount,omitempty" bson:"discount,omitempty"`
+	Total    money.Money  `json:"total" bson:"total"`
+	Status   string       `json:"status" bson:"status"`
+	// History lists every status the order went through, the first being
+	// OrderPending at checkout.
+	History []OrderTransition `json:"history" bson:"history"`
+	// Version is bumped by every change of status, so that two concurrent
+	// changes cannot both apply.
+	Version int64 `json:"-" bson:"version"`
+	// Placing is set while checkout reserves the stock of the items, and
+	// Reserved counts the items, from the first, it has reserved. An order
+	// whose checkout never finished keeps Placing until it is undone.
+	Placing   bool      `json:"-" bson:"placing,omitempty"`
+	Reserved  int64     `json:"-" bson:"reserved"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+type OrderItem struct {
+	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Name      string             `json:"name" bson:"name"`
+	Price     money.Money        `json:"price" bson:"price"`
+	Quantity  int64              `json:"quantity" bson:"quantity"`
+	Subtotal  money.Money        `json:"subtotal" bson:"subtotal"`
+	// Discount is taken off Subtotal by Promotions.
+	Discount   *money.Money       `json:"discount,omitempty" bson:"discount,omitempty"`
+	Promotions []AppliedPromotion `json:"promotions,omitempty" bson:"promotions,omitempty"`
+}
+
+type OrderTransition struct {
+	// From is empty for the transition that placed the order.
+	From   string    `json:"from,omitempty" bson:"from,omitempty"`
+	To     string    `json:"to" bson:"to"`
+	Actor  string    `json:"actor,omitempty" bson:"actor,omitempty"`
+	Reason string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	At     time.Time `json:"at" bson:"at"`
+}
+
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderCancelled = "cancelled"
+)
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sing3demons/go-example/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PriceChange is an entry of a product's price history.
+type PriceChange struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	// Previous is nil for the price a product was created with.
+	Previous *money.Money `json:"previous,omitempty" bson:"previous,omitempty"`
+	Price    money.Money  `json:"price" bson:"price"`
+	Actor    string       `json:"actor,omitempty" bson:"actor,omitempty"`
+	// ScheduleID is the scheduled price that made the change, if any.
+	ScheduleID *primitive.ObjectID `json:"schedule_id,omitempty" bson:"schedule_id,omitempty"`
+	ChangedAt  time.Time           `json:"changed_at" bson:"changed_at"`
+}
+
+// ScheduledPrice is a price a product takes from StartsAt until EndsAt, when
+// the price it had before comes back. Without EndsAt the change is for good.
+type ScheduledPrice struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Price     money.Money        `json:"price" bson:"price"`
+	StartsAt  time.Time          `json:"starts_at" bson:"starts_at"`
+	EndsAt    *time.Time         `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	// Status is one of the Schedule constants.
+	Status string `json:"status" bson:"status"`
+	// Previous is the price the product had when the schedule started, to be
+	// restored at EndsAt.
+	Previous  *money.Money `json:"previous,omitempty" bson:"previous,omitempty"`
+	CreatedBy string       `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	CreatedAt time.Time    `json:"created_at" bson:"created_at"`
+}
+
+const (
+	SchedulePending  = "pending"
+	ScheduleActive   = "active"
+	ScheduleEnded    = "ended"
+	ScheduleCanceled = "canceled"
+)
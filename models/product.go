@@ -1,10 +1,40 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"github.com/sing3demons/go-example/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Product struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	Name        string             `json:"name" binding:"required" bson:"name,omitempty"`
-	Price       int                `json:"price" binding:"required" bson:"price,omitempty"`
+	Price       money.Money        `json:"price" binding:"required" bson:"price"`
 	Description string             `json:"description" binding:"required" bson:"description,omitempty"`
+	// CategoryIDs are the categories the product is assigned to directly.
+	// Like Images, they are changed with Save, so they are stored even when
+	// empty.
+	CategoryIDs []primitive.ObjectID `json:"category_ids,omitempty" bson:"category_ids"`
+	Tags        []string             `json:"tags,omitempty" bson:"tags,omitempty"`
+	Attributes  Attributes           `json:"attributes,omitempty" bson:"attributes,omitempty"`
+	// Images are managed by POST and DELETE /products/:id/images.
+	Images []ProductImage `json:"images,omitempty" bson:"images"`
+	// Pricing is the price with promotions applied, which listings add.
+	Pricing *PriceQuote `json:"pricing,omitempty" bson:"-"`
+}
+
+// ProductImage is an uploaded image of a product and its thumbnail, stored
+// in blob storage under Key and ThumbnailKey.
+type ProductImage struct {
+	ID           string    `json:"id" bson:"id"`
+	URL          string    `json:"url" bson:"url"`
+	ThumbnailURL string    `json:"thumbnail_url" bson:"thumbnail_url"`
+	ContentType  string    `json:"content_type" bson:"content_type"`
+	Size         int64     `json:"size" bson:"size"`
+	Width        int       `json:"width" bson:"width"`
+	Height       int       `json:"height" bson:"height"`
+	Key          string    `json:"-" bson:"key"`
+	ThumbnailKey string    `json:"-" bson:"thumbnail_key"`
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
 }
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sing3demons/go-example/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Promotion lowers the price of the products it targets from StartsAt until
+// EndsAt; without either the window is open on that side.
+type Promotion struct {
+	ID   primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name string             `json:"name" binding:"required" bson:"name"`
+	// Kind is one of the Promotion constants.
+	Kind string `json:"kind" binding:"required,oneof=percent fixed buy_x_get_y" bson:"kind"`
+	// Percent is taken off each unit by a PromotionPercent.
+	Percent int64 `json:"percent,omitempty" bson:"percent,omitempty"`
+	// Amount is taken off each unit by a PromotionFixed, for products priced
+	// in its currency.
+	Amount *money.Money `json:"amount,omitempty" bson:"amount,omitempty"`
+	// Of every Buy+Get units of a product, a PromotionBuyXGetY makes Get free.
+	Buy int64 `json:"buy,omitempty" bson:"buy,omitempty"`
+	Get int64 `json:"get,omitempty" bson:"get,omitempty"`
+	// ProductIDs and CategoryIDs, with their subcategories, are the products
+	// targeted. A promotion with neither targets every product.
+	ProductIDs  []primitive.ObjectID `json:"product_ids,omitempty" bson:"product_ids,omitempty"`
+	CategoryIDs []primitive.ObjectID `json:"category_ids,omitempty" bson:"category_ids,omitempty"`
+	// Code, when set, is the coupon code that must be given for the
+	// promotion to apply. Codes are upper case.
+	Code     string     `json:"code,omitempty" bson:"code,omitempty"`
+	StartsAt *time.Time `json:"starts_at,omitempty" bson:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	// Priority orders the promotions of a product, highest first.
+	Priority int `json:"priority" bson:"priority"`
+	// Stacking is PromotionExclusive, the default, for a promotion that
+	// only applies alone, or PromotionStackable for one that combines with
+	// other stackable ones.
+	Stacking  string    `json:"stacking" binding:"omitempty,oneof=exclusive stackable" bson:"stacking"`
+	CreatedBy string    `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+const (
+	PromotionPercent  = "percent"
+	PromotionFixed    = "fixed"
+	PromotionBuyXGetY = "buy_x_get_y"
+
+	PromotionExclusive = "exclusive"
+	PromotionStackable = "stackable"
+)
+
+// PriceQuote is the price of a quantity of a product once promotions are
+// applied, with the promotions that applied and why the others did not.
+type PriceQuote struct {
+	// Policy is how the promotions were chosen, see promotion.Policy.
+	Policy   string      `json:"policy"`
+	Quantity int64       `json:"quantity"`
+	Subtotal money.Money `json:"subtotal"`
+	Discount money.Money `json:"discount"`
+	Total    money.Money `json:"total"`
+	// Applied is in the order the promotions were applied.
+	Applied []AppliedPromotion `json:"applied"`
+	Skipped []SkippedPromotion `json:"skipped,omitempty"`
+}
+
+// CartPricing is what checking out a cart would cost with the current
+// prices and promotions.
+type CartPricing struct {
+	Items    []CartLine  `json:"items"`
+	Subtotal money.Money `json:"subtotal"`
+	Discount money.Money `json:"discount"`
+	Total    money.Money `json:"total"`
+}
+
+type CartLine struct {
+	ProductID primitive.ObjectID `json:"product_id"`
+	Name      string             `json:"name"`
+	Price     money.Money        `json:"price"`
+	PriceQuote
+}
+
+type AppliedPromotion struct {
+	PromotionID primitive.ObjectID `json:"promotion_id" bson:"promotion_id"`
+	Name        string             `json:"name" bson:"name"`
+	Kind        string             `json:"kind" bson:"kind"`
+	Code        string             `json:"code,omitempty" bson:"code,omitempty"`
+	Discount    money.Money        `json:"discount" bson:"discount"`
+}
+
+type SkippedPromotion struct {
+	PromotionID primitive.ObjectID `json:"promotion_id"`
+	Name        string             `json:"name"`
+	Reason      string             `json:"reason"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StockLevel is the stock of a product in one warehouse. Available is
+// OnHand less Reserved, kept as a field of its own so that a reservation can
+// be made conditional on it in a single update.
+type StockLevel struct {
+	// ID is StockLevelID(ProductID, Warehouse), so a level exists only once.
+	ID                string             `json:"-" bson:"_id"`
+	ProductID         primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Warehouse         string             `json:"warehouse" bson:"warehouse"`
+	OnHand            int64              `json:"on_hand" bson:"on_hand"`
+	Reserved          int64              `json:"reserved" bson:"reserved"`
+	Available         int64              `json:"available" bson:"available"`
+	LowStockThreshold int64              `json:"low_stock_threshold" bson:"low_stock_threshold"`
+	UpdatedAt         time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+func StockLevelID(productID primitive.ObjectID, warehouse string) string {
+	return productID.Hex() + "/" + warehouse
+}
+
+// Low reports whether the available stock is at or below the threshold.
+func (l StockLevel) Low() bool {
+	return l.Available <= l.LowStockThreshold
+}
+
+// StockMovement is an entry of a product's stock history.
+type StockMovement struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ProductID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Warehouse string             `json:"warehouse" bson:"warehouse"`
+	// Kind is one of the Stock constants. Quantity is positive except for
+	// adjustments that remove stock.
+	Kind     string `json:"kind" bson:"kind"`
+	Quantity int64  `json:"quantity" bson:"quantity"`
+	Reason   string `json:"reason,omitempty" bson:"reason,omitempty"`
+	Actor    string `json:"actor,omitempty" bson:"actor,omitempty"`
+	// OnHand, Reserved and Available are the level after the movement.
+	OnHand    int64 `json:"on_hand" bson:"on_hand"`
+	Reserved  int64 `json:"reserved" bson:"reserved"`
+	Available int64 `json:"available" bson:"available"`
+	// LowStock is set on the movement that took the level to its threshold.
+	LowStock  bool      `json:"low_stock,omitempty" bson:"low_stock,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+const (
+	StockAdjusted  = "adjusted"
+	StockReserved  = "reserved"
+	StockReleased  = "released"
+	StockCommitted = "committed"
+)
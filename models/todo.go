@@ -1,9 +1,38 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Todo struct {
 	gorm.Model
 	Title     string `json:"title"`
 	Completed bool   `json:"completed"`
+	// Owner is the user the todo belongs to, as sent in the X-User header.
+	Owner string     `json:"owner,omitempty" gorm:"index"`
+	DueAt *time.Time `json:"due_at,omitempty"`
+	// ReminderMinutes is how long before DueAt to remind; 0 means never.
+	ReminderMinutes int `json:"reminder_minutes,omitempty"`
+	// RRule is an iCalendar recurrence rule such as "FREQ=WEEKLY;BYDAY=MO".
+	RRule string `json:"rrule,omitempty" gorm:"column:rrule"`
+	// SeriesID is the ID of the first todo of a recurring series and
+	// Occurrence the 1-based number of this todo in it.
+	SeriesID   *uint `json:"series_id,omitempty" gorm:"uniqueIndex:idx_todos_series_occurrence"`
+	Occurrence int   `json:"occurrence,omitempty" gorm:"uniqueIndex:idx_todos_series_occurrence"`
+	// NextMaterialized is set once the next occurrence has been created, or
+	// the series has ended.
+	NextMaterialized bool `json:"next_materialized,omitempty"`
+	// ListID is the list the todo belongs to and ParentID the todo it is a
+	// subtask of; a subtask is always in its parent's list.
+	ListID   *uint    `json:"list_id,omitempty" gorm:"index"`
+	ParentID *uint    `json:"parent_id,omitempty" gorm:"index"`
+	Subtasks []Todo   `json:"subtasks,omitempty" gorm:"foreignKey:ParentID"`
+	Priority Priority `json:"priority"`
+	// Position orders todos by plain byte comparison; see package fracindex.
+	Position string  `json:"position,omitempty"`
+	Labels   []Label `json:"labels,omitempty" gorm:"many2many:todo_labels"`
+	// Assignees are filled in from TodoAssignee rows when todos are listed.
+	Assignees []string `json:"assignees,omitempty" gorm:"-"`
 }
@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// TodoList is a project grouping todos.
+type TodoList struct {
+	gorm.Model
+	Name  string `json:"name"`
+	Owner string `json:"owner,omitempty" gorm:"index"`
+	Todos []Todo `json:"todos,omitempty" gorm:"foreignKey:ListID"`
+}
@@ -0,0 +1,131 @@
+package money
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultLocale is used for languages without a format of their own.
+const DefaultLocale = "en"
+
+// format is how a locale writes amounts.
+type format struct {
+	group, decimal string
+	// symbolAfter puts the currency symbol after the number.
+	symbolAfter bool
+}
+
+// formats are keyed by lowercase BCP 47 tag; a tag with a region falls
+// back to its language.
+var formats = map[string]format{
+	"de":    {".", ",", true},
+	"de-ch": {"’", ".", false},
+	"en":    {",", ".", false},
+	"es":    {".", ",", true},
+	"fr":    {"\u202f", ",", true},
+	"ja":    {",", ".", false},
+	"th":    {",", ".", false},
+}
+
+func lookupFormat(locale string) (format, bool) {
+	tag := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if f, ok := formats[tag]; ok {
+		return f, true
+	}
+	lang, _, _ := strings.Cut(tag, "-")
+	f, ok := formats[lang]
+	return f, ok
+}
+
+// Format writes m the way locale does, such as "$1,234.50" for en and
+// "1.234,50 $" for de. Number and symbol are parted by a no-break space, if at all.
+func (m Money) Format(locale string) string {
+	c, err := Lookup(m.Currency)
+	if err != nil {
+		return m.String()
+	}
+	f, ok := lookupFormat(locale)
+	if !ok {
+		f, _ = lookupFormat(DefaultLocale)
+	}
+
+	number := plain(m.Amount, c.Digits)
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	whole, frac, _ := strings.Cut(number, ".")
+	number = group(whole, f.group)
+	if frac != "" {
+		number += f.decimal + frac
+	}
+
+	if f.symbolAfter {
+		return sign + number + "\u00a0" + c.Symbol
+	}
+	// Codes used as symbols, such as CHF, need a space before the number.
+	if r := []rune(c.Symbol); unicode.IsLetter(r[len(r)-1]) {
+		return sign + c.Symbol + "\u00a0" + number
+	}
+	return sign + c.Symbol + number
+}
+
+// plain writes amount minor units with a decimal point, such as "-12.05".
+func plain(amount int64, digits int) string {
+	s := strconv.FormatInt(amount, 10)
+	sign := ""
+	if amount < 0 {
+		sign, s = "-", s[1:]
+	}
+	if digits == 0 {
+		return sign + s
+	}
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+func group(digits, sep string) string {
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// Negotiate picks the supported locale an Accept-Language header prefers,
+// or DefaultLocale.
+func Negotiate(acceptLanguage string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if tag != "" && tag != "*" && q > 0 {
+			choices = append(choices, choice{tag, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+
+	for _, c := range choices {
+		if _, ok := lookupFormat(c.tag); ok {
+			return c.tag
+		}
+	}
+	return DefaultLocale
+}
@@ -0,0 +1,199 @@
+// Package money holds amounts of money as whole minor units, such as cents,
+// of an ISO 4217 currency, so that arithmetic on them is exact.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+)
+
+// Currency is a supported ISO 4217 currency.
+type Currency struct {
+	Code string
+	// Digits is the number of minor-unit digits: 2 for USD, 0 for JPY.
+	Digits int
+	Symbol string
+}
+
+var currencies = map[string]Currency{
+	"CHF": {"CHF", 2, "CHF"},
+	"EUR": {"EUR", 2, "€"},
+	"GBP": {"GBP", 2, "£"},
+	"JPY": {"JPY", 0, "¥"},
+	"KWD": {"KWD", 3, "KWD"},
+	"SGD": {"SGD", 2, "S$"},
+	"THB": {"THB", 2, "฿"},
+	"USD": {"USD", 2, "$"},
+}
+
+// Lookup returns the supported currency with the code, in any case.
+func Lookup(code string) (Currency, error) {
+	c, ok := currencies[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Currency{}, fmt.Errorf("unsupported currency %q, must be one of %s", code, strings.Join(Codes(), ", "))
+	}
+	return c, nil
+}
+
+// Codes returns the codes of the supported currencies, sorted.
+func Codes() []string {
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Money is an amount in minor units of Currency. In mongo it is stored as
+// {amount: Decimal128, currency} with the amount in major units, 12.34
+// rather than 1234, so that the database can compare and sum it exactly.
+type Money struct {
+	Amount   int64  `json:"amount" yaml:"amount" binding:"min=0"`
+	Currency string `json:"currency" yaml:"currency" binding:"required"`
+	// Formatted is the amount written for a locale, set on responses.
+	Formatted string `json:"formatted,omitempty" yaml:"-"`
+}
+
+// New returns amount minor units of currency.
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func (m Money) String() string {
+	c, err := Lookup(m.Currency)
+	if err != nil {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	return plain(m.Amount, c.Digits) + " " + c.Code
+}
+
+// Decimal returns the amount in major units.
+func (m Money) Decimal() (primitive.Decimal128, error) {
+	c, err := Lookup(m.Currency)
+	if err != nil {
+		return primitive.Decimal128{}, err
+	}
+	d, ok := primitive.ParseDecimal128FromBigInt(big.NewInt(m.Amount), -c.Digits)
+	if !ok {
+		return primitive.Decimal128{}, fmt.Errorf("money: %d does not fit a decimal", m.Amount)
+	}
+	return d, nil
+}
+
+// FromDecimal converts an amount in major units of currency. It fails when
+// the amount has more decimals than the currency has minor units.
+func FromDecimal(d primitive.Decimal128, currency string) (Money, error) {
+	c, err := Lookup(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	n, exp, err := d.BigInt()
+	if err != nil {
+		return Money{}, err
+	}
+
+	ten := big.NewInt(10)
+	for ; exp > -c.Digits; exp-- {
+		n.Mul(n, ten)
+	}
+	for ; exp < -c.Digits; exp++ {
+		var rem big.Int
+		if n.QuoRem(n, ten, &rem); rem.Sign() != 0 {
+			return Money{}, fmt.Errorf("money: %s has more than %d decimals for %s", d, c.Digits, c.Code)
+		}
+	}
+	if !n.IsInt64() {
+		return Money{}, fmt.Errorf("money: %s is too large", d)
+	}
+	return Money{Amount: n.Int64(), Currency: c.Code}, nil
+}
+
+// storedMoney is the form of Money in mongo.
+type storedMoney struct {
+	Amount   primitive.Decimal128 `bson:"amount"`
+	Currency string               `bson:"currency"`
+}
+
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	d, err := m.Decimal()
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(storedMoney{Amount: d, Currency: m.Currency})
+}
+
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t != bsontype.EmbeddedDocument {
+		return fmt.Errorf("money: cannot decode %s; prices stored as numbers need `migrate`", t)
+	}
+	var stored storedMoney
+	if err := (bson.RawValue{Type: t, Value: data}).Unmarshal(&stored); err != nil {
+		return err
+	}
+	decoded, err := FromDecimal(stored.Amount, stored.Currency)
+	if err != nil {
+		return err
+	}
+	*m = decoded
+	return nil
+}
+
+// JSONSchema describes the stored form for store.JSONSchema.
+func (Money) JSONSchema() bson.M {
+	codes := bson.A{}
+	for _, code := range Codes() {
+		codes = append(codes, code)
+	}
+	return bson.M{
+		"bsonType": "object",
+		"required": bson.A{"amount", "currency"},
+		"properties": bson.M{
+			"amount":   bson.M{"bsonType": "decimal"},
+			"currency": bson.M{"enum": codes},
+		},
+	}
+}
+
+// money is Money without its methods, for decoding.
+type money Money
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var decoded money
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return err
+	}
+	return m.set(decoded)
+}
+
+func (m *Money) UnmarshalYAML(node *yaml.Node) error {
+	var decoded money
+	if err := node.Decode(&decoded); err != nil {
+		return err
+	}
+	return m.set(decoded)
+}
+
+// set validates the currency of a decoded amount. A missing currency is
+// left for the binding rules to report.
+func (m *Money) set(decoded money) error {
+	decoded.Formatted = ""
+	if decoded.Currency != "" {
+		c, err := Lookup(decoded.Currency)
+		if err != nil {
+			return errors.New("money: " + err.Error())
+		}
+		decoded.Currency = c.Code
+	}
+	*m = Money(decoded)
+	return nil
+}
@@ -0,0 +1,101 @@
+package money_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sing3demons/go-example/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		m      money.Money
+		locale string
+		want   string
+	}{
+		{money.New(123450, "USD"), "en-US", "$1,234.50"},
+		{money.New(123450, "EUR"), "de-DE", "1.234,50\u00a0€"},
+		{money.New(123450, "EUR"), "fr", "1\u202f234,50\u00a0€"},
+		{money.New(123450, "CHF"), "de-CH", "CHF\u00a01’234.50"},
+		{money.New(1234567, "JPY"), "ja", "¥1,234,567"},
+		{money.New(5, "KWD"), "en", "KWD\u00a00.005"},
+		{money.New(-99, "THB"), "th", "-฿0.99"},
+		{money.New(0, "GBP"), "xx", "£0.00"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, tc.m.Format(tc.locale), "%v in %s", tc.m, tc.locale)
+	}
+	assert.Equal(t, "1234.50 USD", money.New(123450, "USD").String())
+}
+
+func TestNegotiate(t *testing.T) {
+	assert.Equal(t, "de-DE", money.Negotiate("de-DE,de;q=0.9,en;q=0.8"))
+	assert.Equal(t, "fr", money.Negotiate("pl;q=0.9, fr;q=0.5"))
+	assert.Equal(t, "en", money.Negotiate("th;q=0.1, en"))
+	assert.Equal(t, money.DefaultLocale, money.Negotiate(""))
+	assert.Equal(t, money.DefaultLocale, money.Negotiate("pl, *;q=0.5"))
+}
+
+func TestDecimal(t *testing.T) {
+	d, err := money.New(1010, "USD").Decimal()
+	require.NoError(t, err)
+	assert.Equal(t, "10.10", d.String())
+
+	for s, want := range map[string]int64{"10.1": 1010, "10.100": 1010, "7": 700, "1E+1": 1000} {
+		d, err := primitive.ParseDecimal128(s)
+		require.NoError(t, err)
+		m, err := money.FromDecimal(d, "usd")
+		require.NoError(t, err, s)
+		assert.Equal(t, money.New(want, "USD"), m, s)
+	}
+
+	d, _ = primitive.ParseDecimal128("10.5")
+	_, err = money.FromDecimal(d, "JPY")
+	assert.EqualError(t, err, "money: 10.5 has more than 0 decimals for JPY")
+
+	_, err = money.FromDecimal(d, "XYZ")
+	assert.Error(t, err)
+}
+
+func TestBSON(t *testing.T) {
+	type product struct {
+		Price money.Money `bson:"price"`
+	}
+
+	b, err := bson.Marshal(product{money.New(0, "EUR")})
+	require.NoError(t, err)
+	var raw bson.M
+	require.NoError(t, bson.Unmarshal(b, &raw))
+	price := raw["price"].(bson.M)
+	assert.Equal(t, "0.00", price["amount"].(primitive.Decimal128).String(), "a price of 0 is kept")
+	assert.Equal(t, "EUR", price["currency"])
+
+	var decoded product
+	require.NoError(t, bson.Unmarshal(b, &decoded))
+	assert.Equal(t, money.New(0, "EUR"), decoded.Price)
+
+	legacy, _ := bson.Marshal(bson.M{"price": 1010})
+	assert.ErrorContains(t, bson.Unmarshal(legacy, &decoded), "migrate")
+
+	_, err = bson.Marshal(product{money.New(1, "XYZ")})
+	assert.Error(t, err)
+}
+
+func TestDecodeValidatesCurrency(t *testing.T) {
+	var m money.Money
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":250,"currency":"thb","formatted":"x"}`), &m))
+	assert.Equal(t, money.New(250, "THB"), m)
+
+	assert.EqualError(t, json.Unmarshal([]byte(`{"amount":250,"currency":"ABC"}`), &m),
+		`money: unsupported currency "ABC", must be one of CHF, EUR, GBP, JPY, KWD, SGD, THB, USD`)
+	assert.Error(t, json.Unmarshal([]byte(`250`), &m))
+
+	require.NoError(t, yaml.Unmarshal([]byte(`{amount: 5, currency: jpy}`), &m))
+	assert.Equal(t, money.New(5, "JPY"), m)
+	assert.Error(t, yaml.Unmarshal([]byte(`{amount: 5, currency: ABC}`), &m))
+}
@@ -0,0 +1,184 @@
+// Package pricing keeps the price history of products and applies scheduled
+// prices. Store wraps the product store and turns every write that changes a
+// price into a models.PriceChange, so handlers never write the history
+// themselves.
+package pricing
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Store struct {
+	next    store.Storer
+	history store.Storer
+	actor   string
+	// schedule is the scheduled price the writes apply.
+	schedule *primitive.ObjectID
+}
+
+// NewStore returns products that records their price changes in history.
+func NewStore(products, history store.Storer) *Store {
+	return &Store{next: products, history: history}
+}
+
+// As returns a store that attributes its price changes to actor.
+func (s *Store) As(actor string) store.Storer {
+	return &Store{next: s.next, history: s.history, actor: actor, schedule: s.schedule}
+}
+
+// forSchedule returns a store that attributes its price changes to actor
+// applying sp.
+func (s *Store) forSchedule(sp *models.ScheduledPrice, actor string) *Store {
+	return &Store{next: s.next, history: s.history, actor: actor, schedule: &sp.ID}
+}
+
+// History returns the price changes of a product, oldest first.
+func (s *Store) History(productID primitive.ObjectID) ([]models.PriceChange, error) {
+	changes := []models.PriceChange{}
+	if err := s.history.Find(&changes, bson.M{"product_id": productID}); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].ChangedAt.Before(changes[j].ChangedAt) })
+	return changes, nil
+}
+
+func (s *Store) Find(dest any, conds ...any) error {
+	return s.next.Find(dest, conds...)
+}
+
+func (s *Store) First(dest any, conds ...any) error {
+	return s.next.First(dest, conds...)
+}
+
+func (s *Store) Each(dest any, fn func() error, conds ...any) error {
+	return store.Each(s.next, dest, fn, conds...)
+}
+
+func (s *Store) Facets(model any, fields []string, conds ...any) (map[string][]store.FacetCount, error) {
+	return store.Facets(s.next, model, fields, conds...)
+}
+
+func (s *Store) Truncate(model any) error {
+	return store.Truncate(s.next, model)
+}
+
+func (s *Store) Delete(value any, conds ...any) error {
+	return store.Delete(s.next, value, conds...)
+}
+
+func (s *Store) Create(value any) error {
+	if err := s.next.Create(value); err != nil {
+		return err
+	}
+	s.record(nil, value)
+	return nil
+}
+
+func (s *Store) Save(value any) error {
+	before := s.previous(value)
+	if err := s.next.Save(value); err != nil {
+		return err
+	}
+	s.record(before, value)
+	return nil
+}
+
+// Update records the price changes of the products it matched.
+func (s *Store) Update(model any, set map[string]any, conds ...any) error {
+	if _, ok := model.(*models.Product); !ok {
+		return store.Update(s.next, model, set, conds...)
+	}
+
+	var matched []models.Product
+	if err := s.next.Find(&matched, conds...); err != nil && !store.IsNotFound(err) {
+		log.Printf("pricing: finding the products to update: %v", err)
+	}
+	if err := store.Update(s.next, model, set, conds...); err != nil {
+		return err
+	}
+	for i := range matched {
+		// The stored version is the updated one by now.
+		if after := s.previous(&matched[i]); after != nil {
+			s.record(&matched[i], after)
+		}
+	}
+	return nil
+}
+
+// BulkWrite records the price changes of the ops that succeeded. An atomic
+// write that was rolled back records nothing.
+func (s *Store) BulkWrite(ops []store.BulkOp, opts store.BulkOptions) ([]error, error) {
+	before := make([]*models.Product, len(ops))
+	for i, op := range ops {
+		if op.Kind == store.BulkUpdate {
+			before[i] = s.previous(op.Value)
+		}
+	}
+
+	errs, err := store.BulkWrite(s.next, ops, opts)
+	if err != nil {
+		return errs, err
+	}
+	for i, op := range ops {
+		if errs[i] == nil && op.Kind != store.BulkDelete {
+			s.record(before[i], op.Value)
+		}
+	}
+	return errs, nil
+}
+
+// previous loads the stored version of a product about to be saved.
+func (s *Store) previous(value any) *models.Product {
+	p, ok := value.(*models.Product)
+	if !ok || p.ID.IsZero() {
+		return nil
+	}
+	var old models.Product
+	if err := s.next.First(&old, bson.M{"_id": p.ID}); err != nil {
+		if !store.IsNotFound(err) {
+			log.Printf("pricing: loading product %s: %v", p.ID.Hex(), err)
+		}
+		return nil
+	}
+	return &old
+}
+
+// record writes the price change of one write, if it made one. The write
+// itself has already succeeded, so a failure here is logged rather than
+// returned.
+func (s *Store) record(before *models.Product, value any) {
+	p, ok := value.(*models.Product)
+	if !ok {
+		return
+	}
+	change := models.PriceChange{
+		ProductID:  p.ID,
+		Price:      p.Price,
+		Actor:      s.actor,
+		ScheduleID: s.schedule,
+		ChangedAt:  time.Now(),
+	}
+	change.Price.Formatted = ""
+	if before != nil {
+		if samePrice(before.Price, p.Price) {
+			return
+		}
+		change.Previous = &before.Price
+		change.Previous.Formatted = ""
+	}
+	if err := s.history.Create(&change); err != nil {
+		log.Printf("pricing: recording the price of product %s: %v", p.ID.Hex(), err)
+	}
+}
+
+func samePrice(a, b money.Money) bool {
+	return a.Amount == b.Amount && a.Currency == b.Currency
+}
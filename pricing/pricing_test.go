@@ -0,0 +1,263 @@
+package pricing_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/pricing"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func prices(t *testing.T, s *pricing.Store, product models.Product) []int64 {
+	t.Helper()
+	changes, err := s.History(product.ID)
+	require.NoError(t, err)
+	var amounts []int64
+	for _, c := range changes {
+		amounts = append(amounts, c.Price.Amount)
+	}
+	return amounts
+}
+
+func current(t *testing.T, s store.Storer, product models.Product) int64 {
+	t.Helper()
+	var p models.Product
+	require.NoError(t, s.First(&p, bson.M{"_id": product.ID}))
+	return p.Price.Amount
+}
+
+func TestHistory(t *testing.T) {
+	s := pricing.NewStore(store.NewMemoryStore(), store.NewMemoryStore())
+
+	product := models.Product{Name: "Lamp", Price: money.New(4500, "USD"), Description: "Desk lamp"}
+	require.NoError(t, store.As(s, "alice").Create(&product))
+
+	product.Description = "A desk lamp"
+	require.NoError(t, s.Save(&product))
+	product.Price = money.New(3900, "USD")
+	require.NoError(t, store.As(s, "bob").Save(&product))
+
+	changes, err := s.History(product.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 2, "saves that keep the price are not recorded")
+	assert.Nil(t, changes[0].Previous)
+	assert.Equal(t, "alice", changes[0].Actor)
+	assert.Equal(t, money.New(4500, "USD"), *changes[1].Previous)
+	assert.Equal(t, money.New(3900, "USD"), changes[1].Price)
+	assert.Equal(t, "bob", changes[1].Actor)
+
+	t.Run("bulk writes", func(t *testing.T) {
+		update := product
+		update.Price = money.New(3500, "USD")
+		created := models.Product{Name: "Shade", Price: money.New(1200, "USD"), Description: "Lamp shade"}
+		errs, err := store.BulkWrite(store.As(s, "carol"), []store.BulkOp{
+			{Kind: store.BulkUpdate, Value: &update},
+			{Kind: store.BulkCreate, Value: &created},
+		}, store.BulkOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []error{nil, nil}, errs)
+
+		assert.Equal(t, []int64{4500, 3900, 3500}, prices(t, s, product))
+		assert.Equal(t, []int64{1200}, prices(t, s, created))
+	})
+
+	t.Run("updates", func(t *testing.T) {
+		filter := bson.M{"_id": product.ID}
+		require.NoError(t, store.Update(s, &models.Product{}, map[string]any{"images": []models.ProductImage{{ID: "a"}}}, filter))
+		require.NoError(t, store.Update(store.As(s, "dave"), &models.Product{}, map[string]any{"price": money.New(3000, "USD")}, filter))
+
+		changes, err := s.History(product.ID)
+		require.NoError(t, err)
+		require.Len(t, changes, 4, "updates that keep the price are not recorded")
+		assert.Equal(t, money.New(3500, "USD"), *changes[3].Previous)
+		assert.Equal(t, "dave", changes[3].Actor)
+		assert.Equal(t, int64(3000), current(t, s, product))
+	})
+}
+
+func TestSchedules(t *testing.T) {
+	s := pricing.NewStore(store.NewMemoryStore(), store.NewMemoryStore())
+	schedules := pricing.NewSchedules(s, store.NewMemoryStore())
+	ctx := context.Background()
+	now := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	at := func(days int) *time.Time {
+		t := now.AddDate(0, 0, days)
+		return &t
+	}
+
+	product := models.Product{Name: "Lamp", Price: money.New(4500, "USD"), Description: "Desk lamp"}
+	require.NoError(t, s.Create(&product))
+
+	sale := models.ScheduledPrice{ProductID: product.ID, Price: money.New(2900, "USD"), StartsAt: *at(1), EndsAt: at(3)}
+	require.NoError(t, schedules.Add(&sale, now))
+	assert.Equal(t, models.SchedulePending, sale.Status)
+
+	for _, invalid := range []models.ScheduledPrice{
+		{ProductID: product.ID, Price: money.New(1, "USD")},
+		{ProductID: product.ID, Price: money.New(1, "USD"), StartsAt: *at(5), EndsAt: at(4)},
+		{ProductID: product.ID, Price: money.New(1, "USD"), StartsAt: *at(-5), EndsAt: at(-1)},
+	} {
+		invalid := invalid
+		assert.ErrorIs(t, schedules.Add(&invalid, now), pricing.ErrInvalidSchedule)
+	}
+	overlapping := models.ScheduledPrice{ProductID: product.ID, Price: money.New(1, "USD"), StartsAt: *at(2)}
+	assert.ErrorIs(t, schedules.Add(&overlapping, now), pricing.ErrScheduleOverlaps)
+
+	rise := models.ScheduledPrice{ProductID: product.ID, Price: money.New(4900, "USD"), StartsAt: *at(3)}
+	require.NoError(t, schedules.Add(&rise, now), "a schedule may start as another ends")
+
+	require.NoError(t, schedules.Run(ctx, now))
+	assert.Equal(t, int64(4500), current(t, s, product), "nothing is due yet")
+
+	require.NoError(t, schedules.Run(ctx, *at(1)))
+	assert.Equal(t, int64(2900), current(t, s, product))
+
+	require.NoError(t, schedules.Run(ctx, *at(3)))
+	assert.Equal(t, int64(4900), current(t, s, product), "the sale ends before the rise starts")
+	assert.Equal(t, []int64{4500, 2900, 4500, 4900}, prices(t, s, product))
+
+	changes, err := s.History(product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, pricing.SchedulerActor, changes[1].Actor)
+	assert.Equal(t, sale.ID, *changes[1].ScheduleID)
+
+	scheduled, err := schedules.List(product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.ScheduleEnded, models.ScheduleEnded}, []string{scheduled[0].Status, scheduled[1].Status})
+
+	t.Run("a price changed during a sale is kept", func(t *testing.T) {
+		sale := models.ScheduledPrice{ProductID: product.ID, Price: money.New(3900, "USD"), StartsAt: *at(10), EndsAt: at(12)}
+		require.NoError(t, schedules.Add(&sale, now))
+		require.NoError(t, schedules.Run(ctx, *at(10)))
+
+		var p models.Product
+		require.NoError(t, s.First(&p, bson.M{"_id": product.ID}))
+		p.Price = money.New(5900, "USD")
+		require.NoError(t, store.As(s, "alice").Save(&p))
+
+		require.NoError(t, schedules.Run(ctx, *at(12)))
+		assert.Equal(t, int64(5900), current(t, s, product))
+	})
+
+	t.Run("a missed window is skipped", func(t *testing.T) {
+		sale := models.ScheduledPrice{ProductID: product.ID, Price: money.New(100, "USD"), StartsAt: *at(20), EndsAt: at(21)}
+		require.NoError(t, schedules.Add(&sale, now))
+		require.NoError(t, schedules.Run(ctx, *at(22)))
+		assert.Equal(t, int64(5900), current(t, s, product))
+	})
+
+	t.Run("cancelling a sale in effect ends it", func(t *testing.T) {
+		sale := models.ScheduledPrice{ProductID: product.ID, Price: money.New(4900, "USD"), StartsAt: *at(30), EndsAt: at(40)}
+		require.NoError(t, schedules.Add(&sale, now))
+		require.NoError(t, schedules.Run(ctx, *at(30)))
+		assert.Equal(t, int64(4900), current(t, s, product))
+
+		canceled, err := schedules.Cancel(product.ID, sale.ID, "bob")
+		require.NoError(t, err)
+		assert.Equal(t, models.ScheduleCanceled, canceled.Status)
+		assert.Equal(t, int64(5900), current(t, s, product))
+
+		_, err = schedules.Cancel(product.ID, sale.ID, "bob")
+		assert.ErrorIs(t, err, pricing.ErrScheduleFinished)
+	})
+}
+
+// editedAfterRead is a product store in which someone else saves a product
+// right after it is first read.
+type editedAfterRead struct {
+	store.Storer
+	edit func()
+}
+
+func (e *editedAfterRead) First(dest any, conds ...any) error {
+	err := e.Storer.First(dest, conds...)
+	if edit := e.edit; edit != nil {
+		e.edit = nil
+		edit()
+	}
+	return err
+}
+
+func (e *editedAfterRead) Update(model any, set map[string]any, conds ...any) error {
+	return store.Update(e.Storer, model, set, conds...)
+}
+
+func TestSchedulesOnlyChangePrices(t *testing.T) {
+	products := &editedAfterRead{Storer: store.NewMemoryStore()}
+	s := pricing.NewStore(products, store.NewMemoryStore())
+	schedules := pricing.NewSchedules(s, store.NewMemoryStore())
+	now := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	product := models.Product{Name: "Lamp", Price: money.New(4500, "USD"), Description: "Desk lamp"}
+	require.NoError(t, s.Create(&product))
+	sale := models.ScheduledPrice{ProductID: product.ID, Price: money.New(2900, "USD"), StartsAt: now.AddDate(0, 0, 1)}
+	require.NoError(t, schedules.Add(&sale, now))
+
+	products.edit = func() {
+		edited := product
+		edited.Description = "A desk lamp"
+		require.NoError(t, products.Storer.Save(&edited))
+	}
+	require.NoError(t, schedules.Run(context.Background(), now.AddDate(0, 0, 1)))
+
+	var p models.Product
+	require.NoError(t, s.First(&p, bson.M{"_id": product.ID}))
+	assert.Equal(t, int64(2900), p.Price.Amount)
+	assert.Equal(t, "A desk lamp", p.Description, "an edit made meanwhile is kept")
+}
+
+// storedFilters is a product store that, like mongo, matches the conditions
+// of partial updates against the product as marshalled to BSON rather than
+// against its Go values.
+type storedFilters struct {
+	store.Storer
+}
+
+func (s storedFilters) Update(model any, set map[string]any, conds ...any) error {
+	filter := conds[0].(bson.M)
+	var p models.Product
+	if err := s.Storer.First(&p, bson.M{"_id": filter["_id"]}); err != nil {
+		return err
+	}
+	doc, err := bson.Marshal(p)
+	if err != nil {
+		return err
+	}
+	for key, want := range filter {
+		typ, data, err := bson.MarshalValue(want)
+		if err != nil {
+			return err
+		}
+		got, err := bson.Raw(doc).LookupErr(strings.Split(key, ".")...)
+		if err != nil || !got.Equal(bson.RawValue{Type: typ, Value: data}) {
+			return store.ErrNotFound
+		}
+	}
+	return store.Update(s.Storer, model, set, conds...)
+}
+
+func TestSchedulesMatchStoredPrices(t *testing.T) {
+	s := pricing.NewStore(storedFilters{store.NewMemoryStore()}, store.NewMemoryStore())
+	schedules := pricing.NewSchedules(s, store.NewMemoryStore())
+	ctx := context.Background()
+	now := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	product := models.Product{Name: "Lamp", Price: money.New(4500, "USD"), Description: "Desk lamp"}
+	require.NoError(t, s.Create(&product))
+	ends := now.AddDate(0, 0, 2)
+	sale := models.ScheduledPrice{ProductID: product.ID, Price: money.New(2900, "USD"), StartsAt: now.AddDate(0, 0, 1), EndsAt: &ends}
+	require.NoError(t, schedules.Add(&sale, now))
+
+	require.NoError(t, schedules.Run(ctx, now.AddDate(0, 0, 1)))
+	assert.Equal(t, int64(2900), current(t, s, product), "the sale starts")
+
+	require.NoError(t, schedules.Run(ctx, ends))
+	assert.Equal(t, int64(4500), current(t, s, product), "the sale ends")
+}
@@ -0,0 +1,236 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SchedulerActor is who the price changes the scheduler makes are
+// attributed to.
+const SchedulerActor = "scheduler"
+
+var (
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+	ErrScheduleOverlaps = errors.New("the product already has a price scheduled then")
+	ErrScheduleFinished = errors.New("the scheduled price is already over")
+)
+
+// Schedules keeps the scheduled prices of products and applies them: Run,
+// a scheduler.JobFunc, starts the ones that are due and ends the ones that
+// are over. At most one scheduled price of a product is in effect at a time.
+type Schedules struct {
+	products  *Store
+	schedules store.Storer
+	// mu serializes Run and Cancel within a replica; across replicas the
+	// scheduler's leader election does.
+	mu sync.Mutex
+}
+
+func NewSchedules(products *Store, schedules store.Storer) *Schedules {
+	return &Schedules{products: products, schedules: schedules}
+}
+
+// List returns the scheduled prices of a product by start.
+func (s *Schedules) List(productID primitive.ObjectID) ([]models.ScheduledPrice, error) {
+	scheduled := []models.ScheduledPrice{}
+	if err := s.schedules.Find(&scheduled, bson.M{"product_id": productID}); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	sort.SliceStable(scheduled, func(i, j int) bool { return scheduled[i].StartsAt.Before(scheduled[j].StartsAt) })
+	return scheduled, nil
+}
+
+// Add schedules sp.Price for sp.ProductID. A start in the past applies at
+// the next run; an end must be after both the start and now.
+func (s *Schedules) Add(sp *models.ScheduledPrice, now time.Time) error {
+	switch {
+	case sp.StartsAt.IsZero():
+		return fmt.Errorf("%w: starts_at is required", ErrInvalidSchedule)
+	case sp.EndsAt != nil && !sp.EndsAt.After(sp.StartsAt):
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidSchedule)
+	case sp.EndsAt != nil && !sp.EndsAt.After(now):
+		return fmt.Errorf("%w: ends_at is in the past", ErrInvalidSchedule)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheduled, err := s.List(sp.ProductID)
+	if err != nil {
+		return err
+	}
+	for _, other := range scheduled {
+		if (other.Status == models.SchedulePending || other.Status == models.ScheduleActive) && overlap(*sp, other) {
+			return fmt.Errorf("%w: %s", ErrScheduleOverlaps, other.ID.Hex())
+		}
+	}
+
+	sp.ID = primitive.NilObjectID
+	sp.Price.Formatted = ""
+	sp.Status = models.SchedulePending
+	sp.Previous = nil
+	sp.CreatedAt = now
+	return s.schedules.Create(sp)
+}
+
+// overlap reports whether two scheduled prices would be in effect at the
+// same time. A change for good only takes its start.
+func overlap(a, b models.ScheduledPrice) bool {
+	return a.StartsAt.Before(until(b)) && b.StartsAt.Before(until(a))
+}
+
+func until(sp models.ScheduledPrice) time.Time {
+	if sp.EndsAt == nil {
+		return sp.StartsAt.Add(time.Nanosecond)
+	}
+	return *sp.EndsAt
+}
+
+// Cancel cancels a scheduled price of a product. One in effect ends at
+// once, giving the product back its previous price.
+func (s *Schedules) Cancel(productID, id primitive.ObjectID, actor string) (models.ScheduledPrice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sp models.ScheduledPrice
+	if err := s.schedules.First(&sp, bson.M{"_id": id, "product_id": productID}); err != nil {
+		return sp, err
+	}
+	switch sp.Status {
+	case models.SchedulePending:
+	case models.ScheduleActive:
+		if err := s.restore(&sp, actor); err != nil {
+			return sp, err
+		}
+	default:
+		return sp, ErrScheduleFinished
+	}
+
+	sp.Status = models.ScheduleCanceled
+	return sp, s.schedules.Save(&sp)
+}
+
+// Run ends the scheduled prices that are over as of now, then starts the
+// ones that are due. It is a scheduler.JobFunc.
+func (s *Schedules) Run(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var scheduled []models.ScheduledPrice
+	filter := bson.M{"status": bson.M{"$in": bson.A{models.SchedulePending, models.ScheduleActive}}}
+	if err := s.schedules.Find(&scheduled, filter); err != nil && !store.IsNotFound(err) {
+		return err
+	}
+	sort.SliceStable(scheduled, func(i, j int) bool { return scheduled[i].StartsAt.Before(scheduled[j].StartsAt) })
+
+	var errs []error
+	for _, status := range []string{models.ScheduleActive, models.SchedulePending} {
+		for i := range scheduled {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			sp := &scheduled[i]
+			if sp.Status != status {
+				continue
+			}
+
+			var err error
+			over := sp.EndsAt != nil && !sp.EndsAt.After(now)
+			switch {
+			case status == models.ScheduleActive && over:
+				err = s.end(sp)
+			case status == models.SchedulePending && over:
+				// The whole window passed while nothing ran.
+				sp.Status = models.ScheduleEnded
+				err = s.schedules.Save(sp)
+			case status == models.SchedulePending && !sp.StartsAt.After(now):
+				err = s.start(sp)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("scheduled price %s: %w", sp.ID.Hex(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// start gives the product its scheduled price. The schedule is marked
+// first, so that a product saved without it is never given the price twice.
+// Only the price is written, and only while it is the one read; otherwise
+// the schedule goes back to pending for the next run.
+func (s *Schedules) start(sp *models.ScheduledPrice) error {
+	var product models.Product
+	if err := s.products.First(&product, bson.M{"_id": sp.ProductID}); err != nil {
+		if store.IsNotFound(err) {
+			log.Printf("pricing: product %s of scheduled price %s is gone", sp.ProductID.Hex(), sp.ID.Hex())
+			sp.Status = models.ScheduleCanceled
+			return s.schedules.Save(sp)
+		}
+		return err
+	}
+
+	previous := product.Price
+	sp.Previous = &previous
+	sp.Status = models.ScheduleActive
+	if sp.EndsAt == nil {
+		// A change for good has nothing left to do once made.
+		sp.Status = models.ScheduleEnded
+	}
+	if err := s.schedules.Save(sp); err != nil {
+		return err
+	}
+
+	if err := s.setPrice(sp, SchedulerActor, previous, sp.Price); err != nil {
+		sp.Previous, sp.Status = nil, models.SchedulePending
+		if err := s.schedules.Save(sp); err != nil {
+			log.Printf("pricing: resetting scheduled price %s: %v", sp.ID.Hex(), err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Schedules) end(sp *models.ScheduledPrice) error {
+	if err := s.restore(sp, SchedulerActor); err != nil {
+		return err
+	}
+	sp.Status = models.ScheduleEnded
+	return s.schedules.Save(sp)
+}
+
+// restore gives the product back the price it had before sp started,
+// unless its price was changed since.
+func (s *Schedules) restore(sp *models.ScheduledPrice, actor string) error {
+	if sp.Previous == nil {
+		return nil
+	}
+	err := s.setPrice(sp, actor, sp.Price, *sp.Previous)
+	if store.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// setPrice changes the price of sp's product from from to to, leaving the
+// rest of the product alone. It fails with a not found error when the
+// product is gone or its price is no longer from. The price is compared
+// whole, so that mongo compares it in its stored form rather than an
+// amount in minor units with one in major units.
+func (s *Schedules) setPrice(sp *models.ScheduledPrice, actor string, from, to money.Money) error {
+	from.Formatted, to.Formatted = "", ""
+	return store.Update(s.products.forSchedule(sp, actor), &models.Product{}, map[string]any{"price": to}, bson.M{
+		"_id":   sp.ProductID,
+		"price": from,
+	})
+}
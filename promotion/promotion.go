@@ -0,0 +1,232 @@
+// Package promotion keeps the promotions marketing runs on products and
+// works out the prices they give: percentages and fixed amounts off each
+// unit, and free units when buying several, on chosen products or
+// categories, for a while, and with or without a coupon code.
+//
+// Several promotions may target a product. Each has a priority and is
+// either exclusive, applying alone, or stackable, combining with the other
+// stackable ones; the Policy of the Engine decides between them, and every
+// quote says which promotions applied and why the others did not.
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Policy decides which of the promotions targeting a product apply.
+type Policy string
+
+const (
+	// PolicyPriority goes through the promotions by priority. The first
+	// decides: an exclusive one applies alone, and a stackable one combines
+	// with the stackable ones after it.
+	PolicyPriority Policy = "priority"
+	// PolicyBestPrice applies whichever takes off most of every exclusive
+	// promotion alone and all stackable ones together.
+	PolicyBestPrice Policy = "best_price"
+)
+
+// ParsePolicy returns the policy named s, PolicyPriority when s is empty.
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case "":
+		return PolicyPriority, nil
+	case PolicyPriority, PolicyBestPrice:
+		return Policy(s), nil
+	}
+	return "", fmt.Errorf("unknown promotion policy %q, use %q or %q", s, PolicyPriority, PolicyBestPrice)
+}
+
+// maxUnits bounds buy and get, at as many units of a product as a cart
+// holds.
+const maxUnits = 1000
+
+var (
+	ErrInvalidPromotion = errors.New("invalid promotion")
+	ErrUnknownCoupon    = errors.New("no promotion runs with this coupon code")
+)
+
+type Engine struct {
+	promotions store.Storer
+	categories store.Storer
+	policy     Policy
+}
+
+// New returns an engine keeping promotions in one store and reading the
+// category tree from another. An empty policy is PolicyPriority.
+func New(promotions, categories store.Storer, policy Policy) *Engine {
+	if policy == "" {
+		policy = PolicyPriority
+	}
+	return &Engine{promotions: promotions, categories: categories, policy: policy}
+}
+
+// NormalizeCode returns a coupon code the way promotions keep it.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// List returns every promotion, in the order they are considered: by
+// priority, then oldest first.
+func (e *Engine) List() ([]models.Promotion, error) {
+	promotions := []models.Promotion{}
+	if err := e.promotions.Find(&promotions, bson.M{}); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	sort.SliceStable(promotions, func(i, j int) bool {
+		a, b := promotions[i], promotions[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return promotions, nil
+}
+
+func (e *Engine) Get(id primitive.ObjectID) (models.Promotion, error) {
+	var p models.Promotion
+	err := e.promotions.First(&p, bson.M{"_id": id})
+	return p, err
+}
+
+// Add checks and stores a new promotion.
+func (e *Engine) Add(p *models.Promotion, now time.Time) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Code = NormalizeCode(p.Code)
+	if p.Stacking == "" {
+		p.Stacking = models.PromotionExclusive
+	}
+	if err := validate(p, now); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPromotion, err)
+	}
+
+	p.ID = primitive.NilObjectID
+	if p.Amount != nil {
+		p.Amount.Formatted = ""
+	}
+	p.CreatedAt = now
+	return e.promotions.Create(p)
+}
+
+func validate(p *models.Promotion, now time.Time) error {
+	switch p.Kind {
+	case models.PromotionPercent:
+		if p.Percent < 1 || p.Percent > 100 {
+			return errors.New("percent must be between 1 and 100")
+		}
+		if p.Amount != nil || p.Buy != 0 || p.Get != 0 {
+			return errors.New("a percent promotion only takes percent")
+		}
+	case models.PromotionFixed:
+		if p.Amount == nil || p.Amount.Amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+		c, err := money.Lookup(p.Amount.Currency)
+		if err != nil {
+			return fmt.Errorf("amount: %v", err)
+		}
+		p.Amount.Currency = c.Code
+		if p.Percent != 0 || p.Buy != 0 || p.Get != 0 {
+			return errors.New("a fixed promotion only takes amount")
+		}
+	case models.PromotionBuyXGetY:
+		if p.Buy < 1 || p.Get < 1 || p.Buy > maxUnits || p.Get > maxUnits {
+			return fmt.Errorf("buy and get must be from 1 to %d", maxUnits)
+		}
+		if p.Percent != 0 || p.Amount != nil {
+			return errors.New("a buy_x_get_y promotion only takes buy and get")
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", p.Kind)
+	}
+
+	switch {
+	case p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt):
+		return errors.New("ends_at must be after starts_at")
+	case p.EndsAt != nil && !p.EndsAt.After(now):
+		return errors.New("ends_at is in the past")
+	}
+	return nil
+}
+
+// Delete ends a promotion at once by removing it. Orders keep the
+// discounts it gave.
+func (e *Engine) Delete(id primitive.ObjectID) (models.Promotion, error) {
+	p, err := e.Get(id)
+	if err != nil {
+		return p, err
+	}
+	return p, store.Delete(e.promotions, &p)
+}
+
+// CheckCoupon returns code as promotions keep it, or ErrUnknownCoupon when
+// no promotion with it runs at now.
+func (e *Engine) CheckCoupon(code string, now time.Time) (string, error) {
+	code = NormalizeCode(code)
+	if e == nil || code == "" {
+		return code, ErrUnknownCoupon
+	}
+	promotions, err := e.List()
+	if err != nil {
+		return code, err
+	}
+	for _, p := range promotions {
+		if p.Code == code && running(p, now) {
+			return code, nil
+		}
+	}
+	return code, ErrUnknownCoupon
+}
+
+func running(p models.Promotion, now time.Time) bool {
+	return (p.StartsAt == nil || !p.StartsAt.After(now)) && (p.EndsAt == nil || p.EndsAt.After(now))
+}
+
+// Rules returns the promotions running at now for a buyer who gave the
+// coupon codes. A nil Engine has none.
+func (e *Engine) Rules(now time.Time, codes []string) (*Rules, error) {
+	if e == nil {
+		return &Rules{policy: PolicyPriority}, nil
+	}
+
+	given := map[string]bool{}
+	for _, code := range codes {
+		given[NormalizeCode(code)] = true
+	}
+	promotions, err := e.List()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Rules{policy: e.policy}
+	byCategory := false
+	for _, p := range promotions {
+		if !running(p, now) || (p.Code != "" && !given[p.Code]) {
+			continue
+		}
+		r.promotions = append(r.promotions, p)
+		byCategory = byCategory || len(p.CategoryIDs) > 0
+	}
+
+	if byCategory {
+		var categories []models.Category
+		if err := e.categories.Find(&categories, bson.M{}); err != nil && !store.IsNotFound(err) {
+			return nil, err
+		}
+		r.ancestors = make(map[primitive.ObjectID][]primitive.ObjectID, len(categories))
+		for _, c := range categories {
+			r.ancestors[c.ID] = c.Ancestors
+		}
+	}
+	return r, nil
+}
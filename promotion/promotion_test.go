@@ -0,0 +1,150 @@
+package promotion_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/promotion"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var now = time.Date(2026, 11, 27, 12, 0, 0, 0, time.UTC)
+
+func newEngine(t *testing.T, policy promotion.Policy, promotions ...models.Promotion) *promotion.Engine {
+	t.Helper()
+	e := promotion.New(store.NewMemoryStore(), store.NewMemoryStore(), policy)
+	for i := range promotions {
+		require.NoError(t, e.Add(&promotions[i], now.Add(-time.Hour)), promotions[i].Name)
+	}
+	return e
+}
+
+func quote(t *testing.T, e *promotion.Engine, product models.Product, quantity int64, codes ...string) models.PriceQuote {
+	t.Helper()
+	rules, err := e.Rules(now, codes)
+	require.NoError(t, err)
+	return rules.Quote(product, quantity)
+}
+
+func names(applied []models.AppliedPromotion) []string {
+	out := []string{}
+	for _, a := range applied {
+		out = append(out, a.Name)
+	}
+	return out
+}
+
+func TestAdd(t *testing.T) {
+	e := newEngine(t, "")
+	ended := now.Add(-time.Minute)
+	for _, p := range []models.Promotion{
+		{Name: "Too much", Kind: models.PromotionPercent, Percent: 120},
+		{Name: "Nothing off", Kind: models.PromotionFixed},
+		{Name: "Mixed", Kind: models.PromotionPercent, Percent: 10, Buy: 2, Get: 1},
+		{Name: "Free", Kind: models.PromotionBuyXGetY, Buy: 2},
+		{Name: "Endless", Kind: models.PromotionBuyXGetY, Buy: math.MaxInt64, Get: 1},
+		{Name: "No currency", Kind: models.PromotionFixed, Amount: &money.Money{Amount: 500}},
+		{Name: "Odd currency", Kind: models.PromotionFixed, Amount: &money.Money{Amount: 500, Currency: "XYZ"}},
+		{Name: "Over", Kind: models.PromotionPercent, Percent: 10, EndsAt: &ended},
+	} {
+		assert.ErrorIs(t, e.Add(&p, now), promotion.ErrInvalidPromotion, p.Name)
+	}
+
+	p := models.Promotion{Name: "Spring", Kind: models.PromotionPercent, Percent: 10, Code: " spring10 "}
+	require.NoError(t, e.Add(&p, now))
+	assert.Equal(t, "SPRING10", p.Code)
+	assert.Equal(t, models.PromotionExclusive, p.Stacking)
+
+	code, err := e.CheckCoupon("Spring10", now)
+	require.NoError(t, err)
+	assert.Equal(t, "SPRING10", code)
+	_, err = e.CheckCoupon("autumn", now)
+	assert.ErrorIs(t, err, promotion.ErrUnknownCoupon)
+
+	_, err = promotion.ParsePolicy("cheapest")
+	assert.Error(t, err)
+}
+
+func TestQuote(t *testing.T) {
+	shirts, summer := primitive.NewObjectID(), primitive.NewObjectID()
+	categories := store.NewMemoryStore()
+	require.NoError(t, categories.Create(&models.Category{ID: shirts, Name: "Shirts", Ancestors: []primitive.ObjectID{}}))
+	require.NoError(t, categories.Create(&models.Category{ID: summer, Name: "Summer shirts", ParentID: &shirts, Ancestors: []primitive.ObjectID{shirts}}))
+
+	shirt := models.Product{ID: primitive.NewObjectID(), Name: "Linen shirt", Price: money.New(4000, "EUR"), CategoryIDs: []primitive.ObjectID{summer}}
+	mug := models.Product{ID: primitive.NewObjectID(), Name: "Mug", Price: money.New(1000, "EUR")}
+	later := now.Add(time.Hour)
+
+	promotions := []models.Promotion{
+		{Name: "Shirts week", Kind: models.PromotionPercent, Percent: 25, CategoryIDs: []primitive.ObjectID{shirts}, Priority: 10},
+		{Name: "Five off", Kind: models.PromotionFixed, Amount: &money.Money{Amount: 500, Currency: "EUR"}, Stacking: models.PromotionStackable, Priority: 5},
+		{Name: "Dollars off", Kind: models.PromotionFixed, Amount: &money.Money{Amount: 500, Currency: "USD"}, Stacking: models.PromotionStackable},
+		{Name: "Two for one", Kind: models.PromotionBuyXGetY, Buy: 1, Get: 1, ProductIDs: []primitive.ObjectID{mug.ID}, Stacking: models.PromotionStackable},
+		{Name: "VIP", Kind: models.PromotionPercent, Percent: 50, Code: "VIP", Priority: 20},
+		{Name: "Tomorrow", Kind: models.PromotionPercent, Percent: 90, StartsAt: &later},
+	}
+
+	t.Run("priority", func(t *testing.T) {
+		e := promotion.New(store.NewMemoryStore(), categories, promotion.PolicyPriority)
+		for i := range promotions {
+			p := promotions[i]
+			require.NoError(t, e.Add(&p, now.Add(-time.Hour)))
+		}
+
+		q := quote(t, e, shirt, 1)
+		assert.Equal(t, "priority", q.Policy)
+		assert.Equal(t, []string{"Shirts week"}, names(q.Applied), "the subcategory is in the promotion's category")
+		assert.Equal(t, money.New(3000, "EUR"), q.Total)
+		reasons := map[string]string{}
+		for _, s := range q.Skipped {
+			reasons[s.Name] = s.Reason
+		}
+		assert.Equal(t, map[string]string{
+			"Five off":    "Shirts week applies alone",
+			"Dollars off": "only for prices in USD",
+		}, reasons)
+
+		q = quote(t, e, mug, 1)
+		assert.Equal(t, []string{"Five off"}, names(q.Applied))
+		assert.Equal(t, "buy 1 get 1 free needs 2 units", q.Skipped[1].Reason)
+
+		q = quote(t, e, mug, 3)
+		assert.Equal(t, []string{"Five off", "Two for one"}, names(q.Applied), "stackable promotions combine")
+		// Five off each of three mugs, then one of them free at 5.00.
+		assert.Equal(t, money.New(1500+500, "EUR"), q.Discount)
+		assert.Equal(t, money.New(1000, "EUR"), q.Total)
+
+		q = quote(t, e, mug, 3, "vip")
+		assert.Equal(t, []string{"VIP"}, names(q.Applied), "coupons unlock their promotions")
+		assert.Equal(t, money.New(1500, "EUR"), q.Total)
+	})
+
+	t.Run("best price", func(t *testing.T) {
+		e := promotion.New(store.NewMemoryStore(), categories, promotion.PolicyBestPrice)
+		for i := range promotions {
+			p := promotions[i]
+			require.NoError(t, e.Add(&p, now.Add(-time.Hour)))
+		}
+
+		q := quote(t, e, mug, 4, "VIP")
+		assert.Equal(t, []string{"Five off", "Two for one"}, names(q.Applied))
+		assert.Equal(t, money.New(2000+1000, "EUR"), q.Discount, "stacking takes off more than half")
+		assert.Equal(t, "the stackable promotions together take off more", q.Skipped[len(q.Skipped)-1].Reason)
+
+		q = quote(t, e, shirt, 2, "VIP")
+		assert.Equal(t, []string{"VIP"}, names(q.Applied))
+		assert.Equal(t, money.New(4000, "EUR"), q.Total)
+	})
+
+	t.Run("without an engine", func(t *testing.T) {
+		q := quote(t, nil, mug, 2)
+		assert.Empty(t, q.Applied)
+		assert.Equal(t, money.New(2000, "EUR"), q.Total)
+	})
+}
@@ -0,0 +1,222 @@
+package promotion
+
+import (
+	"fmt"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Rules are the promotions running at a time, in the order they are
+// considered.
+type Rules struct {
+	policy     Policy
+	promotions []models.Promotion
+	// ancestors maps each category to those above it, when a promotion
+	// targets categories.
+	ancestors map[primitive.ObjectID][]primitive.ObjectID
+}
+
+// Quote prices quantity units of product. Percentages and fixed amounts are
+// taken off the unit price in the order the promotions apply, then free
+// units are given at what is left of it.
+func (r *Rules) Quote(product models.Product, quantity int64) models.PriceQuote {
+	price := product.Price
+	price.Formatted = ""
+	quote := models.PriceQuote{
+		Policy:   string(r.policy),
+		Quantity: quantity,
+		Subtotal: money.New(price.Amount*quantity, price.Currency),
+		Applied:  []models.AppliedPromotion{},
+	}
+
+	var eligible []models.Promotion
+	for _, p := range r.promotions {
+		if !r.targets(p, product) {
+			continue
+		}
+		if reason := ineligible(p, price, quantity); reason != "" {
+			quote.Skipped = append(quote.Skipped, skipped(p, reason))
+			continue
+		}
+		eligible = append(eligible, p)
+	}
+
+	var chosen []models.Promotion
+	var passed []models.SkippedPromotion
+	if r.policy == PolicyBestPrice {
+		chosen, passed = bestPrice(eligible, price, quantity)
+	} else {
+		chosen, passed = byPriority(eligible)
+	}
+	quote.Skipped = append(quote.Skipped, passed...)
+
+	var discount int64
+	quote.Applied = apply(chosen, price, quantity)
+	for _, a := range quote.Applied {
+		discount += a.Discount.Amount
+	}
+	quote.Discount = money.New(discount, price.Currency)
+	quote.Total = money.New(quote.Subtotal.Amount-discount, price.Currency)
+	return quote
+}
+
+// targets reports whether p is for product, directly or through one of its
+// categories or a category above them.
+func (r *Rules) targets(p models.Promotion, product models.Product) bool {
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if id == product.ID {
+			return true
+		}
+	}
+	for _, category := range product.CategoryIDs {
+		for _, id := range p.CategoryIDs {
+			if id == category || contains(r.ancestors[category], id) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ineligible returns why p cannot apply to quantity units at price, or ""
+// when it can.
+func ineligible(p models.Promotion, price money.Money, quantity int64) string {
+	switch {
+	case p.Kind == models.PromotionFixed && p.Amount.Currency != price.Currency:
+		return fmt.Sprintf("only for prices in %s", p.Amount.Currency)
+	case p.Kind == models.PromotionBuyXGetY && quantity < p.Buy+p.Get:
+		return fmt.Sprintf("buy %d get %d free needs %d units", p.Buy, p.Get, p.Buy+p.Get)
+	}
+	return ""
+}
+
+func exclusive(p models.Promotion) bool {
+	return p.Stacking != models.PromotionStackable
+}
+
+func skipped(p models.Promotion, reason string) models.SkippedPromotion {
+	return models.SkippedPromotion{PromotionID: p.ID, Name: p.Name, Reason: reason}
+}
+
+// byPriority chooses the promotions of PolicyPriority out of eligible, which
+// are in priority order.
+func byPriority(eligible []models.Promotion) (chosen []models.Promotion, passed []models.SkippedPromotion) {
+	for _, p := range eligible {
+		switch {
+		case len(chosen) > 0 && exclusive(chosen[0]):
+			passed = append(passed, skipped(p, fmt.Sprintf("%s applies alone", chosen[0].Name)))
+		case len(chosen) > 0 && exclusive(p):
+			passed = append(passed, skipped(p, fmt.Sprintf("exclusive, and %s comes first", chosen[0].Name)))
+		default:
+			chosen = append(chosen, p)
+		}
+	}
+	return chosen, passed
+}
+
+// bestPrice chooses the promotions of PolicyBestPrice out of eligible. Of
+// options taking off as much, the one with the first promotion wins.
+func bestPrice(eligible []models.Promotion, price money.Money, quantity int64) (chosen []models.Promotion, passed []models.SkippedPromotion) {
+	var options [][]models.Promotion
+	stacked := -1
+	for _, p := range eligible {
+		switch {
+		case exclusive(p):
+			options = append(options, []models.Promotion{p})
+		case stacked < 0:
+			stacked = len(options)
+			options = append(options, []models.Promotion{p})
+		default:
+			options[stacked] = append(options[stacked], p)
+		}
+	}
+
+	best, most := -1, int64(-1)
+	for i, option := range options {
+		var discount int64
+		for _, a := range apply(option, price, quantity) {
+			discount += a.Discount.Amount
+		}
+		if discount > most {
+			best, most = i, discount
+		}
+	}
+	if best < 0 {
+		return nil, nil
+	}
+
+	reason := fmt.Sprintf("%s takes off more", options[best][0].Name)
+	if best == stacked && len(options[best]) > 1 {
+		reason = "the stackable promotions together take off more"
+	}
+	for i, option := range options {
+		if i == best {
+			continue
+		}
+		for _, p := range option {
+			passed = append(passed, skipped(p, reason))
+		}
+	}
+	return options[best], passed
+}
+
+// apply works out what each of chosen takes off quantity units at price.
+func apply(chosen []models.Promotion, price money.Money, quantity int64) []models.AppliedPromotion {
+	applied := make([]models.AppliedPromotion, 0, len(chosen))
+	add := func(p models.Promotion, discount int64) {
+		applied = append(applied, models.AppliedPromotion{
+			PromotionID: p.ID,
+			Name:        p.Name,
+			Kind:        p.Kind,
+			Code:        p.Code,
+			Discount:    money.New(discount, price.Currency),
+		})
+	}
+
+	unit := price.Amount
+	for _, p := range chosen {
+		var off int64
+		switch p.Kind {
+		case models.PromotionPercent:
+			// Rounded half up, in minor units.
+			off = (unit*p.Percent + 50) / 100
+		case models.PromotionFixed:
+			off = p.Amount.Amount
+		default:
+			continue
+		}
+		if off > unit {
+			off = unit
+		}
+		unit -= off
+		add(p, off*quantity)
+	}
+
+	paid := quantity
+	for _, p := range chosen {
+		if p.Kind != models.PromotionBuyXGetY {
+			continue
+		}
+		free := quantity / (p.Buy + p.Get) * p.Get
+		if free > paid {
+			free = paid
+		}
+		paid -= free
+		add(p, free*unit)
+	}
+	return applied
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/controllers"
+	"github.com/sing3demons/go-example/store"
+)
+
+// AdminRouter registers the fault injection endpoints. They are only wired up
+// in non-production builds and require the X-Admin-Token header to match
+// ADMIN_TOKEN.
+func AdminRouter(r *gin.Engine, faults map[string]*store.FaultStore) {
+	faultController := controllers.NewFaultController(faults)
+
+	admin := r.Group("/admin", adminOnly(os.Getenv("ADMIN_TOKEN")))
+	admin.GET("/faults", faultController.Index)
+	admin.PUT("/faults/:store/:method", faultController.Set)
+	admin.DELETE("/faults", faultController.Clear)
+	admin.DELETE("/faults/:store/:method", faultController.Clear)
+}
+
+func adminOnly(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !controllers.IsAdmin(c, token) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"message": "Forbidden",
+			})
+			return
+		}
+		c.Next()
+	}
+}
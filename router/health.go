@@ -0,0 +1,21 @@
+package router
+
+import (
+	"expvar"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/controllers"
+	"github.com/sing3demons/go-example/store"
+)
+
+// HealthRouter registers the probes, and the expvar counters, which require
+// the X-Admin-Token header since they include the command line and memory
+// statistics.
+func HealthRouter(r *gin.Engine, breakers map[string]*store.Breaker) {
+	healthController := controllers.NewHealthController(breakers)
+
+	r.GET("/healthz", healthController.Live)
+	r.GET("/readyz", healthController.Ready)
+	r.GET("/debug/vars", adminOnly(os.Getenv("ADMIN_TOKEN")), gin.WrapH(expvar.Handler()))
+}
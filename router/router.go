@@ -1,23 +1,156 @@
 package router
 
 import (
+	"os"
+
 	"github.com/gin-gonic/gin"
+	"github.com/sing3demons/go-example/activity"
+	"github.com/sing3demons/go-example/blob"
 	"github.com/sing3demons/go-example/controllers"
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/pricing"
+	"github.com/sing3demons/go-example/promotion"
+	"github.com/sing3demons/go-example/scheduler"
+	"github.com/sing3demons/go-example/search"
+	"github.com/sing3demons/go-example/shop"
 	"github.com/sing3demons/go-example/store"
 )
 
-func Router(r *gin.Engine, db store.Storer) {
-	todoController := controllers.NewTodoController(db)
+// Router sets up the todo routes, and adds the job creating the next
+// occurrences of recurring todos to jobs when it is not nil.
+func Router(r *gin.Engine, db store.Storer, jobs *scheduler.Scheduler) {
+	// Writes to todos, comments and assignees feed each todo's activity.
+	db = activity.NewStore(db)
+
+	// Completing a todo and the job share one Recurrence.
+	recurrence := scheduler.NewRecurrence(db)
+	if jobs != nil {
+		jobs.Add("recurrence", recurrence.Run)
+	}
+	todoController := controllers.NewTodoController(db, recurrence)
 
 	r.GET("/todos", todoController.Index)
 	r.POST("/todos", todoController.Create)
+	r.POST("/todos/:id/complete", todoController.Complete)
+	r.POST("/todos/:id/move", todoController.Move)
+	r.PUT("/todos/:id/labels", todoController.SetLabels)
+	r.PUT("/todos/:id/assignees", todoController.SetAssignees)
+	r.GET("/todos/:id/activity", todoController.Activity)
+	r.POST("/todos/bulk", todoController.Bulk)
+	r.GET("/todos/export", todoController.Export)
+	r.POST("/todos/import", todoController.Import)
+
+	commentController := controllers.NewCommentController(db)
+	r.GET("/todos/:id/comments", commentController.Index)
+	r.POST("/todos/:id/comments", commentController.Create)
+	r.PUT("/todos/:id/comments/:comment_id", commentController.Update)
+	r.DELETE("/todos/:id/comments/:comment_id", commentController.Delete)
+
+	listController := controllers.NewTodoListController(db)
+	r.GET("/lists", listController.Index)
+	r.POST("/lists", listController.Create)
+	r.GET("/lists/:id", listController.FindOne)
+	r.PUT("/lists/:id", listController.Update)
+	r.DELETE("/lists/:id", listController.Delete)
+	r.GET("/lists/:id/todos", listController.Todos)
+
+	labelController := controllers.NewLabelController(db)
+	r.GET("/labels", labelController.Index)
+	r.POST("/labels", labelController.Create)
+	r.PUT("/labels/:id", labelController.Update)
+	r.DELETE("/labels/:id", labelController.Delete)
+
+	calendarController := controllers.NewCalendarController(db, os.Getenv("CALENDAR_SECRET"))
+	r.GET("/todos/calendar", calendarController.Link)
+	r.GET("/todos/calendar.ics", calendarController.Feed)
+	r.POST("/todos/calendar.ics", calendarController.Import)
 
 }
 
-func ProductRouter(r *gin.Engine, db store.Storer) {
-	productController := controllers.NewProductController(db)
+func ProductRouter(r *gin.Engine, db, categories store.Storer, blobs blob.Store, promotions *promotion.Engine) {
+	productController := controllers.NewProductController(db, categories, blobs, promotions)
 
 	r.GET("/products", productController.Find)
 	r.GET("/products/:id", productController.FindOne)
 	r.POST("/products", productController.Create)
+	r.DELETE("/products/:id", productController.Delete)
+	r.PUT("/products/:id/categories", productController.SetCategories)
+	r.POST("/products/:id/images", productController.UploadImage)
+	r.DELETE("/products/:id/images/:image_id", productController.DeleteImage)
+	r.GET("/images/*key", productController.ServeImage)
+	r.POST("/products/bulk", productController.Bulk)
+	r.GET("/products/export", productController.Export)
+	r.POST("/products/import", productController.Import)
+}
+
+func CategoryRouter(r *gin.Engine, db, products store.Storer) {
+	categoryController := controllers.NewCategoryController(db, products)
+
+	r.GET("/categories", categoryController.Index)
+	r.POST("/categories", categoryController.Create)
+	r.GET("/categories/:id", categoryController.FindOne)
+	r.PUT("/categories/:id", categoryController.Update)
+	r.DELETE("/categories/:id", categoryController.Delete)
+}
+
+// InventoryRouter registers the stock endpoints. Changing stock requires the
+// X-Admin-Token header, since checkout and orders rely on what is reserved.
+func InventoryRouter(r *gin.Engine, products store.Storer, stock *inventory.Service) {
+	inventoryController := controllers.NewInventoryController(products, stock)
+	admin := adminOnly(os.Getenv("ADMIN_TOKEN"))
+
+	r.GET("/products/:id/stock", inventoryController.Levels)
+	r.POST("/products/:id/stock/adjust", admin, inventoryController.Adjust)
+	r.POST("/products/:id/stock/reserve", admin, inventoryController.Reserve)
+	r.POST("/products/:id/stock/release", admin, inventoryController.Release)
+	r.POST("/products/:id/stock/commit", admin, inventoryController.Commit)
+	r.PUT("/products/:id/stock/threshold", admin, inventoryController.SetThreshold)
+	r.GET("/products/:id/stock/history", inventoryController.History)
+	r.GET("/stock/low", inventoryController.LowStock)
+}
+
+func PriceRouter(r *gin.Engine, prices *pricing.Store, schedules *pricing.Schedules) {
+	priceController := controllers.NewPriceController(prices, schedules)
+
+	r.GET("/products/:id/prices", priceController.History)
+	r.PUT("/products/:id/price", priceController.Set)
+	r.GET("/products/:id/prices/scheduled", priceController.Scheduled)
+	r.POST("/products/:id/prices/scheduled", priceController.Schedule)
+	r.DELETE("/products/:id/prices/scheduled/:schedule_id", priceController.Cancel)
+}
+
+func ShopRouter(r *gin.Engine, shop *shop.Service) {
+	cartController := controllers.NewCartController(shop)
+	orderController := controllers.NewOrderController(shop, os.Getenv("ADMIN_TOKEN"))
+
+	r.GET("/cart", cartController.Show)
+	r.POST("/cart/items", cartController.AddItem)
+	r.PUT("/cart/items/:product_id", cartController.SetQuantity)
+	r.DELETE("/cart/items/:product_id", cartController.RemoveItem)
+	r.POST("/cart/coupons", cartController.AddCoupon)
+	r.DELETE("/cart/coupons/:code", cartController.RemoveCoupon)
+	r.POST("/cart/checkout", cartController.Checkout)
+	r.GET("/orders", orderController.Index)
+	r.GET("/orders/:id", orderController.FindOne)
+	r.PUT("/orders/:id/status", orderController.SetStatus)
+}
+
+// PromotionRouter registers the promotion endpoints. Creating and deleting
+// promotions, and seeing their coupon codes, require the X-Admin-Token
+// header.
+func PromotionRouter(r *gin.Engine, promotions *promotion.Engine, products, categories store.Storer) {
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	promotionController := controllers.NewPromotionController(promotions, products, categories, adminToken)
+	admin := adminOnly(adminToken)
+
+	r.GET("/promotions", promotionController.Index)
+	r.POST("/promotions", admin, promotionController.Create)
+	r.GET("/promotions/:id", promotionController.FindOne)
+	r.DELETE("/promotions/:id", admin, promotionController.Delete)
+}
+
+func SearchRouter(r *gin.Engine, backends ...search.Backend) {
+	searchController := controllers.NewSearchController(backends...)
+
+	r.GET("/search", searchController.Search)
 }
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Leader decides which replica runs the jobs.
+type Leader interface {
+	// Acquire reports whether this replica leads, trying to become the
+	// leader if it does not. It is called before every tick.
+	Acquire(ctx context.Context) (bool, error)
+	// Release gives up leadership.
+	Release(ctx context.Context) error
+}
+
+// LocalLeader always leads. It is for single-replica deployments and the
+// memory store.
+type LocalLeader struct{}
+
+func (LocalLeader) Acquire(context.Context) (bool, error) { return true, nil }
+func (LocalLeader) Release(context.Context) error         { return nil }
+
+// AdvisoryLockLeader elects a leader with a postgres session advisory lock.
+// The lock belongs to a connection held for as long as this replica leads;
+// if the connection dies postgres releases the lock and another replica
+// takes over on its next tick.
+type AdvisoryLockLeader struct {
+	db  *sql.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewAdvisoryLockLeader returns a leader that competes for the advisory lock
+// key. Replicas that should share the work must use the same key.
+func NewAdvisoryLockLeader(db *sql.DB, key int64) *AdvisoryLockLeader {
+	return &AdvisoryLockLeader{db: db, key: key}
+}
+
+func (l *AdvisoryLockLeader) Acquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		// The session and with it the lock are gone.
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&locked); err != nil {
+		conn.Close()
+		return false, err
+	}
+	if !locked {
+		conn.Close()
+		return false, nil
+	}
+	l.conn = conn
+	return true, nil
+}
+
+func (l *AdvisoryLockLeader) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	l.conn.Close()
+	l.conn = nil
+	return err
+}
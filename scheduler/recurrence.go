@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sing3demons/go-example/ical"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+// Recurrence creates the next occurrence of recurring todos. A todo's next
+// occurrence is created once it is completed or its due date has passed,
+// and falls on the first date of the rule after both the due date and now,
+// so a series that was neglected does not pile up overdue todos.
+//
+// A todo is claimed by setting NextMaterialized while it is still unset
+// before its next occurrence is created, so of the requests completing it
+// and the scheduler's ticks, in any replica, only one creates it.
+type Recurrence struct {
+	db store.Storer
+}
+
+func NewRecurrence(db store.Storer) *Recurrence {
+	return &Recurrence{db: db}
+}
+
+// Run materializes every todo that is completed or due as of now. It is a
+// JobFunc.
+func (r *Recurrence) Run(ctx context.Context, now time.Time) error {
+	var todos []models.Todo
+	collect := func(conds ...any) error {
+		var todo models.Todo
+		return store.Each(r.db, &todo, func() error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			todos = append(todos, todo)
+			return nil
+		}, conds...)
+	}
+	// Candidates are collected first: writing while iterating would need a
+	// second connection, which SQLite does not have.
+	if err := collect("rrule <> ? AND next_materialized = ? AND completed = ?", "", false, true); err != nil {
+		return err
+	}
+	if err := collect("rrule <> ? AND next_materialized = ? AND completed = ? AND due_at <= ?", "", false, false, now); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range todos {
+		if _, err := r.Materialize(&todos[i], now); err != nil {
+			errs = append(errs, fmt.Errorf("todo %d: %w", todos[i].ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Materialize creates the occurrence after todo and marks todo as done with.
+// It returns the new todo, or nil when todo does not recur or its series has
+// ended.
+func (r *Recurrence) Materialize(todo *models.Todo, now time.Time) (*models.Todo, error) {
+	if todo.RRule == "" || todo.DueAt == nil || todo.NextMaterialized {
+		return nil, nil
+	}
+	rule, err := ical.ParseRecur(todo.RRule)
+	if err != nil {
+		return nil, err
+	}
+
+	series := todo.ID
+	if todo.SeriesID != nil {
+		series = *todo.SeriesID
+	}
+	occurrence := todo.Occurrence
+	if occurrence < 1 {
+		occurrence = 1
+	}
+
+	claim := map[string]any{"series_id": series, "occurrence": occurrence, "next_materialized": true}
+	err = store.Update(r.db, &models.Todo{}, claim, "id = ? AND next_materialized = ?", todo.ID, false)
+	if store.IsNotFound(err) {
+		// Someone else has claimed it, or it is gone.
+		todo.NextMaterialized = true
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	todo.SeriesID = &series
+	todo.Occurrence = occurrence
+	todo.NextMaterialized = true
+
+	// The rule is anchored at this occurrence, so COUNT shrinks by the
+	// occurrences already behind it. A COUNT lowered to this occurrence or
+	// below, by an edit or an import, has ended the series; shrunk to
+	// nothing it would not limit it at all.
+	if rule.Count > 0 {
+		if occurrence >= rule.Count {
+			return nil, nil
+		}
+		rule.Count -= occurrence - 1
+	}
+	after := *todo.DueAt
+	if now.After(after) {
+		after = now
+	}
+
+	var next *models.Todo
+	if due, n, ok := rule.Next(*todo.DueAt, after); ok {
+		due = due.UTC()
+		next = &models.Todo{
+			Title:           todo.Title,
+			Owner:           todo.Owner,
+			DueAt:           &due,
+			ReminderMinutes: todo.ReminderMinutes,
+			RRule:           todo.RRule,
+			SeriesID:        &series,
+			Occurrence:      occurrence + n - 1,
+			ListID:          todo.ListID,
+			Priority:        todo.Priority,
+			Position:        todo.Position,
+		}
+		// The unique series index keeps out an occurrence created twice
+		// all the same, by a todo imported twice for instance.
+		if err := r.db.Create(next); store.IsDuplicateKey(err) {
+			next = nil
+		} else if err != nil {
+			// Released, so that the next tick tries again.
+			release := map[string]any{"next_materialized": false}
+			if err := store.Update(r.db, &models.Todo{}, release, "id = ?", todo.ID); err != nil {
+				log.Printf("recurrence: releasing todo %d: %v", todo.ID, err)
+			}
+			todo.NextMaterialized = false
+			return nil, err
+		} else if err := r.copyLabels(todo.ID, next.ID); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// copyLabels gives the todo with id to the labels of the todo with id from.
+func (r *Recurrence) copyLabels(from, to uint) error {
+	var links []models.TodoLabel
+	if err := r.db.Find(&links, "todo_id = ?", from); err != nil && !store.IsNotFound(err) {
+		return err
+	}
+	for _, link := range links {
+		if err := r.db.Create(&models.TodoLabel{TodoID: to, LabelID: link.LabelID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package scheduler_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/scheduler"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(day, hour int) time.Time {
+	return time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC)
+}
+
+func todos(t *testing.T, db store.Storer) []models.Todo {
+	var all []models.Todo
+	require.NoError(t, db.Find(&all))
+	return all
+}
+
+func TestRecurrenceOnCompletion(t *testing.T) {
+	db := store.NewMemoryStore()
+	due := date(1, 9)
+	chore := models.Todo{Title: "Take out the bins", Owner: "alice", DueAt: &due, ReminderMinutes: 10, RRule: "FREQ=WEEKLY;COUNT=2", Priority: models.PriorityHigh}
+	require.NoError(t, db.Create(&chore))
+	require.NoError(t, db.Create(&models.TodoLabel{TodoID: chore.ID, LabelID: 7}))
+	r := scheduler.NewRecurrence(db)
+
+	chore.Completed = true
+	require.NoError(t, db.Save(&chore))
+	next, err := r.Materialize(&chore, date(1, 8))
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	assert.Equal(t, date(8, 9), *next.DueAt)
+	assert.Equal(t, "alice", next.Owner)
+	assert.Equal(t, 10, next.ReminderMinutes)
+	assert.Equal(t, models.PriorityHigh, next.Priority)
+	var links []models.TodoLabel
+	require.NoError(t, db.Find(&links, "todo_id = ?", next.ID))
+	assert.Len(t, links, 1, "labels carry over")
+	assert.Equal(t, chore.ID, *next.SeriesID)
+	assert.Equal(t, 2, next.Occurrence)
+	assert.True(t, chore.NextMaterialized)
+
+	again, err := r.Materialize(&chore, date(1, 8))
+	require.NoError(t, err)
+	assert.Nil(t, again, "a todo is materialized once")
+
+	last, err := r.Materialize(next, date(9, 9))
+	require.NoError(t, err)
+	assert.Nil(t, last, "COUNT=2 ends the series")
+	assert.Len(t, todos(t, db), 2)
+}
+
+func TestRecurrenceCountLoweredBelowOccurrence(t *testing.T) {
+	db := store.NewMemoryStore()
+	due := date(1, 9)
+	series := uint(1)
+	chore := models.Todo{Title: "Water the plants", Owner: "alice", DueAt: &due, RRule: "FREQ=DAILY;COUNT=3", SeriesID: &series, Occurrence: 5}
+	require.NoError(t, db.Create(&chore))
+
+	next, err := scheduler.NewRecurrence(db).Materialize(&chore, date(1, 8))
+	require.NoError(t, err)
+	assert.Nil(t, next, "the series ended before this occurrence")
+	assert.True(t, chore.NextMaterialized)
+	assert.Len(t, todos(t, db), 1)
+}
+
+func TestRecurrenceJob(t *testing.T) {
+	db := store.NewMemoryStore()
+	due := date(1, 9)
+	for _, todo := range []models.Todo{
+		{Title: "Daily standup", DueAt: &due, RRule: "FREQ=DAILY"},
+		{Title: "One-off", DueAt: &due},
+		{Title: "Later", DueAt: ptr(date(20, 9)), RRule: "FREQ=DAILY"},
+	} {
+		todo := todo
+		require.NoError(t, db.Create(&todo))
+	}
+
+	clock := scheduler.NewTestClock(date(4, 12))
+	s := scheduler.New(scheduler.Options{Interval: time.Minute, Clock: clock, Leader: scheduler.LocalLeader{}})
+	s.Add("recurrence", scheduler.NewRecurrence(db).Run)
+
+	require.NoError(t, s.Tick(context.Background()))
+	all := todos(t, db)
+	require.Len(t, all, 4)
+	assert.Equal(t, "Daily standup", all[3].Title)
+	assert.Equal(t, date(5, 9), *all[3].DueAt, "missed occurrences are skipped")
+	assert.Equal(t, 5, all[3].Occurrence)
+
+	require.NoError(t, s.Tick(context.Background()))
+	assert.Len(t, todos(t, db), 4, "nothing is due until the 5th")
+
+	clock.Advance(24 * time.Hour)
+	require.NoError(t, s.Tick(context.Background()))
+	all = todos(t, db)
+	require.Len(t, all, 5)
+	assert.Equal(t, date(6, 9), *all[4].DueAt)
+	assert.Equal(t, all[0].ID, *all[4].SeriesID)
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestRecurrenceClaimsTodo(t *testing.T) {
+	db := store.NewMemoryStore()
+	due := date(1, 9)
+	chore := models.Todo{Title: "Water plants", DueAt: &due, RRule: "FREQ=DAILY", Completed: true}
+	require.NoError(t, db.Create(&chore))
+
+	// Copies read before either materialized, as by a request completing
+	// the todo and a scheduler tick in another replica.
+	var wg sync.WaitGroup
+	created := make([]*models.Todo, 10)
+	for i := range created {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			todo := chore
+			next, err := scheduler.NewRecurrence(db).Materialize(&todo, date(1, 10))
+			assert.NoError(t, err)
+			created[i] = next
+		}(i)
+	}
+	wg.Wait()
+
+	n := 0
+	for _, next := range created {
+		if next != nil {
+			n++
+		}
+	}
+	assert.Equal(t, 1, n)
+	assert.Len(t, todos(t, db), 2)
+}
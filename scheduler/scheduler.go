@@ -0,0 +1,102 @@
+// Package scheduler runs background jobs on one replica at a time.
+package scheduler
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+var metrics = expvar.NewMap("scheduler")
+
+// JobFunc does one round of a job's work as of now.
+type JobFunc func(ctx context.Context, now time.Time) error
+
+type job struct {
+	name string
+	run  JobFunc
+}
+
+type Options struct {
+	// Interval is the time between ticks.
+	Interval time.Duration
+	Clock    Clock
+	Leader   Leader
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Interval: time.Minute,
+		Clock:    RealClock{},
+		Leader:   LocalLeader{},
+	}
+}
+
+// Scheduler runs its jobs every Interval while this replica is the leader.
+type Scheduler struct {
+	opts    Options
+	jobs    []job
+	leading atomic.Bool
+}
+
+func New(opts Options) *Scheduler {
+	s := &Scheduler{opts: opts}
+	metrics.Set("leader", expvar.Func(func() any { return s.leading.Load() }))
+	return s
+}
+
+// Leading reports whether this replica led at the last tick.
+func (s *Scheduler) Leading() bool {
+	return s.leading.Load()
+}
+
+// Add registers a job. Jobs run in the order they were added.
+func (s *Scheduler) Add(name string, run JobFunc) {
+	s.jobs = append(s.jobs, job{name: name, run: run})
+}
+
+// Run ticks until ctx is done, then gives up leadership.
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.opts.Leader.Release(context.Background())
+
+	for {
+		if err := s.Tick(ctx); err != nil {
+			log.Printf("scheduler: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.opts.Clock.After(s.opts.Interval):
+		}
+	}
+}
+
+// Tick runs every job once if this replica leads. A failing job does not
+// stop the others; the first error is returned.
+func (s *Scheduler) Tick(ctx context.Context) error {
+	leader, err := s.opts.Leader.Acquire(ctx)
+	s.leading.Store(leader)
+	if err != nil {
+		return fmt.Errorf("leader election: %w", err)
+	}
+	if !leader {
+		return nil
+	}
+
+	now := s.opts.Clock.Now()
+	var first error
+	for _, j := range s.jobs {
+		metrics.Add(j.name+".runs", 1)
+		if err := j.run(ctx, now); err != nil {
+			metrics.Add(j.name+".failures", 1)
+			if first == nil {
+				first = fmt.Errorf("%s: %w", j.name, err)
+			}
+		}
+	}
+	return first
+}
@@ -0,0 +1,113 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/db"
+	"github.com/sing3demons/go-example/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLeader struct {
+	leads bool
+	err   error
+}
+
+func (l *fakeLeader) Acquire(context.Context) (bool, error) { return l.leads, l.err }
+func (l *fakeLeader) Release(context.Context) error         { return nil }
+
+func TestTick(t *testing.T) {
+	clock := scheduler.NewTestClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	leader := &fakeLeader{leads: true}
+	s := scheduler.New(scheduler.Options{Interval: time.Minute, Clock: clock, Leader: leader})
+
+	var ran []time.Time
+	s.Add("record", func(_ context.Context, now time.Time) error {
+		ran = append(ran, now)
+		return nil
+	})
+	s.Add("broken", func(context.Context, time.Time) error { return errors.New("boom") })
+
+	assert.EqualError(t, s.Tick(context.Background()), "broken: boom")
+	assert.Equal(t, []time.Time{clock.Now()}, ran)
+	assert.True(t, s.Leading())
+
+	leader.leads = false
+	assert.NoError(t, s.Tick(context.Background()))
+	assert.Len(t, ran, 1)
+	assert.False(t, s.Leading())
+
+	leader.err = errors.New("no database")
+	assert.ErrorContains(t, s.Tick(context.Background()), "leader election")
+	assert.Len(t, ran, 1)
+}
+
+func TestRunWithTestClock(t *testing.T) {
+	clock := scheduler.NewTestClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	s := scheduler.New(scheduler.Options{Interval: time.Minute, Clock: clock, Leader: scheduler.LocalLeader{}})
+
+	var ticks atomic.Int32
+	s.Add("count", func(context.Context, time.Time) error {
+		ticks.Add(1)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	waitFor := func(n int32) {
+		require.Eventually(t, func() bool { return ticks.Load() == n && clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	}
+	waitFor(1)
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, int32(1), ticks.Load())
+	clock.Advance(30 * time.Second)
+	waitFor(2)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestAdvisoryLockLeader(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if !strings.HasPrefix(dsn, "postgres") {
+		t.Skip("TEST_DATABASE_URL is not a postgres database")
+	}
+	gdb, err := db.Open(dsn)
+	require.NoError(t, err)
+	sqlDB, err := gdb.DB()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	first := scheduler.NewAdvisoryLockLeader(sqlDB, 4242)
+	second := scheduler.NewAdvisoryLockLeader(sqlDB, 4242)
+	t.Cleanup(func() {
+		first.Release(ctx)
+		second.Release(ctx)
+	})
+
+	leads, err := first.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, leads)
+	leads, err = first.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, leads, "the leader keeps the lock")
+
+	leads, err = second.Acquire(ctx)
+	require.NoError(t, err)
+	assert.False(t, leads)
+
+	require.NoError(t, first.Release(ctx))
+	leads, err = second.Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, leads, "the lock is free once released")
+}
@@ -0,0 +1,115 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// span is a word of a text, by byte offsets.
+type span struct {
+	start, end int
+	match      bool
+}
+
+func words(text string, terms []string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			spans = append(spans, newSpan(text, start, i, terms))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, newSpan(text, start, len(text), terms))
+	}
+	return spans
+}
+
+func newSpan(text string, start, end int, terms []string) span {
+	word := strings.ToLower(text[start:end])
+	for _, term := range terms {
+		if matches(word, term) {
+			return span{start, end, true}
+		}
+	}
+	return span{start, end, false}
+}
+
+// Highlight returns text HTML-escaped with the words matching terms wrapped
+// in <mark>, and false when none match. A text longer than max bytes is cut
+// to a fragment of about max bytes around the first match, with an ellipsis
+// where it was cut; max 0 keeps the whole text.
+func Highlight(text string, terms []string, max int) (string, bool) {
+	spans := words(text, terms)
+	first := -1
+	for i, s := range spans {
+		if s.match {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	from, to := 0, len(text)
+	if max > 0 && len(text) > max {
+		// Start a quarter of the fragment before the first match, at a word.
+		lo := spans[first].start - max/4
+		i := first
+		for i > 0 && spans[i-1].start >= lo {
+			i--
+		}
+		if i > 0 {
+			from = spans[i].start
+		}
+		to = from
+		for j := i; j < len(spans) && (spans[j].end-from <= max || j == first); j++ {
+			to = spans[j].end
+		}
+		if to == spans[len(spans)-1].end {
+			to = len(text)
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	at := from
+	for _, s := range spans {
+		if s.start < from || s.end > to || !s.match {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[at:s.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[s.start:s.end]))
+		b.WriteString("</mark>")
+		at = s.end
+	}
+	b.WriteString(html.EscapeString(text[at:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
+
+// fragmentSize is the length of the highlighted fragments of long fields.
+const fragmentSize = 160
+
+// highlights highlights every field of a hit that matches terms.
+func highlights(fields map[string]string, terms []string) map[string]string {
+	result := map[string]string{}
+	for name, text := range fields {
+		if h, ok := Highlight(text, terms, fragmentSize); ok {
+			result[name] = h
+		}
+	}
+	return result
+}
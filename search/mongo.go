@@ -0,0 +1,66 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sing3demons/go-example/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoProducts searches products with the name_description_text index
+// declared in db.ProductSchema. Mongo's text score picks the hits, which
+// are then scored like ScanProducts', names counting double.
+type MongoProducts struct {
+	col *mongo.Collection
+}
+
+func NewMongoProducts(col *mongo.Collection) *MongoProducts {
+	return &MongoProducts{col: col}
+}
+
+func (m *MongoProducts) Type() string {
+	return "product"
+}
+
+// Search passes $text the query's terms, which it ORs as PostgresTodos
+// does; they only hold letters and digits, so they cannot form phrases or
+// negations.
+func (m *MongoProducts) Search(ctx context.Context, q Query) ([]Hit, error) {
+	score := bson.D{{Key: "$meta", Value: "textScore"}}
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "score", Value: score}}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(q.Limit))
+	cursor, err := m.col.Find(ctx, bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: strings.Join(q.Terms, " ")}}}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		models.Product `bson:",inline"`
+		Score          float64 `bson:"score"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, len(rows))
+	for i, row := range rows {
+		hits[i] = Hit{
+			Type:  "product",
+			ID:    row.ID.Hex(),
+			Score: Score(q.Terms, Text{row.Name, 2}, Text{row.Description, 1}),
+			Highlights: highlights(map[string]string{
+				"name":        row.Name,
+				"description": row.Description,
+			}, q.Terms),
+			Record: row.Product,
+		}
+	}
+	byScore(hits)
+	return hits, nil
+}
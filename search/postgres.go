@@ -0,0 +1,57 @@
+package search
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/sing3demons/go-example/models"
+	"gorm.io/gorm"
+)
+
+// PostgresTodos searches todo titles through the search tsvector column that
+// db.Open adds to todos in postgres. ts_rank_cd picks the hits, which are
+// then scored like ScanTodos'.
+type PostgresTodos struct {
+	db *gorm.DB
+}
+
+func NewPostgresTodos(db *gorm.DB) *PostgresTodos {
+	return &PostgresTodos{db: db}
+}
+
+func (p *PostgresTodos) Type() string {
+	return "todo"
+}
+
+// Search ORs the query's words, like Mongo's $text, so that a todo matching
+// some of them is found and one matching all of them ranks first. Terms only
+// hold letters and digits, so joining them cannot form other tsquery syntax.
+func (p *PostgresTodos) Search(ctx context.Context, q Query) ([]Hit, error) {
+	var rows []struct {
+		models.Todo
+		Score float64
+	}
+	err := p.db.WithContext(ctx).Raw(`
+		SELECT todos.*, ts_rank_cd(todos.search, query) AS score
+		FROM todos, to_tsquery('english', ?) AS query
+		WHERE todos.search @@ query AND todos.deleted_at IS NULL
+		ORDER BY score DESC, todos.id
+		LIMIT ?`, strings.Join(q.Terms, " | "), q.Limit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, len(rows))
+	for i, row := range rows {
+		hits[i] = Hit{
+			Type:       "todo",
+			ID:         strconv.FormatUint(uint64(row.ID), 10),
+			Score:      Score(q.Terms, Text{row.Title, 1}),
+			Highlights: highlights(map[string]string{"title": row.Title}, q.Terms),
+			Record:     row.Todo,
+		}
+	}
+	byScore(hits)
+	return hits, nil
+}
@@ -0,0 +1,93 @@
+package search
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+)
+
+// Field is a searchable text of a record of type T.
+type Field[T any] struct {
+	Name   string
+	Weight float64
+	Get    func(*T) string
+}
+
+// Scan is a Backend that reads every record of a store and matches words
+// itself. It serves the memory store and SQLite, which have no full-text
+// index worth using, and suits small collections only.
+type Scan[T any] struct {
+	typ    string
+	db     store.Storer
+	id     func(*T) string
+	fields []Field[T]
+}
+
+func NewScan[T any](typ string, db store.Storer, id func(*T) string, fields ...Field[T]) *Scan[T] {
+	return &Scan[T]{typ: typ, db: db, id: id, fields: fields}
+}
+
+func (s *Scan[T]) Type() string {
+	return s.typ
+}
+
+// Search scores the records matching any of the query's words; see Score.
+func (s *Scan[T]) Search(ctx context.Context, q Query) ([]Hit, error) {
+	var hits []Hit
+	var record T
+	err := store.Each(s.db, &record, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		texts := map[string]string{}
+		scored := make([]Text, len(s.fields))
+		for i, field := range s.fields {
+			texts[field.Name] = field.Get(&record)
+			scored[i] = Text{Text: texts[field.Name], Weight: field.Weight}
+		}
+		score := Score(q.Terms, scored...)
+		if score == 0 {
+			return nil
+		}
+
+		r := record
+		hits = append(hits, Hit{
+			Type:       s.typ,
+			ID:         s.id(&r),
+			Score:      score,
+			Highlights: highlights(texts, q.Terms),
+			Record:     r,
+		})
+		return nil
+	})
+	if err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+
+	byScore(hits)
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+// ScanProducts searches product names and descriptions, names counting
+// double.
+func ScanProducts(db store.Storer) Backend {
+	return NewScan("product", db,
+		func(p *models.Product) string { return p.ID.Hex() },
+		Field[models.Product]{Name: "name", Weight: 2, Get: func(p *models.Product) string { return p.Name }},
+		Field[models.Product]{Name: "description", Weight: 1, Get: func(p *models.Product) string { return p.Description }},
+	)
+}
+
+// ScanTodos searches todo titles.
+func ScanTodos(db store.Storer) Backend {
+	return NewScan("todo", db,
+		func(t *models.Todo) string { return strconv.FormatUint(uint64(t.ID), 10) },
+		Field[models.Todo]{Name: "title", Weight: 1, Get: func(t *models.Todo) string { return t.Title }},
+	)
+}
@@ -0,0 +1,206 @@
+// Package search finds records by free text. Every resource has a Backend,
+// usually its database's own full-text index, and a Searcher asks each of
+// them and merges their hits into one ranked, paginated list.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ErrNoTerms is returned for a query without a single word to search for.
+var ErrNoTerms = errors.New("search: the query has no words")
+
+type Query struct {
+	// Text is the query as the user typed it.
+	Text string
+	// Terms are the lowercase words of Text, for backends that match words
+	// themselves and for highlighting.
+	Terms []string
+	// Limit is the number of hits wanted, best first.
+	Limit int
+}
+
+type Hit struct {
+	Type  string  `json:"type"`
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+	// Highlights holds the matching fields, HTML-escaped, with the query's
+	// words wrapped in <mark>.
+	Highlights map[string]string `json:"highlights,omitempty"`
+	Record     any               `json:"record"`
+}
+
+// Backend searches one type of record. Hits match any of the query's words,
+// and hits matching more of them, or matching them in weightier fields,
+// score higher.
+type Backend interface {
+	// Type names the records found, such as "product".
+	Type() string
+	// Search returns up to q.Limit hits, best first, scored by Score so
+	// that hits of every backend compare.
+	Search(ctx context.Context, q Query) ([]Hit, error)
+}
+
+// Text is a searchable text of a hit and the weight of its field.
+type Text struct {
+	Text   string
+	Weight float64
+}
+
+// Score scores a hit with texts from 0 to 1: every term of the query
+// counts the weight of the weightiest text it is in, out of that of the
+// weightiest text there is. So a hit matching every term in its weightiest
+// field scores 1, whatever the backend.
+func Score(terms []string, texts ...Text) float64 {
+	top := 0.0
+	for _, t := range texts {
+		if t.Weight > top {
+			top = t.Weight
+		}
+	}
+	if top == 0 || len(terms) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, term := range terms {
+		best := 0.0
+		for _, t := range texts {
+			if t.Weight > best && hasTerm(t.Text, term) {
+				best = t.Weight
+			}
+		}
+		total += best
+	}
+	return total / (top * float64(len(terms)))
+}
+
+func hasTerm(text, term string) bool {
+	for _, w := range words(text, []string{term}) {
+		if w.match {
+			return true
+		}
+	}
+	return false
+}
+
+// byScore sorts hits best first, keeping the order of hits that score the
+// same.
+func byScore(hits []Hit) {
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+}
+
+type Page struct {
+	Hits    []Hit
+	HasMore bool
+}
+
+type Searcher struct {
+	backends []Backend
+}
+
+func New(backends ...Backend) *Searcher {
+	return &Searcher{backends: backends}
+}
+
+// Types returns the record types the searcher can find.
+func (s *Searcher) Types() []string {
+	types := make([]string, len(s.backends))
+	for i, b := range s.backends {
+		types[i] = b.Type()
+	}
+	return types
+}
+
+// Search returns the 1-based page of hits for text, searching the backends
+// of types, or every backend when types is empty. Ties go by type and ID so
+// that pages are stable.
+func (s *Searcher) Search(ctx context.Context, text string, types []string, page, perPage int) (Page, error) {
+	q := Query{Text: text, Terms: Terms(text), Limit: page*perPage + 1}
+	if len(q.Terms) == 0 {
+		return Page{}, ErrNoTerms
+	}
+
+	var backends []Backend
+	for _, b := range s.backends {
+		if len(types) == 0 || contains(types, b.Type()) {
+			backends = append(backends, b)
+		}
+	}
+
+	results := make([][]Hit, len(backends))
+	errs := make([]error, len(backends))
+	var wg sync.WaitGroup
+	for i, b := range backends {
+		wg.Add(1)
+		go func(i int, b Backend) {
+			defer wg.Done()
+			results[i], errs[i] = b.Search(ctx, q)
+		}(i, b)
+	}
+	wg.Wait()
+
+	var hits []Hit
+	for i, result := range results {
+		if errs[i] != nil {
+			return Page{}, fmt.Errorf("search %ss: %w", backends[i].Type(), errs[i])
+		}
+		hits = append(hits, result...)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Type != hits[j].Type {
+			return hits[i].Type < hits[j].Type
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	start := (page - 1) * perPage
+	if start > len(hits) {
+		start = len(hits)
+	}
+	end := start + perPage
+	if end > len(hits) {
+		end = len(hits)
+	}
+	return Page{Hits: hits[start:end], HasMore: len(hits) > end}, nil
+}
+
+// Terms splits text into distinct lowercase words.
+func Terms(text string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), notWordRune) {
+		if !contains(terms, word) {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// matches reports whether word, in lowercase, is a form of term: term
+// itself or a word starting with it, such as "cottons" for "cotton", or a
+// stem of at least four letters of it, such as "shirt" for "shirts".
+func matches(word, term string) bool {
+	return strings.HasPrefix(word, term) || (len(word) >= 4 && strings.HasPrefix(term, word))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package search_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/db"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/search"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type fakeBackend struct {
+	typ   string
+	hits  []search.Hit
+	err   error
+	limit int
+}
+
+func (f *fakeBackend) Type() string { return f.typ }
+
+func (f *fakeBackend) Search(_ context.Context, q search.Query) ([]search.Hit, error) {
+	f.limit = q.Limit
+	hits := f.hits
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, f.err
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"blue", "cotton", "t", "shirt"}, search.Terms(`Blue  cotton "T-shirt", blue`))
+	assert.Empty(t, search.Terms(" -- "))
+}
+
+func TestHighlight(t *testing.T) {
+	h, ok := search.Highlight("Blue <b>cottons</b> & shirts", []string{"cotton", "shirt"}, 0)
+	require.True(t, ok)
+	assert.Equal(t, "Blue &lt;b&gt;<mark>cottons</mark>&lt;/b&gt; &amp; <mark>shirts</mark>", h)
+
+	_, ok = search.Highlight("Red wool", []string{"cotton"}, 0)
+	assert.False(t, ok)
+
+	long := strings.Repeat("lorem ipsum ", 20) + "soft cotton weave " + strings.Repeat("dolor sit ", 20)
+	h, ok = search.Highlight(long, []string{"cotton"}, 60)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(h, "…"), h)
+	assert.True(t, strings.HasSuffix(h, "…"), h)
+	assert.Contains(t, h, "soft <mark>cotton</mark> weave")
+	assert.LessOrEqual(t, len(h), 60+len("……<mark></mark>"))
+}
+
+func TestSearcherMergesBackends(t *testing.T) {
+	products := &fakeBackend{typ: "product", hits: []search.Hit{
+		{Type: "product", ID: "p1", Score: 1},
+		{Type: "product", ID: "p2", Score: 0.5},
+	}}
+	todos := &fakeBackend{typ: "todo", hits: []search.Hit{
+		{Type: "todo", ID: "1", Score: 0.8},
+		{Type: "todo", ID: "2", Score: 0.5},
+		{Type: "todo", ID: "3", Score: 0.2},
+	}}
+	s := search.New(products, todos)
+	assert.Equal(t, []string{"product", "todo"}, s.Types())
+
+	ids := func(hits []search.Hit) []string {
+		var ids []string
+		for _, hit := range hits {
+			ids = append(ids, hit.Type+":"+hit.ID)
+		}
+		return ids
+	}
+
+	page, err := s.Search(context.Background(), "blue", nil, 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"product:p1", "todo:1", "product:p2"}, ids(page.Hits), "scores of every backend compare")
+	assert.Equal(t, 0.8, page.Hits[1].Score)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, 4, todos.limit, "backends return every hit up to the page, and one more")
+
+	page, err = s.Search(context.Background(), "blue", nil, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"todo:2", "todo:3"}, ids(page.Hits), "ties go by type")
+	assert.False(t, page.HasMore)
+
+	page, err = s.Search(context.Background(), "blue", []string{"todo"}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"todo:1", "todo:2", "todo:3"}, ids(page.Hits))
+
+	page, err = s.Search(context.Background(), "blue", nil, 9, 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Hits)
+
+	_, err = s.Search(context.Background(), "!!", nil, 1, 10)
+	assert.ErrorIs(t, err, search.ErrNoTerms)
+
+	products.err = errors.New("connection refused")
+	_, err = s.Search(context.Background(), "blue", nil, 1, 10)
+	assert.EqualError(t, err, "search products: connection refused")
+}
+
+func TestScanBackends(t *testing.T) {
+	products := store.NewMemoryStore()
+	for _, p := range []models.Product{
+		{Name: "Blue cotton shirt", Price: money.New(20, "USD"), Description: "A shirt"},
+		{Name: "Red scarf", Price: money.New(10, "USD"), Description: "Soft blue cotton"},
+		{Name: "Blue mug", Price: money.New(5, "USD"), Description: "Ceramic"},
+		{Name: "Green hat", Price: money.New(8, "USD"), Description: "Wool"},
+	} {
+		p := p
+		require.NoError(t, products.Create(&p))
+	}
+
+	hits, err := search.ScanProducts(products).Search(context.Background(), search.Query{Terms: []string{"blue", "cotton"}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, hits, 3)
+	assert.Equal(t, "Blue cotton shirt", hits[0].Record.(models.Product).Name, "both words in the name")
+	assert.Equal(t, "Red scarf", hits[1].Record.(models.Product).Name, "both words in the description")
+	assert.Equal(t, "Blue mug", hits[2].Record.(models.Product).Name)
+	assert.Equal(t, []float64{1, 0.5, 0.5}, []float64{hits[0].Score, hits[1].Score, hits[2].Score}, "the share of words matched, names counting double")
+	assert.Equal(t, "<mark>Blue</mark> <mark>cotton</mark> shirt", hits[0].Highlights["name"])
+	assert.NotContains(t, hits[0].Highlights, "description")
+	assert.Equal(t, hits[0].Record.(models.Product).ID.Hex(), hits[0].ID)
+
+	todos := store.NewMemoryStore()
+	require.NoError(t, todos.Create(&models.Todo{Title: "Wash the blue car"}))
+	require.NoError(t, todos.Create(&models.Todo{Title: "Call mum"}))
+	hits, err = search.ScanTodos(todos).Search(context.Background(), search.Query{Terms: []string{"washing"}, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "1", hits[0].ID)
+	assert.Equal(t, "<mark>Wash</mark> the blue car", hits[0].Highlights["title"])
+	assert.Equal(t, 1.0, hits[0].Score)
+}
+
+// TestPostgresTodos runs when TEST_DATABASE_URL points at postgres.
+func TestPostgresTodos(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if !strings.HasPrefix(dsn, "postgres") {
+		t.Skip("TEST_DATABASE_URL is not a postgres database")
+	}
+	gdb, err := db.Open(dsn)
+	require.NoError(t, err)
+	require.NoError(t, gdb.Exec("DELETE FROM todos").Error)
+
+	for _, title := range []string{"Wash the blue car", "Blue paint for the cars", "Call mum"} {
+		require.NoError(t, gdb.Create(&models.Todo{Title: title}).Error)
+	}
+	hits, err := search.NewPostgresTodos(gdb).Search(context.Background(), search.Query{Terms: []string{"blue", "cars"}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	assert.Equal(t, "todo", hits[0].Type)
+	assert.Contains(t, hits[0].Highlights["title"], "<mark>")
+}
+
+// TestMongoProducts runs when TEST_MONGO_URL points at a server.
+func TestMongoProducts(t *testing.T) {
+	uri := os.Getenv("TEST_MONGO_URL")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URL is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	defer client.Disconnect(context.Background())
+
+	col := client.Database("go-example-test").Collection(fmt.Sprintf("search_%d", time.Now().UnixNano()))
+	t.Cleanup(func() { col.Drop(context.Background()) })
+	_, err = store.ApplyMongoSchema(col, db.ProductSchema())
+	require.NoError(t, err)
+
+	products := store.NewMongoStore(col)
+	for _, p := range []models.Product{
+		{Name: "Blue cotton shirt", Price: money.New(20, "USD"), Description: "A shirt"},
+		{Name: "Red scarf", Price: money.New(10, "USD"), Description: "Wool"},
+	} {
+		p := p
+		require.NoError(t, products.Create(&p))
+	}
+
+	hits, err := search.NewMongoProducts(col).Search(ctx, search.Query{Text: "blue cotton", Terms: []string{"blue", "cotton"}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "<mark>Blue</mark> <mark>cotton</mark> shirt", hits[0].Highlights["name"])
+	assert.Equal(t, 1.0, hits[0].Score)
+}
@@ -0,0 +1,511 @@
+// Package shop sells the products of the catalog. Every user has a cart;
+// checkout turns it into an order at the prices promotions give, reserving
+// the stock of every item or, if one cannot be reserved, none of them.
+// Orders then go through the statuses of a small state machine, committing
+// their stock when shipped and releasing it when cancelled.
+package shop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/promotion"
+	"github.com/sing3demons/go-example/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxQuantity is the most of one product a cart can hold, which keeps
+// totals far from overflowing.
+const MaxQuantity = 1000
+
+var (
+	ErrInvalidQuantity   = fmt.Errorf("quantity must be from 1 to %d", MaxQuantity)
+	ErrUnknownProduct    = errors.New("no such product")
+	ErrEmptyCart         = errors.New("the cart is empty")
+	ErrMixedCurrencies   = errors.New("the cart holds products priced in different currencies")
+	ErrInvalidTransition = errors.New("invalid status change")
+	ErrConflict          = errors.New("changed by another request, try again")
+)
+
+// checkoutTimeout is how long a checkout may take before AbandonCheckouts
+// undoes it.
+const checkoutTimeout = 10 * time.Minute
+
+// SchedulerActor is who the stock AbandonCheckouts releases is attributed
+// to.
+const SchedulerActor = "scheduler"
+
+// transitions are the statuses an order in each status can move to.
+// Shipped and cancelled orders are final.
+var transitions = map[string][]string{
+	models.OrderPending: {models.OrderPaid, models.OrderCancelled},
+	models.OrderPaid:    {models.OrderShipped, models.OrderCancelled},
+}
+
+// CanTransition reports whether an order can move from one status to
+// another.
+func CanTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+type Service struct {
+	carts    store.Storer
+	orders   store.Storer
+	products store.Storer
+	stock    *inventory.Service
+	// promotions may be nil, for no promotions.
+	promotions *promotion.Engine
+	// mu serializes changes to carts and checkouts within a replica; across
+	// replicas the carts' versions do, as every write of a cart is
+	// conditional on the version it was loaded at.
+	mu sync.Mutex
+}
+
+// New returns a shop keeping carts and orders, which may be the same store,
+// and selling products from their stock with the promotions of an engine.
+// Orders keep the prices read from products, so it should not be a cache.
+func New(carts, orders, products store.Storer, stock *inventory.Service, promotions *promotion.Engine) *Service {
+	return &Service{carts: carts, orders: orders, products: products, stock: stock, promotions: promotions}
+}
+
+// Cart returns the cart of user, which is empty until something is added.
+func (s *Service) Cart(user string) (models.Cart, error) {
+	cart, _, err := s.cart(user)
+	return cart, err
+}
+
+// cart returns the cart of user and whether it is stored yet.
+func (s *Service) cart(user string) (models.Cart, bool, error) {
+	cart := models.Cart{ID: user}
+	err := s.carts.First(&cart, bson.M{"_id": user})
+	if err != nil && !store.IsNotFound(err) {
+		return cart, false, err
+	}
+	if cart.Items == nil {
+		cart.Items = []models.CartItem{}
+	}
+	if cart.Coupons == nil {
+		cart.Coupons = []string{}
+	}
+	return cart, err == nil, nil
+}
+
+// AddItem adds quantity of a product to the cart of user, up to
+// MaxQuantity in all.
+func (s *Service) AddItem(user string, productID primitive.ObjectID, quantity int64) (models.Cart, error) {
+	if quantity <= 0 || quantity > MaxQuantity {
+		return models.Cart{}, ErrInvalidQuantity
+	}
+	if _, err := s.product(productID); err != nil {
+		return models.Cart{}, err
+	}
+	return s.updateCart(user, func(cart *models.Cart) error {
+		for i := range cart.Items {
+			if cart.Items[i].ProductID == productID {
+				if cart.Items[i].Quantity+quantity > MaxQuantity {
+					return ErrInvalidQuantity
+				}
+				cart.Items[i].Quantity += quantity
+				return nil
+			}
+		}
+		cart.Items = append(cart.Items, models.CartItem{ProductID: productID, Quantity: quantity})
+		return nil
+	})
+}
+
+// SetQuantity changes the quantity of a product in the cart of user.
+func (s *Service) SetQuantity(user string, productID primitive.ObjectID, quantity int64) (models.Cart, error) {
+	if quantity <= 0 || quantity > MaxQuantity {
+		return models.Cart{}, ErrInvalidQuantity
+	}
+	return s.updateCart(user, func(cart *models.Cart) error {
+		for i := range cart.Items {
+			if cart.Items[i].ProductID == productID {
+				cart.Items[i].Quantity = quantity
+				return nil
+			}
+		}
+		return store.ErrNotFound
+	})
+}
+
+// RemoveItem takes a product out of the cart of user.
+func (s *Service) RemoveItem(user string, productID primitive.ObjectID) (models.Cart, error) {
+	return s.updateCart(user, func(cart *models.Cart) error {
+		for i := range cart.Items {
+			if cart.Items[i].ProductID == productID {
+				cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+				return nil
+			}
+		}
+		return store.ErrNotFound
+	})
+}
+
+// AddCoupon gives a coupon code for the cart of user. It fails with
+// promotion.ErrUnknownCoupon unless a promotion runs with the code.
+func (s *Service) AddCoupon(user, code string) (models.Cart, error) {
+	code, err := s.promotions.CheckCoupon(code, time.Now())
+	if err != nil {
+		return models.Cart{}, err
+	}
+	return s.updateCart(user, func(cart *models.Cart) error {
+		for _, given := range cart.Coupons {
+			if given == code {
+				return nil
+			}
+		}
+		cart.Coupons = append(cart.Coupons, code)
+		return nil
+	})
+}
+
+// RemoveCoupon takes a coupon code back from the cart of user.
+func (s *Service) RemoveCoupon(user, code string) (models.Cart, error) {
+	code = promotion.NormalizeCode(code)
+	return s.updateCart(user, func(cart *models.Cart) error {
+		for i, given := range cart.Coupons {
+			if given == code {
+				cart.Coupons = append(cart.Coupons[:i], cart.Coupons[i+1:]...)
+				return nil
+			}
+		}
+		return store.ErrNotFound
+	})
+}
+
+func (s *Service) updateCart(user string, update func(*models.Cart) error) (models.Cart, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cart, stored, err := s.cart(user)
+	if err != nil {
+		return cart, err
+	}
+	if err := update(&cart); err != nil {
+		return cart, err
+	}
+	return cart, s.writeCart(&cart, stored)
+}
+
+// writeCart stores a cart loaded at its version, bumping the version. It
+// fails with ErrConflict when the cart was changed since it was loaded,
+// by another replica or a checkout, rather than lose that change.
+func (s *Service) writeCart(cart *models.Cart, stored bool) error {
+	version := cart.Version
+	cart.Version++
+	cart.UpdatedAt = time.Now()
+
+	var err error
+	if stored {
+		err = store.Update(s.carts, &models.Cart{}, map[string]any{
+			"items":      cart.Items,
+			"coupons":    cart.Coupons,
+			"version":    cart.Version,
+			"updated_at": cart.UpdatedAt,
+		}, bson.M{"_id": cart.ID, "version": version})
+	} else {
+		err = s.carts.Create(cart)
+	}
+	if store.IsNotFound(err) || store.IsDuplicateKey(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *Service) product(id primitive.ObjectID) (models.Product, error) {
+	var product models.Product
+	if err := s.products.First(&product, bson.M{"_id": id}); err != nil {
+		if store.IsNotFound(err) {
+			return product, fmt.Errorf("%w: %s", ErrUnknownProduct, id.Hex())
+		}
+		return product, err
+	}
+	return product, nil
+}
+
+// Price works out what checking out cart would cost now: the current
+// prices of its products, less what the promotions running give for them
+// and its coupons.
+func (s *Service) Price(cart models.Cart) (models.CartPricing, error) {
+	var pricing models.CartPricing
+	rules, err := s.promotions.Rules(time.Now(), cart.Coupons)
+	if err != nil {
+		return pricing, err
+	}
+
+	pricing.Items = []models.CartLine{}
+	for _, item := range cart.Items {
+		product, err := s.product(item.ProductID)
+		if err != nil {
+			return pricing, err
+		}
+		price := product.Price
+		price.Formatted = ""
+		if len(pricing.Items) == 0 {
+			pricing.Subtotal = money.New(0, price.Currency)
+			pricing.Discount = money.New(0, price.Currency)
+		} else if price.Currency != pricing.Subtotal.Currency {
+			return pricing, ErrMixedCurrencies
+		}
+		quote := rules.Quote(product, item.Quantity)
+		pricing.Subtotal.Amount += quote.Subtotal.Amount
+		pricing.Discount.Amount += quote.Discount.Amount
+		pricing.Items = append(pricing.Items, models.CartLine{
+			ProductID:  product.ID,
+			Name:       product.Name,
+			Price:      price,
+			PriceQuote: quote,
+		})
+	}
+	pricing.Total = money.New(pricing.Subtotal.Amount-pricing.Discount.Amount, pricing.Subtotal.Currency)
+	return pricing, nil
+}
+
+// Checkout places an order for the cart of user, with the names and prices
+// the products have now and the promotions running, and empties the cart.
+// The stock of every item is reserved; when one cannot be, those already
+// reserved are released and the cart is given back. The order is written,
+// still placing, before any stock is reserved, so that the stock of a
+// checkout that dies halfway is released by AbandonCheckouts. It fails
+// with ErrConflict when the cart changes while it is checked out.
+func (s *Service) Checkout(user string) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cart, err := s.Cart(user)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if len(cart.Items) == 0 {
+		return models.Order{}, ErrEmptyCart
+	}
+
+	now := time.Now()
+	order := models.Order{
+		ID:        primitive.NewObjectID(),
+		User:      user,
+		Status:    models.OrderPending,
+		History:   []models.OrderTransition{{To: models.OrderPending, Actor: user, At: now}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	pricing, err := s.Price(cart)
+	if err != nil {
+		return models.Order{}, err
+	}
+	for _, line := range pricing.Items {
+		item := models.OrderItem{
+			ProductID: line.ProductID,
+			Name:      line.Name,
+			Price:     line.Price,
+			Quantity:  line.Quantity,
+			Subtotal:  line.Subtotal,
+		}
+		if len(line.Applied) > 0 {
+			discount := line.Discount
+			item.Discount, item.Promotions = &discount, line.Applied
+		}
+		order.Items = append(order.Items, item)
+	}
+	order.Total = pricing.Total
+	if pricing.Discount.Amount > 0 {
+		order.Discount = &pricing.Discount
+	}
+
+	// Emptying the cart claims it, making a second checkout of it, or one
+	// racing a change to it, fail.
+	claimed := cart
+	claimed.Items, claimed.Coupons = []models.CartItem{}, []string{}
+	if err := s.writeCart(&claimed, true); err != nil {
+		return models.Order{}, err
+	}
+
+	order.Placing = true
+	if err := s.orders.Create(&order); err != nil {
+		s.putBack(cart, claimed)
+		return models.Order{}, err
+	}
+	reason := "order " + order.ID.Hex()
+	for i, item := range order.Items {
+		_, err := s.stock.Reserve(inventory.Change{ProductID: item.ProductID, Quantity: item.Quantity, Reason: reason, Actor: user})
+		if err == nil {
+			// Counted once reserved, so that a crash in between leaves the
+			// item reserved rather than have it released without ever being.
+			if err = s.count(order.ID, 1); err != nil {
+				s.release(order.Items[i:i+1], reason+" not placed", user)
+			}
+		}
+		if err != nil {
+			s.undo(order, user)
+			s.putBack(cart, claimed)
+			return models.Order{}, fmt.Errorf("%s: %w", item.Name, err)
+		}
+		order.Reserved++
+	}
+
+	// The order is only placed while AbandonCheckouts has not taken it.
+	err = store.Update(s.orders, &models.Order{}, map[string]any{"placing": false}, bson.M{"_id": order.ID, "placing": true, "version": order.Version})
+	if err != nil {
+		if store.IsNotFound(err) {
+			s.putBack(cart, claimed)
+			return models.Order{}, ErrConflict
+		}
+		return models.Order{}, err
+	}
+	order.Placing = false
+	return order, nil
+}
+
+// putBack gives a cart claimed by a checkout that failed its items and
+// coupons again, unless it was changed since. It runs once the outcome is
+// decided, so failures are logged rather than returned.
+func (s *Service) putBack(cart, claimed models.Cart) {
+	cart.Version = claimed.Version
+	if err := s.writeCart(&cart, true); err != nil {
+		log.Printf("shop: putting back the cart of %s: %v", cart.ID, err)
+	}
+}
+
+// release makes the stock reserved for items available again. It runs once
+// the outcome is decided, so failures are logged rather than returned.
+func (s *Service) release(items []models.OrderItem, reason, actor string) {
+	for _, item := range items {
+		if _, err := s.stock.Release(inventory.Change{ProductID: item.ProductID, Quantity: item.Quantity, Reason: reason, Actor: actor}); err != nil {
+			log.Printf("shop: releasing %d of product %s for %s: %v", item.Quantity, item.ProductID.Hex(), reason, err)
+		}
+	}
+}
+
+// count adds n to the items reserved for the order with id.
+func (s *Service) count(id primitive.ObjectID, n int64) error {
+	var order models.Order
+	return store.Increment(s.orders, &order, bson.M{"_id": id}, map[string]int64{"reserved": n})
+}
+
+// undo releases the stock reserved for an order that was not placed and
+// deletes it. Each item is uncounted before its stock is released, so that
+// a crash in between leaves the stock reserved rather than released twice.
+// It runs once the outcome is decided, so failures are logged rather than
+// returned.
+func (s *Service) undo(order models.Order, actor string) {
+	reason := "order " + order.ID.Hex() + " not placed"
+	for i := order.Reserved - 1; i >= 0; i-- {
+		if err := s.count(order.ID, -1); err != nil {
+			log.Printf("shop: undoing %s: %v", reason, err)
+			return
+		}
+		s.release(order.Items[i:i+1], reason, actor)
+	}
+	if err := store.Delete(s.orders, &order); err != nil {
+		log.Printf("shop: deleting %s: %v", reason, err)
+	}
+}
+
+// AbandonCheckouts undoes the orders whose checkout started more than
+// checkoutTimeout before now and never finished, releasing their stock. It
+// is a scheduler job.
+func (s *Service) AbandonCheckouts(ctx context.Context, now time.Time) error {
+	var orders []models.Order
+	err := s.orders.Find(&orders, bson.M{"placing": true, "created_at": bson.M{"$lt": now.Add(-checkoutTimeout)}})
+	if err != nil && !store.IsNotFound(err) {
+		return err
+	}
+	for _, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Claiming the order makes a checkout still running fail.
+		var claimed models.Order
+		err := store.Increment(s.orders, &claimed, bson.M{"_id": order.ID, "placing": true, "version": order.Version}, map[string]int64{"version": 1})
+		switch {
+		case store.IsNotFound(err):
+			continue
+		case err != nil:
+			return err
+		}
+		log.Printf("shop: checkout of order %s by %s did not finish, undoing it", order.ID.Hex(), order.User)
+		s.undo(claimed, SchedulerActor)
+	}
+	return nil
+}
+
+// Orders returns the orders of user, newest first.
+func (s *Service) Orders(user string) ([]models.Order, error) {
+	orders := []models.Order{}
+	if err := s.orders.Find(&orders, bson.M{"user": user, "placing": bson.M{"$ne": true}}); err != nil && !store.IsNotFound(err) {
+		return nil, err
+	}
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].CreatedAt.After(orders[j].CreatedAt) })
+	return orders, nil
+}
+
+// Order returns an order of user.
+func (s *Service) Order(user string, id primitive.ObjectID) (models.Order, error) {
+	var order models.Order
+	err := s.orders.First(&order, bson.M{"_id": id, "user": user, "placing": bson.M{"$ne": true}})
+	return order, err
+}
+
+// Transition moves an order to status to, recording who did it and why.
+// Shipping commits the stock reserved at checkout and cancelling releases
+// it.
+func (s *Service) Transition(id primitive.ObjectID, to, actor, reason string) (models.Order, error) {
+	var order models.Order
+	if err := s.orders.First(&order, bson.M{"_id": id, "placing": bson.M{"$ne": true}}); err != nil {
+		return order, err
+	}
+	from := order.Status
+	if !CanTransition(from, to) {
+		return order, fmt.Errorf("%w: an order that is %s cannot become %s", ErrInvalidTransition, from, to)
+	}
+
+	// Claiming the order makes a concurrent change of its status fail, so
+	// that its stock is committed or released once.
+	var claimed models.Order
+	if err := store.Increment(s.orders, &claimed, bson.M{"_id": id, "version": order.Version}, map[string]int64{"version": 1}); err != nil {
+		if store.IsNotFound(err) {
+			return order, ErrConflict
+		}
+		return order, err
+	}
+	order.Version = claimed.Version
+
+	// The change is claimed, so a stock change that fails is logged for the
+	// stock to be adjusted rather than holding the order back.
+	why := "order " + order.ID.Hex() + " " + to
+	for _, item := range order.Items {
+		change := inventory.Change{ProductID: item.ProductID, Quantity: item.Quantity, Reason: why, Actor: actor}
+		var err error
+		switch to {
+		case models.OrderShipped:
+			_, err = s.stock.Commit(change)
+		case models.OrderCancelled:
+			_, err = s.stock.Release(change)
+		}
+		if err != nil {
+			log.Printf("shop: stock of product %s for %s: %v", item.ProductID.Hex(), why, err)
+		}
+	}
+
+	now := time.Now()
+	order.Status = to
+	order.History = append(order.History, models.OrderTransition{From: from, To: to, Actor: actor, Reason: reason, At: now})
+	order.UpdatedAt = now
+	return order, s.orders.Save(&order)
+}
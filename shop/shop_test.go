@@ -0,0 +1,320 @@
+package shop_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/inventory"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/promotion"
+	"github.com/sing3demons/go-example/shop"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type fixture struct {
+	shop       *shop.Service
+	stock      *inventory.Service
+	products   store.Storer
+	orders     store.Storer
+	promotions *promotion.Engine
+}
+
+func newFixture(t *testing.T) fixture {
+	products, orders := store.NewMemoryStore(), store.NewMemoryStore()
+	stock := inventory.New(store.NewMemoryStore(), store.NewMemoryStore())
+	promotions := promotion.New(store.NewMemoryStore(), store.NewMemoryStore(), promotion.PolicyPriority)
+	return fixture{
+		shop:       shop.New(store.NewMemoryStore(), orders, products, stock, promotions),
+		stock:      stock,
+		products:   products,
+		orders:     orders,
+		promotions: promotions,
+	}
+}
+
+func (f fixture) product(t *testing.T, name string, price money.Money, stock int64) models.Product {
+	t.Helper()
+	p := models.Product{Name: name, Price: price, Description: name}
+	require.NoError(t, f.products.Create(&p))
+	if stock > 0 {
+		_, err := f.stock.Adjust(inventory.Change{ProductID: p.ID, Quantity: stock, Reason: "delivery"})
+		require.NoError(t, err)
+	}
+	return p
+}
+
+// level returns the on hand, reserved and available stock of a product.
+func (f fixture) level(t *testing.T, p models.Product) [3]int64 {
+	t.Helper()
+	levels, err := f.stock.Levels(p.ID)
+	require.NoError(t, err)
+	require.Len(t, levels, 1)
+	return [3]int64{levels[0].OnHand, levels[0].Reserved, levels[0].Available}
+}
+
+func TestCheckout(t *testing.T) {
+	f := newFixture(t)
+	mug := f.product(t, "Mug", money.New(1250, "EUR"), 10)
+	pot := f.product(t, "Teapot", money.New(3000, "EUR"), 1)
+
+	_, err := f.shop.Checkout("alice")
+	assert.ErrorIs(t, err, shop.ErrEmptyCart)
+
+	_, err = f.shop.AddItem("alice", mug.ID, 2)
+	require.NoError(t, err)
+	cart, err := f.shop.AddItem("alice", mug.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []models.CartItem{{ProductID: mug.ID, Quantity: 3}}, cart.Items)
+	_, err = f.shop.AddItem("alice", pot.ID, 0)
+	assert.ErrorIs(t, err, shop.ErrInvalidQuantity)
+	_, err = f.shop.AddItem("alice", mug.ID, shop.MaxQuantity-2)
+	assert.ErrorIs(t, err, shop.ErrInvalidQuantity, "the cart would hold more than the most")
+	_, err = f.shop.SetQuantity("alice", mug.ID, shop.MaxQuantity+1)
+	assert.ErrorIs(t, err, shop.ErrInvalidQuantity)
+
+	t.Run("stock is reserved for every item or none", func(t *testing.T) {
+		_, err := f.shop.AddItem("alice", pot.ID, 2)
+		require.NoError(t, err)
+		_, err = f.shop.Checkout("alice")
+		assert.ErrorIs(t, err, inventory.ErrInsufficientStock)
+		assert.Equal(t, [3]int64{10, 0, 10}, f.level(t, mug), "the mugs reserved first are released")
+
+		cart, err := f.shop.Cart("alice")
+		require.NoError(t, err)
+		assert.Len(t, cart.Items, 2, "the cart is left as it was")
+		orders, err := f.shop.Orders("alice")
+		require.NoError(t, err)
+		assert.Empty(t, orders, "no order is placed")
+		_, err = f.shop.SetQuantity("alice", pot.ID, 1)
+		require.NoError(t, err)
+	})
+
+	order, err := f.shop.Checkout("alice")
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderPending, order.Status)
+	assert.Equal(t, money.New(3*1250+3000, "EUR"), order.Total)
+	assert.Equal(t, models.OrderItem{ProductID: mug.ID, Name: "Mug", Price: money.New(1250, "EUR"), Quantity: 3, Subtotal: money.New(3750, "EUR")}, order.Items[0])
+	assert.Equal(t, [3]int64{10, 3, 7}, f.level(t, mug))
+	assert.Equal(t, [3]int64{1, 1, 0}, f.level(t, pot))
+
+	cart, err = f.shop.Cart("alice")
+	require.NoError(t, err)
+	assert.Empty(t, cart.Items)
+
+	t.Run("orders keep the prices of checkout", func(t *testing.T) {
+		mug.Price, mug.Name = money.New(1500, "EUR"), "Big mug"
+		require.NoError(t, f.products.Save(&mug))
+
+		saved, err := f.shop.Order("alice", order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Mug", saved.Items[0].Name)
+		assert.Equal(t, money.New(1250, "EUR"), saved.Items[0].Price)
+
+		_, err = f.shop.Order("bob", order.ID)
+		assert.True(t, store.IsNotFound(err), "orders belong to their user")
+	})
+
+	t.Run("carts mixing currencies cannot be checked out", func(t *testing.T) {
+		lamp := f.product(t, "Lamp", money.New(4500, "USD"), 5)
+		_, err := f.shop.AddItem("bob", mug.ID, 1)
+		require.NoError(t, err)
+		_, err = f.shop.AddItem("bob", lamp.ID, 1)
+		require.NoError(t, err)
+		_, err = f.shop.Checkout("bob")
+		assert.ErrorIs(t, err, shop.ErrMixedCurrencies)
+	})
+}
+
+// meanwhile is a store in which another replica changes something right
+// after the next call of method.
+type meanwhile struct {
+	store.Storer
+	method string
+	run    func()
+}
+
+func (m *meanwhile) done(method string) {
+	if run := m.run; run != nil && m.method == method {
+		m.run = nil
+		run()
+	}
+}
+
+func (m *meanwhile) First(dest any, conds ...any) error {
+	err := m.Storer.First(dest, conds...)
+	m.done("First")
+	return err
+}
+
+func (m *meanwhile) Create(value any) error {
+	err := m.Storer.Create(value)
+	m.done("Create")
+	return err
+}
+
+func (m *meanwhile) Update(model any, set map[string]any, conds ...any) error {
+	return store.Update(m.Storer, model, set, conds...)
+}
+
+func (m *meanwhile) Increment(dest any, filter any, inc map[string]int64) error {
+	return store.Increment(m.Storer, dest, filter, inc)
+}
+
+func TestCartsAcrossReplicas(t *testing.T) {
+	f := newFixture(t)
+	mug := f.product(t, "Mug", money.New(1250, "EUR"), 10)
+	pot := f.product(t, "Teapot", money.New(3000, "EUR"), 5)
+	carts, orders := &meanwhile{Storer: store.NewMemoryStore()}, &meanwhile{Storer: f.orders}
+	a := shop.New(carts, orders, f.products, f.stock, f.promotions)
+	b := shop.New(carts, orders, f.products, f.stock, f.promotions)
+
+	_, err := a.AddItem("alice", mug.ID, 1)
+	require.NoError(t, err)
+
+	carts.method, carts.run = "First", func() {
+		_, err := b.AddItem("alice", pot.ID, 1)
+		require.NoError(t, err)
+	}
+	_, err = a.SetQuantity("alice", mug.ID, 2)
+	assert.ErrorIs(t, err, shop.ErrConflict)
+	cart, err := a.Cart("alice")
+	require.NoError(t, err)
+	assert.Equal(t, []models.CartItem{{ProductID: mug.ID, Quantity: 1}, {ProductID: pot.ID, Quantity: 1}}, cart.Items, "the other replica's change is kept")
+
+	carts.method, carts.run = "First", func() {
+		_, err := b.SetQuantity("alice", pot.ID, 2)
+		require.NoError(t, err)
+	}
+	_, err = a.Checkout("alice")
+	assert.ErrorIs(t, err, shop.ErrConflict)
+	placed, err := a.Orders("alice")
+	require.NoError(t, err)
+	assert.Empty(t, placed)
+
+	orders.method, orders.run = "Create", func() {
+		_, err := b.AddItem("alice", pot.ID, 1)
+		require.NoError(t, err)
+	}
+	order, err := a.Checkout("alice")
+	require.NoError(t, err)
+	assert.Len(t, order.Items, 2)
+	cart, err = a.Cart("alice")
+	require.NoError(t, err)
+	assert.Equal(t, []models.CartItem{{ProductID: pot.ID, Quantity: 1}}, cart.Items, "an item added during checkout is kept")
+}
+
+func TestOrderStatus(t *testing.T) {
+	f := newFixture(t)
+	mug := f.product(t, "Mug", money.New(1250, "EUR"), 10)
+	place := func() models.Order {
+		_, err := f.shop.AddItem("alice", mug.ID, 2)
+		require.NoError(t, err)
+		order, err := f.shop.Checkout("alice")
+		require.NoError(t, err)
+		return order
+	}
+
+	shipped := place()
+	_, err := f.shop.Transition(shipped.ID, models.OrderShipped, "staff", "")
+	assert.ErrorIs(t, err, shop.ErrInvalidTransition, "orders are paid before they ship")
+	_, err = f.shop.Transition(shipped.ID, models.OrderPaid, "payments", "card")
+	require.NoError(t, err)
+	shipped, err = f.shop.Transition(shipped.ID, models.OrderShipped, "staff", "")
+	require.NoError(t, err)
+	assert.Equal(t, [3]int64{8, 0, 8}, f.level(t, mug), "shipping takes the stock out")
+	_, err = f.shop.Transition(shipped.ID, models.OrderCancelled, "alice", "")
+	assert.ErrorIs(t, err, shop.ErrInvalidTransition, "shipped orders are final")
+
+	var statuses []string
+	for _, tr := range shipped.History {
+		statuses = append(statuses, tr.From+">"+tr.To)
+	}
+	assert.Equal(t, []string{">pending", "pending>paid", "paid>shipped"}, statuses)
+	assert.Equal(t, "card", shipped.History[1].Reason)
+
+	cancelled := place()
+	assert.Equal(t, [3]int64{8, 2, 6}, f.level(t, mug))
+	cancelled, err = f.shop.Transition(cancelled.ID, models.OrderCancelled, "alice", "changed my mind")
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderCancelled, cancelled.Status)
+	assert.Equal(t, [3]int64{8, 0, 8}, f.level(t, mug), "cancelling releases the stock")
+
+	orders, err := f.shop.Orders("alice")
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, cancelled.ID, orders[0].ID, "newest first")
+}
+
+func TestAbandonCheckouts(t *testing.T) {
+	f := newFixture(t)
+	mug := f.product(t, "Mug", money.New(1250, "EUR"), 10)
+	pot := f.product(t, "Teapot", money.New(3000, "EUR"), 1)
+	started := time.Now()
+
+	// A checkout that reserved the mugs and went no further.
+	stuck := models.Order{
+		User:      "alice",
+		Items:     []models.OrderItem{{ProductID: mug.ID, Quantity: 3}, {ProductID: pot.ID, Quantity: 1}},
+		Status:    models.OrderPending,
+		Placing:   true,
+		Reserved:  1,
+		CreatedAt: started,
+	}
+	require.NoError(t, f.orders.Create(&stuck))
+	_, err := f.stock.Reserve(inventory.Change{ProductID: mug.ID, Quantity: 3, Reason: "order " + stuck.ID.Hex()})
+	require.NoError(t, err)
+
+	orders, err := f.shop.Orders("alice")
+	require.NoError(t, err)
+	assert.Empty(t, orders, "orders being placed are not listed")
+	_, err = f.shop.Transition(stuck.ID, models.OrderPaid, "payments", "")
+	assert.True(t, store.IsNotFound(err), "nor paid")
+
+	require.NoError(t, f.shop.AbandonCheckouts(context.Background(), started.Add(time.Minute)))
+	assert.Equal(t, [3]int64{10, 3, 7}, f.level(t, mug), "the checkout may still be running")
+
+	require.NoError(t, f.shop.AbandonCheckouts(context.Background(), started.Add(time.Hour)))
+	assert.Equal(t, [3]int64{10, 0, 10}, f.level(t, mug))
+	assert.Equal(t, [3]int64{1, 0, 1}, f.level(t, pot), "stock never reserved is not released")
+	assert.True(t, store.IsNotFound(f.orders.First(&models.Order{}, bson.M{"_id": stuck.ID})))
+}
+
+func TestCheckoutWithPromotions(t *testing.T) {
+	f := newFixture(t)
+	mug := f.product(t, "Mug", money.New(1000, "EUR"), 10)
+	now := time.Now()
+	require.NoError(t, f.promotions.Add(&models.Promotion{Name: "Three for two", Kind: models.PromotionBuyXGetY, Buy: 2, Get: 1, Stacking: models.PromotionStackable}, now))
+	require.NoError(t, f.promotions.Add(&models.Promotion{Name: "Welcome", Kind: models.PromotionPercent, Percent: 10, Code: "hello", Stacking: models.PromotionStackable, Priority: 1}, now))
+
+	_, err := f.shop.AddCoupon("alice", "nope")
+	assert.ErrorIs(t, err, promotion.ErrUnknownCoupon)
+	_, err = f.shop.AddItem("alice", mug.ID, 3)
+	require.NoError(t, err)
+	cart, err := f.shop.AddCoupon("alice", " Hello ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HELLO"}, cart.Coupons)
+
+	pricing, err := f.shop.Price(cart)
+	require.NoError(t, err)
+	// 10% off each of the three mugs, then one of them free at 9.00.
+	assert.Equal(t, money.New(3000, "EUR"), pricing.Subtotal)
+	assert.Equal(t, money.New(300+900, "EUR"), pricing.Discount)
+	assert.Equal(t, money.New(1800, "EUR"), pricing.Total)
+
+	order, err := f.shop.Checkout("alice")
+	require.NoError(t, err)
+	assert.Equal(t, money.New(1800, "EUR"), order.Total)
+	require.NotNil(t, order.Discount)
+	assert.Equal(t, int64(1200), order.Discount.Amount)
+	require.Len(t, order.Items[0].Promotions, 2)
+	assert.Equal(t, "HELLO", order.Items[0].Promotions[0].Code)
+
+	cart, err = f.shop.Cart("alice")
+	require.NoError(t, err)
+	assert.Empty(t, cart.Coupons, "coupons are used up by checkout")
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBulkAborted is returned by an atomic bulk write that was rolled back
+// because one of its ops failed.
+var ErrBulkAborted = errors.New("bulk write rolled back")
+
+type BulkOpKind string
+
+const (
+	BulkCreate BulkOpKind = "create"
+	// BulkUpdate replaces the whole record with the value's ID, like Save,
+	// but for the fields the op keeps; the record must exist. Callers updating some fields load the record
+	// and change those first.
+	BulkUpdate BulkOpKind = "update"
+	// BulkDelete deletes the record with the value's ID; the record must
+	// exist.
+	BulkDelete BulkOpKind = "delete"
+)
+
+// BulkOp is one write of a bulk request. Value is a pointer to a record.
+type BulkOp struct {
+	Kind  BulkOpKind
+	Value any
+	// Keep names top-level fields, as in filters, that a BulkUpdate leaves
+	// as stored rather than write back values read before other requests
+	// changed them.
+	Keep []string
+}
+
+type BulkOptions struct {
+	// Atomic applies every op or none of them. Without it every op is tried
+	// and succeeds or fails on its own.
+	Atomic bool
+}
+
+// BulkWriter is implemented by stores that can apply many writes in a few
+// round trips.
+type BulkWriter interface {
+	BulkWrite(ops []BulkOp, opts BulkOptions) ([]error, error)
+}
+
+// BulkWrite applies ops to s and returns the error of every op, in order. The
+// second error is set when the request failed as a whole: ErrBulkAborted
+// when an atomic write was rolled back, in which case the failing ops have
+// their own error, or any other error when no op could be tried.
+//
+// Stores that are not BulkWriters get one call per op and cannot write
+// atomically; their updates are plain Saves.
+func BulkWrite(s Storer, ops []BulkOp, opts BulkOptions) ([]error, error) {
+	if w, ok := s.(BulkWriter); ok {
+		return w.BulkWrite(ops, opts)
+	}
+	if opts.Atomic {
+		return nil, fmt.Errorf("store %T does not support atomic bulk writes", s)
+	}
+
+	errs := make([]error, len(ops))
+	for i, op := range ops {
+		switch op.Kind {
+		case BulkCreate:
+			errs[i] = s.Create(op.Value)
+		case BulkUpdate:
+			if len(op.Keep) > 0 {
+				errs[i] = fmt.Errorf("store %T cannot keep fields of bulk updates", s)
+				continue
+			}
+			errs[i] = s.Save(op.Value)
+		case BulkDelete:
+			errs[i] = Delete(s, op.Value)
+		default:
+			errs[i] = unknownBulkOp(op)
+		}
+	}
+	return errs, nil
+}
+
+// failed reports whether any op failed.
+func failed(errs []error) bool {
+	for _, err := range errs {
+		if err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func unknownBulkOp(op BulkOp) error {
+	return fmt.Errorf("unknown bulk op %q", op.Kind)
+}
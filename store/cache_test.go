@@ -0,0 +1,173 @@
+package store_test
+
+import (
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLRUCache(t *testing.T) {
+	t.Run("evicts the least recently used entry", func(t *testing.T) {
+		c := store.NewLRUCache(2)
+		c.Set("a", []byte("1"), 0)
+		c.Set("b", []byte("2"), 0)
+		c.Get("a")
+		c.Set("c", []byte("3"), 0)
+
+		_, ok := c.Get("b")
+		assert.False(t, ok)
+		v, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), v)
+		assert.Equal(t, 2, c.Len())
+	})
+
+	t.Run("expires entries after their ttl", func(t *testing.T) {
+		now := time.Now()
+		c := store.NewLRUCache(10)
+		c.Now = func() time.Time { return now }
+		c.Set("short", []byte("1"), time.Second)
+		c.Set("forever", []byte("2"), 0)
+
+		now = now.Add(time.Second)
+		_, ok := c.Get("short")
+		assert.False(t, ok)
+		_, ok = c.Get("forever")
+		assert.True(t, ok)
+		assert.Equal(t, 1, c.Len())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c := store.NewLRUCache(10)
+		c.Set("a", []byte("1"), 0)
+		c.Delete("a")
+
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+	})
+}
+
+func newCachingStore(next store.Storer) (*store.CachingStore, *store.LRUCache) {
+	cache := store.NewLRUCache(100)
+	return store.NewCachingStore(next, cache, store.DefaultCacheOptions("")), cache
+}
+
+func TestCachingStoreFirst(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	t.Run("reads through once", func(t *testing.T) {
+		mock := &store.MockStore{}
+		mock.On("First").ReturnData(ProductMock{ID: id, Name: "Laptop", Tags: []string{"a"}})
+		s, _ := newCachingStore(mock)
+
+		for i := 0; i < 3; i++ {
+			var p ProductMock
+			require.NoError(t, s.First(&p, bson.M{"_id": id}))
+			assert.Equal(t, ProductMock{ID: id, Name: "Laptop", Tags: []string{"a"}}, p)
+		}
+		mock.AssertNumberOfCalls(t, "First", 1)
+
+		require.NoError(t, s.First(&ProductMock{}, bson.M{"name": "Laptop"}))
+		mock.AssertNumberOfCalls(t, "First", 2)
+	})
+
+	t.Run("caches not found", func(t *testing.T) {
+		now := time.Now()
+		mock := &store.MockStore{Err: store.ErrNotFound}
+		s, cache := newCachingStore(mock)
+		cache.Now = func() time.Time { return now }
+
+		assert.True(t, store.IsNotFound(s.First(&ProductMock{}, bson.M{"_id": id})))
+		assert.True(t, store.IsNotFound(s.First(&ProductMock{}, bson.M{"_id": id})))
+		mock.AssertNumberOfCalls(t, "First", 1)
+
+		now = now.Add(store.DefaultCacheOptions("").NegativeTTL)
+		assert.True(t, store.IsNotFound(s.First(&ProductMock{}, bson.M{"_id": id})))
+		mock.AssertNumberOfCalls(t, "First", 2)
+	})
+
+	t.Run("does not cache errors", func(t *testing.T) {
+		mock := &store.MockStore{Err: store.ErrInjected}
+		s, _ := newCachingStore(mock)
+
+		assert.ErrorIs(t, s.First(&ProductMock{}), store.ErrInjected)
+		assert.ErrorIs(t, s.First(&ProductMock{}), store.ErrInjected)
+		mock.AssertNumberOfCalls(t, "First", 2)
+	})
+
+	t.Run("passes through a filled dest", func(t *testing.T) {
+		mock := &store.MockStore{}
+		s, _ := newCachingStore(mock)
+
+		require.NoError(t, s.First(&User{ID: 1}))
+		require.NoError(t, s.First(&User{ID: 1}))
+		mock.AssertNumberOfCalls(t, "First", 2)
+	})
+}
+
+func TestCachingStoreInvalidation(t *testing.T) {
+	s, _ := newCachingStore(store.NewMemoryStore())
+
+	p := ProductMock{Name: "Laptop"}
+	require.NoError(t, s.Create(&p))
+	var cached ProductMock
+	require.NoError(t, s.First(&cached, bson.M{"name": "Laptop"}))
+
+	p.Price = 100
+	require.NoError(t, s.Save(&p))
+	var saved ProductMock
+	require.NoError(t, s.First(&saved, bson.M{"name": "Laptop"}))
+	assert.Equal(t, 100, saved.Price)
+
+	require.NoError(t, store.Delete(s, &p))
+	assert.True(t, store.IsNotFound(s.First(&ProductMock{}, bson.M{"name": "Laptop"})))
+
+	require.NoError(t, s.Create(&ProductMock{Name: "Laptop"}))
+	assert.NoError(t, s.First(&ProductMock{}, bson.M{"name": "Laptop"}), "negative entry was invalidated")
+}
+
+func TestCachingStoreSharesConcurrentMisses(t *testing.T) {
+	mock := &store.MockStore{}
+	mock.On("First").ReturnData(UserMock{Name: "Alice"})
+	release := make(chan struct{})
+	slow := store.NewFaultStore(mock)
+	slow.Set("First", store.Fault{Latency: time.Second})
+	slow.Sleep = func(time.Duration) { <-release }
+	s, _ := newCachingStore(slow)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var u UserMock
+			assert.NoError(t, s.First(&u, bson.M{"name": "Alice"}))
+			assert.Equal(t, "Alice", u.Name)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	mock.AssertNumberOfCalls(t, "First", 1)
+}
+
+func TestCachingStoreMetrics(t *testing.T) {
+	mock := &store.MockStore{Data: UserMock{Name: "Alice"}}
+	s := store.NewCachingStore(mock, store.NewLRUCache(10), store.DefaultCacheOptions("cache_metrics_test"))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.First(&UserMock{}))
+	}
+
+	vars := expvar.Get("store").(*expvar.Map)
+	assert.Equal(t, "2", vars.Get("cache_metrics_test.cache_hits").String())
+	assert.Equal(t, "1", vars.Get("cache_metrics_test.cache_misses").String())
+}
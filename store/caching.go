@@ -0,0 +1,197 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type CacheOptions struct {
+	// Name prefixes the cache keys and identifies the store in the metrics
+	// published under "store".
+	Name string
+	// TTL bounds how long a record is served from the cache.
+	TTL time.Duration
+	// NegativeTTL is how long a lookup that found nothing is remembered;
+	// 0 disables negative caching.
+	NegativeTTL time.Duration
+}
+
+func DefaultCacheOptions(name string) CacheOptions {
+	return CacheOptions{
+		Name:        name,
+		TTL:         5 * time.Minute,
+		NegativeTTL: 30 * time.Second,
+	}
+}
+
+// CachingStore serves First from a Cache and reads through to the wrapped
+// store on a miss. Concurrent misses for the same key share one lookup, and
+// lookups that find nothing are cached for NegativeTTL.
+//
+// Every write of a model type moves that type to a new generation, which is
+// part of every key, so a Create, Save, Delete or Truncate invalidates all
+// cached lookups of the type whatever conditions they used. Find is not
+// cached.
+type CachingStore struct {
+	next  Storer
+	cache Cache
+	opts  CacheOptions
+	group singleflight.Group
+}
+
+func NewCachingStore(next Storer, cache Cache, opts CacheOptions) *CachingStore {
+	return &CachingStore{
+		next:  next,
+		cache: cache,
+		opts:  opts,
+	}
+}
+
+func (s *CachingStore) count(metric string) {
+	if s.opts.Name != "" {
+		metrics.Add(s.opts.Name+"."+metric, 1)
+	}
+}
+
+func (s *CachingStore) prefix(t reflect.Type) string {
+	return s.opts.Name + ":" + t.PkgPath() + "." + t.Name() + ":"
+}
+
+// generation returns the current generation of t, starting one if the cache
+// has none.
+func (s *CachingStore) generation(t reflect.Type) string {
+	key := s.prefix(t) + "gen"
+	if gen, ok := s.cache.Get(key); ok {
+		return string(gen)
+	}
+	return s.invalidate(t)
+}
+
+func (s *CachingStore) invalidate(t reflect.Type) string {
+	gen := strconv.FormatUint(rand.Uint64(), 36)
+	s.cache.Set(s.prefix(t)+"gen", []byte(gen), 0)
+	return gen
+}
+
+func (s *CachingStore) key(t reflect.Type, conds []any) string {
+	return s.prefix(t) + s.generation(t) + ":" + fmt.Sprintf("%v", conds)
+}
+
+// First answers from the cache when it can. A dest that is not a zero struct
+// may carry conditions of its own, as with gorm, and is passed through.
+func (s *CachingStore) First(dest any, conds ...any) error {
+	val, err := structPointer(dest)
+	if err != nil || !val.IsZero() {
+		return s.next.First(dest, conds...)
+	}
+
+	key := s.key(val.Type(), conds)
+	if b, ok := s.cache.Get(key); ok {
+		s.count("cache_hits")
+		return decodeCached(b, val)
+	}
+	s.count("cache_misses")
+
+	b, err, _ := s.group.Do(key, func() (any, error) {
+		record := reflect.New(val.Type())
+		err := s.next.First(record.Interface(), conds...)
+		if IsNotFound(err) && s.opts.NegativeTTL > 0 {
+			s.cache.Set(key, []byte{}, s.opts.NegativeTTL)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(record.Interface()); err != nil {
+			return nil, fmt.Errorf("cache %s: %w", val.Type(), err)
+		}
+		s.cache.Set(key, buf.Bytes(), s.opts.TTL)
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		return err
+	}
+	return decodeCached(b.([]byte), val)
+}
+
+// decodeCached fills val from a cache entry. An empty entry records that
+// nothing was found.
+func decodeCached(b []byte, val reflect.Value) error {
+	if len(b) == 0 {
+		return ErrNotFound
+	}
+	val.Set(reflect.Zero(val.Type()))
+	return gob.NewDecoder(bytes.NewReader(b)).DecodeValue(val)
+}
+
+func (s *CachingStore) Find(dest any, conds ...any) error {
+	return s.next.Find(dest, conds...)
+}
+
+func (s *CachingStore) Create(value any) error {
+	defer s.invalidateModel(value)
+	return s.next.Create(value)
+}
+
+func (s *CachingStore) Save(value any) error {
+	defer s.invalidateModel(value)
+	return s.next.Save(value)
+}
+
+func (s *CachingStore) Delete(value any, conds ...any) error {
+	defer s.invalidateModel(value)
+	return Delete(s.next, value, conds...)
+}
+
+func (s *CachingStore) Update(model any, set map[string]any, conds ...any) error {
+	defer s.invalidateModel(model)
+	return Update(s.next, model, set, conds...)
+}
+
+func (s *CachingStore) BulkWrite(ops []BulkOp, opts BulkOptions) ([]error, error) {
+	defer func() {
+		seen := map[reflect.Type]bool{}
+		for _, op := range ops {
+			if t := reflect.TypeOf(op.Value); !seen[t] {
+				seen[t] = true
+				s.invalidateModel(op.Value)
+			}
+		}
+	}()
+	return BulkWrite(s.next, ops, opts)
+}
+
+func (s *CachingStore) Each(dest any, fn func() error, conds ...any) error {
+	return Each(s.next, dest, fn, conds...)
+}
+
+func (s *CachingStore) Facets(model any, fields []string, conds ...any) (map[string][]FacetCount, error) {
+	return Facets(s.next, model, fields, conds...)
+}
+
+func (s *CachingStore) Truncate(model any) error {
+	defer s.invalidateModel(model)
+	return Truncate(s.next, model)
+}
+
+// invalidateModel starts a new generation for the type of value, whether or
+// not the write succeeded: a failed write may still have reached the
+// database.
+func (s *CachingStore) invalidateModel(value any) {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return
+	}
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	s.invalidate(t)
+}
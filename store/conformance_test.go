@@ -0,0 +1,136 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/db"
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/store"
+	"github.com/sing3demons/go-example/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func newSQLiteStore(t *testing.T) store.Storer {
+	gdb, err := db.Open("sqlite://:memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, _ := gdb.DB()
+		sqlDB.Close()
+	})
+	return store.NewGormStore(gdb)
+}
+
+// gormBackends returns the databases the gorm store is checked against.
+// SQLite always runs; postgres runs when TEST_DATABASE_URL is set.
+func gormBackends() map[string]func(t *testing.T) store.Storer {
+	backends := map[string]func(t *testing.T) store.Storer{
+		"sqlite": newSQLiteStore,
+	}
+
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		backends["postgres"] = func(t *testing.T) store.Storer {
+			gdb, err := db.Open(dsn)
+			require.NoError(t, err)
+			s := store.NewGormStore(gdb)
+			require.NoError(t, store.Truncate(s, &models.Todo{}))
+			return s
+		}
+	}
+
+	return backends
+}
+
+func TestGormStoreConformance(t *testing.T) {
+	for name, newStore := range gormBackends() {
+		t.Run(name, func(t *testing.T) {
+			storetest.Run(t, storetest.Todos(newStore))
+		})
+	}
+}
+
+func TestGormStoreTruncateRestartsIDs(t *testing.T) {
+	for name, newStore := range gormBackends() {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			require.NoError(t, s.Create(&models.Todo{Title: "a"}))
+			require.NoError(t, store.Truncate(s, &models.Todo{}))
+
+			todo := models.Todo{Title: "b"}
+			require.NoError(t, s.Create(&todo))
+			assert.Equal(t, uint(1), todo.ID)
+		})
+	}
+}
+
+func TestGormStoreBulkUpdateKeepsCreatedAt(t *testing.T) {
+	s := newSQLiteStore(t)
+	todo := models.Todo{Title: "a"}
+	require.NoError(t, s.Create(&todo))
+	createdAt := todo.CreatedAt
+
+	update := models.Todo{Title: "b", Completed: true}
+	update.ID = todo.ID
+	errs, err := store.BulkWrite(s, []store.BulkOp{{Kind: store.BulkUpdate, Value: &update}}, store.BulkOptions{})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+
+	var saved models.Todo
+	require.NoError(t, s.First(&saved, todo.ID))
+	assert.Equal(t, "b", saved.Title)
+	assert.True(t, saved.Completed)
+	assert.True(t, createdAt.Equal(saved.CreatedAt), "created_at changed to %v", saved.CreatedAt)
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	newStore := func(t *testing.T) store.Storer { return store.NewMemoryStore() }
+
+	t.Run("todos", func(t *testing.T) { storetest.Run(t, storetest.Todos(newStore)) })
+	t.Run("products", func(t *testing.T) { storetest.Run(t, storetest.Products(newStore)) })
+}
+
+func TestFaultStoreConformance(t *testing.T) {
+	newStore := func(t *testing.T) store.Storer { return store.NewFaultStore(store.NewMemoryStore()) }
+
+	storetest.Run(t, storetest.Products(newStore))
+}
+
+func TestCachingStoreConformance(t *testing.T) {
+	newStore := func(t *testing.T) store.Storer {
+		return store.NewCachingStore(store.NewMemoryStore(), store.NewLRUCache(100), store.DefaultCacheOptions(""))
+	}
+
+	t.Run("todos", func(t *testing.T) { storetest.Run(t, storetest.Todos(newStore)) })
+	t.Run("products", func(t *testing.T) { storetest.Run(t, storetest.Products(newStore)) })
+}
+
+// TestMongoStoreConformance runs when TEST_MONGO_URL points at a server.
+// Every test gets its own collection, dropped afterwards.
+func TestMongoStoreConformance(t *testing.T) {
+	uri := os.Getenv("TEST_MONGO_URL")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URL is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	defer client.Disconnect(context.Background())
+
+	n := 0
+	newStore := func(t *testing.T) store.Storer {
+		n++
+		col := client.Database("go-example-test").Collection(fmt.Sprintf("products_%d_%d", time.Now().UnixNano(), n))
+		t.Cleanup(func() { col.Drop(context.Background()) })
+		return store.NewMongoStore(col)
+	}
+
+	storetest.Run(t, storetest.Products(newStore))
+}
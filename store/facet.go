@@ -0,0 +1,141 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// FacetCount is the number of records having one value of a facet.
+type FacetCount struct {
+	Value any   `json:"value"`
+	Count int64 `json:"count"`
+}
+
+// Faceter is implemented by stores that count field values in the database,
+// like mongo's $facet.
+type Faceter interface {
+	// Facets counts, for each of fields, how many records of model's type
+	// matching conds have each value. An array counts each of its elements,
+	// and a document counts each of its keys as a facet of its own, named
+	// field.key. Numbers of any type are counted as float64.
+	Facets(model any, fields []string, conds ...any) (map[string][]FacetCount, error)
+}
+
+// Facets counts the values of fields in s; see Faceter. Stores that are not
+// Faceters stream every matching record through Each to count them. Counts
+// are ordered by count, highest first, then by value.
+func Facets(s Storer, model any, fields []string, conds ...any) (map[string][]FacetCount, error) {
+	if f, ok := s.(Faceter); ok {
+		facets, err := f.Facets(model, fields, conds...)
+		if err != nil {
+			return nil, err
+		}
+		sortFacets(facets)
+		return facets, nil
+	}
+
+	val, err := structPointer(model)
+	if err != nil {
+		return nil, err
+	}
+	dest := reflect.New(val.Type()).Interface()
+
+	counts := facetCounter{}
+	err = Each(s, dest, func() error {
+		raw, err := bson.Marshal(dest)
+		if err != nil {
+			return err
+		}
+		for _, field := range fields {
+			v, err := bson.Raw(raw).LookupErr(strings.Split(field, ".")...)
+			if err != nil {
+				continue
+			}
+			counts.add(field, v, true)
+		}
+		return nil
+	}, conds...)
+	if err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+
+	facets := map[string][]FacetCount{}
+	for name, values := range counts {
+		for value, n := range values {
+			facets[name] = append(facets[name], FacetCount{Value: value, Count: n})
+		}
+	}
+	sortFacets(facets)
+	return facets, nil
+}
+
+// facetCounter counts facet values by facet name.
+type facetCounter map[string]map[any]int64
+
+func (c facetCounter) add(name string, v bson.RawValue, top bool) {
+	switch v.Type {
+	case bsontype.Array:
+		values, _ := v.Array().Values()
+		for _, e := range values {
+			if e.Type != bsontype.Array {
+				c.add(name, e, top)
+			}
+		}
+	case bsontype.EmbeddedDocument:
+		if !top {
+			return
+		}
+		elems, _ := v.Document().Elements()
+		for _, e := range elems {
+			c.add(name+"."+e.Key(), e.Value(), false)
+		}
+	default:
+		value, ok := facetValue(v)
+		if !ok {
+			return
+		}
+		if c[name] == nil {
+			c[name] = map[any]int64{}
+		}
+		c[name][value]++
+	}
+}
+
+// facetValue is the comparable Go value of a scalar, with numbers as float64.
+func facetValue(v bson.RawValue) (any, bool) {
+	switch v.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return nil, false
+	case bsontype.Double:
+		return v.Double(), true
+	case bsontype.Int32:
+		return float64(v.Int32()), true
+	case bsontype.Int64:
+		return float64(v.Int64()), true
+	}
+
+	var value any
+	if err := v.Unmarshal(&value); err != nil {
+		return nil, false
+	}
+	if t := reflect.TypeOf(value); t == nil || !t.Comparable() {
+		return nil, false
+	}
+	return value, true
+}
+
+func sortFacets(facets map[string][]FacetCount) {
+	for _, counts := range facets {
+		sort.Slice(counts, func(i, j int) bool {
+			if counts[i].Count != counts[j].Count {
+				return counts[i].Count > counts[j].Count
+			}
+			return fmt.Sprint(counts[i].Value) < fmt.Sprint(counts[j].Value)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type facetedItem struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Kind       string             `bson:"kind"`
+	Tags       []string           `bson:"tags,omitempty"`
+	Attributes map[string]any     `bson:"attributes,omitempty"`
+}
+
+// facetBackends returns the stores Facets is checked against: the memory
+// store, counted by streaming, and mongo's aggregation when TEST_MONGO_URL is
+// set.
+func facetBackends(t *testing.T) map[string]store.Storer {
+	backends := map[string]store.Storer{
+		"memory":  store.NewMemoryStore(),
+		"caching": store.NewCachingStore(store.NewMemoryStore(), store.NewLRUCache(10), store.DefaultCacheOptions("")),
+	}
+
+	if uri := os.Getenv("TEST_MONGO_URL"); uri != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		require.NoError(t, err)
+		t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+		col := client.Database("go-example-test").Collection(fmt.Sprintf("facets_%d", time.Now().UnixNano()))
+		t.Cleanup(func() { col.Drop(context.Background()) })
+		backends["mongo"] = store.NewMongoStore(col)
+	}
+
+	return backends
+}
+
+func TestFacets(t *testing.T) {
+	for name, s := range facetBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, item := range []facetedItem{
+				{Kind: "shirt", Tags: []string{"cotton", "summer"}, Attributes: map[string]any{"size": "M", "color": "red"}},
+				{Kind: "shirt", Tags: []string{"cotton"}, Attributes: map[string]any{"size": "L", "color": "red"}},
+				{Kind: "shoe", Tags: []string{"leather"}, Attributes: map[string]any{"size": 42.0}},
+				{Kind: "shoe"},
+			} {
+				item := item
+				require.NoError(t, s.Create(&item))
+			}
+
+			facets, err := store.Facets(s, &facetedItem{}, []string{"kind", "tags", "attributes"}, bson.M{})
+			require.NoError(t, err)
+			assert.Equal(t, map[string][]store.FacetCount{
+				"kind":             {{Value: "shirt", Count: 2}, {Value: "shoe", Count: 2}},
+				"tags":             {{Value: "cotton", Count: 2}, {Value: "leather", Count: 1}, {Value: "summer", Count: 1}},
+				"attributes.size":  {{Value: 42.0, Count: 1}, {Value: "L", Count: 1}, {Value: "M", Count: 1}},
+				"attributes.color": {{Value: "red", Count: 2}},
+			}, facets)
+
+			facets, err = store.Facets(s, &facetedItem{}, []string{"tags", "attributes"}, bson.M{"attributes.color": "red"})
+			require.NoError(t, err)
+			assert.Equal(t, []store.FacetCount{{Value: "cotton", Count: 2}, {Value: "summer", Count: 1}}, facets["tags"])
+			assert.Equal(t, []store.FacetCount{{Value: "L", Count: 1}, {Value: "M", Count: 1}}, facets["attributes.size"])
+
+			facets, err = store.Facets(s, &facetedItem{}, []string{"tags"}, bson.M{"kind": "hat"})
+			require.NoError(t, err)
+			assert.Empty(t, facets)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is returned by FaultStore when a fault names no specific error.
+var ErrInjected = errors.New("injected fault")
+
+// Fault describes how calls to a method misbehave.
+type Fault struct {
+	// Latency is added before every call.
+	Latency time.Duration
+	// ErrorRate is the probability, from 0 to 1, that a call fails.
+	ErrorRate float64
+	// Err is the error a failing call returns; see FaultError.
+	Err string
+	// Partial lets a failing call reach the wrapped store before the error
+	// is returned, like a write whose acknowledgement was lost.
+	Partial bool
+}
+
+// FaultError maps the names used in Fault.Err to errors: "timeout",
+// "not_found" and "duplicate_key" return the errors the real stores would,
+// an empty name returns ErrInjected and anything else becomes the message.
+func FaultError(name string) error {
+	switch name {
+	case "":
+		return ErrInjected
+	case "timeout":
+		return context.DeadlineExceeded
+	case "not_found":
+		return ErrNotFound
+	case "duplicate_key":
+		return ErrDuplicateKey
+	}
+	return errors.New(name)
+}
+
+// FaultStore wraps a Storer and injects the faults configured per method.
+// A fault set for "*" applies to methods without their own.
+type FaultStore struct {
+	next Storer
+
+	// Rand and Sleep default to math/rand and time.Sleep; tests replace them.
+	Rand  func() float64
+	Sleep func(time.Duration)
+
+	mu     sync.RWMutex
+	faults map[string]Fault
+}
+
+func NewFaultStore(next Storer) *FaultStore {
+	return &FaultStore{
+		next:   next,
+		Rand:   rand.Float64,
+		Sleep:  time.Sleep,
+		faults: map[string]Fault{},
+	}
+}
+
+func (f *FaultStore) Set(method string, fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults[method] = fault
+}
+
+// Clear removes the fault of method, or every fault when method is empty.
+func (f *FaultStore) Clear(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if method == "" {
+		f.faults = map[string]Fault{}
+		return
+	}
+	delete(f.faults, method)
+}
+
+func (f *FaultStore) Faults() map[string]Fault {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	faults := make(map[string]Fault, len(f.faults))
+	for method, fault := range f.faults {
+		faults[method] = fault
+	}
+	return faults
+}
+
+func (f *FaultStore) inject(method string, call func() error) error {
+	f.mu.RLock()
+	fault, ok := f.faults[method]
+	if !ok {
+		fault, ok = f.faults["*"]
+	}
+	f.mu.RUnlock()
+
+	if !ok {
+		return call()
+	}
+
+	if fault.Latency > 0 {
+		f.Sleep(fault.Latency)
+	}
+
+	if fault.ErrorRate <= 0 || f.Rand() >= fault.ErrorRate {
+		return call()
+	}
+
+	if fault.Partial {
+		if err := call(); err != nil {
+			return err
+		}
+	}
+	return FaultError(fault.Err)
+}
+
+func (f *FaultStore) Find(dest any, conds ...any) error {
+	return f.inject("Find", func() error { return f.next.Find(dest, conds...) })
+}
+
+func (f *FaultStore) Create(value any) error {
+	return f.inject("Create", func() error { return f.next.Create(value) })
+}
+
+func (f *FaultStore) First(dest any, conds ...any) error {
+	return f.inject("First", func() error { return f.next.First(dest, conds...) })
+}
+
+func (f *FaultStore) Save(value any) error {
+	return f.inject("Save", func() error { return f.next.Save(value) })
+}
+
+func (f *FaultStore) Update(model any, set map[string]any, conds ...any) error {
+	return f.inject("Update", func() error { return Update(f.next, model, set, conds...) })
+}
+
+func (f *FaultStore) Delete(value any, conds ...any) error {
+	return f.inject("Delete", func() error { return Delete(f.next, value, conds...) })
+}
+
+func (f *FaultStore) BulkWrite(ops []BulkOp, opts BulkOptions) ([]error, error) {
+	var errs []error
+	err := f.inject("BulkWrite", func() error {
+		var err error
+		errs, err = BulkWrite(f.next, ops, opts)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrBulkAborted) {
+		return nil, err
+	}
+	return errs, err
+}
+
+func (f *FaultStore) Each(dest any, fn func() error, conds ...any) error {
+	return f.inject("Each", func() error { return Each(f.next, dest, fn, conds...) })
+}
+
+func (f *FaultStore) Facets(model any, fields []string, conds ...any) (map[string][]FacetCount, error) {
+	var facets map[string][]FacetCount
+	err := f.inject("Facets", func() error {
+		var err error
+		facets, err = Facets(f.next, model, fields, conds...)
+		return err
+	})
+	return facets, err
+}
+
+func (f *FaultStore) Truncate(model any) error {
+	return f.inject("Truncate", func() error { return Truncate(f.next, model) })
+}
@@ -0,0 +1,106 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFaultStore(next store.Storer, roll float64) (*store.FaultStore, *time.Duration) {
+	slept := new(time.Duration)
+	f := store.NewFaultStore(next)
+	f.Rand = func() float64 { return roll }
+	f.Sleep = func(d time.Duration) { *slept += d }
+	return f, slept
+}
+
+func TestFaultStorePassesThrough(t *testing.T) {
+	mock := &store.MockStore{Data: User{Name: "Alice"}}
+	f, slept := newFaultStore(mock, 0)
+
+	var u User
+	assert.NoError(t, f.First(&u, 1))
+	assert.Equal(t, "Alice", u.Name)
+	assert.Zero(t, *slept)
+	mock.AssertCalled(t, "First", 1)
+}
+
+func TestFaultStoreInjectsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  string
+		want error
+	}{
+		{"timeout", "timeout", context.DeadlineExceeded},
+		{"not found", "not_found", store.ErrNotFound},
+		{"duplicate key", "duplicate_key", store.ErrDuplicateKey},
+		{"default", "", store.ErrInjected},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &store.MockStore{}
+			f, _ := newFaultStore(mock, 0.1)
+			f.Set("Create", store.Fault{ErrorRate: 0.5, Err: tc.err})
+
+			assert.ErrorIs(t, f.Create(&User{}), tc.want)
+			assert.NoError(t, f.Save(&User{}))
+			mock.AssertNotCalled(t, "Create")
+		})
+	}
+
+	t.Run("custom message", func(t *testing.T) {
+		f, _ := newFaultStore(&store.MockStore{}, 0)
+		f.Set("Find", store.Fault{ErrorRate: 1, Err: "connection reset"})
+		assert.EqualError(t, f.Find(&[]User{}), "connection reset")
+	})
+}
+
+func TestFaultStoreErrorRate(t *testing.T) {
+	f, _ := newFaultStore(&store.MockStore{}, 0.7)
+	f.Set("*", store.Fault{ErrorRate: 0.5})
+
+	assert.NoError(t, f.First(&User{}), "a roll above the rate succeeds")
+
+	f.Rand = func() float64 { return 0.2 }
+	assert.ErrorIs(t, f.First(&User{}), store.ErrInjected)
+}
+
+func TestFaultStoreLatency(t *testing.T) {
+	f, slept := newFaultStore(&store.MockStore{}, 0)
+	f.Set("Find", store.Fault{Latency: 200 * time.Millisecond})
+
+	assert.NoError(t, f.Find(&[]User{}))
+	assert.NoError(t, f.Find(&[]User{}))
+	assert.Equal(t, 400*time.Millisecond, *slept)
+}
+
+func TestFaultStorePartialFailure(t *testing.T) {
+	mem := store.NewMemoryStore()
+	f, _ := newFaultStore(mem, 0)
+	f.Set("Create", store.Fault{ErrorRate: 1, Err: "timeout", Partial: true})
+
+	err := f.Create(&TodoMock{Title: "written anyway"})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	var todos []TodoMock
+	assert.NoError(t, mem.Find(&todos))
+	assert.Len(t, todos, 1)
+}
+
+func TestFaultStoreClear(t *testing.T) {
+	f, _ := newFaultStore(&store.MockStore{}, 0)
+	f.Set("Find", store.Fault{ErrorRate: 1})
+	f.Set("Save", store.Fault{ErrorRate: 1})
+
+	f.Clear("Find")
+	assert.NoError(t, f.Find(&[]User{}))
+	assert.Len(t, f.Faults(), 1)
+
+	f.Clear("")
+	assert.Empty(t, f.Faults())
+}
@@ -1,6 +1,13 @@
 package store
 
-import "gorm.io/gorm"
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const bulkBatchSize = 100
 
 func NewGormStore(db *gorm.DB) Storer {
 	return &gormStore{
@@ -22,3 +29,157 @@ func (s *gormStore) First(dest any, conds ...any) error {
 func (s *gormStore) Save(value any) error {
 	return s.db.Save(value).Error
 }
+
+func (s *gormStore) Delete(value any, conds ...any) error {
+	return s.db.Delete(value, conds...).Error
+}
+
+// Each reads the rows with a single query and scans them one at a time.
+// Update runs one UPDATE of the rows matching conds, which gorm requires.
+func (s *gormStore) Update(model any, set map[string]any, conds ...any) error {
+	tx := s.db.Model(model)
+	if len(conds) > 0 {
+		tx = tx.Where(conds[0], conds[1:]...)
+	}
+	r := tx.Updates(set)
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *gormStore) Each(dest any, fn func() error, conds ...any) error {
+	val, err := structPointer(dest)
+	if err != nil {
+		return err
+	}
+
+	tx := s.db.Model(dest)
+	if len(conds) > 0 {
+		tx = tx.Where(conds[0], conds[1:]...)
+	}
+
+	rows, err := tx.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		val.Set(reflect.Zero(val.Type()))
+		if err := s.db.ScanRows(rows, dest); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Truncate removes every row of model's table and restarts its IDs from 1.
+func (s *gormStore) Truncate(model any) error {
+	stmt := &gorm.Statement{DB: s.db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	table := stmt.Schema.Table
+
+	if s.db.Dialector.Name() == "postgres" {
+		return s.db.Exec("TRUNCATE TABLE ? RESTART IDENTITY CASCADE", clause.Table{Name: table}).Error
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(model).Error; err != nil {
+			return err
+		}
+		if s.db.Dialector.Name() == "sqlite" && tx.Migrator().HasTable("sqlite_sequence") {
+			return tx.Exec("DELETE FROM sqlite_sequence WHERE name = ?", table).Error
+		}
+		return nil
+	})
+}
+
+// BulkWrite inserts consecutive creates of the same type with CreateInBatches
+// and runs updates and deletes one statement each. A batch that fails is
+// retried one record at a time to find the records at fault. In atomic mode
+// everything runs in one transaction, with a savepoint around every
+// statement so that a failure does not end the transaction early.
+func (s *gormStore) BulkWrite(ops []BulkOp, opts BulkOptions) ([]error, error) {
+	errs := make([]error, len(ops))
+	if !opts.Atomic {
+		bulkWriteGorm(s.db, ops, errs)
+		return errs, nil
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		bulkWriteGorm(tx, ops, errs)
+		if failed(errs) {
+			return ErrBulkAborted
+		}
+		return nil
+	})
+	if err != nil && !failed(errs) {
+		return nil, err
+	}
+	return errs, err
+}
+
+func bulkWriteGorm(db *gorm.DB, ops []BulkOp, errs []error) {
+	for i := 0; i < len(ops); {
+		op := ops[i]
+		if op.Kind != BulkCreate {
+			errs[i] = db.Transaction(func(tx *gorm.DB) error { return writeOneGorm(tx, op) })
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(ops) && ops[j].Kind == BulkCreate && reflect.TypeOf(ops[j].Value) == reflect.TypeOf(op.Value) {
+			j++
+		}
+		createBatchGorm(db, ops[i:j], errs[i:j])
+		i = j
+	}
+}
+
+func createBatchGorm(db *gorm.DB, ops []BulkOp, errs []error) {
+	values := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(ops[0].Value)), 0, len(ops))
+	for _, op := range ops {
+		values = reflect.Append(values, reflect.ValueOf(op.Value))
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(values.Interface(), bulkBatchSize).Error
+	})
+	if err == nil {
+		return
+	}
+
+	for k, op := range ops {
+		errs[k] = db.Transaction(func(tx *gorm.DB) error { return tx.Create(op.Value).Error })
+	}
+}
+
+func writeOneGorm(tx *gorm.DB, op BulkOp) error {
+	var r *gorm.DB
+	switch op.Kind {
+	case BulkUpdate:
+		r = tx.Model(op.Value).Select("*").Omit(append([]string{"CreatedAt"}, op.Keep...)...).Updates(op.Value)
+	case BulkDelete:
+		r = tx.Delete(op.Value)
+	default:
+		return unknownBulkOp(op)
+	}
+
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
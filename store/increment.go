@@ -0,0 +1,23 @@
+package store
+
+import "fmt"
+
+// Incrementer is implemented by stores that can add to the number fields
+// of a record in one atomic step, like mongo's $inc.
+type Incrementer interface {
+	// Increment adds inc to the fields, named as in filters, of the first
+	// record of dest's type matching filter, and decodes the result into
+	// dest, a pointer to a struct. When no record matches it returns an
+	// error for which IsNotFound is true, so a filter such as
+	// {"available": {"$gte": 2}} makes the update conditional.
+	Increment(dest any, filter any, inc map[string]int64) error
+}
+
+// Increment adds inc to the record of s matching filter; see Incrementer.
+func Increment(s Storer, dest any, filter any, inc map[string]int64) error {
+	i, ok := s.(Incrementer)
+	if !ok {
+		return fmt.Errorf("store %T does not support increment", s)
+	}
+	return i.Increment(dest, filter, inc)
+}
@@ -0,0 +1,1122 @@
+package store
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gorm.io/gorm"
+)
+
+// memoryStore keeps records in process memory, one table per struct type.
+// It understands the conditions the controllers pass to the other stores:
+// bson filters as used with mongoStore, and primary keys or simple
+// "column = ?" clauses as used with gormStore. Like gorm, it soft deletes
+// records with a gorm.DeletedAt field: they keep their ID, and no query or
+// write sees them any more.
+type memoryStore struct {
+	mu     sync.RWMutex
+	tables map[reflect.Type]*memoryTable
+}
+
+type memoryTable struct {
+	rows   []reflect.Value
+	nextID uint64
+}
+
+func NewMemoryStore() Storer {
+	return &memoryStore{
+		tables: map[reflect.Type]*memoryTable{},
+	}
+}
+
+func (s *memoryStore) table(t reflect.Type) *memoryTable {
+	tbl, ok := s.tables[t]
+	if !ok {
+		tbl = &memoryTable{}
+		s.tables[t] = tbl
+	}
+	return tbl
+}
+
+// rows returns the records of type t. Callers must hold at least the read lock.
+func (s *memoryStore) rows(t reflect.Type) []reflect.Value {
+	if tbl, ok := s.tables[t]; ok {
+		return tbl.rows
+	}
+	return nil
+}
+
+func (s *memoryStore) Find(dest any, conds ...any) error {
+	slice, err := slicePointer(dest)
+	if err != nil {
+		return err
+	}
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	match, err := compileConds(structType, conds)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := reflect.MakeSlice(slice.Type(), 0, 0)
+	for _, row := range s.rows(structType) {
+		if !match(row) {
+			continue
+		}
+		if elemType.Kind() == reflect.Ptr {
+			out = reflect.Append(out, copyRow(row).Addr())
+		} else {
+			out = reflect.Append(out, copyRow(row))
+		}
+	}
+	slice.Set(out)
+
+	return nil
+}
+
+func (s *memoryStore) First(dest any, conds ...any) error {
+	val, err := structPointer(dest)
+	if err != nil {
+		return err
+	}
+
+	match, err := compileConds(val.Type(), conds)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, row := range s.rows(val.Type()) {
+		if match(row) {
+			val.Set(copyRow(row))
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (s *memoryStore) Create(value any) error {
+	val, err := structPointer(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.table(val.Type()).insert(val)
+}
+
+// Save replaces the record with the same ID, or creates it when there is
+// none, like gorm's Save.
+func (s *memoryStore) Save(value any) error {
+	val, err := structPointer(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tbl := s.table(val.Type())
+	if tbl.indexOf(idOf(val)) < 0 {
+		return tbl.insert(val)
+	}
+	return tbl.replace(val)
+}
+
+func (s *memoryStore) Delete(value any, conds ...any) error {
+	val := reflect.Indirect(reflect.ValueOf(value))
+	if val.Kind() != reflect.Struct {
+		return fmt.Errorf("expected a struct, got %T", value)
+	}
+
+	match, err := compileConds(val.Type(), conds)
+	if err != nil {
+		return err
+	}
+	if len(conds) == 0 {
+		id := idOf(val)
+		if id == nil || isZero(reflect.ValueOf(id)) {
+			return errMissingConditions
+		}
+		match = func(row reflect.Value) bool { return !isSoftDeleted(row) && equalValues(idOf(row), id) }
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tbl, ok := s.tables[val.Type()]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	kept := tbl.rows[:0]
+	for _, row := range tbl.rows {
+		if !match(row) {
+			kept = append(kept, row)
+		} else if row, ok := markDeleted(row, now); ok {
+			kept = append(kept, row)
+		}
+	}
+	tbl.rows = kept
+
+	return nil
+}
+
+// BulkWrite applies ops under a single lock. An atomic write that fails
+// restores the tables as they were.
+func (s *memoryStore) BulkWrite(ops []BulkOp, opts BulkOptions) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var snapshot map[reflect.Type]*memoryTable
+	if opts.Atomic {
+		snapshot = map[reflect.Type]*memoryTable{}
+		for t, tbl := range s.tables {
+			snapshot[t] = &memoryTable{rows: append([]reflect.Value(nil), tbl.rows...), nextID: tbl.nextID}
+		}
+	}
+
+	errs := make([]error, len(ops))
+	for i, op := range ops {
+		val, err := structPointer(op.Value)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		tbl := s.table(val.Type())
+		switch op.Kind {
+		case BulkCreate:
+			errs[i] = tbl.insert(val)
+		case BulkUpdate:
+			errs[i] = tbl.update(val, op.Keep...)
+		case BulkDelete:
+			errs[i] = tbl.remove(idOf(val))
+		default:
+			errs[i] = unknownBulkOp(op)
+		}
+	}
+
+	if opts.Atomic && failed(errs) {
+		s.tables = snapshot
+		return errs, ErrBulkAborted
+	}
+	return errs, nil
+}
+
+func (s *memoryStore) Each(dest any, fn func() error, conds ...any) error {
+	val, err := structPointer(dest)
+	if err != nil {
+		return err
+	}
+	match, err := compileConds(val.Type(), conds)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	var rows []reflect.Value
+	for _, row := range s.rows(val.Type()) {
+		if match(row) {
+			rows = append(rows, copyRow(row))
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, row := range rows {
+		val.Set(row)
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Truncate(model any) error {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tables, t)
+	return nil
+}
+
+func (t *memoryTable) insert(val reflect.Value) error {
+	if err := t.assignID(val); err != nil {
+		return err
+	}
+	if t.indexOf(idOf(val)) >= 0 {
+		return fmt.Errorf("%w: %v", ErrDuplicateKey, idOf(val))
+	}
+
+	now := time.Now()
+	setTime(val, "CreatedAt", now)
+	setTime(val, "UpdatedAt", now)
+	t.rows = append(t.rows, copyRow(val))
+
+	return nil
+}
+
+// replace writes val over the row with its ID, but for the fields named in
+// keep, which val takes from the row instead.
+func (t *memoryTable) replace(val reflect.Value, keep ...string) error {
+	i := t.indexOf(idOf(val))
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	for _, name := range keep {
+		f, ok := lookupField(val.Type(), name)
+		if !ok {
+			return fmt.Errorf("memory store: %s has no field %q", val.Type(), name)
+		}
+		val.FieldByIndex(f.Index).Set(copyRow(t.rows[i]).FieldByIndex(f.Index))
+	}
+	setTime(val, "UpdatedAt", time.Now())
+	t.rows[i] = copyRow(val)
+
+	return nil
+}
+
+// update is replace for the rows gorm's updates see, which are those not
+// soft deleted.
+func (t *memoryTable) update(val reflect.Value, keep ...string) error {
+	if i := t.indexOf(idOf(val)); i >= 0 && isSoftDeleted(t.rows[i]) {
+		return ErrNotFound
+	}
+	return t.replace(val, keep...)
+}
+
+func (t *memoryTable) remove(id any) error {
+	i := t.indexOf(id)
+	if i < 0 || isSoftDeleted(t.rows[i]) {
+		return ErrNotFound
+	}
+	if row, ok := markDeleted(t.rows[i], time.Now()); ok {
+		t.rows[i] = row
+		return nil
+	}
+
+	rows := make([]reflect.Value, 0, len(t.rows)-1)
+	t.rows = append(append(rows, t.rows[:i]...), t.rows[i+1:]...)
+
+	return nil
+}
+
+func (t *memoryTable) assignID(val reflect.Value) error {
+	field := val.FieldByName("ID")
+	if !field.IsValid() {
+		if idOf(val) != nil {
+			// A composite key is always set by the caller.
+			return nil
+		}
+		return fmt.Errorf("%s has no ID field", val.Type())
+	}
+
+	switch {
+	case field.Type() == objectIDType:
+		if isZero(field) {
+			field.Set(reflect.ValueOf(primitive.NewObjectID()))
+		}
+	case field.CanUint():
+		if field.Uint() == 0 {
+			t.nextID++
+			field.SetUint(t.nextID)
+		} else if field.Uint() > t.nextID {
+			t.nextID = field.Uint()
+		}
+	case field.CanInt():
+		if field.Int() == 0 {
+			t.nextID++
+			field.SetInt(int64(t.nextID))
+		} else if uint64(field.Int()) > t.nextID {
+			t.nextID = uint64(field.Int())
+		}
+	}
+	return nil
+}
+
+func (t *memoryTable) indexOf(id any) int {
+	for i, row := range t.rows {
+		if reflect.DeepEqual(idOf(row), id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// idOf returns the ID field of val or, for join tables without one, the
+// values of the fields tagged gorm:"primaryKey".
+func idOf(val reflect.Value) any {
+	if field := val.FieldByName("ID"); field.IsValid() {
+		return field.Interface()
+	}
+
+	var key []any
+	for i := 0; i < val.NumField(); i++ {
+		if strings.Contains(val.Type().Field(i).Tag.Get("gorm"), "primaryKey") {
+			key = append(key, val.Field(i).Interface())
+		}
+	}
+	if key == nil {
+		return nil
+	}
+	return key
+}
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// isSoftDeleted reports whether row has a gorm.DeletedAt field that is set.
+func isSoftDeleted(row reflect.Value) bool {
+	field := row.FieldByName("DeletedAt")
+	return field.IsValid() && field.Type() == deletedAtType && field.Interface().(gorm.DeletedAt).Valid
+}
+
+// markDeleted returns a copy of row soft deleted at t, or false when row
+// has no gorm.DeletedAt field and is to be removed instead.
+func markDeleted(row reflect.Value, t time.Time) (reflect.Value, bool) {
+	field := row.FieldByName("DeletedAt")
+	if !field.IsValid() || field.Type() != deletedAtType {
+		return reflect.Value{}, false
+	}
+	deleted := copyRow(row)
+	deleted.FieldByName("DeletedAt").Set(reflect.ValueOf(gorm.DeletedAt{Time: t, Valid: true}))
+	return deleted, true
+}
+
+func setTime(val reflect.Value, name string, t time.Time) {
+	field := val.FieldByName(name)
+	if field.IsValid() && field.CanSet() && field.Type() == reflect.TypeOf(t) {
+		if name == "UpdatedAt" || field.Interface().(time.Time).IsZero() {
+			field.Set(reflect.ValueOf(t))
+		}
+	}
+}
+
+// copyRow copies row deeply, so that neither the stored row nor the copy
+// handed to a caller shares slices, maps or pointers with the other, as
+// they would not with a database.
+func copyRow(row reflect.Value) reflect.Value {
+	c := reflect.New(row.Type()).Elem()
+	c.Set(row)
+	deepCopy(c)
+	return c
+}
+
+// deepCopy replaces the slices, maps and pointers reachable from the
+// exported fields of v, which must be settable, with copies.
+func deepCopy(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(v.Elem())
+		deepCopy(p.Elem())
+		v.Set(p)
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(s, v)
+		for i := 0; i < s.Len(); i++ {
+			deepCopy(s.Index(i))
+		}
+		v.Set(s)
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			val := reflect.New(v.Type().Elem()).Elem()
+			val.Set(iter.Value())
+			deepCopy(val)
+			m.SetMapIndex(iter.Key(), val)
+		}
+		v.Set(m)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			deepCopy(v.Index(i))
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		val := reflect.New(v.Elem().Type()).Elem()
+		val.Set(v.Elem())
+		deepCopy(val)
+		v.Set(val)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.CanSet() {
+				deepCopy(f)
+			}
+		}
+	}
+}
+
+func structPointer(v any) (reflect.Value, error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("expected a pointer to a struct, got %T", v)
+	}
+	return val.Elem(), nil
+}
+
+func slicePointer(v any) (reflect.Value, error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("expected a pointer to a slice, got %T", v)
+	}
+	return val.Elem(), nil
+}
+
+type matcher func(row reflect.Value) bool
+
+func matchAll(reflect.Value) bool { return true }
+
+// compileConds returns the matcher for conds, which, as with gorm, skips
+// soft deleted rows.
+func compileConds(t reflect.Type, conds []any) (matcher, error) {
+	match, err := compileCond(t, conds)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := t.FieldByName("DeletedAt"); ok && f.Type == deletedAtType {
+		return func(row reflect.Value) bool { return !isSoftDeleted(row) && match(row) }, nil
+	}
+	return match, nil
+}
+
+func compileCond(t reflect.Type, conds []any) (matcher, error) {
+	if len(conds) == 0 || conds[0] == nil {
+		return matchAll, nil
+	}
+
+	switch c := conds[0].(type) {
+	case bson.M:
+		return compileFilter(t, bson.D(mapToD(c)))
+	case map[string]any:
+		return compileFilter(t, bson.D(mapToD(c)))
+	case bson.D:
+		return compileFilter(t, c)
+	case string:
+		return compileWhere(t, c, conds[1:])
+	}
+
+	// gorm treats a single number or ObjectID as the primary key.
+	v := reflect.ValueOf(conds[0])
+	if v.CanInt() || v.CanUint() || v.Type() == objectIDType {
+		id := conds[0]
+		return func(row reflect.Value) bool { return equalValues(idOf(row), id) }, nil
+	}
+
+	return nil, fmt.Errorf("memory store: unsupported condition %T", conds[0])
+}
+
+func mapToD(m map[string]any) bson.D {
+	d := bson.D{}
+	for k, v := range m {
+		d = append(d, bson.E{Key: k, Value: v})
+	}
+	return d
+}
+
+// compileFilter builds a matcher for a mongo query document.
+func compileFilter(t reflect.Type, filter bson.D) (matcher, error) {
+	var matchers []matcher
+	for _, e := range filter {
+		var m matcher
+		var err error
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			m, err = compileLogical(t, e.Key, e.Value)
+		default:
+			m, err = compileField(t, e.Key, e.Value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+
+	return func(row reflect.Value) bool {
+		for _, m := range matchers {
+			if !m(row) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func compileLogical(t reflect.Type, op string, value any) (matcher, error) {
+	list, ok := toList(value)
+	if !ok {
+		return nil, fmt.Errorf("memory store: %s needs an array", op)
+	}
+
+	var matchers []matcher
+	for _, item := range list {
+		doc, ok := toD(item)
+		if !ok {
+			return nil, fmt.Errorf("memory store: %s needs documents", op)
+		}
+		m, err := compileFilter(t, doc)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+
+	return func(row reflect.Value) bool {
+		for _, m := range matchers {
+			if m(row) {
+				if op == "$or" {
+					return true
+				}
+				if op == "$nor" {
+					return false
+				}
+			} else if op == "$and" {
+				return false
+			}
+		}
+		return op != "$or"
+	}, nil
+}
+
+func compileField(t reflect.Type, path string, cond any) (matcher, error) {
+	get, err := fieldGetter(t, path)
+	if err != nil {
+		return nil, err
+	}
+
+	ops, ok := toD(cond)
+	if !ok || len(ops) == 0 || !strings.HasPrefix(ops[0].Key, "$") {
+		return func(row reflect.Value) bool { return matchEq(get(row), cond) }, nil
+	}
+
+	var preds []func(any) bool
+	for _, op := range ops {
+		p, err := compileOperator(op.Key, op.Value, ops)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			preds = append(preds, p)
+		}
+	}
+
+	return func(row reflect.Value) bool {
+		v := get(row)
+		for _, p := range preds {
+			if !p(v) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func compileOperator(op string, arg any, all bson.D) (func(any) bool, error) {
+	switch op {
+	case "$eq":
+		return func(v any) bool { return matchEq(v, arg) }, nil
+	case "$ne":
+		return func(v any) bool { return !matchEq(v, arg) }, nil
+	case "$gt", "$gte", "$lt", "$lte":
+		return func(v any) bool {
+			c, ok := compareValues(v, arg)
+			if !ok {
+				return false
+			}
+			switch op {
+			case "$gt":
+				return c > 0
+			case "$gte":
+				return c >= 0
+			case "$lt":
+				return c < 0
+			}
+			return c <= 0
+		}, nil
+	case "$in", "$nin", "$all":
+		list, ok := toList(arg)
+		if !ok {
+			return nil, fmt.Errorf("memory store: %s needs an array", op)
+		}
+		return func(v any) bool {
+			switch op {
+			case "$all":
+				for _, want := range list {
+					if !matchEq(v, want) {
+						return false
+					}
+				}
+				return true
+			case "$in":
+				for _, want := range list {
+					if matchEq(v, want) {
+						return true
+					}
+				}
+				return false
+			}
+			for _, want := range list {
+				if matchEq(v, want) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "$exists":
+		want, _ := arg.(bool)
+		return func(v any) bool { return (v != nil) == want }, nil
+	case "$regex":
+		pattern := fmt.Sprint(arg)
+		if r, ok := arg.(primitive.Regex); ok {
+			pattern = "(?" + r.Options + ")" + r.Pattern
+		}
+		for _, e := range all {
+			if e.Key == "$options" && e.Value != "" {
+				pattern = "(?" + fmt.Sprint(e.Value) + ")" + pattern
+			}
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return func(v any) bool {
+			s, ok := v.(string)
+			return ok && re.MatchString(s)
+		}, nil
+	case "$options":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("memory store: unsupported operator %s", op)
+}
+
+var whereClause = regexp.MustCompile(`(?i)^\s*"?(\w+)"?\s*(=|<>|!=|<=|>=|<|>|in|is null|is not null)\s*(\?|\(\?\))?\s*$`)
+
+// compileWhere supports the subset of gorm string conditions made of
+// "column op ?" clauses joined with AND.
+func compileWhere(t reflect.Type, where string, args []any) (matcher, error) {
+	clauses := regexp.MustCompile(`(?i)\s+and\s+`).Split(where, -1)
+
+	var matchers []matcher
+	for _, clause := range clauses {
+		m := whereClause.FindStringSubmatch(clause)
+		if m == nil {
+			return nil, fmt.Errorf("memory store: unsupported condition %q", clause)
+		}
+		column, op := m[1], strings.ToLower(m[2])
+
+		var arg any
+		if m[3] != "" {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("memory store: missing argument for %q", clause)
+			}
+			arg, args = args[0], args[1:]
+		}
+
+		var filter any
+		switch op {
+		case "=":
+			filter = bson.D{{Key: "$eq", Value: arg}}
+		case "<>", "!=":
+			filter = bson.D{{Key: "$ne", Value: arg}}
+		case "<":
+			filter = bson.D{{Key: "$lt", Value: arg}}
+		case "<=":
+			filter = bson.D{{Key: "$lte", Value: arg}}
+		case ">":
+			filter = bson.D{{Key: "$gt", Value: arg}}
+		case ">=":
+			filter = bson.D{{Key: "$gte", Value: arg}}
+		case "in":
+			filter = bson.D{{Key: "$in", Value: arg}}
+		case "is null":
+			filter = bson.D{{Key: "$exists", Value: false}}
+		case "is not null":
+			filter = bson.D{{Key: "$exists", Value: true}}
+		}
+
+		matcher, err := compileField(t, column, filter)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	return func(row reflect.Value) bool {
+		for _, m := range matchers {
+			if !m(row) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// fieldGetter resolves a dotted path of bson, json or column names, or keys
+// of maps such as attributes.color, to a function returning the field's
+// value, with NULL-able and missing values as nil.
+func fieldGetter(t reflect.Type, path string) (func(reflect.Value) any, error) {
+	var steps []func(reflect.Value) reflect.Value
+	cur := t
+	for _, name := range strings.Split(path, ".") {
+		for cur.Kind() == reflect.Ptr {
+			cur = cur.Elem()
+		}
+		switch {
+		case cur.Kind() == reflect.Map && cur.Key().Kind() == reflect.String:
+			key := reflect.ValueOf(name).Convert(cur.Key())
+			steps = append(steps, func(v reflect.Value) reflect.Value { return v.MapIndex(key) })
+			cur = cur.Elem()
+		case cur.Kind() == reflect.Struct:
+			f, ok := lookupField(cur, name)
+			if !ok {
+				return nil, fmt.Errorf("memory store: %s has no field %q", t, path)
+			}
+			index := f.Index
+			steps = append(steps, func(v reflect.Value) reflect.Value { return v.FieldByIndex(index) })
+			cur = f.Type
+		default:
+			return nil, fmt.Errorf("memory store: %s has no field %q", t, path)
+		}
+	}
+
+	return func(row reflect.Value) any {
+		v := row
+		for _, step := range steps {
+			for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+				if v.IsNil() {
+					return nil
+				}
+				v = v.Elem()
+			}
+			if v = step(v); !v.IsValid() {
+				return nil
+			}
+		}
+		return plainValue(v)
+	}, nil
+}
+
+func lookupField(t reflect.Type, name string) (reflect.StructField, bool) {
+	fields := reflect.VisibleFields(t)
+	for _, tag := range []string{"bson", "json"} {
+		for _, f := range fields {
+			if strings.Split(f.Tag.Get(tag), ",")[0] == name {
+				return f, true
+			}
+		}
+	}
+	for _, f := range fields {
+		if f.Anonymous {
+			continue
+		}
+		if snakeCase(f.Name) == name || strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func plainValue(v reflect.Value) any {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		val, err := valuer.Value()
+		if err != nil {
+			return nil
+		}
+		return val
+	}
+	return v.Interface()
+}
+
+// matchEq follows mongo semantics: an array field matches when any element
+// is equal to want.
+func matchEq(v any, want any) bool {
+	if equalValues(v, want) {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	if v != nil && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type() != objectIDType {
+		for i := 0; i < rv.Len(); i++ {
+			if equalValues(plainValue(rv.Index(i)), want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func equalValues(a, b any) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	// Values such as money.Money that mongo stores in another form are
+	// equal when their stored forms are, as they would be there.
+	if x, ok := a.(bson.ValueMarshaler); ok {
+		if y, ok := b.(bson.ValueMarshaler); ok {
+			return equalMarshaled(x, y)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func equalMarshaled(a, b bson.ValueMarshaler) bool {
+	ta, da, err := a.MarshalBSONValue()
+	if err != nil {
+		return false
+	}
+	tb, db, err := b.MarshalBSONValue()
+	if err != nil {
+		return false
+	}
+	return bson.RawValue{Type: ta, Value: da}.Equal(bson.RawValue{Type: tb, Value: db})
+}
+
+// compareValues orders two values of compatible kinds. Numbers of any Go type
+// compare by value.
+func compareValues(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+		return 0, false
+	}
+
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return strings.Compare(x.Hex(), y.Hex()), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok && x == y {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.CanInt():
+		return float64(rv.Int()), true
+	case rv.CanUint():
+		return float64(rv.Uint()), true
+	case rv.CanFloat():
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func toList(v any) ([]any, bool) {
+	rv := reflect.ValueOf(v)
+	if v == nil || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type() == objectIDType {
+		return nil, false
+	}
+	list := make([]any, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+func toD(v any) (bson.D, bool) {
+	switch d := v.(type) {
+	case bson.D:
+		return d, true
+	case bson.M:
+		return mapToD(d), true
+	case map[string]any:
+		return mapToD(d), true
+	}
+	return nil, false
+}
+
+func (s *memoryStore) Update(model any, set map[string]any, conds ...any) error {
+	val, err := structPointer(model)
+	if err != nil {
+		return err
+	}
+	match, err := compileConds(val.Type(), conds)
+	if err != nil {
+		return err
+	}
+	fields := make(map[string][]int, len(set))
+	values := make(map[string]reflect.Value, len(set))
+	for name, v := range set {
+		f, ok := lookupField(val.Type(), name)
+		if !ok {
+			return fmt.Errorf("memory store: %s has no field %q", val.Type(), name)
+		}
+		value, ok := fieldValue(f.Type, v)
+		if !ok {
+			return fmt.Errorf("memory store: cannot set %s.%s to %T", val.Type(), f.Name, v)
+		}
+		fields[name], values[name] = f.Index, value
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tbl := s.table(val.Type())
+	now := time.Now()
+	n := 0
+	for i, row := range tbl.rows {
+		if !match(row) {
+			continue
+		}
+		updated := copyRow(row)
+		for name, value := range values {
+			updated.FieldByIndex(fields[name]).Set(value)
+		}
+		setTime(updated, "UpdatedAt", now)
+		tbl.rows[i] = copyRow(updated)
+		n++
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// fieldValue converts v to a value of a field of type t, the way a
+// database would store it: nil is the zero value and a pointer field takes
+// the value it points to.
+func fieldValue(t reflect.Type, v any) (reflect.Value, bool) {
+	if v == nil {
+		return reflect.Zero(t), true
+	}
+	value := reflect.ValueOf(v)
+	switch {
+	case value.Type().AssignableTo(t):
+		return value, true
+	case t.Kind() == reflect.Ptr:
+		elem, ok := fieldValue(t.Elem(), v)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(elem)
+		return p, true
+	case value.Type().ConvertibleTo(t) && (value.Kind() == reflect.String) == (t.Kind() == reflect.String):
+		return value.Convert(t), true
+	}
+	return reflect.Value{}, false
+}
+
+func (s *memoryStore) Increment(dest any, filter any, inc map[string]int64) error {
+	val, err := structPointer(dest)
+	if err != nil {
+		return err
+	}
+	match, err := compileConds(val.Type(), []any{filter})
+	if err != nil {
+		return err
+	}
+	fields := make(map[string][]int, len(inc))
+	for name := range inc {
+		f, ok := lookupField(val.Type(), name)
+		if !ok || !f.Type.ConvertibleTo(reflect.TypeOf(int64(0))) {
+			return fmt.Errorf("memory store: %s has no number field %q", val.Type(), name)
+		}
+		fields[name] = f.Index
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tbl := s.table(val.Type())
+	for i, row := range tbl.rows {
+		if !match(row) {
+			continue
+		}
+		updated := copyRow(row)
+		for name, n := range inc {
+			field := updated.FieldByIndex(fields[name])
+			if field.CanInt() {
+				field.SetInt(field.Int() + n)
+			} else {
+				field.SetUint(uint64(int64(field.Uint()) + n))
+			}
+		}
+		setTime(updated, "UpdatedAt", time.Now())
+		tbl.rows[i] = updated
+		val.Set(copyRow(updated))
+		return nil
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,274 @@
+package store_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gorm.io/gorm"
+)
+
+type TodoMock struct {
+	gorm.Model
+	Title     string
+	Completed bool
+}
+
+func seedProducts(t *testing.T, s store.Storer) []ProductMock {
+	products := []ProductMock{
+		{Name: "Pen", Price: 10, Tags: []string{"office"}},
+		{Name: "Mug", Price: 250, Tags: []string{"kitchen", "gift"}},
+		{Name: "Lamp", Price: 900, Tags: []string{"home"}},
+	}
+	for i := range products {
+		assert.NoError(t, s.Create(&products[i]))
+	}
+	return products
+}
+
+func TestMemoryStoreCreate(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	t.Run("assigns uint IDs to gorm models", func(t *testing.T) {
+		first, second := TodoMock{Title: "a"}, TodoMock{Title: "b"}
+		assert.NoError(t, s.Create(&first))
+		assert.NoError(t, s.Create(&second))
+		assert.Equal(t, uint(1), first.ID)
+		assert.Equal(t, uint(2), second.ID)
+		assert.False(t, first.CreatedAt.IsZero())
+	})
+
+	t.Run("assigns ObjectIDs", func(t *testing.T) {
+		p := ProductMock{Name: "Pen"}
+		assert.NoError(t, s.Create(&p))
+		assert.NotEqual(t, primitive.NilObjectID, p.ID)
+	})
+
+	t.Run("rejects duplicate IDs", func(t *testing.T) {
+		p := ProductMock{ID: primitive.NewObjectID(), Name: "Cup"}
+		assert.NoError(t, s.Create(&p))
+		err := s.Create(&ProductMock{ID: p.ID, Name: "Cup"})
+		assert.ErrorIs(t, err, store.ErrDuplicateKey)
+	})
+
+	t.Run("uses composite primary keys", func(t *testing.T) {
+		type JoinMock struct {
+			LeftID  uint `gorm:"primaryKey"`
+			RightID uint `gorm:"primaryKey"`
+		}
+		assert.NoError(t, s.Create(&JoinMock{LeftID: 1, RightID: 2}))
+		assert.NoError(t, s.Create(&JoinMock{LeftID: 1, RightID: 3}))
+		assert.ErrorIs(t, s.Create(&JoinMock{LeftID: 1, RightID: 2}), store.ErrDuplicateKey)
+
+		assert.NoError(t, store.Delete(s, &JoinMock{LeftID: 1, RightID: 2}))
+		var rows []JoinMock
+		assert.NoError(t, s.Find(&rows, "left_id = ?", 1))
+		assert.Equal(t, []JoinMock{{LeftID: 1, RightID: 3}}, rows)
+	})
+}
+
+func TestMemoryStoreFindWithBSONFilter(t *testing.T) {
+	s := store.NewMemoryStore()
+	products := seedProducts(t, s)
+
+	tests := []struct {
+		name   string
+		filter any
+		want   []string
+	}{
+		{"empty filter", bson.D{}, []string{"Pen", "Mug", "Lamp"}},
+		{"equality", bson.M{"name": "Mug"}, []string{"Mug"}},
+		{"by id", bson.M{"_id": products[2].ID}, []string{"Lamp"}},
+		{"range", bson.M{"price": bson.M{"$gte": 100, "$lt": 1000}}, []string{"Mug", "Lamp"}},
+		{"in", bson.M{"name": bson.M{"$in": bson.A{"Pen", "Lamp"}}}, []string{"Pen", "Lamp"}},
+		{"array element", bson.M{"tags": "gift"}, []string{"Mug"}},
+		{"regex", bson.M{"name": bson.M{"$regex": "^l", "$options": "i"}}, []string{"Lamp"}},
+		{"or", bson.M{"$or": bson.A{bson.M{"price": 10}, bson.M{"name": "Lamp"}}}, []string{"Pen", "Lamp"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var result []ProductMock
+			assert.NoError(t, s.Find(&result, tc.filter))
+
+			var names []string
+			for _, p := range result {
+				names = append(names, p.Name)
+			}
+			assert.Equal(t, tc.want, names)
+		})
+	}
+
+	t.Run("unknown field", func(t *testing.T) {
+		var result []ProductMock
+		assert.Error(t, s.Find(&result, bson.M{"colour": "red"}))
+	})
+}
+
+func TestMemoryStoreMatchesStoredForms(t *testing.T) {
+	type priced struct {
+		ID    primitive.ObjectID `bson:"_id,omitempty"`
+		Price money.Money        `bson:"price"`
+	}
+	s := store.NewMemoryStore()
+	p := priced{Price: money.Money{Amount: 1234, Currency: "USD", Formatted: "$12.34"}}
+	assert.NoError(t, s.Create(&p))
+
+	var result []priced
+	assert.NoError(t, s.Find(&result, bson.M{"price": money.New(1234, "USD")}))
+	assert.Len(t, result, 1, "prices compare as mongo stores them")
+	assert.NoError(t, s.Find(&result, bson.M{"price": money.New(1234, "EUR")}))
+	assert.Empty(t, result)
+}
+
+func TestMemoryStoreGormConditions(t *testing.T) {
+	s := store.NewMemoryStore()
+	for _, title := range []string{"a", "b", "c"} {
+		assert.NoError(t, s.Create(&TodoMock{Title: title, Completed: title != "b"}))
+	}
+
+	var todo TodoMock
+	assert.NoError(t, s.First(&todo, 2))
+	assert.Equal(t, "b", todo.Title)
+
+	var todos []TodoMock
+	assert.NoError(t, s.Find(&todos, "completed = ? AND id > ?", true, 1))
+	assert.Len(t, todos, 1)
+	assert.Equal(t, "c", todos[0].Title)
+
+	assert.NoError(t, s.Find(&todos, "deleted_at IS NULL"))
+	assert.Len(t, todos, 3)
+
+	assert.NoError(t, s.Find(&todos, "title IN ?", []string{"a", "c"}))
+	assert.Len(t, todos, 2)
+}
+
+func TestMemoryStoreFirstNotFound(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	var p ProductMock
+	err := s.First(&p, bson.M{"_id": primitive.NewObjectID()})
+	assert.True(t, store.IsNotFound(err))
+}
+
+func TestMemoryStoreSave(t *testing.T) {
+	s := store.NewMemoryStore()
+	products := seedProducts(t, s)
+
+	products[1].Price = 300
+	assert.NoError(t, s.Save(&products[1]))
+
+	var p ProductMock
+	assert.NoError(t, s.First(&p, bson.M{"_id": products[1].ID}))
+	assert.Equal(t, 300, p.Price)
+
+	var all []ProductMock
+	assert.NoError(t, s.Find(&all, bson.M{}))
+	assert.Len(t, all, 3)
+}
+
+func TestMemoryStoreCopiesSlices(t *testing.T) {
+	s := store.NewMemoryStore()
+	products := seedProducts(t, s)
+
+	// Neither the value written nor one read back shares its tags with the
+	// stored row.
+	products[1].Tags[0] = "changed"
+	var p ProductMock
+	assert.NoError(t, s.First(&p, bson.M{"_id": products[1].ID}))
+	assert.Equal(t, []string{"kitchen", "gift"}, p.Tags)
+
+	p.Tags[1] = "changed"
+	var again ProductMock
+	assert.NoError(t, s.First(&again, bson.M{"_id": products[1].ID}))
+	assert.Equal(t, []string{"kitchen", "gift"}, again.Tags)
+}
+
+func TestMemoryStoreTruncate(t *testing.T) {
+	s := store.NewMemoryStore()
+	seedProducts(t, s)
+
+	assert.NoError(t, store.Truncate(s, &ProductMock{}))
+
+	var all []ProductMock
+	assert.NoError(t, s.Find(&all))
+	assert.Empty(t, all)
+}
+
+func TestMemoryStoreConcurrentCreate(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.Create(&TodoMock{Title: "todo"}))
+			var todos []TodoMock
+			assert.NoError(t, s.Find(&todos))
+		}()
+	}
+	wg.Wait()
+
+	var todos []TodoMock
+	assert.NoError(t, s.Find(&todos))
+	assert.Len(t, todos, 50)
+}
+
+func TestMemoryStoreIncrement(t *testing.T) {
+	s := store.NewMemoryStore()
+	products := seedProducts(t, s)
+
+	var p ProductMock
+	assert.NoError(t, store.Increment(s, &p, bson.M{"name": "Mug", "price": bson.M{"$gte": 200}}, map[string]int64{"price": -200}))
+	assert.Equal(t, 50, p.Price)
+	assert.Equal(t, products[1].ID, p.ID)
+
+	err := store.Increment(s, &p, bson.M{"name": "Mug", "price": bson.M{"$gte": 200}}, map[string]int64{"price": -200})
+	assert.True(t, store.IsNotFound(err), "the condition no longer holds")
+	assert.Error(t, store.Increment(s, &p, bson.M{"name": "Mug"}, map[string]int64{"name": 1}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var p ProductMock
+			_ = store.Increment(s, &p, bson.M{"name": "Pen", "price": bson.M{"$gte": 1}}, map[string]int64{"price": -1})
+		}()
+	}
+	wg.Wait()
+	assert.NoError(t, s.First(&p, bson.M{"name": "Pen"}))
+	assert.Equal(t, 0, p.Price, "concurrent decrements stop at zero")
+}
+
+func TestMemoryStoreUpdate(t *testing.T) {
+	s := store.NewMemoryStore()
+	products := seedProducts(t, s)
+
+	// Swapping the tags only while they are still the ones read.
+	swap := func(old, tags []string) error {
+		return store.Update(s, &ProductMock{}, map[string]any{"tags": tags}, bson.M{"_id": products[1].ID, "tags": old})
+	}
+	assert.NoError(t, swap(products[1].Tags, []string{"kitchen"}))
+	assert.True(t, store.IsNotFound(swap(products[1].Tags, []string{"gift"})), "the tags have changed")
+
+	box := ProductMock{Name: "Box"}
+	assert.NoError(t, s.Create(&box))
+	assert.NoError(t, store.Update(s, &ProductMock{}, map[string]any{"tags": []string{"office"}}, bson.M{"_id": box.ID, "tags": box.Tags}), "nil tags match a record without any")
+
+	var p ProductMock
+	assert.NoError(t, s.First(&p, bson.M{"_id": products[1].ID}))
+	assert.Equal(t, []string{"kitchen"}, p.Tags)
+	assert.Equal(t, 250, p.Price)
+
+	assert.NoError(t, store.Update(s, &ProductMock{}, map[string]any{"price": int64(5)}, bson.M{"name": "Pen"}))
+	assert.NoError(t, s.First(&p, bson.M{"name": "Pen"}))
+	assert.Equal(t, 5, p.Price)
+	assert.Error(t, store.Update(s, &ProductMock{}, map[string]any{"price": "cheap"}, bson.M{"name": "Pen"}))
+	assert.Error(t, store.Update(s, &ProductMock{}, map[string]any{"color": "red"}, bson.M{"name": "Pen"}))
+}
@@ -2,11 +2,16 @@ package store
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type mongoStore struct {
@@ -23,7 +28,7 @@ func (s *mongoStore) Find(dest any, conds ...any) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := s.col.Find(ctx, conds[0])
+	cursor, err := s.col.Find(ctx, filterOf(conds))
 	if err != nil {
 		return err
 	}
@@ -68,7 +73,7 @@ func (s *mongoStore) First(result any, filter ...any) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := s.col.FindOne(ctx, filter[0]).Decode(result); err != nil {
+	if err := s.col.FindOne(ctx, filterOf(filter)).Decode(result); err != nil {
 		return err
 	}
 
@@ -96,18 +101,358 @@ func (s *mongoStore) Save(value any) error {
 		idField = val.FieldByName("_id")
 	}
 
-	if idField.IsValid() && idField.CanSet() && isZero(idField) {
-		return nil // Cannot save without a valid ID
+	// Like gorm, saving a value without an ID creates it.
+	if !idField.IsValid() || isZero(idField) {
+		return s.Create(value)
 	}
 
 	filter := primitive.M{"_id": idField.Interface()}
 	update := primitive.M{"$set": val.Interface()}
 
-	_, err := s.col.UpdateOne(ctx, filter, update)
+	_, err := s.col.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
 	return err
 }
 
+func (s *mongoStore) Delete(value any, conds ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := filterOf(conds)
+	if len(conds) == 0 {
+		val := reflect.Indirect(reflect.ValueOf(value))
+		if val.Kind() != reflect.Struct {
+			return errMissingConditions
+		}
+		idField := val.FieldByName("ID")
+		if !idField.IsValid() || isZero(idField) {
+			return errMissingConditions
+		}
+		filter = primitive.M{"_id": idField.Interface()}
+	}
+
+	_, err := s.col.DeleteMany(ctx, filter)
+	return err
+}
+
+// BulkWrite sends every op in one BulkWrite call. Updates and deletes of IDs
+// that do not exist are reported as ErrNotFound without being sent. In atomic
+// mode the ops run in order inside a transaction, which needs a replica set.
+func (s *mongoStore) BulkWrite(ops []BulkOp, opts BulkOptions) ([]error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	existing, err := s.existingIDs(ctx, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, len(ops))
+	var models []mongo.WriteModel
+	var index []int // the op of every model
+	for i, op := range ops {
+		val := reflect.Indirect(reflect.ValueOf(op.Value))
+		if val.Kind() != reflect.Struct || !val.FieldByName("ID").IsValid() {
+			errs[i] = fmt.Errorf("expected a pointer to a struct with an ID, got %T", op.Value)
+			continue
+		}
+		idField := val.FieldByName("ID")
+
+		switch op.Kind {
+		case BulkCreate:
+			if idField.Type() == objectIDType && isZero(idField) {
+				idField.Set(reflect.ValueOf(primitive.NewObjectID()))
+			}
+			models = append(models, mongo.NewInsertOneModel().SetDocument(op.Value))
+		case BulkUpdate, BulkDelete:
+			id := idField.Interface()
+			if !existing[id] {
+				errs[i] = ErrNotFound
+				continue
+			}
+			filter := primitive.M{"_id": id}
+			switch {
+			case op.Kind == BulkUpdate && len(op.Keep) > 0:
+				set, err := keptOut(op.Value, op.Keep)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(primitive.M{"$set": set}))
+			case op.Kind == BulkUpdate:
+				models = append(models, mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(op.Value))
+			default:
+				models = append(models, mongo.NewDeleteOneModel().SetFilter(filter))
+			}
+		default:
+			errs[i] = unknownBulkOp(op)
+			continue
+		}
+		index = append(index, i)
+	}
+
+	if opts.Atomic && failed(errs) {
+		return errs, ErrBulkAborted
+	}
+	if len(models) == 0 {
+		return errs, nil
+	}
+
+	// write records the errors of single ops in errs and returns any other.
+	write := func(ctx context.Context) error {
+		_, err := s.col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(opts.Atomic))
+		var bwe mongo.BulkWriteException
+		if errors.As(err, &bwe) && bwe.WriteConcernError == nil {
+			for _, we := range bwe.WriteErrors {
+				errs[index[we.Index]] = we.WriteError
+			}
+			return nil
+		}
+		return err
+	}
+
+	if !opts.Atomic {
+		if err := write(ctx); err != nil {
+			return nil, err
+		}
+		return errs, nil
+	}
+
+	session, err := s.col.Database().Client().StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		if err := write(sc); err != nil {
+			return nil, err
+		}
+		if failed(errs) {
+			return nil, ErrBulkAborted
+		}
+		return nil, nil
+	})
+	if err != nil && !failed(errs) {
+		return nil, err
+	}
+	return errs, err
+}
+
+// keptOut returns the fields of value to set, all but _id and those in keep.
+func keptOut(value any, keep []string) (primitive.M, error) {
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var set primitive.M
+	if err := bson.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	delete(set, "_id")
+	for _, name := range keep {
+		delete(set, name)
+	}
+	return set, nil
+}
+
+// existingIDs returns which of the IDs updated or deleted by ops exist.
+func (s *mongoStore) existingIDs(ctx context.Context, ops []BulkOp) (map[any]bool, error) {
+	var ids []any
+	for _, op := range ops {
+		if op.Kind != BulkUpdate && op.Kind != BulkDelete {
+			continue
+		}
+		val := reflect.Indirect(reflect.ValueOf(op.Value))
+		if val.Kind() == reflect.Struct && idOf(val) != nil {
+			ids = append(ids, idOf(val))
+		}
+	}
+
+	existing := map[any]bool{}
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	cursor, err := s.col.Find(ctx, primitive.M{"_id": primitive.M{"$in": ids}},
+		options.Find().SetProjection(primitive.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID any `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		existing[doc.ID] = true
+	}
+	return existing, cursor.Err()
+}
+
+// Each decodes the documents from a cursor as they arrive. There is no
+// overall timeout because an export may take longer than a single query.
+func (s *mongoStore) Each(dest any, fn func() error, conds ...any) error {
+	val, err := structPointer(dest)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cursor, err := s.col.Find(ctx, filterOf(conds))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		val.Set(reflect.Zero(val.Type()))
+		if err := cursor.Decode(dest); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Increment runs $inc through FindOneAndUpdate, and sets updated_at when
+// dest has an UpdatedAt field.
+func (s *mongoStore) Increment(dest any, filter any, inc map[string]int64) error {
+	val, err := structPointer(dest)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := primitive.M{"$inc": inc}
+	setUpdatedAt(update, val.Type())
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	return s.col.FindOneAndUpdate(ctx, filter, update, opts).Decode(dest)
+}
+
+// Update runs $set through UpdateMany, and sets updated_at when model has
+// an UpdatedAt field.
+func (s *mongoStore) Update(model any, set map[string]any, conds ...any) error {
+	val, err := structPointer(model)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := primitive.M{"$set": set}
+	setUpdatedAt(update, val.Type())
+	res, err := s.col.UpdateMany(ctx, filterOf(conds), update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// setUpdatedAt adds $currentDate for the UpdatedAt field of t, if it has
+// one, to update.
+func setUpdatedAt(update primitive.M, t reflect.Type) {
+	if f, ok := t.FieldByName("UpdatedAt"); ok {
+		if name := strings.Split(f.Tag.Get("bson"), ",")[0]; name != "" && name != "-" {
+			update["$currentDate"] = primitive.M{name: true}
+		}
+	}
+}
+
+func (s *mongoStore) Truncate(model any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.col.DeleteMany(ctx, primitive.M{})
+	return err
+}
+
+// filterOf returns the filter passed as the first condition, or an empty
+// filter matching every document.
+func filterOf(conds []any) any {
+	if len(conds) == 0 || conds[0] == nil {
+		return primitive.D{}
+	}
+	return conds[0]
+}
+
 // isZero checks if a reflect.Value is the zero value for its type
 func isZero(v reflect.Value) bool {
 	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
 }
+
+// Facets counts the values of every field in one aggregation: a $facet
+// branch per field unwinds arrays, splits documents into their keys and
+// groups by value.
+func (s *mongoStore) Facets(model any, fields []string, conds ...any) (map[string][]FacetCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	branches := bson.D{}
+	for i, field := range fields {
+		// Branch names cannot contain the dots of field paths.
+		branches = append(branches, bson.E{Key: fmt.Sprintf("f%d", i), Value: bson.A{
+			bson.M{"$project": bson.M{"v": "$" + field}},
+			bson.M{"$unwind": "$v"},
+			bson.M{"$project": bson.M{"v": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$type": "$v"}, "object"}},
+				bson.M{"$objectToArray": "$v"},
+				bson.A{bson.M{"v": "$v"}},
+			}}}},
+			bson.M{"$unwind": "$v"},
+			bson.M{"$group": bson.M{"_id": bson.M{"k": "$v.k", "v": "$v.v"}, "count": bson.M{"$sum": 1}}},
+		}})
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filterOf(conds)}},
+		{{Key: "$facet", Value: branches}},
+	}
+
+	cursor, err := s.col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []map[string][]struct {
+		ID struct {
+			Key   string        `bson:"k"`
+			Value bson.RawValue `bson:"v"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	facets := map[string][]FacetCount{}
+	if len(results) == 0 {
+		return facets, nil
+	}
+	for i, field := range fields {
+		for _, group := range results[0][fmt.Sprintf("f%d", i)] {
+			value, ok := facetValue(group.ID.Value)
+			if !ok {
+				continue
+			}
+			name := field
+			if group.ID.Key != "" {
+				name += "." + group.ID.Key
+			}
+			facets[name] = append(facets[name], FacetCount{Value: value, Count: group.Count})
+		}
+	}
+	return facets, nil
+}
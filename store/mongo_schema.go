@@ -0,0 +1,411 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoIndex declares an index that should exist on a collection.
+// Text indexes are declared with the value "text" for every key.
+type MongoIndex struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+}
+
+// MongoSchema is the declared state of a collection: its indexes and
+// the $jsonSchema validator applied to inserts and updates.
+type MongoSchema struct {
+	Indexes   []MongoIndex
+	Validator bson.M
+}
+
+// SchemaDiff reports how a live collection differs from its MongoSchema.
+type SchemaDiff struct {
+	MissingIndexes   []string
+	ChangedIndexes   []string
+	ExtraIndexes     []string
+	ValidatorChanged bool
+}
+
+func (d SchemaDiff) Empty() bool {
+	return len(d.MissingIndexes) == 0 && len(d.ChangedIndexes) == 0 &&
+		len(d.ExtraIndexes) == 0 && !d.ValidatorChanged
+}
+
+func (d SchemaDiff) String() string {
+	if d.Empty() {
+		return "schema up to date"
+	}
+
+	var lines []string
+	for _, name := range d.MissingIndexes {
+		lines = append(lines, "+ index "+name)
+	}
+	for _, name := range d.ChangedIndexes {
+		lines = append(lines, "~ index "+name)
+	}
+	for _, name := range d.ExtraIndexes {
+		lines = append(lines, "- index "+name+" (not declared, left in place)")
+	}
+	if d.ValidatorChanged {
+		lines = append(lines, "~ validator")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffMongoSchema compares the live indexes and validator of col with schema.
+func DiffMongoSchema(col *mongo.Collection, schema MongoSchema) (SchemaDiff, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	diff, _, err := diffMongoSchema(ctx, col, schema)
+	return diff, err
+}
+
+// ApplyMongoSchema brings col in line with schema and returns the diff that
+// was found before applying. Indexes that exist but are not declared are
+// reported and left alone. Running it against an up to date collection is a
+// no-op.
+func ApplyMongoSchema(col *mongo.Collection, schema MongoSchema) (SchemaDiff, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	diff, exists, err := diffMongoSchema(ctx, col, schema)
+	if err != nil {
+		return diff, err
+	}
+
+	if !exists {
+		opts := options.CreateCollection().SetValidator(schema.Validator).SetValidationLevel("moderate")
+		if err := col.Database().CreateCollection(ctx, col.Name(), opts); err != nil {
+			return diff, fmt.Errorf("create collection: %w", err)
+		}
+		diff.ValidatorChanged = false
+	}
+
+	changed := map[string]bool{}
+	for _, name := range diff.ChangedIndexes {
+		changed[name] = true
+		if _, err := col.Indexes().DropOne(ctx, name); err != nil {
+			return diff, fmt.Errorf("drop index %s: %w", name, err)
+		}
+	}
+
+	var models []mongo.IndexModel
+	for _, idx := range schema.Indexes {
+		if !changed[idx.Name] && !contains(diff.MissingIndexes, idx.Name) {
+			continue
+		}
+		opts := options.Index().SetName(idx.Name)
+		if idx.Unique {
+			opts.SetUnique(true)
+		}
+		models = append(models, mongo.IndexModel{Keys: idx.Keys, Options: opts})
+	}
+	if len(models) > 0 {
+		if _, err := col.Indexes().CreateMany(ctx, models); err != nil {
+			return diff, fmt.Errorf("create indexes: %w", err)
+		}
+	}
+
+	if diff.ValidatorChanged {
+		cmd := bson.D{
+			{Key: "collMod", Value: col.Name()},
+			{Key: "validator", Value: schema.Validator},
+			{Key: "validationLevel", Value: "moderate"},
+		}
+		if err := col.Database().RunCommand(ctx, cmd).Err(); err != nil {
+			return diff, fmt.Errorf("update validator: %w", err)
+		}
+	}
+
+	return diff, nil
+}
+
+func diffMongoSchema(ctx context.Context, col *mongo.Collection, schema MongoSchema) (SchemaDiff, bool, error) {
+	var diff SchemaDiff
+
+	specs, err := col.Database().ListCollectionSpecifications(ctx, bson.M{"name": col.Name()})
+	if err != nil {
+		return diff, false, err
+	}
+	exists := len(specs) > 0
+
+	var current any
+	if exists && specs[0].Options != nil {
+		if v, err := specs[0].Options.LookupErr("validator"); err == nil {
+			current = v
+		}
+	}
+	diff.ValidatorChanged = !sameDocument(current, schema.Validator)
+
+	var live []bson.Raw
+	if exists {
+		cursor, err := col.Indexes().List(ctx)
+		if err != nil {
+			return diff, exists, err
+		}
+		if err := cursor.All(ctx, &live); err != nil {
+			return diff, exists, err
+		}
+	}
+
+	liveByName := map[string]bson.Raw{}
+	for _, spec := range live {
+		name, _ := spec.Lookup("name").StringValueOK()
+		if name == "_id_" {
+			continue
+		}
+		liveByName[name] = spec
+	}
+
+	for _, idx := range schema.Indexes {
+		spec, ok := liveByName[idx.Name]
+		if !ok {
+			diff.MissingIndexes = append(diff.MissingIndexes, idx.Name)
+			continue
+		}
+		delete(liveByName, idx.Name)
+
+		unique, _ := spec.Lookup("unique").BooleanOK()
+		if unique != idx.Unique || indexKeySignature(liveIndexKeys(spec)) != indexKeySignature(idx.Keys) {
+			diff.ChangedIndexes = append(diff.ChangedIndexes, idx.Name)
+		}
+	}
+	for name := range liveByName {
+		diff.ExtraIndexes = append(diff.ExtraIndexes, name)
+	}
+	sort.Strings(diff.ExtraIndexes)
+
+	return diff, exists, nil
+}
+
+// liveIndexKeys returns the key document of an index as reported by the
+// server. Text indexes are stored as {_fts: "text", _ftsx: 1}, so their keys
+// are rebuilt from the weights document.
+func liveIndexKeys(spec bson.Raw) bson.D {
+	var keys, weights bson.D
+	if v, err := spec.LookupErr("key"); err == nil {
+		_ = v.Unmarshal(&keys)
+	}
+	if v, err := spec.LookupErr("weights"); err == nil {
+		_ = v.Unmarshal(&weights)
+	}
+
+	var out bson.D
+	for _, e := range keys {
+		switch e.Key {
+		case "_fts":
+			for _, w := range weights {
+				out = append(out, bson.E{Key: w.Key, Value: "text"})
+			}
+		case "_ftsx":
+		default:
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// indexKeySignature renders keys in a form that ignores numeric types and the
+// order of text fields, which the server does not preserve.
+func indexKeySignature(keys bson.D) string {
+	var parts, text []string
+	for _, e := range keys {
+		if e.Value == "text" {
+			text = append(text, e.Key)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%v", e.Key, normalizeNumber(e.Value)))
+	}
+	sort.Strings(text)
+	for _, k := range text {
+		parts = append(parts, k+":text")
+	}
+	return strings.Join(parts, ",")
+}
+
+func normalizeNumber(v any) any {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return v
+}
+
+// sameDocument compares two documents by their canonical extended JSON form.
+func sameDocument(live any, declared bson.M) bool {
+	if live == nil || declared == nil {
+		return live == nil && len(declared) == 0
+	}
+
+	var liveDoc bson.M
+	switch v := live.(type) {
+	case bson.RawValue:
+		if err := v.Unmarshal(&liveDoc); err != nil {
+			return false
+		}
+	case bson.M:
+		liveDoc = v
+	default:
+		return false
+	}
+
+	a, err := bson.MarshalExtJSON(canonical(liveDoc), false, false)
+	if err != nil {
+		return false
+	}
+	b, err := bson.MarshalExtJSON(canonical(declared), false, false)
+	if err != nil {
+		return false
+	}
+	return string(a) == string(b)
+}
+
+// canonical converts maps into key-sorted documents and arrays into bson.A so
+// that two equal documents marshal to the same bytes.
+func canonical(v any) any {
+	switch val := v.(type) {
+	case bson.M:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := bson.D{}
+		for _, k := range keys {
+			d = append(d, bson.E{Key: k, Value: canonical(val[k])})
+		}
+		return d
+	case bson.D:
+		m := bson.M{}
+		for _, e := range val {
+			m[e.Key] = e.Value
+		}
+		return canonical(m)
+	case bson.A:
+		out := bson.A{}
+		for _, e := range val {
+			out = append(out, canonical(e))
+		}
+		return out
+	case []string:
+		out := bson.A{}
+		for _, e := range val {
+			out = append(out, e)
+		}
+		return out
+	}
+	return normalizeNumber(v)
+}
+
+// SchemaDescriber is implemented by field types that marshal themselves to
+// BSON, to describe their stored form in place of the one derived from Go.
+type SchemaDescriber interface {
+	JSONSchema() bson.M
+}
+
+// JSONSchema derives a $jsonSchema validator from a struct. Field names come
+// from the bson tags, types from the Go kinds or SchemaDescriber, and fields
+// tagged binding:"required" are required. Slices and maps stored even when
+// empty may be null.
+func JSONSchema(v any) bson.M {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	properties := bson.M{}
+	required := bson.A{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("bson"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		elem := f.Type
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if d, ok := reflect.Zero(elem).Interface().(SchemaDescriber); ok {
+			properties[name] = d.JSONSchema()
+		} else if k := f.Type.Kind(); (k == reflect.Slice || k == reflect.Map) && !strings.Contains(f.Tag.Get("bson"), "omitempty") {
+			// Without omitempty, nil slices and maps are stored as null.
+			properties[name] = bson.M{"bsonType": bson.A{bsonTypeOf(f.Type), "null"}}
+		} else {
+			properties[name] = bson.M{"bsonType": bsonTypeOf(f.Type)}
+		}
+		if strings.Contains(f.Tag.Get("binding"), "required") {
+			required = append(required, name)
+		}
+	}
+
+	schema := bson.M{
+		"bsonType":   "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return bson.M{"$jsonSchema": schema}
+}
+
+var objectIDType = reflect.TypeOf(primitive.ObjectID{})
+
+func bsonTypeOf(t reflect.Type) any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == objectIDType {
+		return "objectId"
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return "date"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return "int"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return bson.A{"int", "long"}
+	case reflect.Float32, reflect.Float64:
+		return "double"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
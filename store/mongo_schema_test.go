@@ -0,0 +1,112 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type ProductMock struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty"`
+	Name  string             `bson:"name" binding:"required"`
+	Price int                `bson:"price" binding:"required"`
+	Tags  []string           `bson:"tags"`
+}
+
+func productMockSchema() store.MongoSchema {
+	return store.MongoSchema{
+		Indexes: []store.MongoIndex{
+			{Name: "name_unique", Keys: bson.D{{Key: "name", Value: 1}}, Unique: true},
+			{Name: "name_text", Keys: bson.D{{Key: "name", Value: "text"}}},
+		},
+		Validator: store.JSONSchema(ProductMock{}),
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema := store.JSONSchema(ProductMock{})["$jsonSchema"].(bson.M)
+
+	assert.Equal(t, bson.A{"name", "price"}, schema["required"])
+	assert.Equal(t, bson.M{
+		"_id":   bson.M{"bsonType": "objectId"},
+		"name":  bson.M{"bsonType": "string"},
+		"price": bson.M{"bsonType": bson.A{"int", "long"}},
+		"tags":  bson.M{"bsonType": bson.A{"array", "null"}},
+	}, schema["properties"])
+}
+
+type amount struct{}
+
+func (amount) JSONSchema() bson.M { return bson.M{"bsonType": "decimal"} }
+
+func TestJSONSchemaDescriber(t *testing.T) {
+	schema := store.JSONSchema(struct {
+		Price amount `bson:"price"`
+	}{})["$jsonSchema"].(bson.M)
+
+	assert.Equal(t, bson.M{"price": bson.M{"bsonType": "decimal"}}, schema["properties"])
+}
+
+func TestDiffMongoSchema(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	collection := func(mt *mtest.T, validator bson.M) bson.D {
+		options := bson.D{}
+		if validator != nil {
+			options = bson.D{{Key: "validator", Value: validator}}
+		}
+		return bson.D{
+			{Key: "name", Value: mt.Coll.Name()},
+			{Key: "type", Value: "collection"},
+			{Key: "options", Value: options},
+		}
+	}
+	ns := func(mt *mtest.T) string { return mt.DB.Name() + "." + mt.Coll.Name() }
+
+	mt.Run("reports missing, changed and extra indexes", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns(mt), mtest.FirstBatch, collection(mt, nil)),
+			mtest.CreateCursorResponse(0, ns(mt), mtest.FirstBatch,
+				bson.D{{Key: "name", Value: "_id_"}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}},
+				bson.D{{Key: "name", Value: "name_unique"}, {Key: "key", Value: bson.D{{Key: "name", Value: 1}}}},
+				bson.D{{Key: "name", Value: "legacy"}, {Key: "key", Value: bson.D{{Key: "price", Value: -1}}}},
+			),
+		)
+
+		diff, err := store.DiffMongoSchema(mt.Coll, productMockSchema())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"name_text"}, diff.MissingIndexes)
+		assert.Equal(t, []string{"name_unique"}, diff.ChangedIndexes)
+		assert.Equal(t, []string{"legacy"}, diff.ExtraIndexes)
+		assert.True(t, diff.ValidatorChanged)
+	})
+
+	mt.Run("up to date collection has an empty diff", func(mt *mtest.T) {
+		schema := productMockSchema()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns(mt), mtest.FirstBatch, collection(mt, schema.Validator)),
+			mtest.CreateCursorResponse(0, ns(mt), mtest.FirstBatch,
+				bson.D{{Key: "name", Value: "_id_"}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}},
+				bson.D{
+					{Key: "name", Value: "name_unique"},
+					{Key: "key", Value: bson.D{{Key: "name", Value: int32(1)}}},
+					{Key: "unique", Value: true},
+				},
+				bson.D{
+					{Key: "name", Value: "name_text"},
+					{Key: "key", Value: bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: 1}}},
+					{Key: "weights", Value: bson.D{{Key: "name", Value: 1}}},
+				},
+			),
+		)
+
+		diff, err := store.ApplyMongoSchema(mt.Coll, schema)
+		assert.NoError(t, err)
+		assert.True(t, diff.Empty(), diff.String())
+	})
+}
@@ -64,6 +64,20 @@ func TestMongoStoreFind(t *testing.T) {
 		assert.Len(t, results, 1)
 		assert.Equal(t, "Bob", results[0].Name)
 	})
+
+	mt.Run("find without conditions", func(mt *mtest.T) {
+		s := store.NewMongoStore(mt.Coll)
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "name", Value: "Bob"}},
+		))
+
+		var results []UserMock
+		assert.NotPanics(t, func() {
+			assert.NoError(t, s.Find(&results))
+		})
+		assert.Len(t, results, 1)
+	})
 }
 
 func TestMongoStoreFirst(t *testing.T) {
@@ -107,4 +121,71 @@ func TestMongoStoreSave(t *testing.T) {
 		err := s.Save(user)
 		assert.NoError(t, err)
 	})
+
+	mt.Run("save without ID inserts", func(mt *mtest.T) {
+		s := store.NewMongoStore(mt.Coll)
+		user := &UserMock{Name: "New"}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		err := s.Save(user)
+		assert.NoError(t, err)
+		assert.NotEqual(t, primitive.NilObjectID, user.ID)
+	})
+}
+
+func TestMongoStoreTruncate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("delete every document", func(mt *mtest.T) {
+		s := store.NewMongoStore(mt.Coll)
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 3}))
+
+		err := store.Truncate(s, &UserMock{})
+		assert.NoError(t, err)
+	})
+}
+
+func TestMongoStoreBulkWrite(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("reports errors per op", func(mt *mtest.T) {
+		s := store.NewMongoStore(mt.Coll)
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 11000, Message: "duplicate key"}),
+		)
+
+		alice := &UserMock{Name: "Alice"}
+		errs, err := store.BulkWrite(s, []store.BulkOp{
+			{Kind: store.BulkCreate, Value: alice},
+			{Kind: store.BulkCreate, Value: &UserMock{Name: "Alice"}},
+			{Kind: store.BulkUpdate, Value: &UserMock{ID: primitive.NewObjectID(), Name: "Bob"}},
+		}, store.BulkOptions{})
+
+		assert.NoError(t, err)
+		assert.NoError(t, errs[0])
+		assert.True(t, store.IsDuplicateKey(errs[1]), errs[1])
+		assert.True(t, store.IsNotFound(errs[2]), errs[2])
+		assert.NotEqual(t, primitive.NilObjectID, alice.ID)
+	})
+
+	mt.Run("atomic write stops before writing when an ID is missing", func(mt *mtest.T) {
+		s := store.NewMongoStore(mt.Coll)
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch))
+
+		errs, err := store.BulkWrite(s, []store.BulkOp{
+			{Kind: store.BulkCreate, Value: &UserMock{Name: "Alice"}},
+			{Kind: store.BulkDelete, Value: &UserMock{ID: primitive.NewObjectID()}},
+		}, store.BulkOptions{Atomic: true})
+
+		assert.ErrorIs(t, err, store.ErrBulkAborted)
+		assert.NoError(t, errs[0])
+		assert.True(t, store.IsNotFound(errs[1]))
+	})
 }
@@ -0,0 +1,309 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"expvar"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrCircuitOpen is returned without calling the database while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+var metrics = expvar.NewMap("store")
+
+// mongoRetryableCodes are the server codes returned during primary elections
+// and shutdowns.
+var mongoRetryableCodes = []int{6, 7, 89, 91, 189, 262, 9001, 10107, 11600, 11602, 13435, 13436}
+
+// IsRetryable reports whether err is a transient driver error, such as a lost
+// connection, a timeout or a failover, that may succeed when tried again.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		if serverErr.HasErrorLabel("RetryableWriteError") || serverErr.HasErrorLabel("TransientTransactionError") {
+			return true
+		}
+		for _, code := range mongoRetryableCodes {
+			if serverErr.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"): // connection exception
+			return true
+		case pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03": // shutdown, cannot connect now
+			return true
+		case pgErr.Code == "40001", pgErr.Code == "40P01": // serialization failure, deadlock
+			return true
+		}
+		return false
+	}
+	return pgconn.SafeToRetry(err) || pgconn.Timeout(err)
+}
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// Breaker opens after FailureThreshold consecutive retryable failures and
+// rejects calls until OpenTimeout has passed. It then lets a single call
+// through: success closes it again, failure reopens it.
+type Breaker struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	Now              func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewBreaker(threshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		FailureThreshold: threshold,
+		OpenTimeout:      openTimeout,
+		Now:              time.Now,
+		state:            BreakerClosed,
+	}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.Now().Sub(b.openedAt) >= b.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Allow returns ErrCircuitOpen when the call must not reach the database.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.Now().Sub(b.openedAt) >= b.OpenTimeout {
+		b.state = BreakerHalfOpen
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed call. Only retryable errors count
+// as failures; a missing record says nothing about the database's health.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !IsRetryable(err) {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = b.Now()
+	}
+}
+
+type ResilienceOptions struct {
+	// Name identifies the store in the metrics published under "store".
+	Name string
+	// MaxAttempts is the number of tries for reads, including the first.
+	MaxAttempts int
+	// BaseDelay doubles after every attempt, capped at MaxDelay, and a random
+	// fraction of it is waited before retrying.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// FailureThreshold and OpenTimeout configure the circuit breaker.
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+func DefaultResilienceOptions(name string) ResilienceOptions {
+	return ResilienceOptions{
+		Name:             name,
+		MaxAttempts:      3,
+		BaseDelay:        50 * time.Millisecond,
+		MaxDelay:         time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+// ResilientStore retries reads that fail with retryable errors and guards
+// every call with a circuit breaker. Writes are not retried because they are
+// not idempotent.
+type ResilientStore struct {
+	next    Storer
+	opts    ResilienceOptions
+	breaker *Breaker
+
+	// Rand and Sleep default to math/rand and time.Sleep; tests replace them.
+	Rand  func() float64
+	Sleep func(time.Duration)
+}
+
+func NewResilientStore(next Storer, opts ResilienceOptions) *ResilientStore {
+	s := &ResilientStore{
+		next:    next,
+		opts:    opts,
+		breaker: NewBreaker(opts.FailureThreshold, opts.OpenTimeout),
+		Rand:    rand.Float64,
+		Sleep:   time.Sleep,
+	}
+
+	if opts.Name != "" {
+		metrics.Set(opts.Name+".breaker_state", expvar.Func(func() any { return s.breaker.State() }))
+	}
+	return s
+}
+
+func (s *ResilientStore) Breaker() *Breaker {
+	return s.breaker
+}
+
+func (s *ResilientStore) count(metric string) {
+	if s.opts.Name != "" {
+		metrics.Add(s.opts.Name+"."+metric, 1)
+	}
+}
+
+func (s *ResilientStore) call(call func() error) error {
+	if err := s.breaker.Allow(); err != nil {
+		s.count("rejected")
+		return err
+	}
+
+	err := call()
+	s.breaker.Record(err)
+	if IsRetryable(err) {
+		s.count("failures")
+	}
+	return err
+}
+
+func (s *ResilientStore) retry(call func() error) error {
+	var err error
+	delay := s.opts.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err = s.call(call)
+		if attempt >= s.opts.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		s.count("retries")
+		s.Sleep(time.Duration(s.Rand() * float64(delay)))
+		if delay *= 2; delay > s.opts.MaxDelay {
+			delay = s.opts.MaxDelay
+		}
+	}
+}
+
+func (s *ResilientStore) Find(dest any, conds ...any) error {
+	return s.retry(func() error { return s.next.Find(dest, conds...) })
+}
+
+func (s *ResilientStore) First(dest any, conds ...any) error {
+	return s.retry(func() error { return s.next.First(dest, conds...) })
+}
+
+func (s *ResilientStore) Create(value any) error {
+	return s.call(func() error { return s.next.Create(value) })
+}
+
+func (s *ResilientStore) Save(value any) error {
+	return s.call(func() error { return s.next.Save(value) })
+}
+
+// Update is not retried: a conditional update that reached the database
+// before failing would not match again.
+func (s *ResilientStore) Update(model any, set map[string]any, conds ...any) error {
+	return s.call(func() error { return Update(s.next, model, set, conds...) })
+}
+
+func (s *ResilientStore) Delete(value any, conds ...any) error {
+	return s.call(func() error { return Delete(s.next, value, conds...) })
+}
+
+func (s *ResilientStore) BulkWrite(ops []BulkOp, opts BulkOptions) ([]error, error) {
+	var errs []error
+	err := s.call(func() error {
+		var err error
+		errs, err = BulkWrite(s.next, ops, opts)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrBulkAborted) {
+		return nil, err
+	}
+	return errs, err
+}
+
+// Each is not retried: fn may already have seen some of the records.
+func (s *ResilientStore) Each(dest any, fn func() error, conds ...any) error {
+	return s.call(func() error { return Each(s.next, dest, fn, conds...) })
+}
+
+func (s *ResilientStore) Facets(model any, fields []string, conds ...any) (map[string][]FacetCount, error) {
+	var facets map[string][]FacetCount
+	err := s.retry(func() error {
+		var err error
+		facets, err = Facets(s.next, model, fields, conds...)
+		return err
+	})
+	return facets, err
+}
+
+func (s *ResilientStore) Truncate(model any) error {
+	return s.call(func() error { return Truncate(s.next, model) })
+}
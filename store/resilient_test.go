@@ -0,0 +1,146 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"not found", store.ErrNotFound, false},
+		{"mongo no documents", mongo.ErrNoDocuments, false},
+		{"deadline", fmt.Errorf("find: %w", context.DeadlineExceeded), true},
+		{"canceled", context.Canceled, false},
+		{"mongo not primary", mongo.CommandError{Code: 10107, Message: "not primary"}, true},
+		{"mongo retryable label", mongo.CommandError{Code: 1, Labels: []string{"RetryableWriteError"}}, true},
+		{"mongo duplicate key", mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}, false},
+		{"postgres connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"postgres admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"postgres unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"circuit open", store.ErrCircuitOpen, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, store.IsRetryable(tc.err))
+		})
+	}
+}
+
+func newResilientStore(next store.Storer) (*store.ResilientStore, *[]time.Duration) {
+	opts := store.DefaultResilienceOptions("")
+	opts.FailureThreshold = 3
+	opts.OpenTimeout = time.Minute
+
+	var waits []time.Duration
+	s := store.NewResilientStore(next, opts)
+	s.Rand = func() float64 { return 1 }
+	s.Sleep = func(d time.Duration) { waits = append(waits, d) }
+	return s, &waits
+}
+
+func TestResilientStoreRetriesReads(t *testing.T) {
+	mock := &store.MockStore{}
+	mock.On("First").Return(context.DeadlineExceeded).Times(2)
+	mock.On("First").ReturnData(User{Name: "Alice"})
+	s, waits := newResilientStore(mock)
+
+	var u User
+	assert.NoError(t, s.First(&u))
+	assert.Equal(t, "Alice", u.Name)
+	mock.AssertNumberOfCalls(t, "First", 3)
+	assert.Equal(t, []time.Duration{50 * time.Millisecond, 100 * time.Millisecond}, *waits)
+}
+
+func TestResilientStoreGivesUpAfterMaxAttempts(t *testing.T) {
+	mock := &store.MockStore{Err: context.DeadlineExceeded}
+	s, _ := newResilientStore(mock)
+
+	assert.ErrorIs(t, s.Find(&[]User{}), context.DeadlineExceeded)
+	mock.AssertNumberOfCalls(t, "Find", 3)
+}
+
+func TestResilientStoreDoesNotRetry(t *testing.T) {
+	t.Run("writes", func(t *testing.T) {
+		mock := &store.MockStore{Err: context.DeadlineExceeded}
+		s, _ := newResilientStore(mock)
+
+		assert.Error(t, s.Create(&User{}))
+		assert.Error(t, s.Save(&User{}))
+		mock.AssertNumberOfCalls(t, "Create", 1)
+		mock.AssertNumberOfCalls(t, "Save", 1)
+	})
+
+	t.Run("permanent errors", func(t *testing.T) {
+		mock := &store.MockStore{Err: store.ErrNotFound}
+		s, _ := newResilientStore(mock)
+
+		assert.True(t, store.IsNotFound(s.First(&User{})))
+		mock.AssertNumberOfCalls(t, "First", 1)
+	})
+}
+
+func TestResilientStoreCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	mock := &store.MockStore{Err: errors.New("unused")}
+	mock.On("Create").Return(context.DeadlineExceeded).Times(3)
+	s, _ := newResilientStore(mock)
+	s.Breaker().Now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, s.Create(&User{}), context.DeadlineExceeded)
+	}
+	assert.Equal(t, store.BreakerOpen, s.Breaker().State())
+
+	assert.ErrorIs(t, s.First(&User{}), store.ErrCircuitOpen)
+	mock.AssertNotCalled(t, "First")
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, store.BreakerHalfOpen, s.Breaker().State())
+
+	mock.On("Create")
+	assert.NoError(t, s.Create(&User{}))
+	assert.Equal(t, store.BreakerClosed, s.Breaker().State())
+}
+
+func TestBreakerHalfOpenFailureReopens(t *testing.T) {
+	now := time.Now()
+	b := store.NewBreaker(1, time.Second)
+	b.Now = func() time.Time { return now }
+
+	assert.NoError(t, b.Allow())
+	b.Record(context.DeadlineExceeded)
+	assert.ErrorIs(t, b.Allow(), store.ErrCircuitOpen)
+
+	now = now.Add(time.Second)
+	assert.NoError(t, b.Allow(), "one probe is let through")
+	assert.ErrorIs(t, b.Allow(), store.ErrCircuitOpen, "only one probe at a time")
+
+	b.Record(context.DeadlineExceeded)
+	assert.Equal(t, store.BreakerOpen, b.State())
+}
+
+func TestResilientStoreWithInjectedFaults(t *testing.T) {
+	faults := store.NewFaultStore(store.NewMemoryStore())
+	faults.Set("Find", store.Fault{ErrorRate: 1, Err: "timeout"})
+	s, _ := newResilientStore(faults)
+
+	assert.ErrorIs(t, s.Find(&[]TodoMock{}), context.DeadlineExceeded)
+	assert.Equal(t, store.BreakerOpen, s.Breaker().State())
+
+	faults.Clear("")
+	assert.ErrorIs(t, s.Find(&[]TodoMock{}), store.ErrCircuitOpen)
+}
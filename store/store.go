@@ -1,9 +1,21 @@
 package store
 
 import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.mongodb.org/mongo-driver/mongo"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrNotFound     = errors.New("record not found")
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	errMissingConditions = errors.New("delete needs conditions or a value with an ID")
+)
+
 type Storer interface {
 	Find(dest any, conds ...any) error
 	Create(value any) error
@@ -11,6 +23,68 @@ type Storer interface {
 	Save(value any) error
 }
 
+// IsNotFound reports whether err means that no record matched, whichever
+// store returned it.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) ||
+		errors.Is(err, gorm.ErrRecordNotFound) ||
+		errors.Is(err, mongo.ErrNoDocuments)
+}
+
+// IsDuplicateKey reports whether err means that a unique index rejected a
+// write, whichever store returned it.
+func IsDuplicateKey(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Is(err, ErrDuplicateKey) ||
+		errors.Is(err, gorm.ErrDuplicatedKey) ||
+		mongo.IsDuplicateKeyError(err) ||
+		(errors.As(err, &pgErr) && pgErr.Code == "23505")
+}
+
+// Truncater is implemented by stores that can delete every record of a model.
+type Truncater interface {
+	Truncate(model any) error
+}
+
+// Truncate deletes every record of model from s.
+func Truncate(s Storer, model any) error {
+	t, ok := s.(Truncater)
+	if !ok {
+		return fmt.Errorf("store %T does not support truncate", s)
+	}
+	return t.Truncate(model)
+}
+
+// Deleter is implemented by stores that can delete records. Like gorm's
+// Delete, the records are selected by conds, or by the ID of value when no
+// conditions are given.
+type Deleter interface {
+	Delete(value any, conds ...any) error
+}
+
+// Delete deletes the records of value's type selected by conds from s.
+func Delete(s Storer, value any, conds ...any) error {
+	d, ok := s.(Deleter)
+	if !ok {
+		return fmt.Errorf("store %T does not support delete", s)
+	}
+	return d.Delete(value, conds...)
+}
+
+// Attributor is implemented by stores that record who makes each write.
+type Attributor interface {
+	As(actor string) Storer
+}
+
+// As returns s with its writes attributed to actor, or s itself when it does
+// not record who writes.
+func As(s Storer, actor string) Storer {
+	if a, ok := s.(Attributor); ok {
+		return a.As(actor)
+	}
+	return s
+}
+
 type gormStore struct {
 	db *gorm.DB
 }
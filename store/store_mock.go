@@ -1,49 +1,234 @@
 package store
 
-import "reflect"
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
 
+// MockStore is a Storer for tests. Err and Data answer every call unless a
+// method has been scripted with On; a Find into a dest of another type than
+// Data finds nothing. Every call is recorded, and the mock is safe for
+// concurrent use.
 type MockStore struct {
 	Err  error
 	Data any
+
+	mu    sync.Mutex
+	stubs map[string][]*Stub
+	calls []Call
+}
+
+// Call is a recorded call. Value is the dest or value argument.
+type Call struct {
+	Method string
+	Value  any
+	Conds  []any
+}
+
+// Stub scripts the response of one method. Stubs of the same method answer
+// in the order they were added; a stub limited with Once or Times is
+// discarded when used up.
+type Stub struct {
+	mock  *MockStore
+	err   error
+	data  any
+	times int
+}
+
+// TestingT is the subset of *testing.T used by the assertions.
+type TestingT interface {
+	Errorf(format string, args ...any)
+}
+
+// On adds a stub for method ("Find", "First", "Create", "Save", "Delete" or
+// "Truncate").
+func (m *MockStore) On(method string) *Stub {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stubs == nil {
+		m.stubs = map[string][]*Stub{}
+	}
+	s := &Stub{mock: m}
+	m.stubs[method] = append(m.stubs[method], s)
+	return s
+}
+
+// Return makes the stub fail with err.
+func (s *Stub) Return(err error) *Stub {
+	s.mock.mu.Lock()
+	defer s.mock.mu.Unlock()
+
+	s.err = err
+	return s
+}
+
+// ReturnData makes the stub copy data into the call's dest or value, the same
+// way MockStore.Data is used.
+func (s *Stub) ReturnData(data any) *Stub {
+	s.mock.mu.Lock()
+	defer s.mock.mu.Unlock()
+
+	s.data = data
+	return s
+}
+
+func (s *Stub) Once() *Stub {
+	return s.Times(1)
+}
+
+func (s *Stub) Times(n int) *Stub {
+	s.mock.mu.Lock()
+	defer s.mock.mu.Unlock()
+
+	s.times = n
+	return s
+}
+
+// record stores the call and returns the response to answer it with.
+func (m *MockStore) record(method string, value any, conds []any) response {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, Call{Method: method, Value: value, Conds: conds})
+
+	stubs := m.stubs[method]
+	if len(stubs) == 0 {
+		return response{err: m.Err, data: m.Data}
+	}
+
+	s := stubs[0]
+	if s.times > 0 {
+		s.times--
+		if s.times == 0 {
+			m.stubs[method] = stubs[1:]
+		}
+	}
+	return response{err: s.err, data: s.data, scripted: true}
+}
+
+type response struct {
+	err      error
+	data     any
+	scripted bool
+}
+
+// Calls returns the recorded calls of method, or every call when method is
+// empty.
+func (m *MockStore) Calls(method string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var calls []Call
+	for _, c := range m.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// AssertCalled checks that method was called with exactly conds.
+func (m *MockStore) AssertCalled(t TestingT, method string, conds ...any) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	calls := m.Calls(method)
+	for _, c := range calls {
+		if reflect.DeepEqual(normalizeConds(conds), normalizeConds(c.Conds)) {
+			return true
+		}
+	}
+
+	var seen []string
+	for _, c := range calls {
+		seen = append(seen, fmt.Sprintf("%v", c.Conds))
+	}
+	t.Errorf("expected %s to be called with %v, got calls: [%s]", method, conds, strings.Join(seen, ", "))
+	return false
+}
+
+func (m *MockStore) AssertNotCalled(t TestingT, method string) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	if n := len(m.Calls(method)); n > 0 {
+		t.Errorf("expected %s not to be called, got %d call(s)", method, n)
+		return false
+	}
+	return true
+}
+
+func (m *MockStore) AssertNumberOfCalls(t TestingT, method string, expected int) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	if n := len(m.Calls(method)); n != expected {
+		t.Errorf("expected %s to be called %d time(s), got %d", method, expected, n)
+		return false
+	}
+	return true
+}
+
+func normalizeConds(conds []any) []any {
+	if len(conds) == 0 {
+		return nil
+	}
+	return conds
 }
 
 func (m *MockStore) Find(dest any, conds ...any) error {
-	if m.Err != nil {
-		return m.Err
+	r := m.record("Find", dest, conds)
+	if r.err != nil {
+		return r.err
 	}
 
-	if m.Data != nil {
+	if r.data != nil {
 		// Ensure dest is a pointer
 		destVal := reflect.ValueOf(dest)
 		if destVal.Kind() != reflect.Ptr {
 			return nil
 		}
 
-		// Set the value pointed to by dest
-		reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(m.Data))
+		// Data only answers Finds of its own type; other Finds see no rows.
+		if dataVal := reflect.ValueOf(r.data); dataVal.Type().AssignableTo(destVal.Elem().Type()) {
+			destVal.Elem().Set(dataVal)
+		}
 	}
 	return nil
 }
 
 func (m *MockStore) Create(value any) error {
-	if m.Err != nil {
-		return m.Err
+	r := m.record("Create", value, nil)
+	if r.err != nil {
+		return r.err
+	}
+
+	// Only scripted data is copied, so a stub can hand back an ID.
+	if r.scripted && r.data != nil && reflect.ValueOf(value).Kind() == reflect.Ptr {
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(r.data))
 	}
 
 	return nil
 }
 
 func (m *MockStore) First(dest any, conds ...any) error {
-	if m.Err != nil {
-		return m.Err
+	r := m.record("First", dest, conds)
+	if r.err != nil {
+		return r.err
 	}
-	if m.Data != nil {
+	if r.data != nil {
 		destVal := reflect.ValueOf(dest)
 		if destVal.Kind() != reflect.Ptr {
 			return nil
 		}
-		dataVal := reflect.ValueOf(m.Data)
-		// If m.Data is a slice, set dest to its first element
+		dataVal := reflect.ValueOf(r.data)
+		// If the data is a slice, set dest to its first element
 		if dataVal.Kind() == reflect.Slice && dataVal.Len() > 0 {
 			reflect.ValueOf(dest).Elem().Set(dataVal.Index(0))
 		} else {
@@ -54,17 +239,26 @@ func (m *MockStore) First(dest any, conds ...any) error {
 }
 
 func (m *MockStore) Save(value any) error {
-	if m.Err != nil {
-		return m.Err
+	r := m.record("Save", value, nil)
+	if r.err != nil {
+		return r.err
 	}
-	if m.Data != nil {
+	if r.data != nil {
 		// Ensure value is a pointer
 		valueVal := reflect.ValueOf(value)
 		if valueVal.Kind() != reflect.Ptr {
 			return nil
 		}
 		// Set the value pointed to by value
-		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(m.Data))
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(r.data))
 	}
 	return nil
 }
+
+func (m *MockStore) Delete(value any, conds ...any) error {
+	return m.record("Delete", value, conds).err
+}
+
+func (m *MockStore) Truncate(model any) error {
+	return m.record("Truncate", model, nil).err
+}
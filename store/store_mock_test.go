@@ -2,10 +2,14 @@ package store_test
 
 import (
 	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/sing3demons/go-example/store"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func TestMockStoreFind(t *testing.T) {
@@ -76,3 +80,127 @@ func TestMockStoreSave(t *testing.T) {
 		assert.EqualError(t, err, "save error")
 	})
 }
+
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockStoreStubs(t *testing.T) {
+	t.Run("per-method stubs", func(t *testing.T) {
+		mock := &store.MockStore{}
+		mock.On("First").Return(errors.New("not found"))
+
+		assert.NoError(t, mock.Create(&User{Name: "Eve"}))
+		assert.EqualError(t, mock.First(&User{}), "not found")
+	})
+
+	t.Run("ordered responses", func(t *testing.T) {
+		mock := &store.MockStore{Err: errors.New("fallback")}
+		mock.On("First").ReturnData(User{Name: "first"}).Once()
+		mock.On("First").Return(errors.New("second")).Times(2)
+
+		var u User
+		assert.NoError(t, mock.First(&u))
+		assert.Equal(t, "first", u.Name)
+		assert.EqualError(t, mock.First(&u), "second")
+		assert.EqualError(t, mock.First(&u), "second")
+		assert.EqualError(t, mock.First(&u), "fallback")
+	})
+
+	t.Run("create can hand back an ID", func(t *testing.T) {
+		mock := &store.MockStore{}
+		mock.On("Create").ReturnData(User{ID: 7, Name: "Eve"})
+
+		u := User{Name: "Eve"}
+		assert.NoError(t, mock.Create(&u))
+		assert.Equal(t, 7, u.ID)
+	})
+}
+
+func TestMockStoreCalls(t *testing.T) {
+	mock := &store.MockStore{}
+	_ = mock.First(&User{}, bson.M{"name": "Alice"})
+	_ = mock.Find(&[]User{})
+
+	assert.Len(t, mock.Calls(""), 2)
+	assert.Len(t, mock.Calls("First"), 1)
+	assert.True(t, mock.AssertCalled(t, "First", bson.M{"name": "Alice"}))
+	assert.True(t, mock.AssertCalled(t, "Find"))
+	assert.True(t, mock.AssertNumberOfCalls(t, "Find", 1))
+	assert.True(t, mock.AssertNotCalled(t, "Save"))
+
+	rt := &recordingT{}
+	assert.False(t, mock.AssertCalled(rt, "First", bson.M{"name": "Bob"}))
+	assert.False(t, mock.AssertNotCalled(rt, "Find"))
+	assert.Len(t, rt.errors, 2)
+}
+
+func TestMockStoreConcurrentUse(t *testing.T) {
+	mock := &store.MockStore{}
+	mock.On("Create").Return(errors.New("once")).Once()
+
+	var wg sync.WaitGroup
+	var failed int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if mock.Create(&User{}) != nil {
+				atomic.AddInt32(&failed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), failed)
+	mock.AssertNumberOfCalls(t, "Create", 20)
+}
+
+func TestBulkWriteFallback(t *testing.T) {
+	mock := &store.MockStore{}
+	mock.On("Save").Return(store.ErrNotFound).Once()
+
+	errs, err := store.BulkWrite(mock, []store.BulkOp{
+		{Kind: store.BulkCreate, Value: &User{Name: "Alice"}},
+		{Kind: store.BulkUpdate, Value: &User{ID: 1}},
+		{Kind: store.BulkDelete, Value: &User{ID: 2}},
+	}, store.BulkOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, store.ErrNotFound, nil}, errs)
+	mock.AssertCalled(t, "Delete")
+
+	_, err = store.BulkWrite(mock, nil, store.BulkOptions{Atomic: true})
+	assert.Error(t, err, "one call per op cannot be atomic")
+}
+
+func TestEachFallback(t *testing.T) {
+	mock := &store.MockStore{Data: []User{{Name: "Alice"}, {Name: "Bob"}}}
+
+	var user User
+	var names []string
+	err := store.Each(mock, &user, func() error {
+		names = append(names, user.Name)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Alice", "Bob"}, names)
+	mock.AssertNumberOfCalls(t, "Find", 1)
+}
+
+func TestMockStoreFindOtherType(t *testing.T) {
+	mock := &store.MockStore{Data: []User{{Name: "Alice"}}}
+
+	var users []User
+	assert.NoError(t, mock.Find(&users))
+	assert.Len(t, users, 1)
+
+	var names []string
+	assert.NoError(t, mock.Find(&names))
+	assert.Empty(t, names)
+}
@@ -94,3 +94,16 @@ func TestGormStoreSave(t *testing.T) {
 	err := s.Save(&user)
 	assert.NoError(t, err)
 }
+
+func TestGormStoreTruncate(t *testing.T) {
+	gdb, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+
+	mock.ExpectExec(`TRUNCATE TABLE "users" RESTART IDENTITY CASCADE`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	s := store.NewGormStore(gdb)
+	err := store.Truncate(s, &User{})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
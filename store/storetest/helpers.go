@@ -0,0 +1,20 @@
+package storetest
+
+import "reflect"
+
+func records(slice any) []any {
+	v := reflect.ValueOf(slice)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	out := make([]any, v.Len())
+	for i := range out {
+		out[i] = v.Index(i).Addr().Interface()
+	}
+	return out
+}
+
+func isZero(v any) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
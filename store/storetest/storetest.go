@@ -0,0 +1,439 @@
+// Package storetest provides behavioural tests that every store.Storer
+// implementation is expected to pass.
+package storetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sing3demons/go-example/models"
+	"github.com/sing3demons/go-example/money"
+	"github.com/sing3demons/go-example/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Harness tells the suite how to build a store and how to work with the
+// record type it is tested with.
+type Harness struct {
+	// New returns an empty store.
+	New func(t *testing.T) store.Storer
+	// NewRecord returns a pointer to an unsaved record with the given name.
+	NewRecord func(name string) any
+	// NewSlice returns a pointer to an empty slice of records.
+	NewSlice func() any
+	Name     func(record any) string
+	SetName  func(record any, name string)
+	// SetField returns the fields store.Update sets to change the name.
+	SetField func(name string) map[string]any
+	// ID returns the record's ID; the zero value means it was not assigned.
+	ID func(record any) any
+	// ByID and ByName return the conditions selecting records by ID or name.
+	ByID   func(id any) []any
+	ByName func(name string) []any
+	// MissingID returns an ID that no record has.
+	MissingID func() any
+	// SoftDeletes is true when deleting a record leaves it stored, as gorm
+	// does with models that have a gorm.DeletedAt field.
+	SoftDeletes bool
+}
+
+type testCase struct {
+	name string
+	run  func(t *testing.T, h Harness, s store.Storer)
+}
+
+var cases = []testCase{
+	{"create assigns ID", testCreateAssignsID},
+	{"first by ID", testFirstByID},
+	{"first not found", testFirstNotFound},
+	{"save updates", testSaveUpdates},
+	{"save without ID creates", testSaveCreates},
+	{"find without conditions", testFindAll},
+	{"find with filter", testFindWithFilter},
+	{"delete by ID", testDeleteByID},
+	{"delete by conditions", testDeleteByConditions},
+	{"soft delete", testSoftDelete},
+	{"bulk write", testBulkWrite},
+	{"atomic bulk write", testBulkWriteAtomic},
+	{"bulk update keeps fields", testBulkWriteKeep},
+	{"each", testEach},
+	{"update", testUpdate},
+	{"truncate", testTruncate},
+}
+
+// Run runs every behavioural test against a fresh store from h.New.
+func Run(t *testing.T, h Harness) {
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, h, h.New(t))
+		})
+	}
+}
+
+func create(t *testing.T, h Harness, s store.Storer, name string) any {
+	record := h.NewRecord(name)
+	require.NoError(t, s.Create(record))
+	return record
+}
+
+func names(h Harness, slice any) []string {
+	var out []string
+	for _, record := range records(slice) {
+		out = append(out, h.Name(record))
+	}
+	return out
+}
+
+func testCreateAssignsID(t *testing.T, h Harness, s store.Storer) {
+	a := create(t, h, s, "a")
+	b := create(t, h, s, "b")
+
+	assert.False(t, isZero(h.ID(a)), "ID was not assigned")
+	assert.NotEqual(t, h.ID(a), h.ID(b), "IDs must be unique")
+}
+
+func testFirstByID(t *testing.T, h Harness, s store.Storer) {
+	create(t, h, s, "a")
+	b := create(t, h, s, "b")
+
+	found := h.NewRecord("")
+	require.NoError(t, s.First(found, h.ByID(h.ID(b))...))
+	assert.Equal(t, "b", h.Name(found))
+	assert.Equal(t, h.ID(b), h.ID(found))
+}
+
+func testFirstNotFound(t *testing.T, h Harness, s store.Storer) {
+	create(t, h, s, "a")
+
+	found := h.NewRecord("")
+	err := s.First(found, h.ByID(h.MissingID())...)
+	assert.True(t, store.IsNotFound(err), "expected a not found error, got %v", err)
+}
+
+func testSaveUpdates(t *testing.T, h Harness, s store.Storer) {
+	record := create(t, h, s, "before")
+
+	h.SetName(record, "after")
+	require.NoError(t, s.Save(record))
+
+	found := h.NewRecord("")
+	require.NoError(t, s.First(found, h.ByID(h.ID(record))...))
+	assert.Equal(t, "after", h.Name(found))
+
+	all := h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.Equal(t, []string{"after"}, names(h, all))
+}
+
+func testSaveCreates(t *testing.T, h Harness, s store.Storer) {
+	record := h.NewRecord("new")
+	require.NoError(t, s.Save(record))
+	assert.False(t, isZero(h.ID(record)), "ID was not assigned")
+
+	all := h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.Equal(t, []string{"new"}, names(h, all))
+}
+
+func testFindAll(t *testing.T, h Harness, s store.Storer) {
+	empty := h.NewSlice()
+	require.NoError(t, s.Find(empty))
+	assert.Empty(t, records(empty))
+
+	create(t, h, s, "a")
+	create(t, h, s, "b")
+
+	all := h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.ElementsMatch(t, []string{"a", "b"}, names(h, all))
+}
+
+func testFindWithFilter(t *testing.T, h Harness, s store.Storer) {
+	create(t, h, s, "a")
+	create(t, h, s, "b")
+	create(t, h, s, "c")
+
+	found := h.NewSlice()
+	require.NoError(t, s.Find(found, h.ByName("b")...))
+	assert.Equal(t, []string{"b"}, names(h, found))
+
+	none := h.NewSlice()
+	require.NoError(t, s.Find(none, h.ByName("z")...))
+	assert.Empty(t, records(none))
+}
+
+func testDeleteByID(t *testing.T, h Harness, s store.Storer) {
+	if _, ok := s.(store.Deleter); !ok {
+		t.Skipf("%T does not implement store.Deleter", s)
+	}
+
+	a := create(t, h, s, "a")
+	create(t, h, s, "b")
+	require.NoError(t, store.Delete(s, a))
+
+	found := h.NewRecord("")
+	assert.True(t, store.IsNotFound(s.First(found, h.ByID(h.ID(a))...)))
+
+	all := h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.Equal(t, []string{"b"}, names(h, all))
+}
+
+func testDeleteByConditions(t *testing.T, h Harness, s store.Storer) {
+	if _, ok := s.(store.Deleter); !ok {
+		t.Skipf("%T does not implement store.Deleter", s)
+	}
+
+	create(t, h, s, "a")
+	create(t, h, s, "b")
+	create(t, h, s, "a")
+	require.NoError(t, store.Delete(s, h.NewRecord(""), h.ByName("a")...))
+
+	all := h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.Equal(t, []string{"b"}, names(h, all))
+}
+
+// testSoftDelete checks that a soft deleted record keeps its ID, yet no
+// query or write sees it.
+func testSoftDelete(t *testing.T, h Harness, s store.Storer) {
+	if !h.SoftDeletes {
+		t.Skip("records are removed")
+	}
+
+	a := create(t, h, s, "a")
+	create(t, h, s, "b")
+	require.NoError(t, store.Delete(s, a))
+	require.NoError(t, store.Delete(s, h.NewRecord(""), h.ByName("a")...))
+
+	assert.True(t, store.IsNotFound(s.First(h.NewRecord(""), h.ByID(h.ID(a))...)))
+	all := h.NewSlice()
+	require.NoError(t, s.Find(all, h.ByName("a")...))
+	assert.Empty(t, records(all))
+
+	var seen []string
+	record := h.NewRecord("")
+	require.NoError(t, store.Each(s, record, func() error {
+		seen = append(seen, h.Name(record))
+		return nil
+	}))
+	assert.Equal(t, []string{"b"}, seen)
+
+	err := store.Update(s, h.NewRecord(""), h.SetField("a2"), h.ByID(h.ID(a))...)
+	assert.True(t, store.IsNotFound(err), "update of a deleted record: %v", err)
+	errs, err := store.BulkWrite(s, []store.BulkOp{
+		{Kind: store.BulkUpdate, Value: a},
+		{Kind: store.BulkDelete, Value: a},
+	}, store.BulkOptions{})
+	require.NoError(t, err)
+	assert.True(t, store.IsNotFound(errs[0]), "bulk update of a deleted record: %v", errs[0])
+	assert.True(t, store.IsNotFound(errs[1]), "bulk delete of a deleted record: %v", errs[1])
+
+	assert.Error(t, s.Create(a), "the ID is still taken")
+}
+
+// deleted returns a record whose ID no longer exists.
+func deleted(t *testing.T, h Harness, s store.Storer) any {
+	record := create(t, h, s, "deleted")
+	require.NoError(t, store.Delete(s, record))
+	return record
+}
+
+func testBulkWrite(t *testing.T, h Harness, s store.Storer) {
+	if _, ok := s.(store.BulkWriter); !ok {
+		t.Skipf("%T does not implement store.BulkWriter", s)
+	}
+
+	a := create(t, h, s, "a")
+	gone := deleted(t, h, s)
+	h.SetName(a, "a2")
+	b := h.NewRecord("b")
+
+	errs, err := store.BulkWrite(s, []store.BulkOp{
+		{Kind: store.BulkCreate, Value: b},
+		{Kind: store.BulkUpdate, Value: a},
+		{Kind: store.BulkUpdate, Value: gone},
+		{Kind: store.BulkCreate, Value: h.NewRecord("c")},
+	}, store.BulkOptions{})
+	require.NoError(t, err)
+	require.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.True(t, store.IsNotFound(errs[2]), "update of a missing record: %v", errs[2])
+	assert.NoError(t, errs[3])
+	assert.False(t, isZero(h.ID(b)), "ID was not assigned")
+
+	all := h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.ElementsMatch(t, []string{"a2", "b", "c"}, names(h, all))
+
+	errs, err = store.BulkWrite(s, []store.BulkOp{
+		{Kind: store.BulkDelete, Value: b},
+		{Kind: store.BulkDelete, Value: gone},
+	}, store.BulkOptions{})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.True(t, store.IsNotFound(errs[1]), "delete of a missing record: %v", errs[1])
+
+	all = h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.ElementsMatch(t, []string{"a2", "c"}, names(h, all))
+}
+
+func testBulkWriteKeep(t *testing.T, h Harness, s store.Storer) {
+	if _, ok := s.(store.BulkWriter); !ok {
+		t.Skipf("%T does not implement store.BulkWriter", s)
+	}
+
+	// The field SetField sets is the one kept.
+	var field string
+	for name := range h.SetField("") {
+		field = name
+	}
+	a := create(t, h, s, "a")
+	h.SetName(a, "a2")
+
+	errs, err := store.BulkWrite(s, []store.BulkOp{{Kind: store.BulkUpdate, Value: a, Keep: []string{field}}}, store.BulkOptions{})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+
+	stored := h.NewRecord("")
+	require.NoError(t, s.First(stored, h.ByID(h.ID(a))...))
+	assert.Equal(t, "a", h.Name(stored), "the kept field is left as stored")
+}
+
+func testBulkWriteAtomic(t *testing.T, h Harness, s store.Storer) {
+	if _, ok := s.(store.BulkWriter); !ok {
+		t.Skipf("%T does not implement store.BulkWriter", s)
+	}
+
+	create(t, h, s, "a")
+	gone := deleted(t, h, s)
+
+	errs, err := store.BulkWrite(s, []store.BulkOp{
+		{Kind: store.BulkCreate, Value: h.NewRecord("b")},
+		{Kind: store.BulkUpdate, Value: gone},
+	}, store.BulkOptions{Atomic: true})
+	if errs == nil && !errors.Is(err, store.ErrBulkAborted) {
+		t.Skipf("atomic bulk writes are not available: %v", err)
+	}
+	assert.ErrorIs(t, err, store.ErrBulkAborted)
+	assert.NoError(t, errs[0])
+	assert.True(t, store.IsNotFound(errs[1]), "update of a missing record: %v", errs[1])
+
+	all := h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.Equal(t, []string{"a"}, names(h, all), "nothing was written")
+
+	errs, err = store.BulkWrite(s, []store.BulkOp{
+		{Kind: store.BulkCreate, Value: h.NewRecord("b")},
+		{Kind: store.BulkCreate, Value: h.NewRecord("c")},
+	}, store.BulkOptions{Atomic: true})
+	require.NoError(t, err)
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	all = h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, names(h, all))
+}
+
+func testEach(t *testing.T, h Harness, s store.Storer) {
+	create(t, h, s, "a")
+	create(t, h, s, "b")
+	create(t, h, s, "a")
+
+	collect := func(conds ...any) []string {
+		var seen []string
+		record := h.NewRecord("")
+		require.NoError(t, store.Each(s, record, func() error {
+			seen = append(seen, h.Name(record))
+			assert.False(t, isZero(h.ID(record)), "ID was not decoded")
+			return nil
+		}, conds...))
+		return seen
+	}
+
+	assert.ElementsMatch(t, []string{"a", "b", "a"}, collect())
+	assert.Equal(t, []string{"a", "a"}, collect(h.ByName("a")...))
+
+	stop := errors.New("stop")
+	calls := 0
+	err := store.Each(s, h.NewRecord(""), func() error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+func testUpdate(t *testing.T, h Harness, s store.Storer) {
+	if _, ok := s.(store.Updater); !ok {
+		t.Skipf("%T does not implement store.Updater", s)
+	}
+
+	a := create(t, h, s, "a")
+	b := create(t, h, s, "b")
+	require.NoError(t, store.Update(s, h.NewRecord(""), h.SetField("c"), h.ByName("a")...))
+
+	got := h.NewRecord("")
+	require.NoError(t, s.First(got, h.ByID(h.ID(a))...))
+	assert.Equal(t, "c", h.Name(got))
+	got = h.NewRecord("")
+	require.NoError(t, s.First(got, h.ByID(h.ID(b))...))
+	assert.Equal(t, "b", h.Name(got), "records not matching keep their fields")
+
+	err := store.Update(s, h.NewRecord(""), h.SetField("d"), h.ByName("a")...)
+	assert.True(t, store.IsNotFound(err), "nothing matches any more: %v", err)
+}
+
+func testTruncate(t *testing.T, h Harness, s store.Storer) {
+	if _, ok := s.(store.Truncater); !ok {
+		t.Skipf("%T does not implement store.Truncater", s)
+	}
+
+	create(t, h, s, "a")
+	require.NoError(t, store.Truncate(s, h.NewRecord("")))
+
+	all := h.NewSlice()
+	require.NoError(t, s.Find(all))
+	assert.Empty(t, records(all))
+}
+
+// Todos exercises a store with models.Todo and gorm style conditions.
+func Todos(newStore func(t *testing.T) store.Storer) Harness {
+	return Harness{
+		New:         newStore,
+		NewRecord:   func(name string) any { return &models.Todo{Title: name} },
+		NewSlice:    func() any { return &[]models.Todo{} },
+		Name:        func(r any) string { return r.(*models.Todo).Title },
+		SetName:     func(r any, name string) { r.(*models.Todo).Title = name },
+		SetField:    func(name string) map[string]any { return map[string]any{"title": name} },
+		ID:          func(r any) any { return r.(*models.Todo).ID },
+		ByID:        func(id any) []any { return []any{id} },
+		ByName:      func(name string) []any { return []any{"title = ?", name} },
+		MissingID:   func() any { return uint(1 << 30) },
+		SoftDeletes: true,
+	}
+}
+
+// Products exercises a store with models.Product and bson filters.
+func Products(newStore func(t *testing.T) store.Storer) Harness {
+	return Harness{
+		New: newStore,
+		NewRecord: func(name string) any {
+			return &models.Product{Name: name, Price: money.New(100, "USD"), Description: name}
+		},
+		NewSlice:  func() any { return &[]models.Product{} },
+		Name:      func(r any) string { return r.(*models.Product).Name },
+		SetName:   func(r any, name string) { r.(*models.Product).Name = name },
+		SetField:  func(name string) map[string]any { return map[string]any{"name": name} },
+		ID:        func(r any) any { return r.(*models.Product).ID },
+		ByID:      func(id any) []any { return []any{bson.M{"_id": id}} },
+		ByName:    func(name string) []any { return []any{bson.M{"name": name}} },
+		MissingID: func() any { return primitive.NewObjectID() },
+	}
+}
@@ -0,0 +1,37 @@
+package store
+
+import "reflect"
+
+// Streamer is implemented by stores that can hand out matching records one
+// at a time instead of loading them all.
+type Streamer interface {
+	// Each decodes every record matching conds into dest, a pointer to a
+	// struct, and calls fn after each one. An error from fn stops the
+	// iteration and is returned.
+	Each(dest any, fn func() error, conds ...any) error
+}
+
+// Each streams the records of s matching conds through dest; see Streamer.
+// Stores that are not Streamers load every record with Find first.
+func Each(s Storer, dest any, fn func() error, conds ...any) error {
+	if st, ok := s.(Streamer); ok {
+		return st.Each(dest, fn, conds...)
+	}
+
+	val, err := structPointer(dest)
+	if err != nil {
+		return err
+	}
+	all := reflect.New(reflect.SliceOf(val.Type()))
+	if err := s.Find(all.Interface(), conds...); err != nil {
+		return err
+	}
+
+	for i := 0; i < all.Elem().Len(); i++ {
+		val.Set(all.Elem().Index(i))
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package store
+
+import "fmt"
+
+// Updater is implemented by stores that can set some fields of records
+// without writing the others, like mongo's $set.
+type Updater interface {
+	// Update sets the fields in set, named as in conditions, of the records
+	// of model's type matching conds, and their UpdatedAt when they have
+	// one. When no record matches it returns an error for which IsNotFound
+	// is true, so conditions on the fields being set make the update a
+	// compare-and-swap.
+	Update(model any, set map[string]any, conds ...any) error
+}
+
+// Update sets fields of the records of s matching conds; see Updater.
+func Update(s Storer, model any, set map[string]any, conds ...any) error {
+	u, ok := s.(Updater)
+	if !ok {
+		return fmt.Errorf("store %T does not support update", s)
+	}
+	return u.Update(model, set, conds...)
+}